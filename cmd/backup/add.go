@@ -3,20 +3,46 @@ package backup
 import (
 	"log/slog"
 
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
 	"github.com/spf13/cobra"
 )
 
+// strictUsage describes --strict, which overrides backup.strict, on by default.
+const strictUsage = "Exit non-zero when a dir fails to back up: 1 when every dir failed, 2 when others were backed up " +
+	"(default: backup.strict)"
+
+var addOutput string
+
 var addCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Perform a backup",
-	Long:  "",
+	Long: "Back up every configured dir and source once. Unless --strict=false or backup.strict is off, exits 1 when every dir failed to back " +
+		"up and 2 when only some did.",
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := cmd.Context()
+		if err := checkRunOutput(addOutput); err != nil {
+			return err
+		}
 
-		if err := bm.Backup(ctx); err != nil {
+		ctx, done := withProgress(cmd)
+		ctx, results := backup.WithResults(ctx)
+		err := bm.Backup(ctx)
+		done()
+		if err != nil {
 			slog.ErrorContext(ctx, "error backing up", "error", err)
-			return err
 		}
-		return nil
+
+		if addOutput == common.OutputJSON {
+			if pErr := printRunOutput(results, err); pErr != nil {
+				return pErr
+			}
+		}
+		return err
 	},
 }
+
+func init() {
+	addCmd.Flags().BoolVar(&strict, "strict", false, strictUsage)
+	addCmd.Flags().StringVarP(&addOutput, "output", "o", outputText, "Output format (text, json); json prints the result of every run")
+}
@@ -3,32 +3,75 @@ package backup
 import (
 	"github.com/hibare/arclift/cmd/common"
 	"github.com/hibare/arclift/internal/backup"
+	"github.com/hibare/arclift/internal/config"
 	"github.com/spf13/cobra"
 )
 
-var bm backup.BackupManagerIface
+var (
+	bm            backup.BackupManagerIface
+	job           string
+	hostname      string
+	oneFileSystem bool
+	strict        bool
+)
 
 // BackupCmd represents the backup command.
 var BackupCmd = &cobra.Command{
 	Use:   "backup",
 	Short: "Perform backups & related operations",
 	Long:  "",
+	// Failed backups are not usage errors, see --strict.
+	SilenceUsage: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		var err error
+		common.LogToStderrForOutput(cmd)
 		configPath := cmd.Root().PersistentFlags().Lookup("config").Value.String()
-		bm, err = common.NewBackupManager(cmd.Context(), configPath)
+		if oneFileSystem || cmd.Flags().Changed("strict") {
+			cfg, cErr := config.GetConfig(cmd.Context(), configPath)
+			if cErr != nil {
+				return cErr
+			}
+			applyFlags(cmd, cfg)
+		}
+		bm, err = common.NewBackupManager(cmd.Context(), configPath, job, hostname)
 		if err != nil {
 			return err
 		}
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return bm.Backup(cmd.Context())
+		ctx, done := withProgress(cmd)
+		defer done()
+		return bm.Backup(ctx)
 	},
 }
 
+// applyFlags overrides the backup config of cfg with the flags given to cmd. Only the commands taking a backup have
+// --strict; backup.strict is kept unless it is given.
+func applyFlags(cmd *cobra.Command, cfg *config.Config) {
+	cfg.Backup.OneFileSystem = cfg.Backup.OneFileSystem || oneFileSystem
+	if cmd.Flags().Changed("strict") {
+		cfg.Backup.Strict = strict
+	}
+}
+
 func init() {
+	BackupCmd.PersistentFlags().StringVarP(&job, "job", "j", "", "Only operate on the named job (default: every job)")
+	BackupCmd.PersistentFlags().BoolVar(&oneFileSystem, "one-file-system", false, "Do not cross mount points, overriding backup.one-file-system")
+	BackupCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "Do not show the transfer progress on a terminal")
+	BackupCmd.Flags().BoolVar(&strict, "strict", false, strictUsage)
+
+	// --hostname selects the jobs storing backups under a hostname, see config.Config.Jobs.
+	for _, c := range []*cobra.Command{listCmd, purgeCmd} {
+		c.Flags().StringVar(&hostname, "hostname", "", "Only operate on the backups stored under this hostname (default: every hostname)")
+	}
+
 	BackupCmd.AddCommand(addCmd)
 	BackupCmd.AddCommand(purgeCmd)
 	BackupCmd.AddCommand(listCmd)
+	BackupCmd.AddCommand(searchCmd)
+	BackupCmd.AddCommand(historyCmd)
+	BackupCmd.AddCommand(restoreCmd)
+	BackupCmd.AddCommand(checkCmd)
+	BackupCmd.AddCommand(streamCmd)
 }
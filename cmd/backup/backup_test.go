@@ -0,0 +1,39 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyFlags_Strict(t *testing.T) {
+	tests := []struct {
+		name   string
+		config bool
+		args   []string
+		want   bool
+	}{
+		{name: "config on", config: true, want: true},
+		{name: "config off", config: false, want: false},
+		{name: "flag off", config: true, args: []string{"--strict=false"}, want: false},
+		{name: "flag on", config: false, args: []string{"--strict"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prevStrict, prevOneFileSystem := strict, oneFileSystem
+			t.Cleanup(func() { strict, oneFileSystem = prevStrict, prevOneFileSystem })
+
+			cmd := &cobra.Command{}
+			cmd.Flags().BoolVar(&strict, "strict", false, strictUsage)
+			require.NoError(t, cmd.ParseFlags(tt.args))
+
+			cfg := &config.Config{Backup: config.BackupConfig{Strict: tt.config}}
+			applyFlags(cmd, cfg)
+			assert.Equal(t, tt.want, cfg.Backup.Strict)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+// ErrInconsistent is returned when orphaned auxiliary objects are found and not deleted.
+var ErrInconsistent = errors.New("orphaned auxiliary objects found")
+
+var (
+	checkFix    bool
+	checkOutput string
+)
+
+// checkCmd represents the check command.
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Find auxiliary objects whose backup no longer exists",
+	Long: "Find manifests, reports, checksums and other auxiliary objects that belong to deleted backups. " +
+		"Exits non-zero when orphans are found, unless --fix deleted them.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		if err := common.CheckOutput(checkOutput, common.OutputTable, common.OutputJSON); err != nil {
+			return err
+		}
+
+		report, err := bm.CheckConsistency(ctx, checkFix)
+		if err != nil {
+			slog.ErrorContext(ctx, "error checking consistency", "error", err)
+			return err
+		}
+
+		if checkOutput == common.OutputJSON {
+			if pErr := common.PrintJSON(report); pErr != nil {
+				return pErr
+			}
+		} else if len(report.OrphanedAux) == 0 {
+			slog.InfoContext(ctx, "No orphaned auxiliary objects found", "backups", report.Backups)
+		} else {
+			t := table.NewWriter()
+			t.SetOutputMirror(common.Stdout())
+			t.AppendHeader(table.Row{"Orphaned Object"})
+			for _, key := range report.OrphanedAux {
+				t.AppendRow(table.Row{key})
+			}
+			t.AppendFooter(table.Row{fmt.Sprintf("%d orphaned, deleted: %t", len(report.OrphanedAux), report.Deleted)})
+			t.Render()
+		}
+
+		if len(report.OrphanedAux) > 0 && !report.Deleted {
+			return ErrInconsistent
+		}
+		return nil
+	},
+}
+
+func init() {
+	checkCmd.Flags().BoolVar(&checkFix, "fix", false, "Delete the orphaned auxiliary objects")
+	checkCmd.Flags().StringVarP(&checkOutput, "output", "o", common.OutputTable, "Output format (table, json)")
+}
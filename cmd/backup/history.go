@@ -0,0 +1,103 @@
+package backup
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/state"
+	"github.com/hibare/arclift/internal/units"
+	"github.com/hibare/arclift/internal/walk"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+const defaultHistoryLimit = 20
+
+var (
+	historyOutput string
+	historyLimit  int
+)
+
+// historyCmd represents the history command.
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the run history journal",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		cfg, err := config.GetConfig(ctx, cmd.Root().PersistentFlags().Lookup("config").Value.String())
+		if err != nil {
+			return err
+		}
+
+		if cfg.State.Dir == "" {
+			slog.WarnContext(ctx, "State persistence is disabled, no run history is recorded")
+			return nil
+		}
+
+		st, err := state.NewStore(cfg.State.Dir).Load(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "error loading state", "error", err)
+			return err
+		}
+
+		runs := st.Runs
+		if job != "" {
+			runs = slices.DeleteFunc(slices.Clone(runs), func(run state.RunRecord) bool { return run.Job != job })
+		}
+		if historyLimit > 0 && len(runs) > historyLimit {
+			runs = runs[:historyLimit]
+		}
+
+		switch historyOutput {
+		case common.OutputJSON:
+			if runs == nil {
+				runs = []state.RunRecord{}
+			}
+			return common.PrintJSON(runs)
+		case common.OutputTable:
+			if len(runs) == 0 {
+				slog.InfoContext(ctx, "No runs recorded")
+				return nil
+			}
+
+			t := table.NewWriter()
+			t.SetOutputMirror(common.Stdout())
+			t.AppendHeader(table.Row{"#", "Job", "Operation", "Status", "Started", "Duration", "Dirs", "Failed", "Deleted", "Bytes", "Skipped", "Error"})
+			for i, run := range runs {
+				dirs, failed := run.Dirs, run.FailedDirs
+				if run.Operation == state.OperationPurge {
+					dirs, failed = 0, run.Failed
+				}
+				t.AppendRow(table.Row{
+					i + 1,
+					run.Job,
+					run.Operation,
+					run.Status,
+					run.StartedAt.Local().Format(time.DateTime),
+					run.Duration().Round(time.Millisecond),
+					dirs,
+					failed,
+					run.Deleted,
+					units.FormatBytes(run.Bytes),
+					walk.Report(run.Skipped).String(),
+					run.Error,
+				})
+				t.AppendSeparator()
+			}
+			t.Render()
+		default:
+			return common.CheckOutput(historyOutput, common.OutputTable, common.OutputJSON)
+		}
+		return nil
+	},
+}
+
+func init() {
+	historyCmd.Flags().StringVarP(&historyOutput, "output", "o", common.OutputTable, "Output format (table, json)")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", defaultHistoryLimit, "Maximum number of runs to show, 0 for all")
+}
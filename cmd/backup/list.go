@@ -1,10 +1,21 @@
 package backup
 
 import (
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/hibare/GoCommon/v2/pkg/datetime"
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/hibare/arclift/internal/units"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
 )
@@ -14,6 +25,185 @@ const (
 	backupKeyColumnWidthMax = 64
 )
 
+var (
+	listOutput string
+	listFilter filter
+)
+
+// ErrInvalidTime is returned when --since or --until cannot be parsed.
+var ErrInvalidTime = errors.New("invalid time")
+
+// filter narrows down and orders the listed backups.
+type filter struct {
+	since   string
+	until   string
+	limit   int
+	reverse bool
+	dir     string
+	labels  []string
+}
+
+// ErrInvalidLabel is returned when --label is not of the form key=value.
+var ErrInvalidLabel = errors.New("invalid label")
+
+// parseTime parses an absolute time (RFC 3339, "2006-01-02" or "2006-01-02 15:04") in local time,
+// or a duration relative to now such as "36h", "7d" or "2w".
+func parseTime(s string, now time.Time) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, time.DateOnly, "2006-01-02 15:04", time.DateTime} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	days := map[byte]int{'d': 1, 'w': 7}
+	if n := len(s); n > 1 && days[s[n-1]] > 0 {
+		count, err := strconv.Atoi(s[:n-1])
+		if err == nil {
+			return now.AddDate(0, 0, -count*days[s[n-1]]), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %s, use a date, an RFC 3339 time or a duration such as 36h or 7d", ErrInvalidTime, s)
+}
+
+// apply returns the backups matching the filter, newest first unless reversed.
+func (f filter) apply(backups []backup.BackupInfo, now time.Time) ([]backup.BackupInfo, error) {
+	var since, until time.Time
+	var err error
+	if f.since != "" {
+		if since, err = parseTime(f.since, now); err != nil {
+			return nil, err
+		}
+	}
+	if f.until != "" {
+		if until, err = parseTime(f.until, now); err != nil {
+			return nil, err
+		}
+		// A bare date includes the whole day.
+		if _, dErr := time.Parse(time.DateOnly, f.until); dErr == nil {
+			until = until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+
+	labels := map[string]string{}
+	for _, l := range f.labels {
+		key, value, ok := strings.Cut(l, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%w: %s, use key=value", ErrInvalidLabel, l)
+		}
+		labels[strings.ToLower(key)] = value
+	}
+
+	out := make([]backup.BackupInfo, 0, len(backups))
+	for _, b := range backups {
+		if (!since.IsZero() && b.CreatedAt.Before(since)) || (!until.IsZero() && b.CreatedAt.After(until)) {
+			continue
+		}
+		if f.dir != "" && !slices.ContainsFunc(b.Dirs, func(dir string) bool {
+			return filepath.Clean(dir) == filepath.Clean(f.dir) || filepath.Base(filepath.Clean(dir)) == f.dir
+		}) {
+			continue
+		}
+		if !hasLabels(b.Labels, labels) {
+			continue
+		}
+		out = append(out, b)
+	}
+
+	if f.reverse {
+		slices.Reverse(out)
+	}
+	if f.limit > 0 && len(out) > f.limit {
+		out = out[:f.limit]
+	}
+	return out, nil
+}
+
+// hasLabels reports whether got contains every label of want.
+func hasLabels(got, want map[string]string) bool {
+	for key, value := range want {
+		if v, ok := got[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// formatLabels returns the labels as key=value pairs sorted by key and joined by sep.
+func formatLabels(labels map[string]string, sep string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, sep)
+}
+
+// listEntry is a backup as emitted by the json and csv outputs.
+type listEntry struct {
+	backup.BackupInfo
+
+	AgeSeconds int64 `json:"age_seconds,omitempty"`
+}
+
+func newListEntries(backups []backup.BackupInfo) []listEntry {
+	entries := make([]listEntry, 0, len(backups))
+	for _, b := range backups {
+		e := listEntry{BackupInfo: b}
+		if !b.CreatedAt.IsZero() {
+			e.AgeSeconds = int64(time.Since(b.CreatedAt).Seconds())
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func printListCSV(entries []listEntry) error {
+	w := csv.NewWriter(common.Stdout())
+	if err := w.Write([]string{"key", "created_at", "age_seconds", "size", "objects", "dirs", "labels"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		var createdAt, age string
+		if !e.CreatedAt.IsZero() {
+			createdAt = e.CreatedAt.Format(time.RFC3339)
+			age = strconv.FormatInt(e.AgeSeconds, 10)
+		}
+		row := []string{e.Key, createdAt, age, strconv.FormatInt(e.Size, 10), strconv.Itoa(e.Objects), strings.Join(e.Dirs, ";"), formatLabels(e.Labels, ";")}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func printListTable(backups []backup.BackupInfo) {
+	fmt.Fprintf(common.Stdout(), "\nTotal backups %d\n", len(backups))
+	t := table.NewWriter()
+	t.SetOutputMirror(common.Stdout())
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{
+			Name:     "Backup Key",
+			WidthMin: backupKeyColumnWidthMin,
+			WidthMax: backupKeyColumnWidthMax,
+		},
+	})
+	t.AppendHeader(table.Row{"#", "Backup Key", "Age", "Size", "Objects"})
+
+	var totalSize int64
+	for i, backup := range backups {
+		t.AppendRow([]interface{}{i + 1, backup.Key, datetime.HumanizeTime(backup.CreatedAt), units.FormatBytes(backup.Size), backup.Objects})
+		t.AppendSeparator()
+		totalSize += backup.Size
+	}
+	t.AppendFooter(table.Row{"", "", "Total", units.FormatBytes(totalSize), ""})
+
+	t.Render()
+}
+
 // listCmd represents the list command.
 var listCmd = &cobra.Command{
 	Use:   "list",
@@ -22,35 +212,42 @@ var listCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
-		backups, err := bm.ListBackups(ctx)
+		if err := common.CheckOutput(listOutput, common.OutputTable, common.OutputJSON, common.OutputCSV); err != nil {
+			return err
+		}
+
+		backups, err := bm.ListBackupDetails(ctx)
 		if err != nil {
 			slog.ErrorContext(ctx, "error listing backups", "error", err)
 			return err
 		}
+
+		if backups, err = listFilter.apply(backups, time.Now()); err != nil {
+			return err
+		}
+
+		switch listOutput {
+		case common.OutputJSON:
+			return common.PrintJSON(newListEntries(backups))
+		case common.OutputCSV:
+			return printListCSV(newListEntries(backups))
+		}
+
 		if len(backups) == 0 {
 			slog.InfoContext(ctx, "No backups found")
 			return nil
-		} else {
-			fmt.Printf("\nTotal backups %d\n", len(backups)) //nolint:forbidigo // CLI output requires fmt.Printf
-			t := table.NewWriter()
-			t.SetOutputMirror(os.Stdout)
-			t.SetColumnConfigs([]table.ColumnConfig{
-				{
-					Name:     "Backup Key",
-					WidthMin: backupKeyColumnWidthMin,
-					WidthMax: backupKeyColumnWidthMax,
-				},
-			})
-			t.AppendHeader(table.Row{"#", "Backup Key"})
-
-			for i, backup := range backups {
-
-				t.AppendRow([]interface{}{i + 1, backup})
-				t.AppendSeparator()
-			}
-
-			t.Render()
 		}
+		printListTable(backups)
 		return nil
 	},
 }
+
+func init() {
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", common.OutputTable, "Output format (table, json, csv)")
+	listCmd.Flags().StringVar(&listFilter.since, "since", "", "Only list backups created at or after this time, e.g. 2024-01-31 or 7d")
+	listCmd.Flags().StringVar(&listFilter.until, "until", "", "Only list backups created at or before this time, e.g. 2024-01-31 or 24h")
+	listCmd.Flags().IntVarP(&listFilter.limit, "limit", "n", 0, "Maximum number of backups to list, 0 for all")
+	listCmd.Flags().BoolVarP(&listFilter.reverse, "reverse", "r", false, "List the oldest backups first")
+	listCmd.Flags().StringVar(&listFilter.dir, "dir", "", "Only list backups containing this directory (path or name)")
+	listCmd.Flags().StringArrayVar(&listFilter.labels, "label", nil, "Only list backups with this label, as key=value; repeat to require several")
+}
@@ -0,0 +1,29 @@
+package backup
+
+import (
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
+)
+
+// outputText is the output of the commands running backups and purges: only their logs.
+const outputText = "text"
+
+// runOutput is the result of backup add and purge printed with --output json.
+type runOutput struct {
+	Runs  []backup.RunResult `json:"runs"`
+	Error string             `json:"error,omitempty"`
+}
+
+// checkRunOutput checks output, the output format of a command running backups or purges.
+func checkRunOutput(output string) error {
+	return common.CheckOutput(output, outputText, common.OutputJSON)
+}
+
+// printRunOutput prints the runs collected by results, and err, the error of the command, as JSON.
+func printRunOutput(results *backup.Results, err error) error {
+	out := runOutput{Runs: results.Runs()}
+	if err != nil {
+		out.Error = err.Error()
+	}
+	return common.PrintJSON(out)
+}
@@ -0,0 +1,62 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeManager is a backup manager whose backups and purges return err.
+type fakeManager struct {
+	backup.BackupManagerIface
+	err error
+}
+
+func (m *fakeManager) Backup(context.Context) error { return m.err }
+
+func (m *fakeManager) PurgeOldBackups(context.Context) error { return m.err }
+
+func TestRunOutput_JSON(t *testing.T) {
+	errFailed := errors.New("upload failed")
+
+	tests := []struct {
+		name   string
+		cmd    *cobra.Command
+		output *string
+		err    error
+		want   string
+	}{
+		{name: "add", cmd: addCmd, output: &addOutput, want: `{"runs": []}`},
+		{name: "add failed", cmd: addCmd, output: &addOutput, err: errFailed, want: `{"runs": [], "error": "upload failed"}`},
+		{name: "purge", cmd: purgeCmd, output: &purgeOutput, want: `{"runs": []}`},
+		{name: "purge failed", cmd: purgeCmd, output: &purgeOutput, err: errFailed, want: `{"runs": [], "error": "upload failed"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			out, prevBM, prevOutput, prevNoProgress := common.Output, bm, *tt.output, noProgress
+			t.Cleanup(func() { common.Output, bm, *tt.output, noProgress = out, prevBM, prevOutput, prevNoProgress })
+			common.Output, bm, *tt.output, noProgress = &buf, &fakeManager{err: tt.err}, common.OutputJSON, true
+
+			tt.cmd.SetContext(t.Context())
+			err := tt.cmd.RunE(tt.cmd, nil)
+			require.ErrorIs(t, err, tt.err)
+
+			assert.JSONEq(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestCheckRunOutput(t *testing.T) {
+	require.NoError(t, checkRunOutput(outputText))
+	require.NoError(t, checkRunOutput(common.OutputJSON))
+	require.ErrorIs(t, checkRunOutput(common.OutputTable), common.ErrInvalidOutput)
+}
@@ -0,0 +1,35 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/hibare/arclift/internal/progress"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var noProgress bool
+
+// withProgress returns the context of cmd reporting transfers to a progress bar on stderr when it is a terminal,
+// and the func ending the bar. The bar is labelled with the dir being backed up.
+func withProgress(cmd *cobra.Command) (context.Context, func()) {
+	ctx := cmd.Context()
+	if noProgress || common.Quiet {
+		return ctx, func() {}
+	}
+	if info, err := os.Stderr.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return ctx, func() {}
+	}
+
+	bar := progress.New(os.Stderr)
+	bm.AddListener(backup.ListenerFunc(func(_ context.Context, ev backup.Event) {
+		if ev.Type == backup.EventDirStarted {
+			bar.SetLabel(filepath.Base(ev.Dir))
+		}
+	}))
+	return storage.WithProgress(ctx, bar), bar.Finish
+}
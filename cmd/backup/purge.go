@@ -3,21 +3,38 @@ package backup
 import (
 	"log/slog"
 
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
 	"github.com/spf13/cobra"
 )
 
+var purgeOutput string
+
 // purgeCmd represents the purge command.
 var purgeCmd = &cobra.Command{
 	Use:   "purge",
 	Short: "Purge old backups",
 	Long:  "",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := cmd.Context()
+		if err := checkRunOutput(purgeOutput); err != nil {
+			return err
+		}
 
-		if err := bm.PurgeOldBackups(ctx); err != nil {
+		ctx, results := backup.WithResults(cmd.Context())
+		err := bm.PurgeOldBackups(ctx)
+		if err != nil {
 			slog.ErrorContext(ctx, "error purging old backups", "error", err)
-			return err
 		}
-		return nil
+
+		if purgeOutput == common.OutputJSON {
+			if pErr := printRunOutput(results, err); pErr != nil {
+				return pErr
+			}
+		}
+		return err
 	},
 }
+
+func init() {
+	purgeCmd.Flags().StringVarP(&purgeOutput, "output", "o", outputText, "Output format (text, json); json prints the result of every run")
+}
@@ -0,0 +1,106 @@
+package backup
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+// passphraseEnv is read when --passphrase is not given, keeping the passphrase out of shell history.
+const passphraseEnv = "ARCLIFT_GPG_PASSPHRASE"
+
+var (
+	restoreOpts   backup.RestoreOptions
+	restoreOutput string
+)
+
+// restoreCmd represents the restore command.
+var restoreCmd = &cobra.Command{
+	Use:   "restore <backup>",
+	Short: "Restore a backup",
+	Long: "Restore a backup, as listed by `backup list`, into a target directory. " +
+		"Exits 0 when the restore is complete, 2 when it is partial and 1 when it failed.",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		if err := common.CheckOutput(restoreOutput, common.OutputTable, common.OutputJSON); err != nil {
+			return err
+		}
+
+		opts := restoreOpts
+		opts.Backup = args[0]
+		if opts.Passphrase == "" {
+			opts.Passphrase = os.Getenv(passphraseEnv)
+		}
+
+		target, err := filepath.Abs(opts.Target)
+		if err != nil {
+			return err
+		}
+		opts.Target = target
+
+		pctx, done := withProgress(cmd)
+		summary, err := bm.Restore(pctx, opts)
+		done()
+		var restoreErr *backup.RestoreError
+		if err != nil && !errors.As(err, &restoreErr) {
+			slog.ErrorContext(ctx, "error restoring backup", "error", err)
+			return err
+		}
+
+		if restoreOutput == common.OutputJSON {
+			if pErr := common.PrintJSON(summary); pErr != nil {
+				return pErr
+			}
+			return err
+		}
+
+		t := table.NewWriter()
+		t.SetOutputMirror(common.Stdout())
+		t.AppendRows([]table.Row{
+			{"Backup", summary.Backup},
+			{"Target", summary.Target},
+			{"Outcome", summary.Outcome},
+			{"Restored", summary.Restored},
+			{"Skipped", summary.Skipped},
+			{"Failed", summary.Failed},
+			{"Checksum Mismatches", summary.ChecksumMismatches},
+		})
+		t.Render()
+
+		if len(summary.Errors) > 0 {
+			names := make([]string, 0, len(summary.Errors))
+			for name := range summary.Errors {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			et := table.NewWriter()
+			et.SetOutputMirror(common.Stdout())
+			et.AppendHeader(table.Row{"Path", "Error"})
+			for _, name := range names {
+				et.AppendRow(table.Row{name, summary.Errors[name]})
+			}
+			et.Render()
+		}
+		return err
+	},
+}
+
+func init() {
+	restoreCmd.Flags().StringVarP(&restoreOpts.Target, "target", "t", ".", "Directory to restore into")
+	restoreCmd.Flags().BoolVar(&restoreOpts.Overwrite, "overwrite", false, "Overwrite existing files instead of skipping them")
+	restoreCmd.Flags().StringVar(&restoreOpts.PrivateKey, "private-key", "", "Path to the armored GPG private key for encrypted backups")
+	restoreCmd.Flags().StringVar(&restoreOpts.Passphrase, "passphrase", "", "Passphrase of the private key (defaults to $"+passphraseEnv+")")
+	restoreCmd.Flags().BoolVar(&restoreOpts.Notify, "notify", false, "Send the restore summary to the configured notifiers")
+	restoreCmd.Flags().StringVarP(&restoreOutput, "output", "o", common.OutputTable, "Output format (table, json)")
+}
@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/datetime"
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/units"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchOutput string
+	searchLimit  int
+)
+
+// searchCmd represents the search command.
+var searchCmd = &cobra.Command{
+	Use:   "search <pattern>",
+	Short: "Find the backups containing files matching a name or glob",
+	Long: "Find the files of every backup whose name matches pattern, newest backup first. " +
+		"A plain pattern matches file names containing it, such as invoices.xlsx; a glob such as '*.xlsx' matches file names, " +
+		"or paths relative to the backup when it contains a slash, such as 'home/*/invoices/*.pdf'. Matching ignores case.",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		if err := common.CheckOutput(searchOutput, common.OutputTable, common.OutputJSON); err != nil {
+			return err
+		}
+
+		matches, err := bm.Search(ctx, args[0])
+		if err != nil {
+			slog.ErrorContext(ctx, "error searching backups", "error", err)
+			return err
+		}
+		if searchLimit > 0 && len(matches) > searchLimit {
+			matches = matches[:searchLimit]
+		}
+
+		if searchOutput == common.OutputJSON {
+			return common.PrintJSON(matches)
+		}
+		if len(matches) == 0 {
+			slog.InfoContext(ctx, "No matching files found", "pattern", args[0])
+			return nil
+		}
+
+		t := table.NewWriter()
+		t.SetOutputMirror(common.Stdout())
+		t.SetColumnConfigs([]table.ColumnConfig{
+			{
+				Name:     "Backup Key",
+				WidthMin: backupKeyColumnWidthMin,
+				WidthMax: backupKeyColumnWidthMax,
+			},
+		})
+		t.AppendHeader(table.Row{"#", "Backup Key", "Age", "Path", "Size", "Modified"})
+		for i, m := range matches {
+			var modified string
+			if !m.ModTime.IsZero() {
+				modified = m.ModTime.Local().Format(time.DateTime)
+			}
+			t.AppendRow(table.Row{i + 1, m.Backup, datetime.HumanizeTime(m.CreatedAt), m.Path, units.FormatBytes(m.Size), modified})
+			t.AppendSeparator()
+		}
+		t.Render()
+		return nil
+	},
+}
+
+func init() {
+	searchCmd.Flags().StringVarP(&searchOutput, "output", "o", common.OutputTable, "Output format (table, json)")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 0, "Maximum number of matching files to show, 0 for all")
+}
@@ -0,0 +1,32 @@
+package backup
+
+import (
+	"log/slog"
+
+	"github.com/spf13/cobra"
+)
+
+var streamName string
+
+// streamCmd represents the stream command.
+var streamCmd = &cobra.Command{
+	Use:   "stream",
+	Short: "Back up what is read from stdin as a single object",
+	Long: "Reads stdin until it is closed and uploads it as a timestamped object, encrypted when encryption is enabled, " +
+		"such as: pg_dump app | arclift backup stream --name app.sql --job db",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		if err := bm.Stream(ctx, streamName, cmd.InOrStdin()); err != nil {
+			slog.ErrorContext(ctx, "error backing up stream", "name", streamName, "error", err)
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	streamCmd.Flags().StringVarP(&streamName, "name", "n", "", "Object name of the stream, such as app.sql")
+	_ = streamCmd.MarkFlagRequired("name")
+}
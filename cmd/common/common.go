@@ -2,28 +2,147 @@ package common
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
 
 	"github.com/hibare/arclift/internal/backup"
 	"github.com/hibare/arclift/internal/config"
 	"github.com/hibare/arclift/internal/notifiers"
+	"github.com/hibare/arclift/internal/state"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/storage/exec"
+	"github.com/hibare/arclift/internal/storage/rclone"
 	"github.com/hibare/arclift/internal/storage/s3"
+	"github.com/hibare/arclift/internal/version"
 )
 
-func NewBackupManager(ctx context.Context, configPath string) (backup.BackupManagerIface, error) {
-	cfg, err := config.GetConfig(ctx, configPath)
-	if err != nil {
-		return nil, err
+// Job is a configured backup job with its backup manager.
+type Job struct {
+	Name      string
+	Hostname  string
+	Schedules []config.Schedule
+	Manager   backup.BackupManagerIface
+}
+
+// newStorage returns the storage backend selected by cfg.
+func newStorage(cfg *config.Config) storage.StorageIface {
+	switch cfg.Storage.Backend {
+	case config.StorageBackendExec:
+		return exec.NewExecStorage(cfg)
+	case config.StorageBackendRclone:
+		return rclone.NewRcloneStorage(cfg)
+	default:
+		return s3.NewS3Storage(cfg)
 	}
+}
 
-	store := s3.NewS3Storage(cfg)
+func newJobManager(ctx context.Context, cfg *config.Config, notifierStore notifiers.NotifierStoreIface) (backup.BackupManagerIface, error) {
+	store := newStorage(cfg)
 	if err := store.Init(ctx); err != nil {
 		return nil, err
 	}
 
+	bm := backup.NewBackupManager(cfg, store, notifierStore, state.NewStore(cfg.State.Dir))
+
+	if coldCfg := cfg.ColdTier(); coldCfg != nil {
+		coldStore := s3.NewS3Storage(coldCfg)
+		if err := coldStore.Init(ctx); err != nil {
+			return nil, err
+		}
+		bm.SetColdStore(coldStore)
+	}
+
+	for _, l := range cfg.Backup.LegacyLayouts {
+		legacyStore := newStorage(cfg.LegacyLayout(l))
+		if err := legacyStore.Init(ctx); err != nil {
+			return nil, err
+		}
+		bm.AddLegacyLayout(l, legacyStore)
+	}
+
+	return bm, nil
+}
+
+// NewJobs creates the backup manager of every configured job.
+func NewJobs(ctx context.Context, configPath string) ([]Job, error) {
+	cfg, err := config.GetConfig(ctx, configPath)
+	if err != nil {
+		return nil, err
+	}
+	CheckForUpdate(ctx, cfg)
+	return LoadJobs(ctx, cfg)
+}
+
+// CheckForUpdate switches the update check to the release channel of cfg and, unless update checks are disabled,
+// checks for an update in the background.
+func CheckForUpdate(ctx context.Context, cfg *config.Config) {
+	version.Configure(cfg)
+	go func() {
+		if err := version.Check(ctx, cfg); err != nil {
+			slog.DebugContext(ctx, "Error checking for updates", "error", err)
+		}
+	}()
+}
+
+// LoadJobs creates the notifiers and the backup manager of every job of cfg.
+func LoadJobs(ctx context.Context, cfg *config.Config) ([]Job, error) {
 	notifierStore := notifiers.NewNotifier(cfg)
 	if err := notifierStore.InitStore(); err != nil {
 		return nil, err
 	}
 
-	return backup.NewBackupManager(cfg, store, notifierStore), nil
+	var jobs []Job
+	for _, jobCfg := range cfg.Jobs() {
+		bm, err := newJobManager(ctx, jobCfg, notifierStore)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, Job{Name: jobCfg.Backup.Job, Hostname: jobCfg.Backup.Hostname, Schedules: jobCfg.Backup.Schedules(), Manager: bm})
+	}
+	return jobs, nil
+}
+
+// NewBackupManager creates the backup manager of the named job, with the jobs of the sources it stores under other
+// hostnames, or, when job is empty, of every job. A non-empty hostname narrows them down to the jobs storing backups
+// under it.
+func NewBackupManager(ctx context.Context, configPath, job, hostname string) (backup.BackupManagerIface, error) {
+	jobs, err := NewJobs(ctx, configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if job != "" {
+		jobs = slices.DeleteFunc(jobs, func(j Job) bool {
+			return j.Name != job && !strings.HasPrefix(j.Name, job+config.HostJobSeparator)
+		})
+		if len(jobs) == 0 {
+			_, err := config.Current().Job(job)
+			return nil, err
+		}
+	}
+	if hostname != "" {
+		jobs = slices.DeleteFunc(jobs, func(j Job) bool { return j.Hostname != hostname })
+		if len(jobs) == 0 {
+			return nil, fmt.Errorf("%w: no job stores backups under %s", config.ErrUnknownHostname, hostname)
+		}
+	}
+
+	return Combine(jobs), nil
+}
+
+// Combine returns a single backup manager running every job.
+func Combine(jobs []Job) backup.BackupManagerIface {
+	if len(jobs) == 1 {
+		return jobs[0].Manager
+	}
+
+	names := make([]string, 0, len(jobs))
+	managers := make(map[string]backup.BackupManagerIface, len(jobs))
+	for _, j := range jobs {
+		names = append(names, j.Name)
+		managers[j.Name] = j.Manager
+	}
+	return backup.NewJobs(names, managers)
 }
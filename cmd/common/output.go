@@ -0,0 +1,74 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// Output formats of the --output flag of commands.
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputCSV   = "csv"
+)
+
+// ErrInvalidOutput is returned when an unsupported output format is requested.
+var ErrInvalidOutput = errors.New("invalid output format")
+
+// Quiet is set by --quiet: only errors are logged, and neither tables nor other informational messages are printed.
+// Output requested in a machine-readable format, such as --output json, is still printed.
+var Quiet bool
+
+// Output is where commands print, os.Stdout unless replaced, as by tests.
+var Output io.Writer = os.Stdout
+
+// machineOutput is set by LogToStderrForOutput when the command prints a machine-readable format, which --quiet keeps.
+var machineOutput bool
+
+// Stdout returns where commands print tables and messages: Output, or io.Discard with --quiet unless a
+// machine-readable format is printed.
+func Stdout() io.Writer {
+	if Quiet && !machineOutput {
+		return io.Discard
+	}
+	return Output
+}
+
+// LogToStderrForOutput makes the config loaded for cmd log to stderr when the --output of cmd is json or csv, so that
+// stdout only holds the output.
+func LogToStderrForOutput(cmd *cobra.Command) {
+	machineOutput = false
+	if output := cmd.Flags().Lookup("output"); output != nil {
+		switch output.Value.String() {
+		case OutputJSON, OutputCSV:
+			config.LogToStderr = true
+			machineOutput = true
+		}
+	}
+}
+
+// CheckOutput returns ErrInvalidOutput unless output is one of the supported formats.
+func CheckOutput(output string, supported ...string) error {
+	if !slices.Contains(supported, output) {
+		return fmt.Errorf("%w: %s, supported: %s", ErrInvalidOutput, output, strings.Join(supported, ", "))
+	}
+	return nil
+}
+
+// PrintJSON prints v as indented JSON to Stdout.
+func PrintJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(Stdout(), string(data))
+	return err
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintJSON_Quiet(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		quiet  bool
+		want   string
+	}{
+		{name: "json", output: OutputJSON, want: "{\n  \"a\": 1\n}\n"},
+		{name: "quiet json", output: OutputJSON, quiet: true, want: "{\n  \"a\": 1\n}\n"},
+		{name: "quiet table", output: OutputTable, quiet: true, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			out, quiet, logToStderr := Output, Quiet, config.LogToStderr
+			t.Cleanup(func() { Output, Quiet, machineOutput, config.LogToStderr = out, quiet, false, logToStderr })
+			Output, Quiet = &buf, tt.quiet
+
+			cmd := &cobra.Command{}
+			cmd.Flags().String("output", tt.output, "")
+			LogToStderrForOutput(cmd)
+
+			require.NoError(t, PrintJSON(map[string]int{"a": 1}))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	require.NoError(t, CheckOutput(OutputJSON, OutputTable, OutputJSON))
+	err := CheckOutput(OutputCSV, OutputTable, OutputJSON)
+	require.ErrorIs(t, err, ErrInvalidOutput)
+	assert.EqualError(t, err, "invalid output format: csv, supported: table, json")
+}
@@ -11,4 +11,7 @@ var ConfigCmd = &cobra.Command{
 
 func init() {
 	ConfigCmd.AddCommand(InitConfigCmd)
+	ConfigCmd.AddCommand(ValidateConfigCmd)
+	ConfigCmd.AddCommand(MigrateConfigCmd)
+	ConfigCmd.AddCommand(EncryptValueCmd)
 }
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var EncryptValueCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt a config value, such as a secret key",
+	Long: "Read a value from stdin and print it encrypted with the passphrase of ARCLIFT_CONFIG_PASSPHRASE or " +
+		"ARCLIFT_CONFIG_PASSPHRASE_FILE, ready to be pasted into the config file.",
+	Example:      `  printf '%s' "$SECRET_KEY" | arclift config encrypt`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		encrypted, err := config.EncryptValue(strings.TrimRight(string(value), "\r\n"))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("!encrypted %s\n", encrypted) //nolint:forbidigo // CLI output requires fmt.Printf
+		return nil
+	},
+}
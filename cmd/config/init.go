@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"os"
 
+	"github.com/hibare/arclift/cmd/common"
 	"github.com/hibare/arclift/internal/config"
 	"github.com/spf13/cobra"
 )
@@ -21,8 +22,8 @@ var InitConfigCmd = &cobra.Command{
 			slog.ErrorContext(ctx, "error generating config file", "error", err)
 			os.Exit(1)
 		} else {
-			fmt.Printf("\n\nConfig file path: %s\n", configPath)                                            //nolint:forbidigo // CLI output requires fmt.Printf
-			fmt.Printf("Empty config file is loaded at above location. Edit config as per your needs.\n\n") //nolint:forbidigo // CLI output requires fmt.Printf
+			fmt.Fprintf(common.Stdout(), "\n\nConfig file path: %s\n", configPath)
+			fmt.Fprintf(common.Stdout(), "Empty config file is loaded at above location. Edit config as per your needs.\n\n")
 		}
 	},
 }
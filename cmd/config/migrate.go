@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var migrateDryRun bool
+
+var MigrateConfigCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate application config to the current schema",
+	Long: "Rewrite an old config file, such as a GoS3Backup config, into the current schema, keeping its comments. " +
+		"The original file is kept with a .bak suffix.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		cPath := cmd.Root().PersistentFlags().Lookup("config").Value.String()
+
+		path, err := config.ResolveConfigFile(ctx, cPath)
+		if err != nil {
+			return fmt.Errorf("failed to find config file: %w", err)
+		}
+
+		changes, err := config.MigrateFile(path, migrateDryRun)
+		if err != nil {
+			return err
+		}
+
+		if len(changes) == 0 {
+			fmt.Fprintf(common.Stdout(), "Config file %s is already at version %d\n", path, config.CurrentVersion)
+			return nil
+		}
+
+		for _, change := range changes {
+			fmt.Fprintf(common.Stdout(), "  %s\n", change)
+		}
+		if migrateDryRun {
+			fmt.Fprintf(common.Stdout(), "Dry run: %s left unchanged\n", path)
+		} else {
+			fmt.Fprintf(common.Stdout(), "Migrated %s, original kept at %s.bak\n", path, path)
+		}
+		return nil
+	},
+}
+
+func init() {
+	MigrateConfigCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Print the changes without writing the config file")
+}
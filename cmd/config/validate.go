@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var ValidateConfigCmd = &cobra.Command{
+	Use:          "validate",
+	Short:        "Validate application config without running a backup",
+	Long:         "Load the config file and environment, validate every option and check that all backup paths exist.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		cPath := cmd.Root().PersistentFlags().Lookup("config").Value.String()
+
+		cfg, err := config.LoadConfig(ctx, cPath)
+		if err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+
+		if err := cfg.Backup.CheckPaths(); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+
+		fmt.Fprintln(common.Stdout(), "Config is valid")
+		return nil
+	},
+}
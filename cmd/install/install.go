@@ -0,0 +1,27 @@
+package install
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// InstallCmd represents the install command.
+var InstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install Arclift components",
+}
+
+// UninstallCmd represents the uninstall command.
+var UninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Uninstall Arclift components",
+}
+
+func init() {
+	InstallCmd.AddCommand(installServiceCmd)
+	UninstallCmd.AddCommand(uninstallServiceCmd)
+
+	ServiceCmd.AddCommand(serviceInstallCmd)
+	ServiceCmd.AddCommand(serviceUninstallCmd)
+	ServiceCmd.AddCommand(serviceStartCmd)
+	ServiceCmd.AddCommand(serviceStopCmd)
+}
@@ -0,0 +1,110 @@
+package install
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/service"
+	"github.com/spf13/cobra"
+)
+
+const (
+	installServiceShort = "Register Arclift as a system service (systemd, launchd or Windows service)"
+	installServiceLong  = "Registers the scheduler with the platform service manager so it starts at boot using the configured config path."
+	uninstallShort      = "Stop and remove the Arclift system service"
+)
+
+func installService(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+
+	configPath := cmd.Root().PersistentFlags().Lookup("config").Value.String()
+	opts, err := service.NewOptions(configPath)
+	if err != nil {
+		return err
+	}
+
+	if err := service.Install(ctx, opts); err != nil {
+		slog.ErrorContext(ctx, "error installing service", "error", err)
+		return err
+	}
+
+	fmt.Fprintf(common.Stdout(), "Service installed: %s -c %s\n", opts.Executable, opts.ConfigPath)
+	return nil
+}
+
+func uninstallService(cmd *cobra.Command, _ []string) error {
+	ctx := cmd.Context()
+
+	if err := service.Uninstall(ctx); err != nil {
+		slog.ErrorContext(ctx, "error uninstalling service", "error", err)
+		return err
+	}
+
+	fmt.Fprintln(common.Stdout(), "Service uninstalled")
+	return nil
+}
+
+var installServiceCmd = &cobra.Command{
+	Use:   "service",
+	Short: installServiceShort,
+	Long:  installServiceLong,
+	RunE:  installService,
+}
+
+var uninstallServiceCmd = &cobra.Command{
+	Use:   "service",
+	Short: uninstallShort,
+	RunE:  uninstallService,
+}
+
+// ServiceCmd represents the service command.
+var ServiceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Manage the Arclift system service",
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: installServiceShort,
+	Long:  installServiceLong,
+	RunE:  installService,
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: uninstallShort,
+	RunE:  uninstallService,
+}
+
+var serviceStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the installed Arclift system service",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		if err := service.Start(ctx); err != nil {
+			slog.ErrorContext(ctx, "error starting service", "error", err)
+			return err
+		}
+
+		fmt.Fprintln(common.Stdout(), "Service started")
+		return nil
+	},
+}
+
+var serviceStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running Arclift system service",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		if err := service.Stop(ctx); err != nil {
+			slog.ErrorContext(ctx, "error stopping service", "error", err)
+			return err
+		}
+
+		fmt.Fprintln(common.Stdout(), "Service stopped")
+		return nil
+	},
+}
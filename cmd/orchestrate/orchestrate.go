@@ -0,0 +1,91 @@
+package orchestrate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/orchestrate"
+	"github.com/hibare/arclift/internal/state"
+	"github.com/hibare/arclift/internal/units"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultParallel = 4
+	defaultTimeout  = 2 * time.Hour
+)
+
+// ErrFleetFailed is returned when the backup did not succeed on every host.
+var ErrFleetFailed = errors.New("backup did not succeed on every host")
+
+var (
+	inventoryPath string
+	parallel      int
+	timeout       time.Duration
+	output        string
+)
+
+func printTable(results []orchestrate.Result) {
+	t := table.NewWriter()
+	t.SetOutputMirror(common.Stdout())
+	t.AppendHeader(table.Row{"Host", "Method", "Status", "Duration", "Dirs", "Failed", "Bytes", "Error"})
+
+	for _, r := range results {
+		dirs, failed, bytes := "", "", ""
+		if r.Run != nil {
+			dirs = fmt.Sprint(r.Run.Dirs)
+			failed = fmt.Sprint(r.Run.FailedDirs)
+			bytes = units.FormatBytes(r.Run.Bytes)
+		}
+		t.AppendRow(table.Row{r.Host, r.Method, r.Status, r.FinishedAt.Sub(r.StartedAt).Round(time.Second), dirs, failed, bytes, r.Error})
+		t.AppendSeparator()
+	}
+	t.Render()
+}
+
+// OrchestrateCmd represents the orchestrate command.
+var OrchestrateCmd = &cobra.Command{
+	Use:   "orchestrate",
+	Short: "Trigger backups on a fleet of hosts and report the results",
+	Long: "Trigger a backup on every host of an inventory, over ssh or through the host's dashboard API, " +
+		"and print a consolidated fleet report. Exits non-zero unless every host succeeded.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		common.LogToStderrForOutput(cmd)
+		if err := common.CheckOutput(output, common.OutputTable, common.OutputJSON); err != nil {
+			return err
+		}
+
+		inv, err := orchestrate.LoadInventory(inventoryPath)
+		if err != nil {
+			return err
+		}
+
+		results := orchestrate.Run(cmd.Context(), inv, orchestrate.Options{Parallel: parallel, Timeout: timeout})
+
+		if output == common.OutputJSON {
+			if err := common.PrintJSON(results); err != nil {
+				return err
+			}
+		} else {
+			printTable(results)
+		}
+
+		for _, r := range results {
+			if r.Status != state.StatusSuccess {
+				return ErrFleetFailed
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	OrchestrateCmd.Flags().StringVarP(&inventoryPath, "inventory", "i", "hosts.yaml", "Path to the inventory file")
+	OrchestrateCmd.Flags().IntVarP(&parallel, "parallel", "p", defaultParallel, "Number of hosts backed up at the same time")
+	OrchestrateCmd.Flags().DurationVar(&timeout, "timeout", defaultTimeout, "Maximum duration of the backup of a single host")
+	OrchestrateCmd.Flags().StringVarP(&output, "output", "o", common.OutputTable, "Output format (table, json)")
+}
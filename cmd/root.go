@@ -2,22 +2,50 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-co-op/gocron"
+	commonLogger "github.com/hibare/GoCommon/v2/pkg/logger"
 	cmdBackup "github.com/hibare/arclift/cmd/backup"
 	"github.com/hibare/arclift/cmd/common"
 	cmdConfig "github.com/hibare/arclift/cmd/config"
+	cmdInstall "github.com/hibare/arclift/cmd/install"
+	cmdOrchestrate "github.com/hibare/arclift/cmd/orchestrate"
+	cmdSelfUpdate "github.com/hibare/arclift/cmd/selfupdate"
+	cmdStop "github.com/hibare/arclift/cmd/stop"
+	cmdStorage "github.com/hibare/arclift/cmd/storage"
+	cmdVerify "github.com/hibare/arclift/cmd/verify"
 	"github.com/hibare/arclift/internal/config"
 	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/daemon"
+	"github.com/hibare/arclift/internal/dashboard"
+	"github.com/hibare/arclift/internal/notifiers/sentry"
+	"github.com/hibare/arclift/internal/priority"
+	"github.com/hibare/arclift/internal/service"
+	"github.com/hibare/arclift/internal/state"
 	"github.com/hibare/arclift/internal/version"
+	"github.com/hibare/arclift/internal/watch"
 	"github.com/spf13/cobra"
 )
 
 var (
 	ConfigPath string
+	pidFile    string
+	detach     bool
+	logFile    string
+	verbose    bool
+	logLevel   string
 )
 
 var RootCmd = &cobra.Command{
@@ -28,64 +56,384 @@ var RootCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
-		s := gocron.NewScheduler(time.UTC)
+		if detach && !daemon.Detached() {
+			pid, err := daemon.Detach(ctx, os.Args[1:], logFile)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(common.Stdout(), "Started arclift in the background (pid %d)\n", pid)
+			return nil
+		}
 
-		bm, err := common.NewBackupManager(ctx, ConfigPath)
-		if err != nil {
+		if pidFile != "" {
+			release, err := daemon.WritePIDFile(pidFile)
+			if err != nil {
+				return err
+			}
+			defer release()
+		}
+
+		if handled, err := service.RunIfService(ctx, runDaemon); handled {
 			return err
 		}
+		return runDaemon(ctx)
+	},
+}
+
+// sleepJitter waits a random duration below jitter, so hosts sharing a schedule do not all hit the storage at once.
+// It returns false when ctx is cancelled while waiting.
+func sleepJitter(ctx context.Context, jitter time.Duration) bool {
+	if jitter <= 0 {
+		return true
+	}
+
+	delay := rand.N(jitter) //nolint:gosec // the delay does not need a secure source
+	slog.DebugContext(ctx, "Delaying scheduled backup", "delay", delay)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// runJob backs up paths of job, every path when nil, then purges its old backups.
+func runJob(ctx context.Context, job common.Job, paths []string) {
+	defer reportPanic()
+
+	if err := job.Manager.BackupPaths(ctx, paths); err != nil {
+		slog.ErrorContext(ctx, "Error backing up", "job", job.Name, "error", err)
+	}
+	if err := job.Manager.PurgeOldBackups(ctx); err != nil {
+		slog.ErrorContext(ctx, "Error purging old backups", "job", job.Name, "error", err)
+	}
+}
+
+// schedule creates a started scheduler running the jobs of cfg, each of its schedules individually, the backup
+// verification and the version check.
+func schedule(ctx context.Context, cfg *config.Config, jobs []common.Job) (*gocron.Scheduler, error) {
+	s := gocron.NewScheduler(cfg.Backup.Location())
+
+	for _, job := range jobs {
+		for _, sched := range job.Schedules {
+			paths := sched.Paths
+			if _, bcErr := s.Cron(sched.Cron).Do(func() {
+				if sleepJitter(ctx, cfg.Backup.Jitter) {
+					runJob(ctx, job, paths)
+				}
+			}); bcErr != nil {
+				slog.ErrorContext(ctx, "Error setting up cron", "job", job.Name, "cron", sched.Cron, "error", bcErr)
+				return nil, bcErr
+			}
+			slog.InfoContext(ctx, "Scheduled backup job", "job", job.Name, "cron", sched.Cron, "paths", sched.Paths)
+		}
+	}
 
-		// Schedule backup job
-		if _, bcErr := s.Cron(config.Current.Backup.Cron).Do(func() {
-			if baErr := bm.Backup(ctx); baErr != nil {
-				slog.ErrorContext(ctx, "Error backing up", "error", baErr)
+	if cfg.Verify.Cron != "" {
+		if _, vErr := s.Cron(cfg.Verify.Cron).Do(func() {
+			for _, job := range jobs {
+				if _, err := job.Manager.Verify(ctx, cfg.Verify.Sample); err != nil {
+					slog.ErrorContext(ctx, "Error verifying backups", "job", job.Name, "error", err)
+				}
 			}
-			if bpErr := bm.PurgeOldBackups(ctx); bpErr != nil {
-				slog.ErrorContext(ctx, "Error purging old backups", "error", bpErr)
+		}); vErr != nil {
+			slog.ErrorContext(ctx, "Error setting up cron", "cron", cfg.Verify.Cron, "error", vErr)
+			return nil, vErr
+		}
+		slog.InfoContext(ctx, "Scheduled backup verification", "cron", cfg.Verify.Cron, "sample", cfg.Verify.Sample)
+	}
+
+	if lockCron := cfg.Verify.ObjectLock.Cron; lockCron != "" {
+		if _, lErr := s.Cron(lockCron).Do(func() {
+			for _, job := range jobs {
+				if _, err := job.Manager.VerifyImmutability(ctx); err != nil {
+					slog.ErrorContext(ctx, "Error checking Object Lock of backups", "job", job.Name, "error", err)
+				}
 			}
-		}); bcErr != nil {
-			slog.ErrorContext(ctx, "Error setting up cron", "error", bcErr)
-			return bcErr
+		}); lErr != nil {
+			slog.ErrorContext(ctx, "Error setting up cron", "cron", lockCron, "error", lErr)
+			return nil, lErr
 		}
-		slog.InfoContext(ctx, "Scheduled backup job", "cron", config.Current.Backup.Cron)
+		slog.InfoContext(ctx, "Scheduled Object Lock check", "cron", lockCron)
+	}
 
-		// Schedule version check job
+	// Schedule version check job
+	if cfg.UpdateCheck.Enabled {
 		if _, vcErr := s.Cron(constants.VersionCheckCron).Do(func() {
-			if vErr := version.V.CheckUpdate(); vErr != nil {
+			if vErr := version.Check(ctx, cfg); vErr != nil {
 				slog.ErrorContext(ctx, "Error checking for updates", "error", vErr)
 			}
 		}); vcErr != nil {
 			slog.WarnContext(ctx, "Failed to schedule version check job", "error", vcErr)
 		}
+	}
 
-		s.StartBlocking()
-		return nil
-	},
+	s.StartAsync()
+	return s, nil
+}
+
+// startWatch watches the paths of every job when watch mode is enabled and backs them up after they change. It returns
+// a function stopping the watchers and waiting for the backups they started.
+func startWatch(ctx context.Context, cfg *config.Config, jobs []common.Job) func() {
+	watchCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	if cfg.Backup.Watch.Enabled {
+		for _, job := range jobs {
+			var paths []string
+			for _, sched := range job.Schedules {
+				// Remote dirs cannot be watched; they are only backed up on their schedule.
+				paths = append(paths, slices.DeleteFunc(slices.Clone(sched.Paths), config.IsRemote)...)
+			}
+			if len(paths) == 0 {
+				continue
+			}
+
+			w, err := watch.New(ctx, paths, cfg.Backup.Watch.Interval)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error watching paths", "job", job.Name, "error", err)
+				continue
+			}
+			slog.InfoContext(ctx, "Watching paths", "job", job.Name, "paths", paths, "interval", cfg.Backup.Watch.Interval)
+
+			wg.Go(func() {
+				// Backups run with ctx, so stopping the watchers on reload does not interrupt them.
+				w.Run(watchCtx, func(changed []string) {
+					slog.InfoContext(ctx, "Backing up changed paths", "job", job.Name, "paths", changed)
+					runJob(ctx, job, changed)
+				})
+			})
+		}
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// reload loads the config file again and schedules its jobs. The current configuration is kept when the new one is
+// invalid or its storage cannot be initialized.
+func reload(ctx context.Context) (*config.Config, []common.Job, *gocron.Scheduler, error) {
+	cfg, err := config.LoadConfig(ctx, ConfigPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	service.AttachEventLog()
+	common.CheckForUpdate(ctx, cfg)
+	jobs, err := common.LoadJobs(ctx, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	s, err := schedule(ctx, cfg, jobs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cfg, jobs, s, nil
+}
+
+// startDashboard serves the dashboard when it is enabled and returns its server, nil when it is disabled. running
+// tracks the server until it stopped with the operations it triggered.
+func startDashboard(ctx context.Context, cfg *config.Config, jobs []common.Job, running *sync.WaitGroup) (*dashboard.Server, error) {
+	if !cfg.Dashboard.Enabled {
+		return nil, nil //nolint:nilnil // the dashboard is disabled
+	}
+
+	srv, err := dashboard.NewServer(cfg, common.Combine(jobs), state.NewStore(cfg.State.Dir))
+	if err != nil {
+		return nil, err
+	}
+	running.Go(func() {
+		if sErr := srv.Run(ctx); sErr != nil {
+			slog.ErrorContext(ctx, "Dashboard stopped", "error", sErr)
+		}
+	})
+	return srv, nil
+}
+
+// runOnStart runs the initial backup of every job, after the configured jitter.
+func runOnStart(ctx context.Context, cfg *config.Config, jobs []common.Job) {
+	if !sleepJitter(ctx, cfg.Backup.Jitter) {
+		return
+	}
+	slog.InfoContext(ctx, "Running initial backup")
+	for _, job := range jobs {
+		runJob(ctx, job, nil)
+	}
+}
+
+// setupProcess prepares the scheduler process once cfg is loaded. The priority is lowered for the life of the
+// process, so it is not changed by reloads.
+func setupProcess(ctx context.Context, cfg *config.Config) {
+	// Loading the config replaced the default logger.
+	service.AttachEventLog()
+	priority.Apply(ctx, cfg.Backup.Priority)
+}
+
+// runDaemon schedules the backup jobs and blocks until ctx is cancelled. On SIGHUP the config file is reloaded and the
+// jobs rescheduled; running backups finish with the previous configuration. On SIGUSR1 every job is backed up
+// immediately.
+func runDaemon(ctx context.Context) error {
+	jobs, err := common.NewJobs(ctx, ConfigPath)
+	if err != nil {
+		return err
+	}
+	cfg := config.Current()
+	setupProcess(ctx, cfg)
+
+	var running sync.WaitGroup
+	srv, err := startDashboard(ctx, cfg, jobs, &running)
+	if err != nil {
+		return err
+	}
+
+	s, err := schedule(ctx, cfg, jobs)
+	if err != nil {
+		return err
+	}
+
+	stopWatch := startWatch(ctx, cfg, jobs)
+
+	if cfg.Backup.RunOnStart {
+		running.Go(func() { runOnStart(ctx, cfg, jobs) })
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	trigger := make(chan os.Signal, 1)
+	if len(triggerSignals) > 0 {
+		signal.Notify(trigger, triggerSignals...)
+		defer signal.Stop(trigger)
+	}
+
+	var triggered atomic.Bool
+	for {
+		select {
+		case <-trigger:
+			if !triggered.CompareAndSwap(false, true) {
+				slog.WarnContext(ctx, "Ignoring backup trigger, a triggered backup is already running")
+				continue
+			}
+			current := jobs
+			running.Go(func() {
+				defer triggered.Store(false)
+				slog.InfoContext(ctx, "Running triggered backup")
+				for _, job := range current {
+					runJob(ctx, job, nil)
+				}
+			})
+		case <-hup:
+			slog.InfoContext(ctx, "Reloading configuration")
+			cfg, newJobs, newS, rErr := reload(ctx)
+			if rErr != nil {
+				slog.ErrorContext(ctx, "Error reloading configuration, keeping the current one", "error", rErr)
+				continue
+			}
+
+			// Stopping waits for the running backups; the backup lock keeps them from overlapping with the new jobs.
+			old, oldStopWatch := s, stopWatch
+			running.Go(func() {
+				oldStopWatch()
+				old.Stop()
+			})
+			stopWatch = startWatch(ctx, cfg, newJobs)
+			s, jobs = newS, newJobs
+			config.SetCurrent(cfg)
+			if srv != nil {
+				srv.Reload(cfg, common.Combine(newJobs))
+			}
+			slog.InfoContext(ctx, "Reloaded configuration")
+		case <-ctx.Done():
+			slog.InfoContext(ctx, "Shutting down, waiting for running backups to stop")
+			stopWatch()
+			s.Stop()
+			running.Wait()
+			return nil
+		}
+	}
+}
+
+// reportPanic reports a panic of the calling goroutine to Sentry, when configured, then panics again. Scheduled jobs run
+// on their own goroutines, so it is deferred by runJob as well as Execute.
+func reportPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if err := sentry.CapturePanic(config.Current(), r, debug.Stack()); err != nil {
+		slog.Error("Error reporting panic to Sentry", "error", err)
+	}
+	panic(r)
+}
+
+// overrideLogLevel applies --quiet, --verbose and --log-level to the configs loaded by the command, and to what is
+// logged before they are.
+func overrideLogLevel() {
+	switch {
+	case common.Quiet:
+		config.LoggerLevelOverride = commonLogger.LogLevelError
+	case verbose:
+		config.LoggerLevelOverride = commonLogger.LogLevelDebug
+	default:
+		config.LoggerLevelOverride = logLevel
+	}
+	if config.LoggerLevelOverride != "" {
+		commonLogger.InitLogger(&config.LoggerLevelOverride, nil)
+	}
 }
 
 func Execute() {
-	err := RootCmd.Execute()
+	defer reportPanic()
+
+	// Cancel the context on SIGINT and SIGTERM, so running backups stop and clean up before the process exits.
+	// A second signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := RootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
-func init() {
-	ctx := context.Background()
-	RootCmd.SetContext(ctx)
+// exitCode returns the process exit code for the error of a command: the one of its outcome, such as a partially
+// failed backup or restore, or ExitCodeFailure.
+func exitCode(err error) int {
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return constants.ExitCodeFailure
+}
 
+func init() {
 	// Add global flags
 	RootCmd.PersistentFlags().StringVarP(&ConfigPath, "config", "c", "", "Path to config file")
+	RootCmd.PersistentFlags().BoolVarP(&common.Quiet, "quiet", "q", false, "Only log errors and do not print tables or messages, overriding logger.level")
+	RootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Log debug messages, overriding logger.level")
+	RootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "Log level of this run, overriding logger.level: debug, info, warn, error")
+	RootCmd.PersistentFlags().BoolVar(&config.AllowInsecureConfigURL, "allow-insecure-config-url", false,
+		"Allow fetching the config file and its includes over plain http")
+	RootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose", "log-level")
+	cobra.OnInitialize(overrideLogLevel)
+	RootCmd.Flags().StringVar(&pidFile, "pidfile", "", "Write the scheduler's process id to this file, for arclift stop")
+	RootCmd.Flags().BoolVarP(&detach, "detach", "d", false, "Run the scheduler in the background")
+	RootCmd.Flags().StringVar(&logFile, "log-file", os.DevNull, "File the output of a detached scheduler is appended to")
 
 	// Add commands
 	RootCmd.AddCommand(cmdConfig.ConfigCmd)
 	RootCmd.AddCommand(cmdBackup.BackupCmd)
-
-	// Perform initial version check
-	go func() {
-		_ = version.V.CheckUpdate()
-		if version.V.IsUpdateAvailable() {
-			slog.Info(version.V.GetUpdateNotification())
-		}
-	}()
+	RootCmd.AddCommand(cmdInstall.InstallCmd)
+	RootCmd.AddCommand(cmdInstall.UninstallCmd)
+	RootCmd.AddCommand(cmdInstall.ServiceCmd)
+	RootCmd.AddCommand(cmdOrchestrate.OrchestrateCmd)
+	RootCmd.AddCommand(cmdSelfUpdate.SelfUpdateCmd)
+	RootCmd.AddCommand(cmdStop.StopCmd)
+	RootCmd.AddCommand(cmdStorage.StorageCmd)
+	RootCmd.AddCommand(cmdVerify.VerifyCmd)
 }
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/hibare/arclift/internal/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingManager is a backup manager whose backups return err.
+type failingManager struct {
+	backup.BackupManagerIface
+	err error
+}
+
+func (m *failingManager) Backup(context.Context) error { return m.err }
+
+func TestExitCode(t *testing.T) {
+	failed := &backup.BackupError{Errors: map[string]string{"/data": "upload failed"}}
+	partial := &backup.BackupError{Errors: map[string]string{"/data": "upload failed"}, Partial: true}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "error", err: errors.New("config not found"), want: constants.ExitCodeFailure},
+		{name: "backup failed", err: failed, want: constants.ExitCodeFailure},
+		{name: "backup partially failed", err: partial, want: constants.ExitCodeBackupPartial},
+		{name: "wrapped", err: fmt.Errorf("job db: %w", partial), want: constants.ExitCodeBackupPartial},
+		{
+			name: "restore partially failed",
+			err:  &backup.RestoreError{Summary: backup.RestoreSummary{Outcome: backup.RestorePartial}},
+			want: constants.ExitCodeRestorePartial,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, exitCode(tt.err))
+		})
+	}
+}
+
+func TestExitCode_Jobs(t *testing.T) {
+	failed := &backup.BackupError{Errors: map[string]string{"/data": "upload failed"}}
+
+	tests := []struct {
+		name string
+		errs map[string]error
+		want int
+	}{
+		{name: "every job failed", errs: map[string]error{"db": failed, "home": failed}, want: constants.ExitCodeFailure},
+		{name: "a job failed", errs: map[string]error{"db": nil, "home": failed}, want: constants.ExitCodeBackupPartial},
+		{
+			name: "a job did not run",
+			errs: map[string]error{"db": errors.New("lock held"), "home": nil},
+			want: constants.ExitCodeFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managers := map[string]backup.BackupManagerIface{}
+			for name, err := range tt.errs {
+				managers[name] = &failingManager{err: err}
+			}
+
+			err := backup.NewJobs([]string{"db", "home"}, managers).Backup(t.Context())
+			assert.Equal(t, tt.want, exitCode(err))
+		})
+	}
+}
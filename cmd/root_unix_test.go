@@ -0,0 +1,94 @@
+//go:build unix
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeDaemonConfig writes the config of a daemon backing up to an exec store under hostname.
+func writeDaemonConfig(t *testing.T, path, hostname string) {
+	t.Helper()
+
+	content := fmt.Sprintf(`
+storage:
+  backend: exec
+  exec:
+    command: "true"
+backup:
+  dirs: [%q]
+  cron: "0 0 1 1 *"
+  hostname: %s
+state:
+  dir: %q
+update-check:
+  enabled: false
+`, filepath.Dir(path), hostname, filepath.Join(filepath.Dir(path), "state"))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestRunDaemon_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeDaemonConfig(t, path, "before")
+
+	originalPath, originalCurrent := ConfigPath, config.Current()
+	t.Cleanup(func() {
+		ConfigPath = originalPath
+		config.SetCurrent(originalCurrent)
+	})
+	ConfigPath = path
+	config.SetCurrent(nil)
+
+	// Until runDaemon handles SIGHUP, it must not terminate the test process.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	t.Cleanup(func() { signal.Stop(hup) })
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- runDaemon(ctx)
+	}()
+
+	// Jobs read the config while the daemon replaces it.
+	var readers sync.WaitGroup
+	readers.Go(func() {
+		for ctx.Err() == nil {
+			if cfg := config.Current(); cfg != nil {
+				_ = cfg.Backup.Hostname
+			}
+		}
+	})
+
+	require.Eventually(t, func() bool {
+		cfg := config.Current()
+		return cfg != nil && cfg.Backup.Hostname == "before"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	writeDaemonConfig(t, path, "after")
+	require.Eventually(t, func() bool {
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+		return config.Current().Backup.Hostname == "after"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	cancel()
+	readers.Wait()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("runDaemon did not return after its context was cancelled")
+	}
+}
@@ -0,0 +1,60 @@
+package selfupdate
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/version"
+	"github.com/spf13/cobra"
+)
+
+const defaultTimeout = 5 * time.Minute
+
+var (
+	releaseVersion string
+	prerelease     bool
+	publicKey      string
+	force          bool
+	timeout        time.Duration
+)
+
+// SelfUpdateCmd represents the self-update command.
+var SelfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Replace this binary with the latest release",
+	Long: "Download the latest release of arclift for this platform from GitHub, verify it against the published " +
+		"checksums, signed with the release key built into arclift or with --public-key, and replace the running " +
+		"binary with it. Only linux and darwin releases are published. A running scheduler keeps the previous " +
+		"version until it is restarted. Requests honor HTTP_PROXY, HTTPS_PROXY and NO_PROXY.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := version.SelfUpdate(cmd.Context(), version.UpdateOptions{
+			Version:    releaseVersion,
+			Prerelease: prerelease,
+			PublicKey:  publicKey,
+			Force:      force,
+			Client:     &http.Client{Timeout: timeout},
+		})
+		if err != nil {
+			return err
+		}
+
+		if !result.Updated {
+			fmt.Fprintf(common.Stdout(), "arclift %s is already installed\n", result.Version)
+			return nil
+		}
+		fmt.Fprintf(common.Stdout(), "Updated %s from %s to %s\n", result.Path, result.Previous, result.Version)
+		return nil
+	},
+}
+
+func init() {
+	SelfUpdateCmd.Flags().StringVar(&releaseVersion, "version", "", "Release to install, such as v1.4.0; defaults to the latest")
+	SelfUpdateCmd.Flags().BoolVar(&prerelease, "prerelease", false, "Consider pre-releases for the latest release")
+	SelfUpdateCmd.Flags().StringVar(&publicKey, "public-key", "", "Armored GPG public key the release checksums must be signed with, instead of the built-in release key")
+	SelfUpdateCmd.Flags().BoolVar(&force, "force", false, "Reinstall the release even when it is the running version")
+	SelfUpdateCmd.Flags().DurationVar(&timeout, "timeout", defaultTimeout, "Maximum time of each download")
+	SelfUpdateCmd.MarkFlagsMutuallyExclusive("version", "prerelease")
+}
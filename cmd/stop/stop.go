@@ -0,0 +1,41 @@
+package stop
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+const defaultTimeout = time.Minute
+
+var (
+	pidFile string
+	timeout time.Duration
+)
+
+// StopCmd represents the stop command.
+var StopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the scheduler recorded in a pid file",
+	Long: "Stop a scheduler started with --pidfile, such as one started with --detach. Running backups are " +
+		"interrupted and cleaned up before it exits.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pid, err := daemon.Stop(cmd.Context(), pidFile, timeout)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(common.Stdout(), "Stopped arclift (pid %d)\n", pid)
+		return nil
+	},
+}
+
+func init() {
+	StopCmd.Flags().StringVar(&pidFile, "pidfile", "", "Path to the pid file of the scheduler")
+	StopCmd.Flags().DurationVar(&timeout, "timeout", defaultTimeout, "Maximum time to wait for the scheduler to exit")
+	_ = StopCmd.MarkFlagRequired("pidfile")
+}
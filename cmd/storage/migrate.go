@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/units"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+// fromLayoutName is the name of the legacy layout given with the --from-* flags.
+const fromLayoutName = "from"
+
+// ErrConflictingLayout is returned when --layout is combined with the --from-* flags.
+var ErrConflictingLayout = errors.New("--layout cannot be combined with the --from-* flags")
+
+var (
+	migrateLayout string
+	migrateFrom   config.LegacyLayoutConfig
+	migrateDryRun bool
+)
+
+var migrateKeysCmd = &cobra.Command{
+	Use:   "migrate-keys",
+	Short: "Move backups of a legacy key layout to the current layout",
+	Long: "Move the backups stored with a legacy prefix, hostname, key template or date-time layout, such as those " +
+		"of GoS3Backup, to the current key layout. Each backup is copied, the copy verified, then the original " +
+		"deleted. The legacy layouts are those of backup.legacy-layouts, or the one given with the --from-* flags.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		configPath := cmd.Root().PersistentFlags().Lookup("config").Value.String()
+		if migrateFrom != (config.LegacyLayoutConfig{}) {
+			if migrateLayout != "" {
+				return ErrConflictingLayout
+			}
+			cfg, err := config.GetConfig(ctx, configPath)
+			if err != nil {
+				return err
+			}
+			migrateFrom.Name = fromLayoutName
+			if err := cfg.AddLegacyLayout(migrateFrom); err != nil {
+				return err
+			}
+			migrateLayout = fromLayoutName
+		}
+
+		bm, err := common.NewBackupManager(ctx, configPath, job, "")
+		if err != nil {
+			return err
+		}
+
+		migrations, err := bm.MigrateKeys(ctx, migrateLayout, migrateDryRun)
+		printMigrations(migrations)
+		if err != nil {
+			slog.ErrorContext(ctx, "error migrating backup keys", "error", err)
+			return err
+		}
+		if migrateDryRun && len(migrations) > 0 {
+			fmt.Fprintln(common.Stdout(), "Dry run: no backup was moved")
+		}
+		return nil
+	},
+}
+
+func printMigrations(migrations []backup.KeyMigration) {
+	if len(migrations) == 0 {
+		fmt.Fprintln(common.Stdout(), "No legacy backups found")
+		return
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(common.Stdout())
+	t.AppendHeader(table.Row{"Layout", "From", "To", "Size", "Objects", "Status"})
+	for _, m := range migrations {
+		status := m.Status
+		if m.Error != "" {
+			status += ": " + m.Error
+		}
+		t.AppendRow(table.Row{m.Layout, m.From, m.To, units.FormatBytes(m.Size), m.Objects, status})
+	}
+	t.Render()
+}
+
+func init() {
+	migrateKeysCmd.Flags().StringVar(&migrateLayout, "layout", "", "Only migrate the named legacy layout (default: every legacy layout)")
+	migrateKeysCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Print the planned moves without changing the storage")
+	migrateKeysCmd.Flags().StringVar(&migrateFrom.Prefix, "from-prefix", "", "Prefix the backups to migrate are stored under")
+	migrateKeysCmd.Flags().StringVar(&migrateFrom.Hostname, "from-hostname", "", "Hostname the backups to migrate are stored under")
+	migrateKeysCmd.Flags().StringVar(&migrateFrom.KeyTemplate, "from-key-template", "", "Key template the backups to migrate are named with")
+	migrateKeysCmd.Flags().StringVar(&migrateFrom.DateTimeLayout, "from-date-time-layout", "", "Date-time layout the backups to migrate are named with")
+}
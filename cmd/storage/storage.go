@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var job string
+
+// StorageCmd groups the commands maintaining the stored backups.
+var StorageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Maintain the stored backups",
+}
+
+func init() {
+	StorageCmd.PersistentFlags().StringVarP(&job, "job", "j", "", "Only operate on the named job (default: every job)")
+
+	StorageCmd.AddCommand(migrateKeysCmd)
+}
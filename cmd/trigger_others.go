@@ -0,0 +1,9 @@
+//go:build !unix
+
+package cmd
+
+import "os"
+
+// triggerSignals are the signals asking the daemon to back up every job immediately. There is no SIGUSR1 on this
+// platform; use the dashboard instead.
+var triggerSignals []os.Signal
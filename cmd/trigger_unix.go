@@ -0,0 +1,11 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// triggerSignals are the signals asking the daemon to back up every job immediately.
+var triggerSignals = []os.Signal{syscall.SIGUSR1}
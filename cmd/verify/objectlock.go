@@ -0,0 +1,60 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var objectLockOutput string
+
+// objectLockCmd represents the object-lock command.
+var objectLockCmd = &cobra.Command{
+	Use:   "object-lock",
+	Short: "Check that stored backups are still protected by S3 Object Lock",
+	Long: "Check that Object Lock is enabled on the bucket, and that every object of every retained backup is still " +
+		"locked with the mode and retention of verify.object-lock and was not replaced or deleted since it was stored. " +
+		"Only object metadata is read. Exits non-zero when unprotected objects are found.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		if err := common.CheckOutput(objectLockOutput, common.OutputTable, common.OutputJSON); err != nil {
+			return err
+		}
+
+		report, err := bm.VerifyImmutability(ctx)
+		if err != nil && !errors.Is(err, backup.ErrNotImmutable) {
+			slog.ErrorContext(ctx, "error checking Object Lock", "error", err)
+			return err
+		}
+
+		if objectLockOutput == common.OutputJSON {
+			if pErr := common.PrintJSON(report); pErr != nil {
+				return pErr
+			}
+		} else if len(report.Unprotected) == 0 && err == nil {
+			slog.InfoContext(ctx, "Every backup is protected by Object Lock", "backups", report.Backups, "objects", report.Objects)
+		} else if len(report.Unprotected) > 0 {
+			t := table.NewWriter()
+			t.SetOutputMirror(common.Stdout())
+			t.AppendHeader(table.Row{"Backup Key", "Object", "Problem"})
+			for _, u := range report.Unprotected {
+				t.AppendRow(table.Row{u.Backup, u.Object, u.Error})
+			}
+			t.AppendFooter(table.Row{fmt.Sprintf("%d backups, %d objects checked, %d unprotected",
+				report.Backups, report.Objects, len(report.Unprotected))})
+			t.Render()
+		}
+		return err
+	},
+}
+
+func init() {
+	objectLockCmd.Flags().StringVarP(&objectLockOutput, "output", "o", common.OutputTable, "Output format (table, json)")
+}
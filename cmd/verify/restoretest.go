@@ -0,0 +1,93 @@
+package verify
+
+import (
+	"errors"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+// passphraseEnv is read when --passphrase is not given, as for `backup restore`.
+const passphraseEnv = "ARCLIFT_GPG_PASSPHRASE"
+
+var (
+	restoreTestOpts   backup.RestoreTestOptions
+	restoreTestOutput string
+)
+
+// restoreTestCmd represents the restore-test command.
+var restoreTestCmd = &cobra.Command{
+	Use:   "restore-test [backup]",
+	Short: "Restore a backup into a temporary directory and validate it",
+	Long: "Restore the newest backup, or the given one as listed by `backup list`, into a temporary directory, check " +
+		"that every file of its file index was restored with its size and recorded checksum, then remove it. " +
+		"Exits non-zero when a file did not restore or validate.",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		if err := common.CheckOutput(restoreTestOutput, common.OutputTable, common.OutputJSON); err != nil {
+			return err
+		}
+
+		opts := restoreTestOpts
+		if len(args) > 0 {
+			opts.Backup = args[0]
+		}
+		if opts.Passphrase == "" {
+			opts.Passphrase = os.Getenv(passphraseEnv)
+		}
+
+		report, err := bm.RestoreTest(ctx, opts)
+		if err != nil && !errors.Is(err, backup.ErrRestoreTestFailed) {
+			slog.ErrorContext(ctx, "error testing restore", "error", err)
+			return err
+		}
+
+		if restoreTestOutput == common.OutputJSON {
+			if pErr := common.PrintJSON(report); pErr != nil {
+				return pErr
+			}
+			return err
+		}
+
+		t := table.NewWriter()
+		t.SetOutputMirror(common.Stdout())
+		t.AppendRows([]table.Row{
+			{"Backup", report.Backup},
+			{"Passed", report.Passed},
+			{"Duration", report.Duration.Round(time.Second)},
+			{"Expected Files", report.Expected},
+			{"Restored Files", report.Restored},
+			{"Verified Files", report.Verified},
+			{"Problems", len(report.Problems)},
+		})
+		t.Render()
+
+		if len(report.Problems) > 0 {
+			pt := table.NewWriter()
+			pt.SetOutputMirror(common.Stdout())
+			pt.AppendHeader(table.Row{"Path", "Problem"})
+			for _, path := range slices.Sorted(maps.Keys(report.Problems)) {
+				pt.AppendRow(table.Row{path, report.Problems[path]})
+			}
+			pt.Render()
+		}
+		return err
+	},
+}
+
+func init() {
+	restoreTestCmd.Flags().StringVar(&restoreTestOpts.PrivateKey, "private-key", "", "Path to the armored GPG private key for encrypted backups")
+	restoreTestCmd.Flags().StringVar(&restoreTestOpts.Passphrase, "passphrase", "", "Passphrase of the private key (defaults to $"+passphraseEnv+")")
+	restoreTestCmd.Flags().BoolVar(&restoreTestOpts.Notify, "notify", false, "Send the result to the configured notifiers")
+	restoreTestCmd.Flags().StringVarP(&restoreTestOutput, "output", "o", common.OutputTable, "Output format (table, json)")
+}
@@ -0,0 +1,65 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scrubSample int
+	scrubOutput string
+)
+
+// scrubCmd represents the scrub command.
+var scrubCmd = &cobra.Command{
+	Use:   "scrub",
+	Short: "Check stored backups against their checksums",
+	Long: "Download the objects of every retained backup, or of a random sample of them, and compare them with the " +
+		"checksums recorded when they were uploaded, to find corruption before a restore needs them. " +
+		"Exits non-zero when corrupted objects are found.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		if err := common.CheckOutput(scrubOutput, common.OutputTable, common.OutputJSON); err != nil {
+			return err
+		}
+
+		report, err := bm.Verify(ctx, scrubSample)
+		if err != nil && !errors.Is(err, backup.ErrCorrupted) {
+			slog.ErrorContext(ctx, "error verifying backups", "error", err)
+			return err
+		}
+
+		if scrubOutput == common.OutputJSON {
+			if pErr := common.PrintJSON(report); pErr != nil {
+				return pErr
+			}
+		} else if len(report.Corrupted) == 0 {
+			slog.InfoContext(ctx, "No corrupted objects found",
+				"backups", report.Backups, "objects", report.Objects, "unverified", report.Unverified)
+		} else {
+			t := table.NewWriter()
+			t.SetOutputMirror(common.Stdout())
+			t.AppendHeader(table.Row{"Backup Key", "Object", "Error"})
+			for _, c := range report.Corrupted {
+				t.AppendRow(table.Row{c.Backup, c.Object, c.Error})
+			}
+			t.AppendFooter(table.Row{fmt.Sprintf("%d backups, %d objects verified, %d unverified, %d corrupted",
+				report.Backups, report.Objects, report.Unverified, len(report.Corrupted))})
+			t.Render()
+		}
+		return err
+	},
+}
+
+func init() {
+	scrubCmd.Flags().IntVarP(&scrubSample, "sample", "s", 0, "Number of backups to verify, picked at random, 0 for all")
+	scrubCmd.Flags().StringVarP(&scrubOutput, "output", "o", common.OutputTable, "Output format (table, json)")
+}
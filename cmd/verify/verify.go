@@ -0,0 +1,33 @@
+package verify
+
+import (
+	"github.com/hibare/arclift/cmd/common"
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bm  backup.BackupManagerIface
+	job string
+)
+
+// VerifyCmd groups the commands checking that stored backups can be relied on.
+var VerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that stored backups are intact and restorable",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		common.LogToStderrForOutput(cmd)
+		configPath := cmd.Root().PersistentFlags().Lookup("config").Value.String()
+		bm, err = common.NewBackupManager(cmd.Context(), configPath, job, "")
+		return err
+	},
+}
+
+func init() {
+	VerifyCmd.PersistentFlags().StringVarP(&job, "job", "j", "", "Only operate on the named job (default: every job)")
+
+	VerifyCmd.AddCommand(scrubCmd)
+	VerifyCmd.AddCommand(restoreTestCmd)
+	VerifyCmd.AddCommand(objectLockCmd)
+}
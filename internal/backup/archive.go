@@ -0,0 +1,328 @@
+package backup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	commonGPG "github.com/hibare/GoCommon/v2/pkg/crypto/gpg"
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/walk"
+)
+
+// wipeBufferSize is the size of the zero buffer used to overwrite files before removing them.
+const wipeBufferSize = 64 * 1024
+
+// archiveResult is the outcome of archiving a directory.
+type archiveResult struct {
+	ArchivePath  string
+	TotalFiles   int
+	TotalDirs    int
+	SuccessFiles int
+	FailedFiles  map[string]error
+	Skipped      walk.Report
+}
+
+// encryptWriter returns a writer encrypting to recipients in the armored format read by GPG.DecryptFile.
+// Closing it flushes the encryption and the armor, but not w.
+func encryptWriter(w io.Writer, recipients openpgp.EntityList) (io.WriteCloser, error) {
+	armored, err := armor.Encode(w, commonGPG.GPGEncodeBlockType, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create armored output: %w", ErrEncryptionFailed, err)
+	}
+
+	encrypted, err := openpgp.Encrypt(armored, recipients, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to initialize encryption: %w", ErrEncryptionFailed, err)
+	}
+	return &chainCloser{Writer: encrypted, closers: []io.Closer{encrypted, armored}}, nil
+}
+
+// chainCloser closes its closers in order.
+type chainCloser struct {
+	io.Writer
+	closers []io.Closer
+}
+
+func (c *chainCloser) Close() error {
+	for _, closer := range c.closers {
+		if err := closer.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveWriter writes the entries of an archive.
+type archiveWriter interface {
+	// Dir records the directory rel with the metadata of info.
+	Dir(rel string, info fs.FileInfo) error
+
+	// Special records the special file rel, which has no content, with the metadata of info.
+	Special(rel string, info fs.FileInfo) error
+
+	// File writes the regular file rel with the content of r. info is nil when the file could not be stat'ed.
+	File(rel string, info fs.FileInfo, r io.Reader) error
+
+	// Close finalizes the archive, but does not close the underlying writer.
+	Close() error
+}
+
+// zipArchive writes zip archives, see config.ArchiveFormatZip.
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchive) Dir(rel string, info fs.FileInfo) error {
+	_, err := a.zw.CreateHeader(zipHeader(rel+"/", info))
+	return err
+}
+
+func (a *zipArchive) Special(rel string, info fs.FileInfo) error {
+	zh := zipHeader(rel, info)
+	zh.Method = zip.Store
+	_, err := a.zw.CreateHeader(zh)
+	return err
+}
+
+func (a *zipArchive) File(rel string, info fs.FileInfo, r io.Reader) error {
+	zh := &zip.FileHeader{Name: rel, Method: zip.Deflate}
+	if info != nil {
+		zh = zipHeader(rel, info)
+	}
+	w, err := a.zw.CreateHeader(zh)
+	if err != nil {
+		return fmt.Errorf("failed to create zip header: %w", err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("failed to copy file to zip: %w", err)
+	}
+	return nil
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
+
+// tarArchive writes PAX tar archives, see config.ArchiveFormatTar.
+type tarArchive struct {
+	tw *tar.Writer
+}
+
+// tarHeader returns the PAX header of the tar entry rel recording the metadata of info.
+func tarHeader(rel string, info fs.FileInfo) (*tar.Header, error) {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = rel
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	// PAX keeps the modification time to the nanosecond; access and change times are not restored.
+	hdr.Format = tar.FormatPAX
+	hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+	return hdr, nil
+}
+
+func (a *tarArchive) Dir(rel string, info fs.FileInfo) error {
+	return a.Special(rel, info)
+}
+
+func (a *tarArchive) Special(rel string, info fs.FileInfo) error {
+	hdr, err := tarHeader(rel, info)
+	if err != nil {
+		return fmt.Errorf("failed to create tar header: %w", err)
+	}
+	return a.tw.WriteHeader(hdr)
+}
+
+func (a *tarArchive) File(rel string, info fs.FileInfo, r io.Reader) error {
+	if info == nil {
+		return errors.New("failed to create tar header: file size unknown")
+	}
+	hdr, err := tarHeader(rel, info)
+	if err != nil {
+		return fmt.Errorf("failed to create tar header: %w", err)
+	}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write tar header: %w", err)
+	}
+
+	// The entry holds the size the file had when it was stat'ed: a file that grew is cut, and one that shrank is
+	// padded with zeros, so the archive stays readable.
+	n, err := io.CopyN(a.tw, r, hdr.Size)
+	if n < hdr.Size {
+		if _, pErr := io.CopyN(a.tw, zeroReader{}, hdr.Size-n); pErr != nil {
+			return fmt.Errorf("failed to copy file to tar: %w", pErr)
+		}
+		if err == nil || errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("failed to copy file to tar, it shrank while it was archived: %w", err)
+	}
+	return nil
+}
+
+func (a *tarArchive) Close() error {
+	return a.tw.Close()
+}
+
+// zeroReader reads zeros.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// archiveExtension returns the extension of the archives of format, encrypted or not.
+func archiveExtension(format string, encrypted bool) string {
+	ext := archiveExt
+	if format == config.ArchiveFormatTar {
+		ext = tarArchiveExt
+	}
+	if encrypted {
+		ext += encryptedExt
+	}
+	return ext
+}
+
+// archiveDir archives dir into the file name of workDir, in the given format. Entries that cannot be archived are
+// skipped and reported. When recipients are given the archive is encrypted while it is written, so no plaintext
+// reaches the disk.
+func archiveDir(
+	ctx context.Context, dir, workDir, name, format string, opts walk.Options, recipients openpgp.EntityList,
+) (archiveResult, error) {
+	dir = filepath.Clean(dir)
+	res := archiveResult{
+		ArchivePath: filepath.Join(workDir, name),
+		FailedFiles: map[string]error{},
+	}
+
+	archiveFile, err := os.OpenFile(res.ArchivePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return res, fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer func() {
+		_ = archiveFile.Close()
+	}()
+
+	var out io.WriteCloser = archiveFile
+	if recipients != nil {
+		if out, err = encryptWriter(archiveFile, recipients); err != nil {
+			return res, err
+		}
+	}
+
+	var archive archiveWriter
+	if format == config.ArchiveFormatTar {
+		gz := gzip.NewWriter(out)
+		out = &chainCloser{Writer: gz, closers: []io.Closer{gz, out}}
+		archive = &tarArchive{tw: tar.NewWriter(gz)}
+	} else {
+		archive = &zipArchive{zw: zip.NewWriter(out)}
+	}
+
+	if opts.RecordSpecial {
+		// Special files have no content; their entries only carry their type and metadata.
+		visit := opts.Visit
+		opts.Visit = func(path, rel string, info fs.FileInfo) {
+			if visit != nil {
+				visit(path, rel, info)
+			}
+			if info.IsDir() || info.Mode().IsRegular() {
+				return
+			}
+			if aErr := archive.Special(rel, info); aErr != nil {
+				res.FailedFiles[path] = fmt.Errorf("failed to archive special file: %w", aErr)
+			}
+		}
+	}
+
+	res.Skipped, err = walk.Dir(ctx, dir, opts, func(path, rel string, d fs.DirEntry, f *os.File) error {
+		if f == nil {
+			res.TotalDirs++
+			// The directory entries carry the metadata of the directories; the root is the archive itself.
+			if info, iErr := d.Info(); iErr == nil && rel != "." {
+				if aErr := archive.Dir(rel, info); aErr != nil {
+					res.FailedFiles[path] = fmt.Errorf("failed to archive directory: %w", aErr)
+				}
+			}
+			return nil
+		}
+		res.TotalFiles++
+
+		info, _ := f.Stat()
+		if aErr := archive.File(rel, info, f); aErr != nil {
+			res.FailedFiles[path] = aErr
+			return nil
+		}
+
+		res.SuccessFiles++
+		return nil
+	})
+	if err != nil {
+		_ = archive.Close()
+		return res, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return res, fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return res, fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	return res, nil
+}
+
+// wipeFile overwrites the file at path with zeros before removing it, so its plaintext does not linger in free
+// blocks. This is best effort: copy-on-write filesystems and SSD wear levelling may keep older copies.
+func wipeFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err == nil {
+		zeros := make([]byte, wipeBufferSize)
+		for remaining := info.Size(); remaining > 0 && err == nil; {
+			n := min(remaining, int64(len(zeros)))
+			_, err = f.Write(zeros[:n])
+			remaining -= n
+		}
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// wipeDir wipes every regular file under dir and removes dir.
+func wipeDir(dir string) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		return wipeFile(path)
+	})
+	if rErr := os.RemoveAll(dir); err == nil {
+		err = rErr
+	}
+	return err
+}
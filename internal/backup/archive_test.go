@@ -0,0 +1,154 @@
+package backup
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/walk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFileInfo describes a regular file that does not exist on disk.
+type fakeFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i fakeFileInfo) Name() string       { return i.name }
+func (i fakeFileInfo) Size() int64        { return i.size }
+func (i fakeFileInfo) Mode() fs.FileMode  { return 0o640 }
+func (i fakeFileInfo) ModTime() time.Time { return i.modTime }
+func (i fakeFileInfo) IsDir() bool        { return false }
+func (i fakeFileInfo) Sys() any           { return nil }
+
+// onlyReader and onlyWriter hide the ReadFrom and WriteTo methods of their field, so copies use the given buffer.
+type (
+	onlyReader struct{ io.Reader }
+	onlyWriter struct{ io.Writer }
+)
+
+func TestTarArchive_LargeFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("streams 8GiB")
+	}
+
+	// Over the 8GiB limit of the ustar size field, so only a PAX header records the size.
+	const size = 8<<30 + 1
+	modTime := time.Date(2024, 1, 31, 13, 4, 5, 123456789, time.UTC)
+
+	pr, pw := io.Pipe()
+	go func() {
+		a := &tarArchive{tw: tar.NewWriter(pw)}
+		err := a.File("vm.img", fakeFileInfo{name: "vm.img", size: size, modTime: modTime}, io.LimitReader(zeroReader{}, size))
+		if err == nil {
+			err = a.File("after", fakeFileInfo{name: "after", size: 5, modTime: modTime}, onlyReader{Reader: io.LimitReader(zeroReader{}, 5)})
+		}
+		if err == nil {
+			err = a.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	var entries []archiveEntry
+	sizes := map[string]int64{}
+	buf := make([]byte, 1<<20)
+	err := tarEntries(pr, func(e archiveEntry) error {
+		rc, err := e.open()
+		if err != nil {
+			return err
+		}
+		n, err := io.CopyBuffer(onlyWriter{Writer: io.Discard}, onlyReader{Reader: rc}, buf)
+		entries = append(entries, e)
+		sizes[e.name] = n
+		return err
+	})
+	require.NoError(t, err)
+
+	require.Len(t, entries, 2)
+	assert.Equal(t, "vm.img", entries[0].name)
+	assert.Equal(t, int64(size), sizes["vm.img"])
+	assert.True(t, entries[0].meta.ModTime.Equal(modTime), "modification time kept to the nanosecond")
+	assert.Equal(t, fs.FileMode(0o640), entries[0].meta.Mode)
+	assert.Equal(t, int64(5), sizes["after"])
+}
+
+func TestTarArchive_ShrunkFile(t *testing.T) {
+	pr, pw := io.Pipe()
+	var fileErr error
+	go func() {
+		a := &tarArchive{tw: tar.NewWriter(pw)}
+		fileErr = a.File("log", fakeFileInfo{name: "log", size: 10}, io.LimitReader(zeroReader{}, 4))
+		pw.CloseWithError(a.Close())
+	}()
+
+	var n int64
+	err := tarEntries(pr, func(e archiveEntry) error {
+		rc, err := e.open()
+		if err == nil {
+			n, err = io.Copy(io.Discard, rc)
+		}
+		return err
+	})
+	require.NoError(t, err, "the archive stays readable")
+	require.ErrorIs(t, fileErr, io.ErrUnexpectedEOF)
+	assert.Equal(t, int64(10), n)
+}
+
+func TestArchiveDir_RoundTrip(t *testing.T) {
+	for _, format := range []string{config.ArchiveFormatZip, config.ArchiveFormatTar} {
+		t.Run(format, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "data")
+			require.NoError(t, os.MkdirAll(filepath.Join(src, "nested"), 0o750))
+			require.NoError(t, os.WriteFile(filepath.Join(src, "a.txt"), []byte("alpha"), 0o600))
+			require.NoError(t, os.WriteFile(filepath.Join(src, "nested", "b.txt"), []byte("beta"), 0o640))
+			modTime := time.Date(2024, 1, 31, 13, 4, 5, 123456700, time.UTC)
+			require.NoError(t, os.Chtimes(filepath.Join(src, "nested", "b.txt"), modTime, modTime))
+
+			res, err := archiveDir(t.Context(), src, t.TempDir(), "data"+archiveExtension(format, false), format, walk.Options{}, nil)
+			require.NoError(t, err)
+			assert.Equal(t, 2, res.SuccessFiles)
+			assert.Empty(t, res.FailedFiles)
+
+			gotFormat, base, ok := archiveFormatOf(filepath.Base(res.ArchivePath))
+			require.True(t, ok)
+			assert.Equal(t, format, gotFormat)
+			assert.Equal(t, "data", base)
+
+			target := filepath.Join(t.TempDir(), base)
+			summary := RestoreSummary{Errors: map[string]string{}}
+			b := &BackupManager{}
+			require.NoError(t, b.extractArchive(t.Context(), res.ArchivePath, format, target, RestoreOptions{}, &summary))
+			assert.Equal(t, 2, summary.Restored)
+			assert.Empty(t, summary.Errors)
+
+			data, err := os.ReadFile(filepath.Join(target, "a.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "alpha", string(data))
+
+			info, err := os.Stat(filepath.Join(target, "nested", "b.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, int64(4), info.Size())
+			assert.Equal(t, fs.FileMode(0o640), info.Mode().Perm())
+			assert.True(t, info.ModTime().Equal(modTime), "modification time %s, expected %s", info.ModTime(), modTime)
+		})
+	}
+}
+
+func TestBackupManager_Backup_ArchiveNameTemplate(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.txt": "alpha"})
+	b, mem := newTestManager(t, []string{dir}, "archive-dirs: true\narchive-format: tar\narchive-name-template: \"{hostname}-{dir}\"")
+	require.NoError(t, b.Backup(t.Context()))
+
+	keys, err := b.ListBackups(t.Context())
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Contains(t, mem.keys(), "backups/host/"+keys[0]+"/host-data.tar.gz")
+}
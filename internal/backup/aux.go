@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/hibare/arclift/internal/naming"
+)
+
+// auxDir is the host level directory holding auxiliary objects such as manifests, reports, checksums and
+// heartbeats. Objects under auxDir/<backup key>/ belong to that backup and are purged with it; objects directly
+// under auxDir belong to the host.
+const auxDir = ".arclift"
+
+// hostsDir is the directory of auxDir holding the host level objects of each host, when the backups of several hosts
+// share the directory of the key layout.
+const hostsDir = "hosts"
+
+// auxKey returns the key, relative to the host prefix, of the auxiliary objects of a backup.
+func auxKey(key string) string {
+	return auxDir + "/" + key
+}
+
+// hostAuxDir returns the directory, relative to the host prefix, of the host level auxiliary objects such as the
+// lease: auxDir, or a directory of the host below it when other hosts store their backups alongside, see
+// naming.KeyLayout.Shared.
+func (b *BackupManager) hostAuxDir() string {
+	if !b.keyLayout().Shared() {
+		return auxDir
+	}
+	return auxDir + "/" + hostsDir + "/" + naming.SanitizeSegment(b.cfg.Backup.KeyHostname())
+}
+
+// keyLayout returns the key layout of the backups. The config was validated, so it parses.
+func (b *BackupManager) keyLayout() naming.KeyLayout {
+	b.keysOnce.Do(func() {
+		b.keys, _ = b.cfg.KeyLayout()
+	})
+	return b.keys
+}
+
+// splitKey splits rel, relative to the host prefix, into the key of the backup it is under and the rest, such as
+// the name of a dir in the backup, see naming.KeyLayout.Cut.
+func (b *BackupManager) splitKey(rel string) (key, rest string, nested bool) {
+	return b.keyLayout().Cut(rel)
+}
+
+// splitAuxKey returns the backup key an auxiliary object belongs to, or "" for host level objects and those of
+// backups not named after the key layout, such as the backups of other hosts sharing its directory.
+// rel is relative to the host prefix. ok is false when rel is not an auxiliary object.
+func (b *BackupManager) splitAuxKey(rel string) (string, bool) {
+	rest, ok := strings.CutPrefix(rel, auxDir+"/")
+	if !ok {
+		return "", false
+	}
+	key, _, nested := b.splitKey(rest)
+	if !nested {
+		return "", true
+	}
+	if _, ok := b.keyLayout().Parse(key); !ok {
+		return "", true
+	}
+	return key, true
+}
+
+// deleteBackup deletes a backup together with its auxiliary objects.
+func (b *BackupManager) deleteBackup(ctx context.Context, key string) error {
+	if legacy, legacyKey, ok := b.legacyBackup(key); ok {
+		return legacy.deleteBackup(ctx, legacyKey)
+	}
+	if err := b.store.Delete(ctx, key); err != nil {
+		return err
+	}
+	if err := b.store.Delete(ctx, auxKey(key)); err != nil {
+		slog.WarnContext(ctx, "Error deleting auxiliary objects", "key", key, "error", err)
+		return err
+	}
+	return nil
+}
+
+// ConsistencyReport lists the inconsistencies found between backups and their auxiliary objects.
+type ConsistencyReport struct {
+	// Backups is the number of backups found.
+	Backups int `json:"backups"`
+
+	// OrphanedAux are the auxiliary objects whose backup no longer exists.
+	OrphanedAux []string `json:"orphaned_aux"`
+
+	// Deleted reports whether the orphaned objects were deleted.
+	Deleted bool `json:"deleted"`
+}
+
+// CheckConsistency finds auxiliary objects that belong to backups that no longer exist and, when fix is set,
+// deletes them.
+func (b *BackupManager) CheckConsistency(ctx context.Context, fix bool) (ConsistencyReport, error) {
+	report := ConsistencyReport{OrphanedAux: []string{}}
+
+	keys, err := b.ListBackups(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.Backups = len(keys)
+
+	objects, err := b.store.ListObjects(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing objects", "error", err)
+		return report, err
+	}
+
+	var orphanedKeys []string
+	for _, obj := range objects {
+		rel := b.store.TrimPrefix([]string{obj.Key})[0]
+		key, ok := b.splitAuxKey(rel)
+		if !ok || key == "" || slices.Contains(keys, key) {
+			continue
+		}
+
+		slog.WarnContext(ctx, "Found orphaned auxiliary object", "key", obj.Key, "backup", key)
+		report.OrphanedAux = append(report.OrphanedAux, obj.Key)
+		if !slices.Contains(orphanedKeys, key) {
+			orphanedKeys = append(orphanedKeys, key)
+		}
+	}
+
+	if !fix || len(orphanedKeys) == 0 {
+		return report, nil
+	}
+
+	for _, key := range orphanedKeys {
+		slog.InfoContext(ctx, "Deleting orphaned auxiliary objects", "backup", key)
+		if err := b.store.Delete(ctx, auxKey(key)); err != nil {
+			slog.ErrorContext(ctx, "Error deleting orphaned auxiliary objects", "backup", key, "error", err)
+			return report, err
+		}
+	}
+	report.Deleted = true
+	return report, nil
+}
@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupManager_SharedDir(t *testing.T) {
+	const sharedTemplate = `key-template: "{prefix}/{hostname}-{timestamp}"`
+	mem := &memStore{objects: map[string][]byte{}, putting: make(chan struct{}, 1)}
+	dir := writeTree(t, map[string]string{"a.txt": "alpha"})
+	b := newHostManager(t, mem, "host", []string{dir}, sharedTemplate)
+	other := newHostManager(t, mem, "other", []string{writeTree(t, map[string]string{"b.txt": "beta, longer"})}, sharedTemplate)
+
+	require.NoError(t, other.Backup(t.Context()))
+	require.NoError(t, b.Backup(t.Context()))
+	var otherKeys []string
+	for _, key := range mem.keys() {
+		if strings.Contains(key, "other-") {
+			otherKeys = append(otherKeys, key)
+		}
+	}
+	require.NotEmpty(t, otherKeys)
+
+	keys, err := b.ListBackups(t.Context())
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.True(t, strings.HasPrefix(keys[0], "host-"), keys[0])
+
+	// The quota only counts the backups of the host, even among objects listed unfiltered.
+	objects, err := mem.List(t.Context(), "backups/")
+	require.NoError(t, err)
+	usage := b.usageByBackup(objects, "")
+	require.Len(t, usage, 1)
+	assert.Equal(t, keys[0], usage[0].timestamp)
+	assert.Equal(t, int64(len("alpha")), usage[0].size)
+
+	// The auxiliary objects of the other host are not orphans of this one.
+	report, err := b.CheckConsistency(t.Context(), true)
+	require.NoError(t, err)
+	assert.Empty(t, report.OrphanedAux)
+	for _, key := range otherKeys {
+		assert.Contains(t, mem.keys(), key)
+	}
+
+	// Each host takes its own lease.
+	assert.Equal(t, ".arclift/hosts/host", b.hostAuxDir())
+	assert.Equal(t, ".arclift/hosts/other", other.hostAuxDir())
+	single, _ := newTestManager(t, []string{dir}, "")
+	assert.Equal(t, auxDir, single.hostAuxDir())
+}
+
+func TestBackupManager_CheckConsistency(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.txt": "alpha"})
+	b, mem := newTestManager(t, []string{dir}, "")
+	require.NoError(t, b.Backup(t.Context()))
+	keys, err := b.ListBackups(t.Context())
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+
+	var live []string
+	for _, key := range mem.keys() {
+		if strings.HasPrefix(key, "backups/host/.arclift/"+keys[0]+"/") {
+			live = append(live, key)
+		}
+	}
+	require.NotEmpty(t, live, "the backup has auxiliary objects")
+
+	orphans := []string{
+		"backups/host/.arclift/20200101000000/run-report.json",
+		"backups/host/.arclift/20200101000000/checksums/data.json",
+	}
+	for _, key := range append([]string{"backups/host/.arclift/lease"}, orphans...) {
+		mem.objects[key] = []byte("{}")
+	}
+
+	report, err := b.CheckConsistency(t.Context(), false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Backups)
+	assert.ElementsMatch(t, orphans, report.OrphanedAux)
+	assert.False(t, report.Deleted)
+	assert.Subset(t, mem.keys(), orphans, "reported without fix, not deleted")
+
+	report, err = b.CheckConsistency(t.Context(), true)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, orphans, report.OrphanedAux)
+	assert.True(t, report.Deleted)
+	stored := mem.keys()
+	for _, key := range orphans {
+		assert.NotContains(t, stored, key)
+	}
+	assert.Subset(t, stored, live, "auxiliary objects of the live backup deleted")
+	assert.Contains(t, stored, "backups/host/.arclift/lease", "host level object deleted")
+
+	report, err = b.CheckConsistency(t.Context(), true)
+	require.NoError(t, err)
+	assert.Empty(t, report.OrphanedAux)
+	assert.False(t, report.Deleted)
+}
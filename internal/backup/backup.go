@@ -3,27 +3,105 @@ package backup
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	commonGPG "github.com/hibare/GoCommon/v2/pkg/crypto/gpg"
-	"github.com/hibare/GoCommon/v2/pkg/datetime"
-	commonFiles "github.com/hibare/GoCommon/v2/pkg/file"
 	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/lock"
+	"github.com/hibare/arclift/internal/naming"
 	"github.com/hibare/arclift/internal/notifiers"
+	"github.com/hibare/arclift/internal/state"
 	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/walk"
 )
 
 var (
 	// ErrNoProcessableFiles is returned when no processable files are found.
 	ErrNoProcessableFiles = errors.New("no processable files")
+
+	// ErrColdCopyFailed is returned when a backup could not be copied to the cold tier.
+	ErrColdCopyFailed = errors.New("failed to copy backup to cold tier")
+
+	// ErrInterrupted is returned when a backup is stopped before it completed because its context was cancelled.
+	ErrInterrupted = errors.New("backup interrupted")
+
+	// ErrEncryptionFailed is returned when a backup could not be encrypted, such as when the GPG key could not be
+	// fetched or was rejected.
+	ErrEncryptionFailed = errors.New("encryption failed")
+
+	// ErrTimeout is returned when a backup run, or the backup of a dir, exceeded its timeout.
+	ErrTimeout = errors.New("backup timed out")
 )
 
+// BackupError is returned by a strict backup run, see config.BackupConfig.Strict, in which dirs failed to back up.
+type BackupError struct {
+	// Errors are the errors of the failed dirs, by dir.
+	Errors map[string]string
+
+	// Partial is set when other dirs were backed up, or found unchanged.
+	Partial bool
+}
+
+func (e *BackupError) Error() string {
+	outcome := "failed"
+	if e.Partial {
+		outcome = "partially failed"
+	}
+	failures := make([]string, 0, len(e.Errors))
+	for _, dir := range slices.Sorted(maps.Keys(e.Errors)) {
+		failures = append(failures, dir+": "+e.Errors[dir])
+	}
+	return fmt.Sprintf("backup %s, %d dirs failed: %s", outcome, len(e.Errors), strings.Join(failures, "; "))
+}
+
+// ExitCode returns the process exit code for the backup outcome.
+func (e *BackupError) ExitCode() int {
+	if e.Partial {
+		return constants.ExitCodeBackupPartial
+	}
+	return constants.ExitCodeFailure
+}
+
 // BackupManagerIface defines the interface for the backup manager.
 type BackupManagerIface interface {
 	Backup(ctx context.Context) error
+	BackupPaths(ctx context.Context, paths []string) error
 	PurgeOldBackups(ctx context.Context) error
 	ListBackups(ctx context.Context) ([]string, error)
+	ListBackupDetails(ctx context.Context) ([]BackupInfo, error)
+	Restore(ctx context.Context, opts RestoreOptions) (RestoreSummary, error)
+	CheckConsistency(ctx context.Context, fix bool) (ConsistencyReport, error)
+	Stream(ctx context.Context, name string, r io.Reader) error
+	MigrateKeys(ctx context.Context, layout string, dryRun bool) ([]KeyMigration, error)
+	Search(ctx context.Context, pattern string) ([]SearchMatch, error)
+	Verify(ctx context.Context, sample int) (VerifyReport, error)
+	VerifyImmutability(ctx context.Context) (ImmutabilityReport, error)
+	RestoreTest(ctx context.Context, opts RestoreTestOptions) (RestoreTestReport, error)
+	AddListener(l Listener)
+}
+
+// BackupInfo describes a stored backup.
+type BackupInfo struct {
+	Key       string            `json:"key"`
+	CreatedAt time.Time         `json:"created_at,omitzero"`
+	Size      int64             `json:"size"`
+	Objects   int               `json:"objects"`
+	Dirs      []string          `json:"dirs,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+
+	// Legacy is the name of the legacy layout the backup is stored with, "" for the current layout.
+	Legacy string `json:"legacy,omitempty"`
 }
 
 // BackupManager implements the BackupManagerIface.
@@ -32,24 +110,306 @@ type BackupManager struct {
 	store         storage.StorageIface
 	gpg           commonGPG.GPGIface
 	notifierStore notifiers.NotifierStoreIface
+	stateStore    state.StoreIface
+
+	// cold is the manager of the cold tier, nil when the cold tier is disabled.
+	cold *BackupManager
+
+	// legacy are the managers of the legacy layouts, see AddLegacyLayout.
+	legacy []legacyLayout
+
+	// listeners receive the events of the backups, see AddListener.
+	listenersMu sync.RWMutex
+	listeners   []Listener
+
+	// keys is the key layout of cfg, see keyLayout.
+	keysOnce sync.Once
+	keys     naming.KeyLayout
+}
+
+// SetColdStore enables the cold tier: every backup is copied to store, which is purged with the cold retention.
+func (b *BackupManager) SetColdStore(store storage.StorageIface) {
+	b.cold = &BackupManager{
+		cfg:           b.cfg.ColdTier(),
+		store:         store,
+		gpg:           b.gpg,
+		notifierStore: b.notifierStore,
+		stateStore:    b.stateStore,
+	}
 }
 
-func (b *BackupManager) unArchivedBackup(ctx context.Context, dir string) (storage.UploadDirResponse, error) {
+// copyToCold copies the backup at key to the cold tier.
+func (b *BackupManager) copyToCold(ctx context.Context, dir, key string) error {
+	slog.InfoContext(ctx, "Copying backup to cold tier", "dir", dir, "key", key, "storage", b.cold.store.Name())
+	if err := b.store.Copy(ctx, key, b.cold.store); err != nil {
+		slog.ErrorContext(ctx, "Error copying backup to cold tier", "dir", dir, "key", key, "error", err)
+		return fmt.Errorf("%w: %w", ErrColdCopyFailed, err)
+	}
+	return nil
+}
+
+// recordDir records the result of backing up dir in the state store and returns it.
+func (b *BackupManager) recordDir(ctx context.Context, dir string, resp storage.UploadDirResponse, bErr error, startedAt time.Time) state.DirRecord {
+	rec := state.DirRecord{
+		Dir:          dir,
+		Key:          resp.BaseKey,
+		Status:       state.StatusSuccess,
+		TotalDirs:    resp.TotalDirs,
+		TotalFiles:   resp.TotalFiles,
+		SuccessFiles: resp.SuccessFiles,
+		Bytes:        resp.Bytes,
+		Skipped:      resp.Skipped,
+		TooLarge:     resp.TooLarge,
+		Fingerprint:  resp.Fingerprint,
+		StartedAt:    startedAt,
+		FinishedAt:   time.Now(),
+	}
+	switch {
+	case errors.Is(bErr, ErrUnchanged):
+		rec.Status = state.StatusUnchanged
+	case bErr != nil:
+		rec.Status = state.StatusFailure
+		rec.Error = bErr.Error()
+	}
+
+	if err := b.stateStore.RecordDir(ctx, rec); err != nil {
+		slog.WarnContext(ctx, "Failed to record backup state", "dir", dir, "error", err)
+	}
+	return rec
+}
+
+// recordRun finalises run and appends it to the run journal.
+func (b *BackupManager) recordRun(ctx context.Context, run *state.RunRecord, rErr error) {
+	run.FinishedAt = time.Now()
+	switch {
+	case errors.Is(rErr, ErrInterrupted):
+		run.Status = state.StatusInterrupted
+		run.Error = rErr.Error()
+	case rErr != nil:
+		run.Status = state.StatusFailure
+		run.Error = rErr.Error()
+	case run.FailedDirs > 0 && run.FailedDirs == run.Dirs, run.Failed > 0 && run.Deleted == 0:
+		run.Status = state.StatusFailure
+	case run.FailedDirs > 0, run.Failed > 0, run.ColdFailed > 0:
+		run.Status = state.StatusPartial
+	default:
+		run.Status = state.StatusSuccess
+	}
+
+	if err := b.stateStore.RecordRun(ctx, *run); err != nil {
+		slog.WarnContext(ctx, "Failed to record run", "operation", run.Operation, "error", err)
+	}
+}
+
+// acquireLock acquires the lock of the host in the state dir or, when it cannot be created there, as when a user
+// other than root runs with the default state dir, in the user cache dir, and returns the func releasing it. When
+// neither can be created, it logs a warning and operations run without being serialized rather than not at all.
+func (b *BackupManager) acquireLock(ctx context.Context) (func(), error) {
+	paths := []string{b.cfg.State.LockPath()}
+	if cacheDir, cErr := os.UserCacheDir(); cErr == nil {
+		paths = append(paths, filepath.Join(cacheDir, constants.ProgramIdentifier, filepath.Base(paths[0])))
+	}
+
+	var err error
+	for _, path := range paths {
+		var l *lock.Lock
+		if l, err = lock.Acquire(ctx, path); err == nil {
+			return func() {
+				if rErr := l.Release(); rErr != nil {
+					slog.WarnContext(ctx, "Failed to release lock", "error", rErr)
+				}
+			}, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		slog.DebugContext(ctx, "Cannot create the lock", "lock", path, "error", err)
+	}
+
+	slog.WarnContext(ctx, "Cannot create the lock, running without serializing operations on this host", "error", err)
+	return func() {}, nil
+}
+
+// lock waits for other backups and purges on this host to finish, as they share temp files, and takes their place.
+// With the lease enabled it then takes the lease of the prefix, failing with storage.ErrLeaseHeld when another
+// instance holds it. The returned context is cancelled with storage.ErrLeaseLost when the lease is lost, and the
+// returned func releases both.
+func (b *BackupManager) lock(ctx context.Context) (context.Context, func(), error) {
+	unlock, err := b.acquireLock(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+
+	leaser, ok := b.store.(storage.Leaser)
+	if !b.cfg.Backup.Lease.Enabled || !ok {
+		return ctx, unlock, nil
+	}
+
+	lease, err := leaser.AcquireLease(ctx, b.hostAuxDir()+"/lease", b.cfg.Backup.Lease.TTL)
+	if err != nil {
+		unlock()
+		slog.WarnContext(ctx, "Skipping operation, another instance is running it", "job", b.cfg.Backup.Job, "error", err)
+		return nil, nil, err
+	}
+
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-lease.Lost():
+			cancel(storage.ErrLeaseLost)
+		case <-leaseCtx.Done():
+		}
+	}()
+	return leaseCtx, func() {
+		cancel(nil)
+		// Release the lease even when ctx is cancelled, so other instances do not wait for it to expire.
+		if rErr := lease.Release(context.WithoutCancel(ctx)); rErr != nil {
+			slog.WarnContext(ctx, "Failed to release lease", "error", rErr)
+		}
+		unlock()
+	}, nil
+}
+
+// archiveName renders the configured archive name template for dir.
+func (b *BackupManager) archiveName(dir string, now time.Time) string {
+	name := naming.Render(b.cfg.Backup.ArchiveNameTemplate, naming.Vars{
+		"dir":       filepath.Base(filepath.Clean(dir)),
+		"path":      naming.SanitizePath(dir),
+		"hostname":  b.cfg.Backup.Hostname,
+		"timestamp": now.In(b.cfg.Backup.Location()).Format(b.cfg.Backup.DateTimeLayout),
+		"label":     b.cfg.Backup.Label,
+	})
+	return naming.SanitizeSegment(name)
+}
+
+// unArchivedBackup uploads the files of dir, which are read from root: dir itself, or a local copy of a remote dir.
+// In sync mode, the newest backup of dir is updated instead, see syncBackup. The metadata of the files is stored with
+// the backup, as objects do not carry it.
+func (b *BackupManager) unArchivedBackup(ctx context.Context, dir, root string, opts walk.Options) (storage.UploadDirResponse, error) {
+	meta := map[string]fileMeta{}
+	opts.Visit = chainVisit(opts.Visit, collectMetadata(root, meta))
+
+	if p := storage.ProgressFrom(ctx); p != nil {
+		if size, sErr := walkedSize(ctx, root, opts); sErr == nil {
+			p.Expect(size)
+		}
+	}
+
+	if b.cfg.Backup.Sync.Enabled {
+		resp, synced, err := b.syncBackup(ctx, dir, root, opts)
+		if err != nil {
+			// The synced backup is kept: it holds the files of the previous runs.
+			slog.ErrorContext(ctx, "Error syncing directory", "dir", dir, "error", err)
+			return storage.UploadDirResponse{}, err
+		}
+		if synced {
+			if resp.BaseKey != "" {
+				b.storeMetadata(ctx, resp.BaseKey, meta)
+			}
+			return resp, nil
+		}
+	}
+
 	slog.InfoContext(ctx, "uploading directory", "dir", dir)
-	resp, err := b.store.UploadDir(ctx, dir)
+	resp, err := b.store.UploadDir(ctx, root, opts)
+	if err == nil && ctx.Err() != nil {
+		// The files failing as ctx is cancelled are only reported, so an upload interrupted on its last file ends
+		// without an error.
+		err = ctx.Err()
+	}
 	if err != nil {
 		slog.ErrorContext(ctx, "Error uploading directory", "dir", dir, "error", err)
+		if resp.BaseKey != "" {
+			b.deletePartial(ctx, resp.BaseKey)
+		}
 		return storage.UploadDirResponse{}, err
 	}
+	if resp.BaseKey != "" {
+		b.storeMetadata(ctx, resp.BaseKey, meta)
+	}
 	return resp, nil
 }
 
-func (b *BackupManager) archivedBackup(ctx context.Context, dir string) (storage.UploadDirResponse, error) {
+// deletePartial deletes the objects uploaded by a failed backup, so an interrupted or failed upload does not
+// count as a backup. It runs even when ctx is cancelled.
+func (b *BackupManager) deletePartial(ctx context.Context, baseKey string) {
+	key := b.store.TrimPrefix([]string{baseKey})[0]
+	slog.InfoContext(ctx, "Deleting partial upload", "key", baseKey)
+	if err := b.store.Delete(context.WithoutCancel(ctx), key); err != nil {
+		slog.WarnContext(ctx, "Error deleting partial upload", "key", baseKey, "error", err)
+	}
+}
+
+// encryptionRecipients fetches the configured GPG public key and returns the entities archives are encrypted to.
+// Failures are wrapped in ErrEncryptionFailed.
+func (b *BackupManager) encryptionRecipients(ctx context.Context) (openpgp.EntityList, error) {
+	recipients, err := b.readRecipients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEncryptionFailed, err)
+	}
+	return recipients, nil
+}
+
+// readRecipients fetches and checks the GPG key and returns its entities.
+func (b *BackupManager) readRecipients(ctx context.Context) (openpgp.EntityList, error) {
+	slog.InfoContext(ctx, "Fetching GPG key")
+	if err := b.fetchPublicKey(ctx); err != nil {
+		slog.ErrorContext(ctx, "Error fetching GPG key", "error", err)
+		return nil, err
+	}
+
+	publicKey, err := b.gpg.ReadPublicKeyFromFile()
+	if err != nil {
+		slog.ErrorContext(ctx, "Error reading GPG key", "error", err)
+		return nil, err
+	}
+
+	recipients, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error parsing GPG key", "error", err)
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, commonGPG.ErrNoEntitiesFoundInPublicKey
+	}
+	if err := checkFingerprint(recipients, b.cfg.Backup.Encryption.GPG.ExpectedFingerprint); err != nil {
+		slog.ErrorContext(ctx, "Refusing to encrypt to GPG key", "error", err)
+		return nil, err
+	}
+	return recipients, nil
+}
+
+// archivedBackup uploads an archive of dir, whose files are read from root: dir itself, or a local copy of a remote dir.
+func (b *BackupManager) archivedBackup(ctx context.Context, dir, root string, opts walk.Options) (storage.UploadDirResponse, error) {
 	var uploadPath string
 
-	slog.InfoContext(ctx, "Archiving dir", "dir", dir)
+	var recipients openpgp.EntityList
+	if b.cfg.Backup.Encryption.Enabled {
+		var err error
+		if recipients, err = b.encryptionRecipients(ctx); err != nil {
+			return storage.UploadDirResponse{}, err
+		}
+	}
+
+	if err := b.checkFreeSpace(ctx, dir, root, opts, recipients != nil); err != nil {
+		slog.ErrorContext(ctx, "Error checking free space", "dir", dir, "error", err)
+		return storage.UploadDirResponse{}, err
+	}
+
+	workDir, err := os.MkdirTemp("", stagingArchive)
+	if err != nil {
+		return storage.UploadDirResponse{}, err
+	}
+	defer func() {
+		_ = os.RemoveAll(workDir)
+	}()
+
+	slog.InfoContext(ctx, "Archiving dir", "dir", dir, "encrypted", recipients != nil)
 
-	archiveResp, err := commonFiles.ArchiveDir(dir, nil)
+	// The archive is written under its configured name, with the extension of its format.
+	ext := archiveExtension(b.cfg.Backup.ArchiveFormat, recipients != nil)
+	name := b.archiveName(dir, time.Now()) + ext
+	archiveResp, err := archiveDir(ctx, root, workDir, b.storedName(name, ext), b.cfg.Backup.ArchiveFormat, opts, recipients)
 	if err != nil {
 		slog.ErrorContext(ctx, "Error archiving dir", "dir", dir, "error", err)
 		return storage.UploadDirResponse{}, err
@@ -64,23 +424,18 @@ func (b *BackupManager) archivedBackup(ctx context.Context, dir string) (storage
 
 	slog.InfoContext(ctx, "Archived dir", "dir", dir, "archiveResp", archiveResp)
 
-	if b.cfg.Backup.Encryption.Enabled {
-		slog.InfoContext(ctx, "Fetching GPG key")
-		if _, gErr := b.gpg.FetchGPGPubKeyFromKeyServer(b.cfg.Backup.Encryption.GPG.KeyID, b.cfg.Backup.Encryption.GPG.KeyServer); gErr != nil {
-			slog.ErrorContext(ctx, "Error fetching GPG key", "error", gErr)
-			return storage.UploadDirResponse{}, gErr
-		}
+	var size int64
+	if info, sErr := os.Stat(uploadPath); sErr == nil {
+		size = info.Size()
+	}
 
-		slog.InfoContext(ctx, "Encrypting archive")
-		encryptedFilePath, eErr := b.gpg.EncryptFile(archiveResp.ArchivePath)
-		if eErr != nil {
-			slog.ErrorContext(ctx, "Error encrypting archive", "error", eErr)
-			return storage.UploadDirResponse{}, eErr
-		}
+	if p := storage.ProgressFrom(ctx); p != nil {
+		p.Expect(size)
+	}
 
-		uploadPath = encryptedFilePath
-		slog.InfoContext(ctx, "Encrypted archive", "uploadPath", uploadPath)
-		_ = os.Remove(archiveResp.ArchivePath)
+	sum, err := fileChecksum(uploadPath)
+	if err != nil {
+		return storage.UploadDirResponse{}, err
 	}
 
 	slog.InfoContext(ctx, "uploading file", "uploadPath", uploadPath, "storage", b.store.Name())
@@ -91,103 +446,548 @@ func (b *BackupManager) archivedBackup(ctx context.Context, dir string) (storage
 	}
 
 	slog.InfoContext(ctx, "Uploaded file", "uploadPath", uploadPath)
-	_ = os.Remove(uploadPath)
+	if b.cfg.Backup.OpaqueNames {
+		if err := b.storeManifest(ctx, resp, name, dir, recipients); err != nil {
+			slog.ErrorContext(ctx, "Error storing manifest", "dir", dir, "error", err)
+			b.deletePartial(ctx, resp)
+			return storage.UploadDirResponse{}, err
+		}
+	}
 	return storage.UploadDirResponse{
 		BaseKey:      resp,
 		TotalFiles:   archiveResp.TotalFiles,
 		TotalDirs:    archiveResp.TotalDirs,
 		SuccessFiles: archiveResp.SuccessFiles,
 		FailedFiles:  archiveResp.FailedFiles,
+		Bytes:        size,
+		Skipped:      archiveResp.Skipped,
+		Checksums:    map[string]string{resp: sum},
 	}, nil
 }
 
 // Backup performs a backup & sends notifications.
 func (b *BackupManager) Backup(ctx context.Context) error {
-	for _, dir := range b.cfg.Backup.Dirs {
-		slog.InfoContext(ctx, "Processing path", "path", dir)
+	return b.BackupPaths(ctx, nil)
+}
 
-		if b.cfg.Backup.ArchiveDirs {
-			backupResp, err := b.archivedBackup(ctx, dir)
-			if err != nil {
-				slog.ErrorContext(ctx, "Error backing up dir", "dir", dir, "error", err)
-				b.notifierStore.NotifyBackupFailure(ctx, dir, backupResp.TotalDirs, backupResp.TotalFiles, err)
-				continue
-			}
+// BackupPaths backs up the configured dirs and sources whose path is in paths, or all of them when paths is nil.
+func (b *BackupManager) BackupPaths(ctx context.Context, paths []string) error {
+	runCtx, unlock, err := b.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	b.cleanStaging(ctx)
+
+	if timeout := b.cfg.Backup.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeoutCause(runCtx, timeout, fmt.Errorf("%w: run exceeded %s", ErrTimeout, timeout))
+		defer cancel()
+	}
+
+	run := state.RunRecord{Operation: state.OperationBackup, Job: b.cfg.Backup.Job, StartedAt: time.Now()}
+	results, err := b.backup(runCtx, &run, paths)
+	if errors.Is(err, ErrInterrupted) {
+		b.notifierStore.NotifyBackupInterrupted(context.WithoutCancel(ctx), b.cfg.Backup.Job, run.Dirs, run.FailedDirs)
+	}
+	b.recordRun(ctx, &run, err)
+	b.addResult(ctx, run, results, nil)
+	keys := b.runKeys(results)
+	b.storeRunReport(context.WithoutCancel(ctx), run, results, keys)
+	b.storeConfig(context.WithoutCancel(ctx), keys)
+	if err == nil && b.cfg.Backup.Strict && run.FailedDirs > 0 {
+		return newBackupError(run, results)
+	}
+	return err
+}
 
-			slog.InfoContext(ctx, "Backed up dir", "dir", dir, "backupResp", backupResp)
-			b.notifierStore.NotifyBackupSuccess(ctx, dir, backupResp.TotalDirs, backupResp.TotalFiles, backupResp.SuccessFiles, backupResp.BaseKey)
+// newBackupError returns the error of a strict run in which dirs failed.
+func newBackupError(run state.RunRecord, results []state.DirRecord) *BackupError {
+	bErr := &BackupError{Errors: map[string]string{}, Partial: run.FailedDirs < run.Dirs}
+	for _, rec := range results {
+		if rec.Status == state.StatusFailure {
+			bErr.Errors[rec.Dir] = rec.Error
+		}
+	}
+	return bErr
+}
+
+// backup backs up the sources whose path is in paths, or all of them when paths is nil, and returns their results.
+// The job hooks run around the whole run; when the pre hook aborts, every source fails with its error.
+func (b *BackupManager) backup(ctx context.Context, run *state.RunRecord, paths []string) ([]state.DirRecord, error) {
+	blocked, err := b.enforceQuotas(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Backup blocked by quota", "error", err)
+		return nil, err
+	}
+
+	hooks := b.cfg.Backup.Hooks
+	hookErr := b.preHook(ctx, hooks, "")
+	results, err := b.backupSources(ctx, run, paths, blocked, hookErr)
+	b.postHook(ctx, hooks, "", err != nil || run.FailedDirs > 0)
+	return results, err
+}
+
+// backupSources backs up the sources whose path is in paths, or all of them when paths is nil, failing each with
+// hookErr when it is set.
+func (b *BackupManager) backupSources(
+	ctx context.Context, run *state.RunRecord, paths []string, blocked map[string]bool, hookErr error,
+) ([]state.DirRecord, error) {
+	var results []state.DirRecord
+	for _, src := range b.sources() {
+		dir := src.dir
+		if paths != nil && !slices.Contains(paths, dir) {
 			continue
 		}
+		if ctx.Err() != nil {
+			slog.WarnContext(ctx, "Backup interrupted", "job", b.cfg.Backup.Job, "cause", context.Cause(ctx))
+			return results, interrupted(ctx)
+		}
+		slog.InfoContext(ctx, "Processing path", "path", dir)
+		startedAt := time.Now()
+		b.emit(ctx, Event{Type: EventDirStarted, Dir: dir})
+
+		backupResp, err := storage.UploadDirResponse{}, hookErr
+		if hookErr == nil {
+			backupResp, err = b.backupSourceWithin(b.withUploadEvents(ctx, dir), src, blocked[dir])
+		}
 
-		backupResp, err := b.unArchivedBackup(ctx, dir)
+		rec := b.recordDir(ctx, dir, backupResp, err, startedAt)
+		results = append(results, rec)
+		b.emit(ctx, Event{Type: EventDirFinished, Dir: dir, Result: &rec})
+		run.Dirs++
+		run.Bytes += backupResp.Bytes
+		run.AddSkipped(backupResp.Skipped)
+
+		if errors.Is(err, ErrUnchanged) {
+			run.Unchanged++
+			b.notifierStore.NotifyBackupUnchanged(ctx, dir, backupResp.BaseKey)
+			continue
+		}
 		if err != nil {
+			run.FailedDirs++
 			slog.ErrorContext(ctx, "Error backing up dir", "dir", dir, "error", err)
+			if ctx.Err() != nil {
+				// Reported once for the whole run by the interruption notification.
+				continue
+			}
 			b.notifierStore.NotifyBackupFailure(ctx, dir, backupResp.TotalDirs, backupResp.TotalFiles, err)
 			continue
 		}
 
 		slog.InfoContext(ctx, "Backed up dir", "dir", dir, "backupResp", backupResp)
-		b.notifierStore.NotifyBackupSuccess(ctx, dir, backupResp.TotalDirs, backupResp.TotalFiles, backupResp.SuccessFiles, backupResp.BaseKey)
-		continue
+		if pErr := backupResp.Err(); pErr != nil {
+			slog.WarnContext(ctx, "Some files were not backed up", "dir", dir, "error", pErr)
+		}
+
+		if b.cold != nil {
+			if cErr := b.copyToCold(ctx, dir, backupResp.BaseKey); cErr != nil {
+				run.ColdFailed++
+				b.notifierStore.NotifyBackupFailure(ctx, dir, backupResp.TotalDirs, backupResp.TotalFiles, cErr)
+			}
+		}
+
+		b.notifierStore.NotifyBackupSuccess(
+			ctx, dir, backupResp.TotalDirs, backupResp.TotalFiles, backupResp.SuccessFiles, backupResp.BaseKey, b.notifiedSkipped(backupResp),
+		)
+	}
+
+	if ctx.Err() != nil {
+		slog.WarnContext(ctx, "Backup interrupted", "job", b.cfg.Backup.Job, "cause", context.Cause(ctx))
+		return results, interrupted(ctx)
+	}
+	return results, nil
+}
+
+// interrupted returns the error of a run stopped as ctx was cancelled: ErrInterrupted, wrapping ErrTimeout when the
+// run exceeded its timeout and storage.ErrLeaseLost when its lease was lost.
+func interrupted(ctx context.Context) error {
+	if cause := context.Cause(ctx); errors.Is(cause, ErrTimeout) || errors.Is(cause, storage.ErrLeaseLost) {
+		return fmt.Errorf("%w: %w", ErrInterrupted, cause)
+	}
+	return ErrInterrupted
+}
+
+// backupSourceWithin backs up src like backupSource, failing it with ErrTimeout when it takes longer than the
+// dir-timeout. The archive, encryption and upload stop at the deadline; the post hook still runs.
+func (b *BackupManager) backupSourceWithin(ctx context.Context, src source, blocked bool) (storage.UploadDirResponse, error) {
+	timeout := b.cfg.Backup.DirTimeout
+	if timeout <= 0 {
+		return b.backupSource(ctx, src, blocked)
+	}
+
+	dirCtx, cancel := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w: %s exceeded %s", ErrTimeout, src.dir, timeout))
+	defer cancel()
+	resp, err := b.backupSource(dirCtx, src, blocked)
+	if err != nil && ctx.Err() == nil && dirCtx.Err() != nil {
+		err = fmt.Errorf("%w: %w", context.Cause(dirCtx), err)
+	}
+	return resp, err
+}
+
+// notifiedSkipped returns the skipped entry counts of resp that are notified: all of them with report-skipped, and
+// otherwise only the files left out for their size, as those are data missing from the backup.
+func (b *BackupManager) notifiedSkipped(resp storage.UploadDirResponse) map[string]int {
+	if b.cfg.Notifiers.ReportSkipped {
+		return resp.Skipped
+	}
+	if n := resp.Skipped[walk.CategoryTooLarge]; n > 0 {
+		return map[string]int{walk.CategoryTooLarge: n}
 	}
 	return nil
 }
 
-// ListBackups lists the backups.
+// backupSource backs up a single source between its hooks.
+func (b *BackupManager) backupSource(ctx context.Context, src source, blocked bool) (storage.UploadDirResponse, error) {
+	if blocked {
+		return storage.UploadDirResponse{}, fmt.Errorf("%w for %s", ErrQuotaExceeded, src.dir)
+	}
+
+	if err := b.preHook(ctx, src.hooks, src.dir); err != nil {
+		b.postHook(ctx, src.hooks, src.dir, true)
+		return storage.UploadDirResponse{}, err
+	}
+
+	resp, err := b.uploadSource(ctx, src)
+	b.postHook(ctx, src.hooks, src.dir, err != nil)
+	return resp, err
+}
+
+// uploadSource uploads a single source, taking its database dump first. A backup smaller than the minimum of src is
+// kept, for inspection, but reported as failed.
+func (b *BackupManager) uploadSource(ctx context.Context, src source) (storage.UploadDirResponse, error) {
+	if len(src.command) > 0 {
+		resp, err := b.uploadCommand(ctx, src)
+		if err != nil {
+			return resp, err
+		}
+		return resp, src.checkMinimum(resp)
+	}
+
+	root := src.dir
+	if config.IsRemote(src.dir) {
+		fetched, cleanup, err := b.fetchRemote(ctx, src.dir)
+		defer cleanup()
+		if err != nil {
+			return storage.UploadDirResponse{}, err
+		}
+		root = fetched
+	}
+	if src.volume != nil {
+		mounted, cleanup, err := b.takeSnapshot(ctx, src.dir, *src.volume)
+		defer cleanup()
+		if err != nil {
+			return storage.UploadDirResponse{}, err
+		}
+		root = mounted
+	}
+
+	opts, cleanup, err := b.prepare(ctx, src)
+	defer cleanup()
+	if err != nil {
+		return storage.UploadDirResponse{}, err
+	}
+	var tooLarge []state.SkippedFile
+	opts.TooLarge = func(path string, size int64) {
+		slog.WarnContext(ctx, "Skipping file larger than max-file-size", "path", path, "size", size)
+		tooLarge = append(tooLarge, state.SkippedFile{Path: path, Size: size})
+	}
+
+	unchanged, err := b.checkUnchanged(ctx, src, root, opts)
+	if err != nil {
+		return unchanged, err
+	}
+	index := map[string]indexEntry{}
+	opts.Visit = chainVisit(opts.Visit, collectIndex(root, index), b.visitEvents(ctx, src.dir))
+
+	var resp storage.UploadDirResponse
+	if b.cfg.Backup.ArchiveDirs {
+		resp, err = b.archivedBackup(ctx, src.dir, root, opts)
+	} else {
+		resp, err = b.unArchivedBackup(ctx, src.dir, root, opts)
+	}
+	if err != nil {
+		return resp, err
+	}
+	b.storeIndex(ctx, resp.BaseKey, index)
+	b.storeChecksums(ctx, resp.BaseKey, resp.Checksums)
+	resp.TooLarge = tooLarge
+	resp.Fingerprint = unchanged.Fingerprint
+	return resp, src.checkMinimum(resp)
+}
+
+// ListBackups lists the backups, newest first. Backups of legacy layouts are listed as "<layout>/<backup key>".
 func (b *BackupManager) ListBackups(ctx context.Context) ([]string, error) {
-	keys, err := b.store.List(ctx)
+	times, err := b.listBackupTimes(ctx)
 	if err != nil {
-		slog.ErrorContext(ctx, "Error listing backups", "error", err)
 		return nil, err
 	}
 
-	if len(keys) == 0 {
+	if len(times) == 0 {
 		slog.InfoContext(ctx, "No backups found")
 		return []string{}, nil
 	}
 
-	keys = b.store.TrimPrefix(keys)
-	keys = datetime.SortDateTimes(keys)
+	keys := slices.SortedFunc(maps.Keys(times), func(a, b string) int { return times[b].Compare(times[a]) })
 	slog.DebugContext(ctx, "Found backups", "keys", keys)
 	return keys, nil
 }
 
+// listBackupTimes returns the backups listed by ListBackups, by the time they were taken.
+func (b *BackupManager) listBackupTimes(ctx context.Context) (map[string]time.Time, error) {
+	times, err := b.backupTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range b.legacy {
+		legacyTimes, err := l.manager.backupTimes(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("legacy layout %s: %w", l.name, err)
+		}
+		for key, t := range legacyTimes {
+			times[l.name+legacySeparator+key] = t
+		}
+	}
+	return times, nil
+}
+
+// backupTimes returns the backups of the store, by the time they were taken.
+func (b *BackupManager) backupTimes(ctx context.Context) (map[string]time.Time, error) {
+	keys, err := b.store.List(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing backups", "error", err)
+		return nil, err
+	}
+
+	layout, err := b.cfg.KeyLayout()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only keep the keys named after the key template; this also drops auxDir.
+	times := map[string]time.Time{}
+	for _, key := range b.store.TrimPrefix(keys) {
+		if t, ok := layout.Parse(key); ok {
+			times[key] = t
+		}
+	}
+	return times, nil
+}
+
+// ListBackupDetails lists the backups, newest first, with their creation time, size and object count.
+func (b *BackupManager) ListBackupDetails(ctx context.Context) ([]BackupInfo, error) {
+	infos, err := b.backupDetails(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.legacy) == 0 {
+		return infos, nil
+	}
+
+	for _, l := range b.legacy {
+		legacyInfos, err := l.manager.backupDetails(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("legacy layout %s: %w", l.name, err)
+		}
+		for _, info := range legacyInfos {
+			info.Key = l.name + legacySeparator + info.Key
+			info.Legacy = l.name
+			infos = append(infos, info)
+		}
+	}
+	slices.SortStableFunc(infos, func(a, b BackupInfo) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return infos, nil
+}
+
+// backupDetails lists the backups of the store, newest first, with their creation time, size and object count.
+func (b *BackupManager) backupDetails(ctx context.Context) ([]BackupInfo, error) {
+	times, err := b.backupTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := b.store.ListDetailed(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing backup details", "error", err)
+		return nil, err
+	}
+
+	byKey := make(map[string]storage.BackupDetail, len(details))
+	for _, d := range details {
+		byKey[d.Key] = d
+	}
+
+	keys := slices.SortedFunc(maps.Keys(times), func(a, b string) int { return times[b].Compare(times[a]) })
+	infos := make([]BackupInfo, 0, len(keys))
+	for _, key := range keys {
+		detail := byKey[key]
+		info := BackupInfo{Key: key, CreatedAt: times[key], Size: detail.Size, Objects: detail.Objects, Labels: b.cfg.Backup.RenderedLabels()}
+		for _, dir := range b.cfg.Backup.Paths() {
+			if slices.ContainsFunc(detail.Names, func(name string) bool { return b.belongsToDir(name, dir) }) {
+				info.Dirs = append(info.Dirs, dir)
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
 // PurgeOldBackups purges old backups.
 func (b *BackupManager) PurgeOldBackups(ctx context.Context) error {
+	ctx, unlock, err := b.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	run := state.RunRecord{Operation: state.OperationPurge, Job: b.cfg.Backup.Job, StartedAt: time.Now()}
+	deleted, err := b.purgeOldBackups(ctx, &run)
+	if err == nil && b.cold != nil {
+		slog.InfoContext(ctx, "Purging cold tier", "storage", b.cold.store.Name())
+		_, err = b.cold.purgeOldBackups(ctx, &run)
+	}
+	b.recordRun(ctx, &run, err)
+	b.addResult(ctx, run, nil, deleted)
+	return err
+}
+
+// purgeOldBackups deletes the backups past retention, counting them in run, and returns the keys of those deleted.
+func (b *BackupManager) purgeOldBackups(ctx context.Context, run *state.RunRecord) ([]string, error) {
 	keys, err := b.ListBackups(ctx)
 	if err != nil {
 		slog.ErrorContext(ctx, "Error listing backups", "error", err)
-		return err
+		return nil, err
+	}
+
+	keysToDelete := keys[min(b.cfg.Backup.RetentionCount, len(keys)):]
+	if len(b.cfg.Backup.Schedules()) > 1 {
+		if keysToDelete, err = b.expiredPerDir(ctx, keys); err != nil {
+			return nil, err
+		}
+	}
+
+	var synced []string
+	if b.cfg.Backup.Versioning == config.VersioningInPlace {
+		// Synced backups hold the current files whatever their age; retention applies to the versions of the files.
+		targets, err := b.syncTargets(ctx)
+		if err != nil {
+			return nil, err
+		}
+		synced = slices.Compact(slices.Sorted(maps.Values(targets)))
+		keysToDelete = slices.DeleteFunc(keysToDelete, func(key string) bool { return slices.Contains(synced, key) })
 	}
 
-	if len(keys) <= b.cfg.Backup.RetentionCount {
+	if len(keysToDelete) == 0 {
 		slog.InfoContext(ctx, "No backups to purge")
-		return nil
+		return nil, b.pruneVersions(ctx, nil, synced)
 	}
 
-	keysToDelete := keys[b.cfg.Backup.RetentionCount:]
 	slog.InfoContext(ctx, "Found backups to delete", "keys", keysToDelete, "retention", b.cfg.Backup.RetentionCount)
 
+	var deleted []string
 	for _, key := range keysToDelete {
 		slog.InfoContext(ctx, "Deleting backup", "key", key)
-		err := b.store.Delete(ctx, key)
+		err := b.deleteBackup(ctx, key)
 		if err != nil {
 			slog.ErrorContext(ctx, "Error deleting backup", "key", key, "error", err)
 			b.notifierStore.NotifyBackupDeleteFailure(ctx, key, err)
+			run.Failed++
 			continue
 		}
+		deleted = append(deleted, key)
+		run.Deleted++
 	}
 
 	slog.InfoContext(ctx, "Deletion completed successfully")
-	return nil
+	return deleted, b.pruneVersions(ctx, deleted, synced)
+}
+
+// pruneVersions applies retention to the versions kept with in-place versioning: the objects of the deleted
+// backups lose every version, and the files of the synced backups keep their newest retention-count versions.
+func (b *BackupManager) pruneVersions(ctx context.Context, deleted, synced []string) error {
+	v, ok := b.store.(storage.Versioner)
+	if !ok || b.cfg.Backup.Versioning != config.VersioningInPlace {
+		return nil
+	}
+
+	keep := map[string]int{}
+	for _, key := range deleted {
+		keep[key] = 0
+	}
+	for _, key := range synced {
+		keep[key] = b.cfg.Backup.RetentionCount
+	}
+
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(keep)) {
+		for _, k := range []string{key, auxKey(key)} {
+			n, err := v.PruneVersions(ctx, k, keep[key])
+			if err != nil {
+				slog.ErrorContext(ctx, "Error pruning versions", "key", k, "error", err)
+				errs = append(errs, fmt.Errorf("%s: %w", k, err))
+				continue
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "Pruned versions", "key", k, "versions", n, "keep", keep[key])
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expiredPerDir returns the backups, newest first, that are not among the newest retention-count backups of any
+// dir. It is used when dirs run on different schedules, so a frequent dir does not push out the backups of the
+// others. Backups that cannot be attributed to a dir are kept with the retention of the job as a whole.
+func (b *BackupManager) expiredPerDir(ctx context.Context, keys []string) ([]string, error) {
+	details, err := b.store.ListDetailed(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing backup details", "error", err)
+		return nil, err
+	}
+	names := make(map[string][]string, len(details))
+	for _, d := range details {
+		names[d.Key] = d.Names
+	}
+
+	kept := map[string]int{}
+	var expired []string
+	for _, key := range keys {
+		groups := []string{""}
+		for _, dir := range b.cfg.Backup.Paths() {
+			if slices.ContainsFunc(names[key], func(name string) bool { return b.belongsToDir(name, dir) }) {
+				groups = append(groups, dir)
+			}
+		}
+		if len(groups) > 1 {
+			groups = groups[1:]
+		}
+
+		retained := false
+		for _, group := range groups {
+			if kept[group] < b.cfg.Backup.RetentionCount {
+				kept[group]++
+				retained = true
+			}
+		}
+		if !retained {
+			expired = append(expired, key)
+		}
+	}
+	return expired, nil
 }
 
-func newBackupManager(cfg *config.Config, store storage.StorageIface, notifierStore notifiers.NotifierStoreIface) *BackupManager {
+func newBackupManager(
+	cfg *config.Config,
+	store storage.StorageIface,
+	notifierStore notifiers.NotifierStoreIface,
+	stateStore state.StoreIface,
+) *BackupManager {
 	return &BackupManager{
 		cfg:           cfg,
 		store:         store,
 		gpg:           commonGPG.NewGPG(commonGPG.Options{}),
 		notifierStore: notifierStore,
+		stateStore:    stateStore,
 	}
 }
 
@@ -0,0 +1,294 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/notifiers"
+	"github.com/hibare/arclift/internal/state"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/storage/objects"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is an object store in memory. Put waits for its context to be cancelled, after sending on putting, for the
+// keys block reports.
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	block   func(key string) bool
+	putting chan struct{}
+}
+
+func (m *memStore) Init(context.Context) error { return nil }
+
+func (m *memStore) Name() string { return "memory" }
+
+func (m *memStore) Put(ctx context.Context, key string, body io.Reader) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	if m.block != nil && m.block(key) {
+		m.putting <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = data
+	return nil
+}
+
+func (m *memStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, os.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memStore) List(_ context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var infos []storage.ObjectInfo
+	for key, data := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, storage.ObjectInfo{Key: key, Size: int64(len(data)), LastModified: time.Now()})
+		}
+	}
+	slices.SortFunc(infos, func(a, b storage.ObjectInfo) int { return strings.Compare(a.Key, b.Key) })
+	return infos, nil
+}
+
+func (m *memStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+// keys returns the keys of the stored objects, sorted.
+func (m *memStore) keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.objects))
+	for key := range m.objects {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// writeTree writes files, keyed by their slash separated path, below a new directory and returns it.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "data")
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	return dir
+}
+
+// newTestManager returns a backup manager of dirs storing its backups in memory, with backup, lines of YAML, added
+// to its backup config.
+func newTestManager(t *testing.T, dirs []string, backup string) (*BackupManager, *memStore) {
+	t.Helper()
+
+	mem := &memStore{objects: map[string][]byte{}, putting: make(chan struct{}, 1)}
+	return newHostManager(t, mem, "host", dirs, backup), mem
+}
+
+// newHostManager returns a backup manager of dirs on hostname storing its backups in mem, with backup, lines of
+// YAML, added to its backup config.
+func newHostManager(t *testing.T, mem *memStore, hostname string, dirs []string, backup string) *BackupManager {
+	t.Helper()
+
+	root := t.TempDir()
+	quoted := make([]string, len(dirs))
+	for i, dir := range dirs {
+		quoted[i] = fmt.Sprintf("%q", dir)
+	}
+	content := fmt.Sprintf(`
+s3:
+  bucket: test
+  prefix: backups
+storage:
+  backend: exec
+  exec:
+    command: "true"
+backup:
+  hostname: %s
+  dirs: [%s]
+%s
+state:
+  dir: %q
+update-check:
+  enabled: false
+`, hostname, strings.Join(quoted, ", "), indent(backup), filepath.Join(root, "state"))
+	path := filepath.Join(root, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	cfg, err := config.LoadConfig(t.Context(), path)
+	require.NoError(t, err)
+
+	store := objects.New(cfg, mem)
+	require.NoError(t, store.Init(t.Context()))
+	notifierStore := notifiers.NewNotifier(cfg)
+	require.NoError(t, notifierStore.InitStore())
+	return newBackupManager(cfg, store, notifierStore, state.NewStore(cfg.State.Dir))
+}
+
+// indent indents the lines of yaml below a section.
+func indent(yaml string) string {
+	var b strings.Builder
+	for line := range strings.Lines(strings.TrimSpace(yaml)) {
+		b.WriteString("  " + line)
+	}
+	return b.String()
+}
+
+// fakeLease is a lease lost once lost is closed.
+type fakeLease struct {
+	lost     chan struct{}
+	released bool
+}
+
+func (l *fakeLease) Release(context.Context) error {
+	l.released = true
+	return nil
+}
+
+func (l *fakeLease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// leaseStore is a storage backend holding lease.
+type leaseStore struct {
+	storage.StorageIface
+	lease *fakeLease
+}
+
+func (s *leaseStore) AcquireLease(context.Context, string, time.Duration) (storage.Lease, error) {
+	return s.lease, nil
+}
+
+func TestBackupManager_lock_LeaseLost(t *testing.T) {
+	lease := &fakeLease{lost: make(chan struct{})}
+	b := &BackupManager{
+		cfg: &config.Config{
+			Backup: config.BackupConfig{Lease: config.LeaseConfig{Enabled: true, TTL: time.Minute}},
+			State:  config.StateConfig{Dir: t.TempDir()},
+		},
+		store: &leaseStore{lease: lease},
+	}
+
+	ctx, unlock, err := b.lock(t.Context())
+	require.NoError(t, err)
+	require.NoError(t, ctx.Err())
+
+	close(lease.lost)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not cancelled after the lease was lost")
+	}
+	require.ErrorIs(t, context.Cause(ctx), storage.ErrLeaseLost)
+
+	err = interrupted(ctx)
+	require.ErrorIs(t, err, ErrInterrupted)
+	require.ErrorIs(t, err, storage.ErrLeaseLost)
+
+	unlock()
+	assert.True(t, lease.released)
+}
+
+func TestBackupManager_BackupPaths_Interrupted(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.txt": "alpha", "nested/b.txt": "beta"})
+	b, mem := newTestManager(t, []string{dir}, "")
+	mem.block = func(key string) bool { return strings.HasSuffix(key, "b.txt") }
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- b.BackupPaths(ctx, nil)
+	}()
+
+	select {
+	case <-mem.putting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("backup did not start uploading")
+	}
+	require.Len(t, mem.keys(), 1, "a.txt uploaded before b.txt")
+	cancel()
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, ErrInterrupted)
+	case <-time.After(5 * time.Second):
+		t.Fatal("backup did not stop after its context was cancelled")
+	}
+
+	assert.Empty(t, mem.keys(), "partial upload left behind")
+	st, err := b.stateStore.Load(t.Context())
+	require.NoError(t, err)
+	require.Len(t, st.Runs, 1)
+	assert.Equal(t, state.StatusInterrupted, st.Runs[0].Status)
+}
+
+func TestBackupManager_lock_Fallback(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the cache dir is only set through XDG_CACHE_HOME on linux")
+	}
+
+	// The state dir cannot be created below a regular file, whoever runs the test.
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+	b := &BackupManager{cfg: &config.Config{State: config.StateConfig{Dir: filepath.Join(file, "state")}}}
+
+	tests := []struct {
+		name     string
+		cacheDir string
+		wantLock bool
+	}{
+		{name: "cache dir", cacheDir: t.TempDir(), wantLock: true},
+		{name: "no lock", cacheDir: file},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", tt.cacheDir)
+
+			ctx, unlock, err := b.lock(t.Context())
+			require.NoError(t, err)
+			require.NoError(t, ctx.Err())
+			lockPath := filepath.Join(tt.cacheDir, constants.ProgramIdentifier, filepath.Base(b.cfg.State.LockPath()))
+			if tt.wantLock {
+				assert.FileExists(t, lockPath)
+			} else {
+				assert.NoFileExists(t, lockPath)
+			}
+			unlock()
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/presets"
+	"github.com/hibare/arclift/internal/walk"
+)
+
+// setDatabase sets src up to back up the database of sc and reports whether sc is a database source. Only the dumps
+// of database sources are backed up, not the files of their path.
+func setDatabase(src *source, sc config.SourceConfig) bool {
+	switch sc.Type {
+	case config.SourceTypePostgres:
+		src.dumps = postgresDumps(sc.Postgres)
+	case config.SourceTypeMySQL:
+		src.dumps = mysqlDumps(sc.MySQL)
+	case config.SourceTypeRedis:
+		setRedis(src, sc.Redis)
+	default:
+		return false
+	}
+	src.walk.Include = []string{dumpDir}
+	return true
+}
+
+// postgresDumps returns a pg_dump of every configured database, or a pg_dumpall of the cluster when there is none.
+// The password is passed in PGPASSWORD so it does not show in the process list.
+func postgresDumps(pg config.PostgresConfig) []presets.Dump {
+	args := []string{"--no-password"}
+	if pg.Host != "" {
+		args = append(args, "--host="+pg.Host)
+	}
+	if pg.Port > 0 {
+		args = append(args, "--port="+strconv.Itoa(pg.Port))
+	}
+	if pg.User != "" {
+		args = append(args, "--username="+pg.User)
+	}
+	args = append(args, pg.Options...)
+
+	var env []string
+	if pg.Password != "" {
+		env = []string{"PGPASSWORD=" + pg.Password}
+	}
+
+	if len(pg.Databases) == 0 {
+		return []presets.Dump{{Name: "pg_dumpall.sql", Command: append([]string{"pg_dumpall"}, args...), Env: env}}
+	}
+
+	dumps := make([]presets.Dump, 0, len(pg.Databases))
+	for _, db := range pg.Databases {
+		command := append(append([]string{"pg_dump"}, args...), "--dbname="+db)
+		dumps = append(dumps, presets.Dump{Name: db + ".sql", Command: command, Env: env})
+	}
+	return dumps
+}
+
+// mysqlDumps returns a mysqldump of every configured database, or of all databases when there is none. InnoDB tables
+// are dumped in a single transaction, so the dump is consistent without locking them. The password is passed in
+// MYSQL_PWD so it does not show in the process list.
+func mysqlDumps(my config.MySQLConfig) []presets.Dump {
+	args := []string{"mysqldump", "--single-transaction"}
+	if my.Host != "" {
+		args = append(args, "--host="+my.Host)
+	}
+	if my.Port > 0 {
+		args = append(args, "--port="+strconv.Itoa(my.Port))
+	}
+	if my.User != "" {
+		args = append(args, "--user="+my.User)
+	}
+	args = append(args, my.Options...)
+
+	var env []string
+	if my.Password != "" {
+		env = []string{"MYSQL_PWD=" + my.Password}
+	}
+
+	if len(my.Databases) == 0 {
+		return []presets.Dump{{Name: "all-databases.sql", Command: append(args, "--all-databases"), Env: env}}
+	}
+
+	dumps := make([]presets.Dump, 0, len(my.Databases))
+	for _, db := range my.Databases {
+		command := append(slices.Clone(args), "--databases", db)
+		dumps = append(dumps, presets.Dump{Name: db + ".sql", Command: command, Env: env})
+	}
+	return dumps
+}
+
+// redisPollInterval is how often the end of a BGSAVE is checked for.
+const redisPollInterval = time.Second
+
+// setRedis sets src up to back up a redis snapshot: transferred with redis-cli --rdb, or written by the server with
+// BGSAVE when the RDB path is set. The password is passed in REDISCLI_AUTH so it does not show in the process list.
+func setRedis(src *source, r config.RedisConfig) {
+	args := []string{"redis-cli"}
+	if r.Socket != "" {
+		args = append(args, "-s", r.Socket)
+	}
+	if r.Host != "" {
+		args = append(args, "-h", r.Host)
+	}
+	if r.Port > 0 {
+		args = append(args, "-p", strconv.Itoa(r.Port))
+	}
+	if r.User != "" {
+		args = append(args, "--user", r.User)
+	}
+
+	var env []string
+	if r.Password != "" {
+		env = []string{"REDISCLI_AUTH=" + r.Password}
+	}
+
+	if r.RDBPath == "" {
+		src.dumps = []presets.Dump{{Name: "dump.rdb", Command: append(args, "--rdb", "-"), Env: env}}
+		return
+	}
+
+	src.snapshot = func(ctx context.Context) error {
+		return redisBGSave(ctx, args, env)
+	}
+	src.walk.Extra = []walk.ExtraFile{{Name: dumpDir + "/" + filepath.Base(r.RDBPath), Path: r.RDBPath}}
+}
+
+// redisCLI runs redis-cli, args[0], with a command and returns its trimmed output.
+func redisCLI(ctx context.Context, args, env []string, command ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, args[0], append(slices.Clone(args[1:]), command...)...) //nolint:gosec // connection settings come from the config file
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	reply := strings.TrimSpace(string(out))
+	if err != nil {
+		return "", fmt.Errorf("%w: redis-cli %s: %w: %s", ErrDumpFailed, command[0], err, reply)
+	}
+	// redis-cli exits 0 on server errors, which it prints as "ERR ...".
+	if strings.HasPrefix(reply, "ERR") || strings.HasPrefix(reply, "NOAUTH") || strings.HasPrefix(reply, "WRONGPASS") {
+		return "", fmt.Errorf("%w: redis-cli %s: %s", ErrDumpFailed, command[0], reply)
+	}
+	return reply, nil
+}
+
+// redisBGSave starts a BGSAVE and waits for it to finish, so the RDB file is a fresh snapshot. A BGSAVE already in
+// progress is waited for instead.
+func redisBGSave(ctx context.Context, args, env []string) error {
+	slog.InfoContext(ctx, "Taking redis snapshot", "command", "BGSAVE")
+	if _, err := redisCLI(ctx, args, env, "BGSAVE"); err != nil && !strings.Contains(err.Error(), "already in progress") {
+		return err
+	}
+
+	// The server forks before replying to BGSAVE, so the save is reported in progress until it finishes.
+	ticker := time.NewTicker(redisPollInterval)
+	defer ticker.Stop()
+	for {
+		status, err := redisCLI(ctx, args, env, "INFO", "persistence")
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(status, "rdb_bgsave_in_progress:1") {
+			if !strings.Contains(status, "rdb_last_bgsave_status:ok") {
+				return fmt.Errorf("%w: redis BGSAVE failed, see the redis log", ErrDumpFailed)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/hibare/arclift/internal/state"
+	"github.com/hibare/arclift/internal/storage"
+)
+
+// EventType is the type of an Event.
+type EventType string
+
+// Types of the events of a backup run.
+const (
+	// EventDirStarted is emitted when the backup of a dir or source starts, before its hooks.
+	EventDirStarted EventType = "dir_started"
+
+	// EventFileArchived is emitted for every file walked into the backup of a dir, as it is read to be archived or
+	// uploaded. Files that then fail are listed in the result of the dir.
+	EventFileArchived EventType = "file_archived"
+
+	// EventUploadProgress is emitted as the backup data of a dir is uploaded.
+	EventUploadProgress EventType = "upload_progress"
+
+	// EventDirFinished is emitted when the backup of a dir or source ended, with its result.
+	EventDirFinished EventType = "dir_finished"
+)
+
+// Event is an event of a backup run, see Listener.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Job  string    `json:"job,omitempty"`
+	Dir  string    `json:"dir"`
+
+	// Path and Size are the path and size of the file, for EventFileArchived.
+	Path string `json:"path,omitempty"`
+	Size int64  `json:"size,omitempty"`
+
+	// Bytes are the bytes of the dir uploaded so far, out of the Expected ones when known, for EventUploadProgress.
+	Bytes    int64 `json:"bytes,omitempty"`
+	Expected int64 `json:"expected,omitempty"`
+
+	// Result is the result of the dir, for EventDirFinished.
+	Result *state.DirRecord `json:"result,omitempty"`
+}
+
+// Listener receives the events of the backup runs of a manager it was added to, see AddListener. OnEvent is called
+// from the goroutine running the backup, which waits for it, so it must return quickly; files may be uploaded
+// concurrently, so it must be safe for concurrent use.
+type Listener interface {
+	OnEvent(ctx context.Context, ev Event)
+}
+
+// ListenerFunc is a func used as a Listener.
+type ListenerFunc func(ctx context.Context, ev Event)
+
+// OnEvent calls f.
+func (f ListenerFunc) OnEvent(ctx context.Context, ev Event) {
+	f(ctx, ev)
+}
+
+// AddListener adds l to the listeners of the events of the backups of b.
+func (b *BackupManager) AddListener(l Listener) {
+	b.listenersMu.Lock()
+	defer b.listenersMu.Unlock()
+	b.listeners = append(b.listeners, l)
+}
+
+// hasListeners reports whether events of b are listened to, so they are only collected when they are.
+func (b *BackupManager) hasListeners() bool {
+	b.listenersMu.RLock()
+	defer b.listenersMu.RUnlock()
+	return len(b.listeners) > 0
+}
+
+// emit sends ev, of the job of b, to the listeners of b.
+func (b *BackupManager) emit(ctx context.Context, ev Event) {
+	b.listenersMu.RLock()
+	listeners := b.listeners
+	b.listenersMu.RUnlock()
+
+	ev.Time = time.Now()
+	ev.Job = b.cfg.Backup.Job
+	for _, l := range listeners {
+		l.OnEvent(ctx, ev)
+	}
+}
+
+// visitEvents returns a walk Visit func emitting EventFileArchived for the files of dir, or nil without listeners.
+func (b *BackupManager) visitEvents(ctx context.Context, dir string) func(path, rel string, info fs.FileInfo) {
+	if !b.hasListeners() {
+		return nil
+	}
+	return func(path, _ string, info fs.FileInfo) {
+		if info.IsDir() {
+			return
+		}
+		b.emit(ctx, Event{Type: EventFileArchived, Dir: dir, Path: path, Size: info.Size()})
+	}
+}
+
+// withUploadEvents returns a copy of ctx whose transfers, made for the backup of dir, are emitted as
+// EventUploadProgress as well as reported to the progress ctx already had. Without listeners, it returns ctx.
+func (b *BackupManager) withUploadEvents(ctx context.Context, dir string) context.Context {
+	if !b.hasListeners() {
+		return ctx
+	}
+	return storage.WithProgress(ctx, &eventProgress{b: b, ctx: ctx, dir: dir, next: storage.ProgressFrom(ctx)})
+}
+
+// eventProgress is the storage.Progress of withUploadEvents.
+type eventProgress struct {
+	b    *BackupManager
+	ctx  context.Context
+	dir  string
+	next storage.Progress
+
+	mu       sync.Mutex
+	expected int64
+	done     int64
+}
+
+func (p *eventProgress) Expect(total int64) {
+	if p.next != nil {
+		p.next.Expect(total)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expected += total
+}
+
+func (p *eventProgress) Add(n int64) {
+	if p.next != nil {
+		p.next.Add(n)
+	}
+	p.mu.Lock()
+	p.done += n
+	ev := Event{Type: EventUploadProgress, Dir: p.dir, Bytes: p.done, Expected: p.expected}
+	p.mu.Unlock()
+	p.b.emit(p.ctx, ev)
+}
+
+// AddListener adds l to the listeners of every job.
+func (j *Jobs) AddListener(l Listener) {
+	for _, name := range j.names {
+		j.managers[name].AddListener(l)
+	}
+}
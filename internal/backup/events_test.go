@@ -0,0 +1,54 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hibare/arclift/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupManager_AddListener_Order(t *testing.T) {
+	data := writeTree(t, map[string]string{"a.txt": "alpha", "nested/b.txt": "beta"})
+	other := filepath.Join(t.TempDir(), "other")
+	require.NoError(t, os.Mkdir(other, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(other, "c.txt"), []byte("gamma"), 0o600))
+	b, _ := newTestManager(t, []string{data, other}, "")
+
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+	b.AddListener(ListenerFunc(func(_ context.Context, ev Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+	}))
+	require.NoError(t, b.Backup(t.Context()))
+
+	// Each dir starts, archives its files and finishes before the next one starts; upload progress may come between.
+	var got []string
+	files := map[string][]string{}
+	for _, ev := range events {
+		switch ev.Type {
+		case EventDirStarted, EventDirFinished:
+			got = append(got, string(ev.Type)+" "+filepath.Base(ev.Dir))
+		case EventFileArchived:
+			assert.Equal(t, "dir_started "+filepath.Base(ev.Dir), got[len(got)-1], "file archived outside of its dir")
+			files[ev.Dir] = append(files[ev.Dir], filepath.Base(ev.Path))
+		}
+	}
+	assert.Equal(t, []string{"dir_started data", "dir_finished data", "dir_started other", "dir_finished other"}, got)
+	assert.ElementsMatch(t, []string{"a.txt", "b.txt"}, files[data])
+	assert.Equal(t, []string{"c.txt"}, files[other])
+
+	last := events[len(events)-1]
+	require.Equal(t, EventDirFinished, last.Type)
+	require.NotNil(t, last.Result)
+	assert.Equal(t, state.StatusSuccess, last.Result.Status)
+	assert.Equal(t, 1, last.Result.SuccessFiles)
+}
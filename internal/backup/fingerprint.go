@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/hibare/arclift/internal/state"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/walk"
+)
+
+// ErrUnchanged is returned when a dir is not backed up again, as it did not change since its last backup.
+var ErrUnchanged = errors.New("no changes since the last backup")
+
+// fingerprint returns a digest of the path, mode, size and modification time of every entry walked from root with
+// opts, so it changes whenever a file backed up with opts is added, removed or modified. Contents are not read.
+func fingerprint(ctx context.Context, root string, opts walk.Options) (string, error) {
+	h := sha256.New()
+	opts.TooLarge = nil
+	opts.Visit = func(_, rel string, info fs.FileInfo) {
+		_, _ = fmt.Fprintf(h, "%s\x00%o\x00%d\x00%d\n", rel, info.Mode(), info.Size(), info.ModTime().UnixNano())
+	}
+	_, err := walk.Dir(ctx, root, opts, func(string, string, fs.DirEntry, *os.File) error { return nil })
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lastBackup returns the last record of dir that backed it up, or found it unchanged. ok is false when there is none,
+// when it has no fingerprint, or when its backup no longer exists.
+func (b *BackupManager) lastBackup(ctx context.Context, dir string) (state.DirRecord, bool) {
+	st, err := b.stateStore.Load(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to load backup state", "dir", dir, "error", err)
+		return state.DirRecord{}, false
+	}
+
+	idx := slices.IndexFunc(st.Dirs[dir], func(rec state.DirRecord) bool {
+		return rec.Status == state.StatusSuccess || rec.Status == state.StatusUnchanged
+	})
+	if idx < 0 || st.Dirs[dir][idx].Fingerprint == "" || st.Dirs[dir][idx].Key == "" {
+		return state.DirRecord{}, false
+	}
+	last := st.Dirs[dir][idx]
+
+	// Retention may have deleted the backup since, when other dirs kept changing.
+	times, err := b.backupTimes(ctx)
+	if err != nil {
+		return state.DirRecord{}, false
+	}
+	key, _, _ := b.splitKey(b.store.TrimPrefix([]string{last.Key})[0])
+	if _, ok := times[key]; !ok {
+		return state.DirRecord{}, false
+	}
+	return last, true
+}
+
+// checkUnchanged fingerprints src, whose files are read from root with opts. When the fingerprint matches that of
+// the last backup of src, it returns ErrUnchanged with the key of that backup. Sources with database dumps always
+// change.
+func (b *BackupManager) checkUnchanged(ctx context.Context, src source, root string, opts walk.Options) (storage.UploadDirResponse, error) {
+	if !b.cfg.Backup.SkipUnchanged || len(src.dumps) > 0 || src.snapshot != nil {
+		return storage.UploadDirResponse{}, nil
+	}
+
+	fp, err := fingerprint(ctx, root, opts)
+	if err != nil {
+		slog.WarnContext(ctx, "Error fingerprinting dir, backing it up", "dir", src.dir, "error", err)
+		return storage.UploadDirResponse{}, nil
+	}
+
+	last, ok := b.lastBackup(ctx, src.dir)
+	if !ok || last.Fingerprint != fp {
+		return storage.UploadDirResponse{Fingerprint: fp}, nil
+	}
+	slog.InfoContext(ctx, "Skipping unchanged dir", "dir", src.dir, "key", last.Key, "since", last.StartedAt)
+	return storage.UploadDirResponse{BaseKey: last.Key, Fingerprint: fp}, ErrUnchanged
+}
@@ -0,0 +1,47 @@
+package backup
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/hibare/arclift/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupManager_Backup_SkipUnchanged(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.txt": "alpha", "nested/b.txt": "beta"})
+	b, mem := newTestManager(t, []string{dir}, "skip-unchanged: true")
+
+	require.NoError(t, b.Backup(t.Context()))
+	keys := mem.keys()
+	require.NotEmpty(t, keys)
+	backups, err := b.ListBackups(t.Context())
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+
+	// Backing up the unchanged dir again makes no new backup.
+	ctx, results := WithResults(t.Context())
+	require.NoError(t, b.Backup(ctx))
+	assert.Equal(t, keys, mem.keys())
+	runs := results.Runs()
+	require.Len(t, runs, 1)
+	assert.Equal(t, 1, runs[0].Unchanged)
+	assert.Equal(t, 0, runs[0].FailedDirs)
+	require.Len(t, runs[0].Results, 1)
+	assert.Equal(t, state.StatusUnchanged, runs[0].Results[0].Status)
+	assert.Equal(t, backups[0], path.Base(path.Dir(runs[0].Results[0].Key)), "unchanged dir names the backup holding it")
+
+	// A changed file backs the dir up again.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha, changed"), 0o600))
+	ctx, results = WithResults(t.Context())
+	require.NoError(t, b.Backup(ctx))
+	runs = results.Runs()
+	require.Len(t, runs, 1)
+	assert.Equal(t, 0, runs[0].Unchanged)
+	require.Len(t, runs[0].Results, 1)
+	assert.Equal(t, state.StatusSuccess, runs[0].Results[0].Status)
+	assert.Equal(t, 2, runs[0].Results[0].SuccessFiles)
+}
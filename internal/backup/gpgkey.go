@@ -0,0 +1,137 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// maxPublicKeySize bounds the response of a key server, which is an armored public key.
+const maxPublicKeySize = 1 << 20
+
+var (
+	// ErrKeyServersFailed is returned when the GPG key could not be fetched from any key server nor read from the cache.
+	ErrKeyServersFailed = errors.New("failed to fetch GPG key from every key server")
+
+	// ErrFingerprintMismatch is returned when the fingerprint of the GPG key is not the expected one.
+	ErrFingerprintMismatch = errors.New("GPG key fingerprint mismatch")
+)
+
+// fetchPublicKey fetches the configured GPG public key from the key servers, in order, and caches it on disk, so
+// backups still encrypt to the last key fetched while every key server is unreachable. The GPG client reads the key
+// from the cache afterwards.
+func (b *BackupManager) fetchPublicKey(ctx context.Context) error {
+	gpgCfg := b.cfg.Backup.Encryption.GPG
+	cachePath := b.cfg.State.GPGKeyPath(gpgCfg.KeyID)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:errcheck // the default transport is an *http.Transport
+	transport.Proxy = b.cfg.Proxy.Func()
+	client := &http.Client{Timeout: gpgCfg.Timeout, Transport: transport}
+
+	var errs []error
+	for _, server := range gpgCfg.Servers() {
+		key, err := fetchKey(ctx, client, server, gpgCfg.KeyID)
+		if err == nil {
+			err = checkKey(key, gpgCfg.ExpectedFingerprint)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Error fetching GPG key", "server", server, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", server, err))
+			continue
+		}
+
+		if err := writeCachedKey(cachePath, key); err != nil {
+			return err
+		}
+		b.gpg.SetPublicKey(cachePath)
+		return nil
+	}
+
+	if _, err := os.Stat(cachePath); err != nil {
+		return fmt.Errorf("%w: %w", ErrKeyServersFailed, errors.Join(errs...))
+	}
+	slog.WarnContext(ctx, "Using the cached GPG key, every key server failed", "path", cachePath)
+	b.gpg.SetPublicKey(cachePath)
+	return nil
+}
+
+// fetchKey fetches the armored public key keyID from the HKP key server server, which defaults to HTTPS without a
+// scheme.
+func fetchKey(ctx context.Context, client *http.Client, server, keyID string) ([]byte, error) {
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	keyURL := strings.TrimSuffix(server, "/") + "/pks/lookup?" + url.Values{"op": {"get"}, "search": {keyID}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keyURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("key server returned status %d", resp.StatusCode)
+	}
+	key, err := io.ReadAll(io.LimitReader(resp.Body, maxPublicKeySize))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(string(key), "BEGIN PGP PUBLIC KEY BLOCK") {
+		return nil, errors.New("key server did not return an armored public key")
+	}
+	return key, nil
+}
+
+// checkKey checks that the armored key ring key holds only keys with the expected fingerprint, if any.
+func checkKey(key []byte, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+	if err != nil {
+		return err
+	}
+	return checkFingerprint(entities, expected)
+}
+
+// checkFingerprint checks that every entity has the expected fingerprint, as uppercase hex digits; a key server
+// returning another key, or several keys sharing the key ID, is rejected.
+func checkFingerprint(entities openpgp.EntityList, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	for _, e := range entities {
+		if got := fmt.Sprintf("%X", e.PrimaryKey.Fingerprint); got != expected {
+			return fmt.Errorf("%w: got %s, expected %s", ErrFingerprintMismatch, got, expected)
+		}
+	}
+	return nil
+}
+
+// writeCachedKey replaces the cached key at path with key.
+func writeCachedKey(path string, key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, key, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
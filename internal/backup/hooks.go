@@ -0,0 +1,87 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/state"
+)
+
+// ErrHookFailed is returned when a pre hook with the abort failure policy fails.
+var ErrHookFailed = errors.New("hook failed")
+
+// Hook phases, passed to hooks in ARCLIFT_HOOK.
+const (
+	hookPre  = "pre"
+	hookPost = "post"
+)
+
+// runHook runs a hook command in dir, or the working directory when dir is empty, with {path} in its arguments
+// replaced by dir. The phase, job, dir and, for post hooks, the backup status are passed in ARCLIFT_ variables.
+func (b *BackupManager) runHook(ctx context.Context, phase string, command []string, dir, status string) error {
+	args := make([]string, 0, len(command))
+	for _, arg := range command {
+		args = append(args, strings.ReplaceAll(arg, "{path}", dir))
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec // hook commands come from the config file
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(),
+		"ARCLIFT_HOOK="+phase,
+		"ARCLIFT_JOB="+b.cfg.Backup.Job,
+		"ARCLIFT_DIR="+dir,
+	)
+	if status != "" {
+		cmd.Env = append(cmd.Env, "ARCLIFT_STATUS="+status)
+	}
+
+	slog.InfoContext(ctx, "Running hook", "phase", phase, "job", b.cfg.Backup.Job, "dir", dir, "command", args[0])
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook %s: %w: %s", phase, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// preHook runs the pre hook of hooks, if any. A failure returns ErrHookFailed with the abort policy and is only
+// logged with the warn policy.
+func (b *BackupManager) preHook(ctx context.Context, hooks config.HooksConfig, dir string) error {
+	if len(hooks.Pre) == 0 {
+		return nil
+	}
+
+	err := b.runHook(ctx, hookPre, hooks.Pre, dir, "")
+	if err == nil {
+		return nil
+	}
+	if hooks.OnFailure == config.HookOnFailureWarn {
+		slog.WarnContext(ctx, "Pre hook failed, backing up anyway", "job", b.cfg.Backup.Job, "dir", dir, "error", err)
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrHookFailed, err)
+}
+
+// postHook runs the post hook of hooks, if any, passing whether the backup failed. Failures are logged, as the backup
+// has already been made.
+func (b *BackupManager) postHook(ctx context.Context, hooks config.HooksConfig, dir string, failed bool) {
+	if len(hooks.Post) == 0 {
+		return
+	}
+
+	status := state.StatusSuccess
+	if failed {
+		status = state.StatusFailure
+	}
+	// Run even when ctx is cancelled, as post hooks often undo what the pre hook did, such as stopping a service.
+	if err := b.runHook(context.WithoutCancel(ctx), hookPost, hooks.Post, dir, status); err != nil {
+		slog.WarnContext(ctx, "Post hook failed", "job", b.cfg.Backup.Job, "dir", dir, "error", err)
+	}
+}
@@ -0,0 +1,158 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/storage"
+)
+
+var (
+	// ErrNotImmutable is returned when objects of retained backups are not protected by Object Lock as configured.
+	ErrNotImmutable = errors.New("backups not immutable")
+
+	// ErrObjectLockUnsupported is returned when the storage backend cannot lock objects.
+	ErrObjectLockUnsupported = errors.New("storage does not support Object Lock")
+)
+
+// UnprotectedObject is an object of a backup that is not protected by Object Lock as configured.
+type UnprotectedObject struct {
+	Backup string `json:"backup"`
+	Object string `json:"object"`
+	Error  string `json:"error"`
+}
+
+// ImmutabilityReport reports the Object Lock state of stored backups.
+type ImmutabilityReport struct {
+	// Backups and Objects are the numbers of backups and objects checked.
+	Backups int `json:"backups"`
+	Objects int `json:"objects"`
+
+	// Unprotected are the objects whose lock is missing, expired, of another mode or too short, and those replaced or
+	// deleted since they were stored.
+	Unprotected []UnprotectedObject `json:"unprotected"`
+}
+
+// VerifyImmutability checks that the objects of every retained backup are still locked as verify.object-lock
+// expects, and were not replaced or deleted since they were stored, from their metadata. Backups that are not
+// protected are notified, and VerifyImmutability fails with ErrNotImmutable.
+func (b *BackupManager) VerifyImmutability(ctx context.Context) (ImmutabilityReport, error) {
+	report := ImmutabilityReport{Unprotected: []UnprotectedObject{}}
+
+	locker, ok := b.store.(storage.ObjectLocker)
+	if !ok {
+		return report, fmt.Errorf("%w: %s", ErrObjectLockUnsupported, b.store.Name())
+	}
+	enabled, err := locker.ObjectLockEnabled(ctx)
+	if err != nil {
+		return report, err
+	}
+	if !enabled {
+		bucket := b.cfg.S3.Bucket
+		slog.ErrorContext(ctx, "Object Lock is not enabled on the bucket", "bucket", bucket)
+		b.notifierStore.NotifyImmutability(ctx, bucket, 0, map[string]string{bucket: "Object Lock is not enabled"})
+		return report, fmt.Errorf("%w: Object Lock is not enabled on bucket %s", ErrNotImmutable, bucket)
+	}
+
+	times, err := b.backupTimes(ctx)
+	if err != nil {
+		return report, err
+	}
+	keys := slices.SortedFunc(maps.Keys(times), func(a, b string) int { return times[b].Compare(times[a]) })
+
+	slog.InfoContext(ctx, "Checking Object Lock of backups", "backups", len(keys))
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		objects, problems, err := b.checkLocks(ctx, locker, key, times[key])
+		if err != nil {
+			slog.ErrorContext(ctx, "Error checking Object Lock", "key", key, "error", err)
+			return report, err
+		}
+		if len(problems) > 0 && !b.retained(ctx, key) {
+			slog.InfoContext(ctx, "Backup was deleted while it was checked", "key", key)
+			continue
+		}
+
+		report.Backups++
+		report.Objects += objects
+		if len(problems) == 0 {
+			slog.InfoContext(ctx, "Backup is immutable", "key", key, "objects", objects)
+			continue
+		}
+
+		slog.ErrorContext(ctx, "Backup is not immutable", "key", key, "objects", objects, "unprotected", len(problems))
+		for _, name := range slices.Sorted(maps.Keys(problems)) {
+			report.Unprotected = append(report.Unprotected, UnprotectedObject{Backup: key, Object: name, Error: problems[name]})
+		}
+		b.notifierStore.NotifyImmutability(ctx, key, objects, problems)
+	}
+
+	slog.InfoContext(ctx, "Object Lock check finished", "backups", report.Backups, "objects", report.Objects,
+		"unprotected", len(report.Unprotected))
+	if len(report.Unprotected) > 0 {
+		return report, fmt.Errorf("%w: %d unprotected objects", ErrNotImmutable, len(report.Unprotected))
+	}
+	return report, nil
+}
+
+// checkLocks returns the number of objects of the backup key, taken at taken, and why those that are not protected
+// are not, by their name relative to the backup. Objects a sync deleted from a backup updated in place are not
+// counted, nor are their previous versions.
+func (b *BackupManager) checkLocks(
+	ctx context.Context, locker storage.ObjectLocker, key string, taken time.Time,
+) (int, map[string]string, error) {
+	locks, err := locker.ObjectLocks(ctx, key)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	inPlace := b.cfg.Backup.Versioning == config.VersioningInPlace
+	now := time.Now()
+	objects := 0
+	problems := map[string]string{}
+	for _, lock := range locks {
+		if inPlace && lock.Deleted {
+			continue
+		}
+		objects++
+
+		name := strings.TrimPrefix(b.store.TrimPrefix([]string{lock.Key})[0], key+"/")
+		if problem := lockProblem(lock, taken, now, b.cfg.Verify.ObjectLock, inPlace); problem != "" {
+			problems[name] = problem
+		}
+	}
+	return objects, problems, nil
+}
+
+// lockProblem returns why lock does not protect an object of the backup taken at taken as check expects, or "" when
+// it does. A legal hold protects the object whatever its retention. Backups updated in place are overwritten by
+// design, so only the lock of their current version matters.
+func lockProblem(lock storage.ObjectLock, taken, now time.Time, check config.ObjectLockCheckConfig, inPlace bool) string {
+	switch {
+	case lock.Deleted:
+		return "deleted since it was stored"
+	case !inPlace && lock.Versions > 1:
+		return fmt.Sprintf("replaced since it was stored, %d versions", lock.Versions)
+	case lock.LegalHold:
+		return ""
+	case lock.Mode == "":
+		return "not locked"
+	case check.Mode != "" && !strings.EqualFold(lock.Mode, check.Mode):
+		return fmt.Sprintf("locked in %s mode, expected %s", lock.Mode, strings.ToUpper(check.Mode))
+	case check.MinRetention > 0 && lock.RetainUntil.Before(taken.Add(check.MinRetention)):
+		return fmt.Sprintf("locked until %s, expected at least until %s",
+			lock.RetainUntil.UTC().Format(time.RFC3339), taken.Add(check.MinRetention).UTC().Format(time.RFC3339))
+	case check.MinRetention == 0 && !lock.RetainUntil.After(now):
+		return "lock expired at " + lock.RetainUntil.UTC().Format(time.RFC3339)
+	}
+	return ""
+}
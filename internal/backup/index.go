@@ -0,0 +1,239 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/storage"
+)
+
+const (
+	// indexDir is the directory, among the auxiliary objects of a backup, holding the file index of each backed up
+	// dir as <name>.json.gz, where name is the archive or directory name of the dir in the backup.
+	indexDir = "index"
+
+	// indexExt is the extension of index objects.
+	indexExt = ".json.gz"
+)
+
+// indexEntry is a file of a backup, as recorded in its file index.
+type indexEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// SearchMatch is a file of a backup whose name matches a search pattern.
+type SearchMatch struct {
+	Backup    string    `json:"backup"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mtime,omitzero"`
+}
+
+// collectIndex returns a walk Visit func recording the files of the dir read from root in index, keyed by their name
+// relative to the backup. Files visited twice, such as by the free space check and then the archive, are recorded
+// once.
+func collectIndex(root string, index map[string]indexEntry) func(path, rel string, info fs.FileInfo) {
+	root = filepath.Clean(root)
+	base := filepath.Base(root)
+	return func(path, rel string, info fs.FileInfo) {
+		if info.IsDir() {
+			return
+		}
+		name := base
+		if path != root {
+			name = base + "/" + rel
+		}
+		index[name] = indexEntry{Path: name, Size: info.Size(), ModTime: info.ModTime()}
+	}
+}
+
+// chainVisit returns a walk Visit func calling every non-nil func of visits in order.
+func chainVisit(visits ...func(path, rel string, info fs.FileInfo)) func(path, rel string, info fs.FileInfo) {
+	visits = slices.DeleteFunc(visits, func(v func(path, rel string, info fs.FileInfo)) bool { return v == nil })
+	return func(path, rel string, info fs.FileInfo) {
+		for _, visit := range visits {
+			visit(path, rel, info)
+		}
+	}
+}
+
+// storeIndex stores the file index of the dir backed up at baseKey in the auxiliary objects of its backup. Failures
+// are logged; search falls back to the listing of the backup without it.
+func (b *BackupManager) storeIndex(ctx context.Context, baseKey string, index map[string]indexEntry) {
+	rel := b.store.TrimPrefix([]string{baseKey})[0]
+	key, name, _ := b.splitKey(rel)
+	// The index names every file, which opaque names keep out of the bucket.
+	if name == "" || b.cfg.Backup.OpaqueNames {
+		return
+	}
+
+	entries := make([]indexEntry, 0, len(index))
+	for _, p := range slices.Sorted(maps.Keys(index)) {
+		entries = append(entries, index[p])
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	err := json.NewEncoder(zw).Encode(entries)
+	if cErr := zw.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Error encoding file index", "key", baseKey, "error", err)
+		return
+	}
+	if err := b.store.Put(ctx, auxKey(key)+"/"+indexDir+"/"+name+indexExt, &buf); err != nil {
+		slog.WarnContext(ctx, "Error storing file index", "key", baseKey, "error", err)
+	}
+}
+
+// loadIndex downloads and decodes the index object at key.
+func (b *BackupManager) loadIndex(ctx context.Context, key, workDir string) ([]indexEntry, error) {
+	local := filepath.Join(workDir, indexDir+indexExt)
+	if err := b.store.Download(ctx, key, local); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.Remove(local)
+	}()
+
+	f, err := os.Open(local)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var entries []indexEntry
+	if err := json.NewDecoder(zr).Decode(&entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// matchName reports whether the file at name, relative to its backup, matches pattern, case-insensitively. A pattern
+// with glob characters matches the base name of the file, or its whole name when the pattern contains a slash; any
+// other pattern matches base names containing it.
+func matchName(pattern, name string) bool {
+	pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	if !strings.ContainsAny(pattern, "*?[") {
+		return strings.Contains(path.Base(name), pattern)
+	}
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(name)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// Search returns the files of every backup whose name matches pattern, see matchName, newest backup first. Files
+// are found in the file index of each backed up dir. Dirs backed up before indexes were stored are searched by their
+// stored objects, so only the archive name of an archived dir is matched.
+func (b *BackupManager) Search(ctx context.Context, pattern string) ([]SearchMatch, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	infos, err := b.backupDetails(ctx)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := b.store.ListObjects(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing objects", "error", err)
+		return nil, err
+	}
+
+	// The index and data objects of each backup, by the name of the dir in the backup they belong to.
+	indexes := map[string]map[string][]storage.ObjectInfo{}
+	stored := map[string]map[string][]storage.ObjectInfo{}
+	for _, obj := range objects {
+		rel := b.store.TrimPrefix([]string{obj.Key})[0]
+		if key, ok := b.splitAuxKey(rel); ok {
+			name, isIndex := strings.CutPrefix(strings.TrimPrefix(rel, auxKey(key)+"/"), indexDir+"/")
+			if isIndex && key != "" && strings.HasSuffix(name, indexExt) {
+				addObject(indexes, key, strings.TrimSuffix(name, indexExt), obj)
+			}
+			continue
+		}
+		key, rest, _ := b.splitKey(rel)
+		name, _, _ := strings.Cut(rest, "/")
+		addObject(stored, key, name, obj)
+	}
+
+	workDir, err := os.MkdirTemp("", constants.ProgramIdentifier+"-search-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(workDir)
+	}()
+
+	matches := []SearchMatch{}
+	for _, info := range infos {
+		for _, name := range slices.Sorted(maps.Keys(stored[info.Key])) {
+			entries := b.searchEntries(ctx, info.Key, name, indexes[info.Key][name], stored[info.Key][name], workDir)
+			for _, e := range entries {
+				if matchName(pattern, e.Path) {
+					matches = append(matches, SearchMatch{
+						Backup: info.Key, CreatedAt: info.CreatedAt, Path: e.Path, Size: e.Size, ModTime: e.ModTime,
+					})
+				}
+			}
+		}
+	}
+	return matches, nil
+}
+
+// searchEntries returns the files of the dir stored as name in the backup key: those of its index, or its stored
+// objects when it has no readable index.
+func (b *BackupManager) searchEntries(
+	ctx context.Context, key, name string, index, stored []storage.ObjectInfo, workDir string,
+) []indexEntry {
+	if len(index) > 0 {
+		entries, err := b.loadIndex(ctx, index[0].Key, workDir)
+		if err == nil {
+			return entries
+		}
+		slog.WarnContext(ctx, "Error reading file index, searching stored objects", "key", index[0].Key, "error", err)
+	}
+
+	entries := make([]indexEntry, 0, len(stored))
+	for _, obj := range stored {
+		entries = append(entries, indexEntry{
+			Path:    strings.TrimPrefix(b.store.TrimPrefix([]string{obj.Key})[0], key+"/"),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+		})
+	}
+	slog.DebugContext(ctx, "Searching stored objects of a dir without file index", "key", key, "name", name)
+	return entries
+}
+
+// addObject adds obj to the objects of the dir name of the backup key.
+func addObject(objects map[string]map[string][]storage.ObjectInfo, key, name string, obj storage.ObjectInfo) {
+	if objects[key] == nil {
+		objects[key] = map[string][]storage.ObjectInfo{}
+	}
+	objects[key][name] = append(objects[key][name], obj)
+}
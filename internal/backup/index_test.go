@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// moveBackup moves the objects of the backup from, and its auxiliary objects, to the backup to, so backups taken
+// within the same second do not share a key.
+func moveBackup(mem *memStore, from, to string) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	for key, data := range mem.objects {
+		moved := strings.Replace(key, "/"+from+"/", "/"+to+"/", 1)
+		if moved != key {
+			mem.objects[moved] = data
+			delete(mem.objects, key)
+		}
+	}
+}
+
+// searchPaths returns the backup and path of matches.
+func searchPaths(matches []SearchMatch) []string {
+	paths := make([]string, 0, len(matches))
+	for _, m := range matches {
+		paths = append(paths, m.Backup+":"+m.Path)
+	}
+	return paths
+}
+
+func TestBackupManager_Search(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.txt": "alpha", "docs/report.pdf": "report", "docs/notes.txt": "notes"})
+	// Archived dirs are only searchable by their index, as their files are not stored as objects.
+	b, mem := newTestManager(t, []string{dir}, "archive-dirs: true")
+
+	backup := func(older string) {
+		t.Helper()
+		require.NoError(t, b.Backup(t.Context()))
+		keys, err := b.ListBackups(t.Context())
+		require.NoError(t, err)
+		moveBackup(mem, keys[0], older)
+	}
+	backup("20240101000000")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "Report-2.PDF"), []byte("second report"), 0o600))
+	backup("20240201000000")
+	require.NoError(t, os.Remove(filepath.Join(dir, "docs", "report.pdf")))
+	backup("20240301000000")
+
+	keys, err := b.ListBackups(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []string{"20240301000000", "20240201000000", "20240101000000"}, keys)
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+	}{
+		{
+			name:    "glob",
+			pattern: "*.pdf",
+			want: []string{
+				"20240301000000:data/docs/Report-2.PDF",
+				"20240201000000:data/docs/Report-2.PDF",
+				"20240201000000:data/docs/report.pdf",
+				"20240101000000:data/docs/report.pdf",
+			},
+		},
+		{
+			name:    "substring",
+			pattern: "notes",
+			want: []string{
+				"20240301000000:data/docs/notes.txt",
+				"20240201000000:data/docs/notes.txt",
+				"20240101000000:data/docs/notes.txt",
+			},
+		},
+		{
+			name:    "path glob",
+			pattern: "data/*.txt",
+			want: []string{
+				"20240301000000:data/a.txt",
+				"20240201000000:data/a.txt",
+				"20240101000000:data/a.txt",
+			},
+		},
+		{name: "no match", pattern: "*.jpg", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, sErr := b.Search(t.Context(), tt.pattern)
+			require.NoError(t, sErr)
+			assert.Equal(t, tt.want, searchPaths(matches))
+		})
+	}
+
+	matches, err := b.Search(t.Context(), "report-2.pdf")
+	require.NoError(t, err)
+	require.NotEmpty(t, matches)
+	assert.Equal(t, int64(len("second report")), matches[0].Size)
+	assert.False(t, matches[0].CreatedAt.IsZero())
+
+	_, err = b.Search(t.Context(), "[")
+	require.Error(t, err)
+
+	// The files of a deleted backup are not found, even when its index was left behind.
+	for _, key := range mem.keys() {
+		if strings.HasPrefix(key, "backups/host/20240201000000/") {
+			require.NoError(t, mem.Delete(t.Context(), key))
+		}
+	}
+	require.Contains(t, strings.Join(mem.keys(), "\n"), "backups/host/.arclift/20240201000000/index/")
+	matches, err = b.Search(t.Context(), "*.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"20240301000000:data/docs/Report-2.PDF",
+		"20240101000000:data/docs/report.pdf",
+	}, searchPaths(matches))
+}
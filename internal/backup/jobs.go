@@ -0,0 +1,300 @@
+package backup
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// jobSeparator separates the job name from the backup key in keys listed across jobs.
+const jobSeparator = "/"
+
+// Jobs runs a set of named backup jobs as one backup manager. Backups listed across jobs are keyed
+// "<job>/<backup key>", and these keys are accepted by Restore.
+type Jobs struct {
+	names    []string
+	managers map[string]BackupManagerIface
+}
+
+// NewJobs creates a backup manager over the named job managers, run in the order of names.
+func NewJobs(names []string, managers map[string]BackupManagerIface) *Jobs {
+	return &Jobs{names: names, managers: managers}
+}
+
+// Backup backs up every job, continuing with the next job when one fails.
+func (j *Jobs) Backup(ctx context.Context) error {
+	return j.backupEach(ctx, func(m BackupManagerIface) error { return m.Backup(ctx) })
+}
+
+// BackupPaths backs up the given paths of every job.
+func (j *Jobs) BackupPaths(ctx context.Context, paths []string) error {
+	return j.backupEach(ctx, func(m BackupManagerIface) error { return m.BackupPaths(ctx, paths) })
+}
+
+// backupEach backs up every job with fn, merging the *BackupError of the strict runs of the jobs into one, which is
+// partial when another job backed up dirs.
+func (j *Jobs) backupEach(ctx context.Context, fn func(BackupManagerIface) error) error {
+	merged := &BackupError{Errors: map[string]string{}}
+	var errs []error
+	for _, name := range j.names {
+		slog.InfoContext(ctx, "Running job", "job", name, "operation", "backup")
+		err := fn(j.managers[name])
+		if err == nil {
+			merged.Partial = true
+			continue
+		}
+
+		slog.ErrorContext(ctx, "Job failed", "job", name, "operation", "backup", "error", err)
+		var bErr *BackupError
+		if !errors.As(err, &bErr) {
+			errs = append(errs, fmt.Errorf("job %s: %w", name, err))
+			continue
+		}
+		merged.Partial = merged.Partial || bErr.Partial
+		for dir, msg := range bErr.Errors {
+			merged.Errors[fmt.Sprintf("job %s: %s", name, dir)] = msg
+		}
+	}
+
+	if len(merged.Errors) > 0 {
+		errs = append([]error{merged}, errs...)
+	}
+	return errors.Join(errs...)
+}
+
+// PurgeOldBackups purges the old backups of every job with the job's retention.
+func (j *Jobs) PurgeOldBackups(ctx context.Context) error {
+	return j.each(ctx, "purge", func(m BackupManagerIface) error { return m.PurgeOldBackups(ctx) })
+}
+
+func (j *Jobs) each(ctx context.Context, operation string, fn func(BackupManagerIface) error) error {
+	var errs []error
+	for _, name := range j.names {
+		slog.InfoContext(ctx, "Running job", "job", name, "operation", operation)
+		if err := fn(j.managers[name]); err != nil {
+			slog.ErrorContext(ctx, "Job failed", "job", name, "operation", operation, "error", err)
+			errs = append(errs, fmt.Errorf("job %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListBackups lists the backups of every job, newest first.
+func (j *Jobs) ListBackups(ctx context.Context) ([]string, error) {
+	type jobKey struct {
+		job, key  string
+		createdAt time.Time
+	}
+
+	var all []jobKey
+	for _, name := range j.names {
+		times, err := backupTimesOf(ctx, j.managers[name])
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", name, err)
+		}
+		for key, t := range times {
+			all = append(all, jobKey{name, key, t})
+		}
+	}
+
+	// The key templates of the jobs may differ, so keys are ordered by the time they were taken rather than by name.
+	slices.SortFunc(all, func(a, b jobKey) int {
+		return cmp.Or(b.createdAt.Compare(a.createdAt), cmp.Compare(a.job, b.job), cmp.Compare(b.key, a.key))
+	})
+
+	keys := make([]string, 0, len(all))
+	for _, k := range all {
+		keys = append(keys, k.job+jobSeparator+k.key)
+	}
+	return keys, nil
+}
+
+// backupTimesOf returns the backups listed by m, by the time they were taken.
+func backupTimesOf(ctx context.Context, m BackupManagerIface) (map[string]time.Time, error) {
+	if bm, ok := m.(*BackupManager); ok {
+		return bm.listBackupTimes(ctx)
+	}
+
+	infos, err := m.ListBackupDetails(ctx)
+	if err != nil {
+		return nil, err
+	}
+	times := make(map[string]time.Time, len(infos))
+	for _, info := range infos {
+		times[info.Key] = info.CreatedAt
+	}
+	return times, nil
+}
+
+// ListBackupDetails lists the backups of every job, newest first.
+func (j *Jobs) ListBackupDetails(ctx context.Context) ([]BackupInfo, error) {
+	var infos []BackupInfo
+	for _, name := range j.names {
+		details, err := j.managers[name].ListBackupDetails(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", name, err)
+		}
+		for _, info := range details {
+			info.Key = name + jobSeparator + info.Key
+			infos = append(infos, info)
+		}
+	}
+
+	slices.SortStableFunc(infos, func(a, b BackupInfo) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return infos, nil
+}
+
+// Restore restores a backup listed as "<job>/<backup key>".
+func (j *Jobs) Restore(ctx context.Context, opts RestoreOptions) (RestoreSummary, error) {
+	name, key, ok := strings.Cut(opts.Backup, jobSeparator)
+	m, known := j.managers[name]
+	if !ok || !known {
+		return RestoreSummary{Backup: opts.Backup}, fmt.Errorf("%w: %s, use <job>%s<backup> or select a job with --job",
+			ErrBackupNotFound, opts.Backup, jobSeparator)
+	}
+
+	opts.Backup = key
+	return m.Restore(ctx, opts)
+}
+
+// RestoreTest tests the restore of a backup listed as "<job>/<backup key>", or of the newest backup of every job.
+func (j *Jobs) RestoreTest(ctx context.Context, opts RestoreTestOptions) (RestoreTestReport, error) {
+	if opts.Backup == "" {
+		infos, err := j.ListBackupDetails(ctx)
+		if err != nil {
+			return RestoreTestReport{}, err
+		}
+		if len(infos) == 0 {
+			return RestoreTestReport{}, fmt.Errorf("%w: no backups stored", ErrBackupNotFound)
+		}
+		opts.Backup = infos[0].Key
+	}
+
+	name, key, ok := strings.Cut(opts.Backup, jobSeparator)
+	m, known := j.managers[name]
+	if !ok || !known {
+		return RestoreTestReport{Backup: opts.Backup}, fmt.Errorf("%w: %s, use <job>%s<backup> or select a job with --job",
+			ErrBackupNotFound, opts.Backup, jobSeparator)
+	}
+
+	opts.Backup = key
+	report, err := m.RestoreTest(ctx, opts)
+	report.Backup = name + jobSeparator + report.Backup
+	return report, err
+}
+
+// Stream fails with ErrJobRequired, as a stream is stored in a single job.
+func (j *Jobs) Stream(context.Context, string, io.Reader) error {
+	return ErrJobRequired
+}
+
+// CheckConsistency checks every job and merges the reports.
+func (j *Jobs) CheckConsistency(ctx context.Context, fix bool) (ConsistencyReport, error) {
+	merged := ConsistencyReport{OrphanedAux: []string{}, Deleted: true}
+	for _, name := range j.names {
+		report, err := j.managers[name].CheckConsistency(ctx, fix)
+		if err != nil {
+			return merged, fmt.Errorf("job %s: %w", name, err)
+		}
+		merged.Backups += report.Backups
+		merged.OrphanedAux = append(merged.OrphanedAux, report.OrphanedAux...)
+		merged.Deleted = merged.Deleted && (report.Deleted || len(report.OrphanedAux) == 0)
+	}
+	merged.Deleted = merged.Deleted && len(merged.OrphanedAux) > 0
+	return merged, nil
+}
+
+// Search searches the backups of every job, newest first. Backups are reported as "<job>/<backup key>".
+func (j *Jobs) Search(ctx context.Context, pattern string) ([]SearchMatch, error) {
+	matches := []SearchMatch{}
+	for _, name := range j.names {
+		jobMatches, err := j.managers[name].Search(ctx, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", name, err)
+		}
+		for _, m := range jobMatches {
+			m.Backup = name + jobSeparator + m.Backup
+			matches = append(matches, m)
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b SearchMatch) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return matches, nil
+}
+
+// Verify verifies a sample of the backups of every job, continuing with the next job when one fails, and merges the
+// reports. Backups are reported as "<job>/<backup key>".
+func (j *Jobs) Verify(ctx context.Context, sample int) (VerifyReport, error) {
+	merged := VerifyReport{Corrupted: []CorruptObject{}}
+	var errs []error
+	for _, name := range j.names {
+		report, err := j.managers[name].Verify(ctx, sample)
+		if err != nil {
+			slog.ErrorContext(ctx, "Job failed", "job", name, "operation", "verify", "error", err)
+			errs = append(errs, fmt.Errorf("job %s: %w", name, err))
+		}
+		merged.Backups += report.Backups
+		merged.Objects += report.Objects
+		merged.Unverified += report.Unverified
+		for _, c := range report.Corrupted {
+			c.Backup = name + jobSeparator + c.Backup
+			merged.Corrupted = append(merged.Corrupted, c)
+		}
+	}
+	return merged, errors.Join(errs...)
+}
+
+// VerifyImmutability checks the Object Lock of the backups of every job, continuing with the next job when one fails,
+// and merges the reports. Backups are reported as "<job>/<backup key>".
+func (j *Jobs) VerifyImmutability(ctx context.Context) (ImmutabilityReport, error) {
+	merged := ImmutabilityReport{Unprotected: []UnprotectedObject{}}
+	var errs []error
+	for _, name := range j.names {
+		report, err := j.managers[name].VerifyImmutability(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Job failed", "job", name, "operation", "verify object-lock", "error", err)
+			errs = append(errs, fmt.Errorf("job %s: %w", name, err))
+		}
+		merged.Backups += report.Backups
+		merged.Objects += report.Objects
+		for _, u := range report.Unprotected {
+			u.Backup = name + jobSeparator + u.Backup
+			merged.Unprotected = append(merged.Unprotected, u)
+		}
+	}
+	return merged, errors.Join(errs...)
+}
+
+// MigrateKeys migrates the legacy backups of every job. Keys are reported as "<job>/<backup key>".
+func (j *Jobs) MigrateKeys(ctx context.Context, layout string, dryRun bool) ([]KeyMigration, error) {
+	var (
+		migrations = []KeyMigration{}
+		errs       []error
+		found      bool
+	)
+	for _, name := range j.names {
+		jobMigrations, err := j.managers[name].MigrateKeys(ctx, layout, dryRun)
+		switch {
+		case errors.Is(err, ErrLayoutNotFound):
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("job %s: %w", name, err))
+		}
+		found = true
+		for _, m := range jobMigrations {
+			m.From = name + jobSeparator + m.From
+			m.To = name + jobSeparator + m.To
+			migrations = append(migrations, m)
+		}
+	}
+	if !found && len(j.names) > 0 {
+		return migrations, fmt.Errorf("%w: %s", ErrLayoutNotFound, layout)
+	}
+	return migrations, errors.Join(errs...)
+}
@@ -0,0 +1,44 @@
+package backup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// detailsManager is a backup manager listing infos.
+type detailsManager struct {
+	BackupManagerIface
+	infos []BackupInfo
+}
+
+func (m *detailsManager) ListBackupDetails(context.Context) ([]BackupInfo, error) {
+	return m.infos, nil
+}
+
+func TestJobs_ListBackups(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 2, 0, 0, 0, time.UTC) }
+	jobs := NewJobs([]string{"db", "home"}, map[string]BackupManagerIface{
+		// The key templates differ, so the keys do not sort chronologically by name.
+		"db": &detailsManager{infos: []BackupInfo{
+			{Key: "20240303020000", CreatedAt: day(3)},
+			{Key: "20240301020000", CreatedAt: day(1)},
+		}},
+		"home": &detailsManager{infos: []BackupInfo{
+			{Key: "laptop-2024-03-04", CreatedAt: day(4)},
+			{Key: "laptop-2024-03-02", CreatedAt: day(2)},
+		}},
+	})
+
+	keys, err := jobs.ListBackups(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"home/laptop-2024-03-04",
+		"db/20240303020000",
+		"home/laptop-2024-03-02",
+		"db/20240301020000",
+	}, keys)
+}
@@ -0,0 +1,47 @@
+package backup
+
+import (
+	"strings"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/storage"
+)
+
+// legacySeparator separates the name of a legacy layout from the backup key in listed keys.
+const legacySeparator = "/"
+
+// legacyLayout is the manager of the backups stored with a legacy layout.
+type legacyLayout struct {
+	name    string
+	manager *BackupManager
+}
+
+// AddLegacyLayout lists, restores and purges the backups of store, stored with the legacy layout l, alongside the
+// current ones. They are listed as "<layout>/<backup key>" and count towards the retention of the current backups.
+func (b *BackupManager) AddLegacyLayout(l config.LegacyLayoutConfig, store storage.StorageIface) {
+	b.legacy = append(b.legacy, legacyLayout{
+		name: l.Name,
+		manager: &BackupManager{
+			cfg:           b.cfg.LegacyLayout(l),
+			store:         store,
+			gpg:           b.gpg,
+			notifierStore: b.notifierStore,
+			stateStore:    b.stateStore,
+		},
+	})
+}
+
+// legacyBackup returns the manager of the legacy layout a listed key belongs to, and the key in that layout. ok is
+// false for the backups of the current layout.
+func (b *BackupManager) legacyBackup(key string) (*BackupManager, string, bool) {
+	name, rest, found := strings.Cut(key, legacySeparator)
+	if !found {
+		return nil, "", false
+	}
+	for _, l := range b.legacy {
+		if l.name == name {
+			return l.manager, rest, true
+		}
+	}
+	return nil, "", false
+}
@@ -0,0 +1,335 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/storage"
+)
+
+const (
+	// metadataDir is the directory, among the auxiliary objects of an unarchived backup, holding the metadata of the
+	// files of each backed up dir as <dir>.json.
+	metadataDir = "metadata"
+
+	// zipCreatorUnix is the "version made by" host of zip entries whose external attributes hold a Unix mode.
+	zipCreatorUnix = 3
+
+	// zipUnixExtraID identifies the Info-ZIP Unix extra field holding the owner of a zip entry.
+	zipUnixExtraID = 0x7875
+
+	// zipIDSize is the size of the uid and gid written in the Info-ZIP Unix extra field.
+	zipIDSize = 4
+
+	// zipExtTimeExtraID identifies the extended timestamp extra field, holding the modification time to the second.
+	zipExtTimeExtraID = 0x5455
+
+	// zipNTFSExtraID identifies the NTFS extra field, holding the modification time to 100ns.
+	zipNTFSExtraID = 0x000a
+
+	// zipNTFSEpochTicks is the Unix epoch in the 100ns ticks since 1601 of NTFS timestamps.
+	zipNTFSEpochTicks = 116444736000000000
+)
+
+// permBits are the mode bits of a file that are restored.
+const permBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// fileMeta is the metadata of a backed up file or directory, restored with it. UID and GID are -1 when the owner is
+// not known, such as for backups taken on Windows.
+type fileMeta struct {
+	Mode    fs.FileMode `json:"mode"`
+	UID     int         `json:"uid"`
+	GID     int         `json:"gid"`
+	ModTime time.Time   `json:"mtime"`
+}
+
+// metaOf returns the metadata of the file described by info.
+func metaOf(info fs.FileInfo) fileMeta {
+	m := fileMeta{Mode: info.Mode(), UID: -1, GID: -1, ModTime: info.ModTime()}
+	if uid, gid, ok := fileOwner(info); ok {
+		m.UID, m.GID = uid, gid
+	}
+	return m
+}
+
+// canChown reports whether restored files can be given to their original owner, which needs root.
+func canChown() bool {
+	return os.Geteuid() == 0
+}
+
+// apply restores the permissions and modification time of path from m and, when chown is set, its owner.
+func (m fileMeta) apply(path string, chown bool) error {
+	// Changing the owner clears the setuid and setgid bits, so it goes first.
+	if chown && m.UID >= 0 && m.GID >= 0 {
+		if err := os.Lchown(path, m.UID, m.GID); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(path, m.Mode&permBits); err != nil {
+		return err
+	}
+	return os.Chtimes(path, time.Time{}, m.ModTime)
+}
+
+// zipExtraHeader precedes the data of every zip extra field.
+type zipExtraHeader struct {
+	ID   uint16
+	Size uint16
+}
+
+// zipUnixExtra is the Info-ZIP Unix extra field, version 1, with 32-bit ids.
+type zipUnixExtra struct {
+	Version uint8
+	UIDSize uint8
+	UID     uint32
+	GIDSize uint8
+	GID     uint32
+}
+
+var zipUnixExtraSize = uint16(binary.Size(zipUnixExtra{})) //nolint:gosec // the field is 11 bytes
+
+// zipExtTimeExtra is the extended timestamp extra field holding the modification time only.
+type zipExtTimeExtra struct {
+	Flags   uint8
+	ModTime uint32
+}
+
+// zipNTFSExtra is the NTFS extra field holding its timestamps attribute, in 100ns ticks since 1601.
+type zipNTFSExtra struct {
+	Reserved uint32
+	Tag      uint16
+	Size     uint16
+	ModTime  uint64
+	AccTime  uint64
+	CrtTime  uint64
+}
+
+var (
+	zipExtTimeExtraSize = uint16(binary.Size(zipExtTimeExtra{})) //nolint:gosec // the field is 5 bytes
+	zipNTFSExtraSize    = uint16(binary.Size(zipNTFSExtra{}))    //nolint:gosec // the field is 32 bytes
+)
+
+// writeZipTimes records t as the modification time of zh: in the MS-DOS fields and the extended timestamp to the
+// second, which every unzip reads, then in the NTFS extra field to 100ns, which archive/zip reads instead, so
+// sub-second modification times are restored. The extra fields are written to buf.
+func writeZipTimes(buf *bytes.Buffer, zh *zip.FileHeader, t time.Time) {
+	// Modified stays zero, so the writer does not append an extended timestamp that would take precedence.
+	t = t.Local()
+	zh.ModifiedDate = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9) //nolint:gosec // MS-DOS dates are 16-bit
+	zh.ModifiedTime = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)      //nolint:gosec // MS-DOS times are 16-bit
+
+	extTime := zipExtTimeExtra{Flags: 1, ModTime: uint32(t.Unix())} //nolint:gosec // the field is 32-bit
+	_ = binary.Write(buf, binary.LittleEndian, zipExtraHeader{ID: zipExtTimeExtraID, Size: zipExtTimeExtraSize})
+	_ = binary.Write(buf, binary.LittleEndian, extTime)
+
+	ticks := uint64(t.UnixNano()/100 + zipNTFSEpochTicks) //nolint:gosec // times after 1601
+	_ = binary.Write(buf, binary.LittleEndian, zipExtraHeader{ID: zipNTFSExtraID, Size: zipNTFSExtraSize})
+	_ = binary.Write(buf, binary.LittleEndian, zipNTFSExtra{Tag: 1, Size: 24, ModTime: ticks, AccTime: ticks, CrtTime: ticks})
+}
+
+// zipHeader returns the header of the zip entry name recording the metadata of info.
+func zipHeader(name string, info fs.FileInfo) *zip.FileHeader {
+	zh := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	zh.SetMode(info.Mode())
+
+	var buf bytes.Buffer
+	writeZipTimes(&buf, zh, info.ModTime())
+	if uid, gid, ok := fileOwner(info); ok {
+		field := zipUnixExtra{Version: 1, UIDSize: zipIDSize, UID: uint32(uid), GIDSize: zipIDSize, GID: uint32(gid)} //nolint:gosec // ids are 32-bit on every Unix
+		_ = binary.Write(&buf, binary.LittleEndian, zipExtraHeader{ID: zipUnixExtraID, Size: zipUnixExtraSize})
+		_ = binary.Write(&buf, binary.LittleEndian, field)
+	}
+	zh.Extra = buf.Bytes()
+	return zh
+}
+
+// zipMeta returns the metadata recorded in the zip entry f. ok is false for entries of archives taken before
+// metadata was recorded, or on hosts without Unix modes.
+func zipMeta(f *zip.File) (fileMeta, bool) {
+	if f.CreatorVersion>>8 != zipCreatorUnix {
+		return fileMeta{}, false
+	}
+
+	m := fileMeta{Mode: f.Mode(), UID: -1, GID: -1, ModTime: f.Modified}
+	r := bytes.NewReader(f.Extra)
+	var hdr zipExtraHeader
+	for binary.Read(r, binary.LittleEndian, &hdr) == nil {
+		data := make([]byte, hdr.Size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			break
+		}
+
+		var field zipUnixExtra
+		if hdr.ID != zipUnixExtraID || hdr.Size != zipUnixExtraSize ||
+			binary.Read(bytes.NewReader(data), binary.LittleEndian, &field) != nil ||
+			field.UIDSize != zipIDSize || field.GIDSize != zipIDSize {
+			continue
+		}
+		m.UID, m.GID = int(field.UID), int(field.GID)
+	}
+	return m, true
+}
+
+// dirMeta is a restored directory whose metadata is applied once its files are restored.
+type dirMeta struct {
+	path string
+	meta fileMeta
+}
+
+// applyDirMeta applies the metadata of dirs, given parents first, once their files are restored. They are applied
+// deepest first, so a parent that is not writable does not stop its subdirectories from being updated. Directories
+// that were not restored are skipped.
+func applyDirMeta(ctx context.Context, dirs []dirMeta, chown bool) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if !exists(dirs[i].path) {
+			continue
+		}
+		if err := dirs[i].meta.apply(dirs[i].path, chown); err != nil {
+			slog.WarnContext(ctx, "Error restoring directory metadata", "path", dirs[i].path, "error", err)
+		}
+	}
+}
+
+// collectMetadata returns a walk Visit func recording the metadata of the files of the dir read from root in meta,
+// keyed by their name relative to the backup.
+func collectMetadata(root string, meta map[string]fileMeta) func(path, rel string, info fs.FileInfo) {
+	root = filepath.Clean(root)
+	base := filepath.Base(root)
+	return func(path, rel string, info fs.FileInfo) {
+		name := base
+		if path != root {
+			name = base + "/" + rel
+		}
+		meta[name] = metaOf(info)
+	}
+}
+
+// storeMetadata stores the metadata of the files of the unarchived backup at baseKey in its auxiliary objects.
+// Failures are logged; the backup is still usable without them.
+func (b *BackupManager) storeMetadata(ctx context.Context, baseKey string, meta map[string]fileMeta) {
+	rel := b.store.TrimPrefix([]string{baseKey})[0]
+	key, dir, _ := b.splitKey(rel)
+
+	body, err := json.Marshal(meta)
+	if err != nil {
+		slog.WarnContext(ctx, "Error encoding file metadata", "key", baseKey, "error", err)
+		return
+	}
+	if err := b.store.Put(ctx, auxKey(key)+"/"+metadataDir+"/"+dir+".json", bytes.NewReader(body)); err != nil {
+		slog.WarnContext(ctx, "Error storing file metadata", "key", baseKey, "error", err)
+	}
+}
+
+// loadMetadata downloads and merges the metadata of the unarchived files of backup, keyed by their name relative
+// to the backup. It is empty for archived backups and for backups taken before metadata was recorded.
+func (b *BackupManager) loadMetadata(ctx context.Context, objects []storage.ObjectInfo, backup, workDir string) map[string]fileMeta {
+	meta := map[string]fileMeta{}
+	prefix := auxKey(backup) + "/" + metadataDir + "/"
+	local := filepath.Join(workDir, metadataDir+".json")
+	for _, obj := range objects {
+		rel := b.store.TrimPrefix([]string{obj.Key})[0]
+		if !strings.HasPrefix(rel, prefix) || !strings.HasSuffix(rel, ".json") {
+			continue
+		}
+
+		if err := b.store.Download(ctx, obj.Key, local); err != nil {
+			slog.WarnContext(ctx, "Error downloading file metadata", "key", obj.Key, "error", err)
+			continue
+		}
+		data, err := os.ReadFile(local)
+		_ = os.Remove(local)
+		if err == nil {
+			err = json.Unmarshal(data, &meta)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Error reading file metadata", "key", obj.Key, "error", err)
+		}
+	}
+	return meta
+}
+
+// restoredDirs returns the directories of meta, parents first, whose metadata is restored: those that do not exist
+// in the target yet, or all of them with Overwrite.
+func restoredDirs(meta map[string]fileMeta, opts RestoreOptions) []dirMeta {
+	var dirs []dirMeta
+	for _, name := range slices.Sorted(maps.Keys(meta)) {
+		if !meta[name].Mode.IsDir() {
+			continue
+		}
+		dst, err := safeJoin(opts.Target, name)
+		if err != nil || (!opts.Overwrite && exists(dst)) {
+			continue
+		}
+		dirs = append(dirs, dirMeta{path: dst, meta: meta[name]})
+	}
+	return dirs
+}
+
+// isSpecial reports whether mode is that of a recorded special file.
+func isSpecial(mode fs.FileMode) bool {
+	return mode&(fs.ModeNamedPipe|fs.ModeDevice|fs.ModeCharDevice) != 0
+}
+
+// restoreSpecial recreates the recorded special file name at dst. Only FIFOs are recreated; device nodes are
+// skipped, as they are created by the system or by hand.
+func restoreSpecial(ctx context.Context, name, dst string, meta fileMeta, chown bool, opts RestoreOptions, summary *RestoreSummary) {
+	if meta.Mode&fs.ModeNamedPipe == 0 {
+		slog.WarnContext(ctx, "Skipping device node, it is not recreated", "path", dst)
+		summary.Skipped++
+		return
+	}
+
+	if _, err := os.Lstat(dst); err == nil {
+		if !opts.Overwrite {
+			slog.DebugContext(ctx, "Skipping existing file", "path", dst)
+			summary.Skipped++
+			return
+		}
+		if err := os.Remove(dst); err != nil {
+			summary.fail(name, err)
+			return
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		summary.fail(name, err)
+		return
+	}
+	if err := makeFIFO(dst); err != nil {
+		slog.ErrorContext(ctx, "Error recreating FIFO", "path", dst, "error", err)
+		summary.fail(name, err)
+		return
+	}
+	if err := meta.apply(dst, chown); err != nil {
+		slog.WarnContext(ctx, "Error restoring file metadata", "path", dst, "error", err)
+	}
+	summary.Restored++
+}
+
+// restoreSpecialFiles recreates the special files recorded in the metadata of an unarchived backup.
+func restoreSpecialFiles(ctx context.Context, meta map[string]fileMeta, opts RestoreOptions, summary *RestoreSummary) {
+	chown := canChown()
+	for _, name := range slices.Sorted(maps.Keys(meta)) {
+		if !isSpecial(meta[name].Mode) {
+			continue
+		}
+		dst, err := safeJoin(opts.Target, name)
+		if err != nil {
+			summary.fail(name, err)
+			continue
+		}
+		restoreSpecial(ctx, name, dst, meta[name], chown, opts, summary)
+	}
+}
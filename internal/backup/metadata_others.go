@@ -0,0 +1,14 @@
+//go:build !unix
+
+package backup
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// File owners are not known on this platform.
+func fileOwner(fs.FileInfo) (int, int, bool) { return 0, 0, false }
+
+// FIFOs cannot be created on this platform.
+func makeFIFO(string) error { return errors.ErrUnsupported }
@@ -0,0 +1,22 @@
+//go:build unix
+
+package backup
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileOwner returns the owner of the file described by info.
+func fileOwner(info fs.FileInfo) (int, int, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// makeFIFO creates a FIFO at path.
+func makeFIFO(path string) error {
+	return syscall.Mkfifo(path, 0o600)
+}
@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/hibare/arclift/internal/storage"
+)
+
+// Statuses of a key migration.
+const (
+	MigrationPlanned  = "planned"
+	MigrationMigrated = "migrated"
+	MigrationExists   = "exists"
+	MigrationFailed   = "failed"
+)
+
+var (
+	// ErrLayoutNotFound is returned when the requested legacy layout is not configured.
+	ErrLayoutNotFound = errors.New("legacy layout not found")
+
+	// ErrVerifyFailed is returned when a migrated backup does not match the backup it was copied from.
+	ErrVerifyFailed = errors.New("copied backup does not match the original")
+)
+
+// KeyMigration is the move of a backup stored with a legacy layout to the current key layout.
+type KeyMigration struct {
+	Layout  string `json:"layout"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Size    int64  `json:"size"`
+	Objects int    `json:"objects"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// MigrateKeys moves the backups of the legacy layout named layout, every legacy layout when empty, to the current key
+// layout. Each backup and its auxiliary objects are copied, the copy is verified against the original, then the
+// original is deleted. Backups whose current key is already taken are left in place. With dryRun, only the planned
+// migrations are returned.
+func (b *BackupManager) MigrateKeys(ctx context.Context, layout string, dryRun bool) ([]KeyMigration, error) {
+	if layout != "" && !slices.ContainsFunc(b.legacy, func(l legacyLayout) bool { return l.name == layout }) {
+		return nil, fmt.Errorf("%w: %s", ErrLayoutNotFound, layout)
+	}
+
+	current, err := b.cfg.KeyLayout()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := b.backupTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []KeyMigration{}
+	var errs []error
+	for _, l := range b.legacy {
+		if layout != "" && l.name != layout {
+			continue
+		}
+		legacy, err := l.manager.cfg.KeyLayout()
+		if err != nil {
+			return migrations, fmt.Errorf("legacy layout %s: %w", l.name, err)
+		}
+		if legacy == current {
+			return migrations, fmt.Errorf("legacy layout %s: same as the current key layout", l.name)
+		}
+
+		infos, err := l.manager.backupDetails(ctx)
+		if err != nil {
+			return migrations, fmt.Errorf("legacy layout %s: %w", l.name, err)
+		}
+		// Oldest first, so an interrupted migration leaves the newest backups where retention expects them.
+		slices.Reverse(infos)
+
+		for _, info := range infos {
+			m := KeyMigration{
+				Layout:  l.name,
+				From:    info.Key,
+				To:      current.Format(info.CreatedAt),
+				Size:    info.Size,
+				Objects: info.Objects,
+				Status:  MigrationPlanned,
+			}
+			switch _, taken := existing[m.To]; {
+			case taken:
+				m.Status = MigrationExists
+				slog.WarnContext(ctx, "Skipping backup, its key is already taken", "layout", l.name, "key", m.From, "dst", m.To)
+			case !dryRun:
+				if err := b.migrateBackup(ctx, l.manager, info, m.To); err != nil {
+					slog.ErrorContext(ctx, "Error migrating backup", "layout", l.name, "key", m.From, "dst", m.To, "error", err)
+					m.Status, m.Error = MigrationFailed, err.Error()
+					errs = append(errs, fmt.Errorf("%s%s%s: %w", l.name, legacySeparator, m.From, err))
+				} else {
+					m.Status = MigrationMigrated
+					existing[m.To] = info.CreatedAt
+					slog.InfoContext(ctx, "Migrated backup", "layout", l.name, "key", m.From, "dst", m.To)
+				}
+			}
+			migrations = append(migrations, m)
+		}
+	}
+	return migrations, errors.Join(errs...)
+}
+
+// migrateBackup copies the backup info of legacy, with its auxiliary objects, to dst in the current layout, verifies
+// the copy and deletes the original.
+func (b *BackupManager) migrateBackup(ctx context.Context, legacy *BackupManager, info BackupInfo, dst string) error {
+	key := info.Key
+	if err := legacy.store.CopyTo(ctx, key, b.store, dst); err != nil {
+		return err
+	}
+	if err := legacy.store.CopyTo(ctx, auxKey(key), b.store, auxKey(dst)); err != nil {
+		return err
+	}
+
+	details, err := b.store.ListDetailed(ctx)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(details, func(d storage.BackupDetail) bool { return d.Key == dst })
+	if i < 0 || details[i].Size != info.Size || details[i].Objects != info.Objects {
+		return fmt.Errorf("%w: %s", ErrVerifyFailed, dst)
+	}
+
+	return legacy.deleteBackup(ctx, key)
+}
@@ -0,0 +1,145 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/hibare/arclift/internal/storage"
+)
+
+// manifestDir is the directory, among the auxiliary objects of a backup, mapping the opaque names of its objects to
+// their names as <opaque name>.json.gpg, encrypted to the GPG key, see config.BackupConfig.OpaqueNames. The opaque
+// name of an object is its name up to its extensions.
+const manifestDir = "manifest"
+
+// opaqueNameBytes is the number of random bytes of an opaque object name.
+const opaqueNameBytes = 16
+
+// manifestEntry is the encrypted manifest entry of an object stored under an opaque name.
+type manifestEntry struct {
+	// Name is the name the object would have been stored under without opaque names, with its extensions.
+	Name string `json:"name"`
+
+	// Dir is the backed up dir, or the name of a stream.
+	Dir string `json:"dir"`
+}
+
+// opaqueName returns a random object name, which reveals nothing of what the object holds.
+func opaqueName() string {
+	b := make([]byte, opaqueNameBytes)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// storeManifest stores the manifest entry of the object stored at key, the backup of dir, as name, encrypted to
+// recipients. Without it the object is restored under its opaque name, so failures fail the backup.
+func (b *BackupManager) storeManifest(ctx context.Context, key, name, dir string, recipients openpgp.EntityList) error {
+	rel := b.store.TrimPrefix([]string{key})[0]
+	backup, stored, _ := b.splitKey(rel)
+	opaque, _, _ := strings.Cut(stored, ".")
+
+	workDir, err := os.MkdirTemp("", stagingStream)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(workDir)
+	}()
+
+	local := filepath.Join(workDir, manifestDir+".json.gpg")
+	if err := writeStream(local, recipients, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(manifestEntry{Name: name, Dir: dir})
+	}); err != nil {
+		return err
+	}
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return b.store.Put(ctx, auxKey(backup)+"/"+manifestDir+"/"+opaque+".json.gpg", f)
+}
+
+// loadManifest downloads and decrypts the manifest of backup with the private key of opts, and returns the names of
+// its objects by opaque name, see restoredName. It is empty for backups without opaque names, or without a private
+// key.
+func (b *BackupManager) loadManifest(
+	ctx context.Context, objects []storage.ObjectInfo, backup, workDir string, opts RestoreOptions,
+) map[string]string {
+	names := map[string]string{}
+	if opts.PrivateKey == "" {
+		return names
+	}
+
+	prefix := auxKey(backup) + "/" + manifestDir + "/"
+	for _, obj := range objects {
+		opaque, ok := strings.CutPrefix(b.store.TrimPrefix([]string{obj.Key})[0], prefix)
+		if !ok || !strings.HasSuffix(opaque, ".json.gpg") {
+			continue
+		}
+		opaque = strings.TrimSuffix(opaque, ".json.gpg")
+
+		entry, err := b.readManifestEntry(ctx, obj.Key, filepath.Join(workDir, opaque+".json.gpg"), opts)
+		if err != nil {
+			slog.WarnContext(ctx, "Error reading manifest entry", "key", obj.Key, "error", err)
+			continue
+		}
+		names[opaque] = entry.Name
+	}
+	return names
+}
+
+// restoredName returns the name the object name is restored under: the name it was backed up as, when it is stored
+// under an opaque name listed in names, or name itself.
+func restoredName(name string, names map[string]string) string {
+	opaque, _, _ := strings.Cut(name, ".")
+	if plain, ok := names[opaque]; ok && !strings.Contains(name, "/") {
+		return plain
+	}
+	return name
+}
+
+// readManifestEntry downloads the manifest entry at key to local and decrypts it.
+func (b *BackupManager) readManifestEntry(ctx context.Context, key, local string, opts RestoreOptions) (manifestEntry, error) {
+	var entry manifestEntry
+	if err := b.store.Download(ctx, key, local); err != nil {
+		return entry, err
+	}
+	defer func() {
+		_ = os.Remove(local)
+	}()
+
+	b.gpg.SetPrivateKey(opts.PrivateKey)
+	decrypted, err := b.gpg.DecryptFile(local, opts.Passphrase)
+	if err != nil {
+		return entry, err
+	}
+	defer func() {
+		_ = os.Remove(decrypted)
+	}()
+
+	data, err := os.ReadFile(decrypted)
+	if err != nil {
+		return entry, err
+	}
+	return entry, json.Unmarshal(data, &entry)
+}
+
+// storedName returns the name an object named name, ending with the extensions ext, is stored under: an opaque name
+// keeping ext with opaque names, or name itself.
+func (b *BackupManager) storedName(name, ext string) string {
+	if !b.cfg.Backup.OpaqueNames {
+		return name
+	}
+	return opaqueName() + ext
+}
@@ -0,0 +1,196 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/naming"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/units"
+)
+
+// ErrQuotaExceeded is returned when a stored size quota blocks a backup.
+var ErrQuotaExceeded = errors.New("stored size quota exceeded")
+
+// backupUsage is the stored size of a single backup, optionally restricted to one directory.
+type backupUsage struct {
+	timestamp string
+	keys      []string
+	size      int64
+}
+
+// splitBackupKey splits a key relative to the host prefix into its timestamp and top-level object name.
+func (b *BackupManager) splitBackupKey(key string) (string, string) {
+	if hidden, _, _ := strings.Cut(key, "/"); hidden == auxDir {
+		return auxDir, ""
+	}
+	timestamp, rest, _ := b.splitKey(key)
+	name, _, _ := strings.Cut(rest, "/")
+	return timestamp, name
+}
+
+// belongsToDir reports whether a top-level object name was produced by backing up dir.
+func (b *BackupManager) belongsToDir(name, dir string) bool {
+	base := filepath.Base(filepath.Clean(dir))
+	if name == base {
+		return true
+	}
+
+	// Archive names are rendered from the template; the timestamp is the only part that varies between runs.
+	const timestampMarker = "\x00timestamp\x00"
+	tmpl := strings.ReplaceAll(b.cfg.Backup.ArchiveNameTemplate, "{timestamp}", timestampMarker)
+	rendered := naming.SanitizeSegment(naming.Render(tmpl, naming.Vars{
+		"dir":      base,
+		"path":     naming.SanitizePath(dir),
+		"hostname": b.cfg.Backup.Hostname,
+		"label":    b.cfg.Backup.Label,
+	}))
+	pattern := strings.ReplaceAll(regexp.QuoteMeta(rendered), timestampMarker, ".+")
+
+	matched, err := regexp.MatchString("^"+pattern+`(\..+)?$`, name)
+	return err == nil && matched
+}
+
+func totalUsage(usage []backupUsage) int64 {
+	var total int64
+	for _, u := range usage {
+		total += u.size
+	}
+	return total
+}
+
+// usageByBackup groups objects by backup timestamp, newest first. Only the backups named after the key layout are
+// counted, not those of other hosts sharing its directory.
+// When dir is set only objects of that directory are considered and keys hold the individual objects.
+func (b *BackupManager) usageByBackup(objects []storage.ObjectInfo, dir string) []backupUsage {
+	byTimestamp := map[string]*backupUsage{}
+	layout := b.keyLayout()
+
+	for _, obj := range objects {
+		rel := b.store.TrimPrefix([]string{obj.Key})[0]
+		timestamp, name := b.splitBackupKey(rel)
+		if timestamp == "" || timestamp == auxDir || (dir != "" && !b.belongsToDir(name, dir)) {
+			continue
+		}
+		if _, ok := layout.Parse(timestamp); !ok {
+			continue
+		}
+
+		u, ok := byTimestamp[timestamp]
+		if !ok {
+			u = &backupUsage{timestamp: timestamp}
+			if dir == "" {
+				u.keys = []string{timestamp}
+			}
+			byTimestamp[timestamp] = u
+		}
+		if dir != "" {
+			u.keys = append(u.keys, rel)
+		}
+		u.size += obj.Size
+	}
+
+	usage := make([]backupUsage, 0, len(byTimestamp))
+	for _, u := range byTimestamp {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].timestamp > usage[j].timestamp
+	})
+
+	return usage
+}
+
+// purgeToQuota deletes the oldest backups until usage fits limit, always keeping the newest backup.
+func (b *BackupManager) purgeToQuota(ctx context.Context, usage []backupUsage, limit int64) {
+	used := totalUsage(usage)
+	for i := len(usage) - 1; i > 0 && used > limit; i-- {
+		slog.InfoContext(ctx, "Purging backup to satisfy quota", "timestamp", usage[i].timestamp, "size", usage[i].size)
+		for _, key := range usage[i].keys {
+			del := b.store.Delete
+			if key == usage[i].timestamp {
+				del = b.deleteBackup
+			}
+			if err := del(ctx, key); err != nil {
+				slog.ErrorContext(ctx, "Error deleting backup", "key", key, "error", err)
+				b.notifierStore.NotifyBackupDeleteFailure(ctx, key, err)
+			}
+		}
+		used -= usage[i].size
+	}
+
+	if used > limit {
+		slog.WarnContext(ctx, "Quota still exceeded after purging", "used", units.FormatBytes(used), "limit", units.FormatBytes(limit))
+	}
+}
+
+// applyQuota notifies about an exceeded quota and applies the configured action. It reports whether backups must be blocked.
+func (b *BackupManager) applyQuota(ctx context.Context, scope string, usage []backupUsage, limit int64) bool {
+	used := totalUsage(usage)
+	if used <= limit {
+		return false
+	}
+
+	action := b.cfg.Backup.QuotaAction
+	slog.WarnContext(ctx, "Stored size quota exceeded",
+		"scope", scope, "used", units.FormatBytes(used), "limit", units.FormatBytes(limit), "action", action)
+	b.notifierStore.NotifyQuotaExceeded(ctx, scope, used, limit, action)
+
+	switch action {
+	case config.QuotaActionBlock:
+		return true
+	case config.QuotaActionPurge:
+		b.purgeToQuota(ctx, usage, limit)
+	}
+	return false
+}
+
+// enforceQuotas checks stored usage against the configured quotas and returns the dirs whose backup is blocked.
+func (b *BackupManager) enforceQuotas(ctx context.Context) (map[string]bool, error) {
+	blocked := map[string]bool{}
+
+	hostLimit := b.cfg.Backup.MaxStoredSizeBytes()
+	if hostLimit == 0 && len(b.cfg.Backup.DirQuotas) == 0 {
+		return blocked, nil
+	}
+
+	objects, err := b.store.ListObjects(ctx)
+	if err != nil {
+		// Never block backups just because usage could not be determined.
+		slog.WarnContext(ctx, "Unable to determine stored size; skipping quota checks", "error", err)
+		return blocked, nil //nolint:nilerr // quota checks are best effort
+	}
+
+	if hostLimit > 0 {
+		if b.applyQuota(ctx, b.cfg.Backup.Hostname, b.usageByBackup(objects, ""), hostLimit) {
+			return nil, fmt.Errorf("%w for host %s", ErrQuotaExceeded, b.cfg.Backup.Hostname)
+		}
+
+		// A host wide purge may have removed objects; refresh before checking directories.
+		if b.cfg.Backup.QuotaAction == config.QuotaActionPurge && len(b.cfg.Backup.DirQuotas) > 0 {
+			if objects, err = b.store.ListObjects(ctx); err != nil {
+				slog.WarnContext(ctx, "Unable to determine stored size; skipping directory quota checks", "error", err)
+				return blocked, nil //nolint:nilerr // quota checks are best effort
+			}
+		}
+	}
+
+	for _, dir := range b.cfg.Backup.Paths() {
+		limit := b.cfg.Backup.DirMaxStoredSizeBytes(dir)
+		if limit == 0 {
+			continue
+		}
+		if b.applyQuota(ctx, dir, b.usageByBackup(objects, dir), limit) {
+			blocked[dir] = true
+		}
+	}
+
+	return blocked, nil
+}
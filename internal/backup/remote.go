@@ -0,0 +1,160 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hibare/arclift/internal/config"
+)
+
+// ErrRemoteFetchFailed is returned when a remote dir cannot be fetched over SSH.
+var ErrRemoteFetchFailed = errors.New("failed to fetch remote dir")
+
+// shellQuote quotes s for the POSIX shell running the remote command.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fetchRemote copies the tree of the remote dir into a local directory named like it, streaming a tar of it over
+// ssh, and returns that directory. Only directories and regular files are copied; symlinks and special files are
+// skipped, so a remote host cannot make arclift read local files. The returned cleanup removes the copy, wiping it
+// when encryption is enabled, and must always be called.
+func (b *BackupManager) fetchRemote(ctx context.Context, dir string) (string, func(), error) {
+	remote, err := config.ParseRemote(dir)
+	if err != nil {
+		return "", func() {}, err
+	}
+
+	workDir, err := os.MkdirTemp("", stagingRemote)
+	if err != nil {
+		return "", func() {}, err
+	}
+	cleanup := func() {
+		if !b.cfg.Backup.Encryption.Enabled {
+			_ = os.RemoveAll(workDir)
+			return
+		}
+		// The copy is plaintext; wipe it so an encrypted backup does not leave it behind on disk.
+		if wErr := wipeDir(workDir); wErr != nil {
+			slog.WarnContext(ctx, "Error wiping remote dir copy", "dir", workDir, "error", wErr)
+		}
+	}
+	root := filepath.Join(workDir, path.Base(remote.Path))
+
+	args := append([]string{"-o", "BatchMode=yes"}, b.cfg.Backup.SSH.Options...)
+	if remote.Port != "" {
+		args = append(args, "-p", remote.Port)
+	}
+	args = append(args, remote.Destination, b.remoteTarCommand(remote.Path))
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, b.cfg.Backup.SSH.Command, args...) //nolint:gosec // the ssh client and remote dirs come from the config file
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", cleanup, err
+	}
+
+	slog.InfoContext(ctx, "Fetching remote dir", "dir", dir)
+	if err := cmd.Start(); err != nil {
+		return "", cleanup, fmt.Errorf("%w %s: %w", ErrRemoteFetchFailed, dir, err)
+	}
+	extractErr := extractTar(ctx, stdout, root)
+	// Drain the stream so ssh does not block writing to it when the extraction stopped early.
+	_, _ = io.Copy(io.Discard, stdout)
+	waitErr := cmd.Wait()
+
+	switch {
+	case waitErr != nil:
+		return "", cleanup, fmt.Errorf("%w %s: %w: %s", ErrRemoteFetchFailed, dir, waitErr, strings.TrimSpace(stderr.String()))
+	case extractErr != nil:
+		return "", cleanup, fmt.Errorf("%w %s: %w", ErrRemoteFetchFailed, dir, extractErr)
+	}
+	return root, cleanup, nil
+}
+
+// remoteTarCommand returns the command streaming a tar of the remote dir, in the configured format.
+func (b *BackupManager) remoteTarCommand(dir string) string {
+	flags := []string{"tar"}
+	if f := b.cfg.Backup.SSH.TarFormat; f != "" && f != config.TarFormatNative {
+		flags = append(flags, "--format="+f)
+	}
+	if b.cfg.Backup.OneFileSystem {
+		flags = append(flags, "--one-file-system")
+	}
+	return strings.Join(flags, " ") + " -cf - -C " + shellQuote(dir) + " ."
+}
+
+// extractTar extracts the directories and regular files of the tar stream r under root.
+func extractTar(ctx context.Context, r io.Reader, root string) error {
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("unsafe path in remote tar: %s", hdr.Name)
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractFile(tr, target, hdr); err != nil {
+				return err
+			}
+		default:
+			slog.DebugContext(ctx, "Skipping remote entry that is not a regular file", "name", hdr.Name, "type", string(hdr.Typeflag))
+		}
+	}
+}
+
+// extractFile writes the current entry of tr to target, keeping its modification time.
+func extractFile(tr *tar.Reader, target string, hdr *tar.Header) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, tr); err != nil { //nolint:gosec // the size of remote files is not limited, like local ones
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+}
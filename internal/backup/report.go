@@ -0,0 +1,121 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/hibare/arclift/internal/state"
+	"github.com/hibare/arclift/internal/version"
+	"gopkg.in/yaml.v3"
+)
+
+// runReportName is the name of the run report among the auxiliary objects of a backup.
+const runReportName = "run-report.json"
+
+// runReport is the machine readable report of a backup run, stored with every backup the run made so audit tooling
+// can verify backups without access to the host.
+type runReport struct {
+	Version  string `json:"version"`
+	Hostname string `json:"hostname"`
+	state.RunRecord
+
+	Results []state.DirRecord `json:"results"`
+}
+
+// runKeys returns the keys of the backups made by a run, whose directory results are results.
+func (b *BackupManager) runKeys(results []state.DirRecord) []string {
+	var keys []string
+	for _, rec := range results {
+		// Unchanged dirs are stored in the backup of an earlier run.
+		if rec.Key == "" || rec.Status == state.StatusUnchanged {
+			continue
+		}
+		key, _, _ := b.splitKey(b.store.TrimPrefix([]string{rec.Key})[0])
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// storeRunReport stores the report of run, whose directory results are results, with keys, the backups the run made.
+// Failures are logged; they do not fail the run.
+func (b *BackupManager) storeRunReport(ctx context.Context, run state.RunRecord, results []state.DirRecord, keys []string) {
+	// The report names the host and its dirs, which opaque names keep out of the bucket.
+	if len(keys) == 0 || b.cfg.Backup.OpaqueNames {
+		return
+	}
+
+	body, err := json.MarshalIndent(runReport{
+		Version:   version.CurrentVersion,
+		Hostname:  b.cfg.Backup.Hostname,
+		RunRecord: run,
+		Results:   results,
+	}, "", "  ")
+	if err != nil {
+		slog.WarnContext(ctx, "Error encoding run report", "error", err)
+		return
+	}
+
+	for _, key := range keys {
+		if err := b.store.Put(ctx, auxKey(key)+"/"+runReportName, bytes.NewReader(body)); err != nil {
+			slog.WarnContext(ctx, "Error storing run report", "key", key, "error", err)
+		}
+	}
+}
+
+// Names of the copy of the configuration among the auxiliary objects of a backup, see storeConfig.
+const (
+	configName       = "config.yaml"
+	gpgPublicKeyName = "gpg-public-key.asc"
+)
+
+// storeConfig stores a redacted copy of the effective configuration, and the GPG public key backups are encrypted to,
+// with keys, the backups a run made, when backup.include-config is enabled. Failures are logged; they do not fail
+// the run.
+func (b *BackupManager) storeConfig(ctx context.Context, keys []string) {
+	if !b.cfg.Backup.IncludeConfig || len(keys) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Effective configuration of %s, arclift %s, with secrets redacted\n",
+		b.cfg.Backup.Hostname, version.CurrentVersion)
+
+	var publicKey string
+	if b.cfg.Backup.Encryption.Enabled {
+		var err error
+		if publicKey, err = b.gpg.ReadPublicKeyFromFile(); err != nil {
+			slog.WarnContext(ctx, "Error reading GPG key, storing the config without it", "error", err)
+		} else if entities, pErr := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey)); pErr == nil {
+			for _, e := range entities {
+				fmt.Fprintf(&buf, "# Encrypted to GPG key %X, stored as %s\n", e.PrimaryKey.Fingerprint, gpgPublicKeyName)
+			}
+		}
+	}
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(b.cfg.Redacted()); err != nil {
+		slog.WarnContext(ctx, "Error encoding config", "error", err)
+		return
+	}
+
+	for _, key := range keys {
+		if err := b.store.Put(ctx, auxKey(key)+"/"+configName, bytes.NewReader(buf.Bytes())); err != nil {
+			slog.WarnContext(ctx, "Error storing config", "key", key, "error", err)
+		}
+		if publicKey == "" {
+			continue
+		}
+		if err := b.store.Put(ctx, auxKey(key)+"/"+gpgPublicKeyName, strings.NewReader(publicKey)); err != nil {
+			slog.WarnContext(ctx, "Error storing GPG key", "key", key, "error", err)
+		}
+	}
+}
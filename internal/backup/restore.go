@@ -0,0 +1,550 @@
+package backup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/md5" //nolint:gosec // S3 ETags of single part uploads are MD5 digests
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/storage"
+)
+
+const (
+	// RestoreComplete means every file was restored and verified.
+	RestoreComplete = "complete"
+
+	// RestorePartial means some files were restored while others were skipped over errors.
+	RestorePartial = "partial"
+
+	// RestoreFailed means nothing could be restored.
+	RestoreFailed = "failed"
+
+	archiveExt    = ".zip"
+	tarArchiveExt = ".tar.gz"
+	encryptedExt  = ".gpg"
+)
+
+var (
+	// ErrBackupNotFound is returned when the requested backup does not exist.
+	ErrBackupNotFound = errors.New("backup not found")
+
+	// ErrChecksumMismatch is returned when restored data does not match its stored checksum.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrPrivateKeyRequired is returned when restoring an encrypted archive without a private key.
+	ErrPrivateKeyRequired = errors.New("private key required to restore encrypted archive")
+
+	// ErrUnsafePath is returned when an object or archive entry would be written outside the target.
+	ErrUnsafePath = errors.New("path escapes restore target")
+)
+
+// RestoreOptions controls a restore.
+type RestoreOptions struct {
+	// Backup is the backup timestamp, as listed by ListBackups.
+	Backup string
+
+	// Target is the directory the backup is restored into.
+	Target string
+
+	// Overwrite replaces existing files instead of skipping them.
+	Overwrite bool
+
+	// PrivateKey is the path of the armored GPG private key used to decrypt encrypted archives.
+	PrivateKey string
+
+	// Passphrase unlocks PrivateKey.
+	Passphrase string
+
+	// Notify sends the summary to the configured notifiers.
+	Notify bool
+}
+
+// RestoreSummary is the outcome of a restore.
+type RestoreSummary struct {
+	Backup             string            `json:"backup"`
+	Target             string            `json:"target"`
+	Outcome            string            `json:"outcome"`
+	Restored           int               `json:"restored"`
+	Skipped            int               `json:"skipped"`
+	Failed             int               `json:"failed"`
+	ChecksumMismatches int               `json:"checksum_mismatches"`
+	Errors             map[string]string `json:"errors,omitempty"`
+}
+
+func (s *RestoreSummary) fail(name string, err error) {
+	if errors.Is(err, ErrChecksumMismatch) || errors.Is(err, zip.ErrChecksum) || errors.Is(err, gzip.ErrChecksum) {
+		s.ChecksumMismatches++
+	} else {
+		s.Failed++
+	}
+	s.Errors[name] = err.Error()
+}
+
+func (s *RestoreSummary) outcome() string {
+	switch {
+	case s.Failed == 0 && s.ChecksumMismatches == 0:
+		return RestoreComplete
+	case s.Restored > 0:
+		return RestorePartial
+	default:
+		return RestoreFailed
+	}
+}
+
+// RestoreError is returned when a restore did not complete.
+type RestoreError struct {
+	Summary RestoreSummary
+}
+
+func (e *RestoreError) Error() string {
+	return fmt.Sprintf("restore %s: %d failed, %d checksum mismatches", e.Summary.Outcome, e.Summary.Failed, e.Summary.ChecksumMismatches)
+}
+
+// ExitCode returns the process exit code for the restore outcome.
+func (e *RestoreError) ExitCode() int {
+	if e.Summary.Outcome == RestorePartial {
+		return constants.ExitCodeRestorePartial
+	}
+	return constants.ExitCodeFailure
+}
+
+// safeJoin joins name onto root, refusing names that would escape root.
+func safeJoin(root, name string) (string, error) {
+	p := filepath.Join(root, filepath.FromSlash(name))
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	return p, nil
+}
+
+func exists(p string) bool {
+	_, err := os.Lstat(p)
+	return err == nil
+}
+
+// digestETag reports whether etag is the MD5 digest of its object. Multipart ETags, ending with -<parts>, are not.
+func digestETag(etag string) bool {
+	return len(etag) == md5.Size*2 && !strings.Contains(etag, "-")
+}
+
+// verifyETag compares the MD5 of path with etag. Multipart ETags are not digests and are not verified.
+func verifyETag(path, etag string) error {
+	if !digestETag(etag) {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := md5.New() //nolint:gosec // S3 ETags of single part uploads are MD5 digests
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != etag {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// verifyDownload compares the file at path with sum, the SHA-256 checksum recorded when it was uploaded, see
+// storeChecksums, or with etag without one.
+func verifyDownload(path, sum, etag string) error {
+	if sum == "" {
+		return verifyETag(path, etag)
+	}
+	got, err := fileChecksum(path)
+	if err != nil {
+		return err
+	}
+	if got != sum {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// moveFile moves src to dst, falling back to a copy when they are on different filesystems.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+		_ = os.Remove(src)
+	}()
+
+	return writeFile(dst, in)
+}
+
+// writeFile writes r to dst through a temporary file so a failed write never leaves a partial file behind.
+func writeFile(dst string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// archiveEntry is an entry of an archive being extracted.
+type archiveEntry struct {
+	name    string
+	isDir   bool
+	meta    fileMeta
+	hasMeta bool
+	open    func() (io.ReadCloser, error)
+}
+
+// archiveFormatOf returns the format of the archive name, encrypted or not, and name without its extensions. ok is
+// false when name is not that of an archive.
+func archiveFormatOf(name string) (format, base string, ok bool) {
+	if strings.Contains(name, "/") {
+		return "", "", false
+	}
+	plain := strings.TrimSuffix(name, encryptedExt)
+	if base, ok := strings.CutSuffix(plain, tarArchiveExt); ok {
+		return config.ArchiveFormatTar, base, true
+	}
+	if base, ok := strings.CutSuffix(plain, archiveExt); ok {
+		return config.ArchiveFormatZip, base, true
+	}
+	return "", "", false
+}
+
+// zipEntries calls fn with each entry of the zip archive at path.
+func zipEntries(path string, fn func(archiveEntry) error) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	for _, f := range r.File {
+		meta, hasMeta := zipMeta(f)
+		if err := fn(archiveEntry{name: f.Name, isDir: f.FileInfo().IsDir(), meta: meta, hasMeta: hasMeta, open: f.Open}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tarEntries calls fn with each entry of the tar stream r, in order. Entries other than directories, regular files
+// and special files are skipped.
+func tarEntries(r io.Reader, fn func(archiveEntry) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeFifo, tar.TypeChar, tar.TypeBlock:
+		default:
+			continue
+		}
+		meta := fileMeta{Mode: hdr.FileInfo().Mode(), UID: hdr.Uid, GID: hdr.Gid, ModTime: hdr.ModTime}
+		if hdr.Uid == 0 && hdr.Uname == "" {
+			// Archives taken on Windows do not record owners.
+			meta.UID, meta.GID = -1, -1
+		}
+		entry := archiveEntry{
+			name: strings.TrimSuffix(hdr.Name, "/"), isDir: hdr.Typeflag == tar.TypeDir, meta: meta, hasMeta: true,
+			open: func() (io.ReadCloser, error) { return io.NopCloser(tr), nil },
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// extractArchive extracts the archive at path, in format, into dir, restoring the recorded metadata of its entries.
+// The metadata of directories that already existed is only restored with Overwrite.
+func (b *BackupManager) extractArchive(
+	ctx context.Context, path, format, dir string, opts RestoreOptions, summary *RestoreSummary,
+) error {
+	chown := canChown()
+	var dirs []dirMeta
+	defer func() {
+		applyDirMeta(ctx, dirs, chown)
+	}()
+
+	extract := func(e archiveEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := filepath.Join(filepath.Base(dir), e.name)
+
+		dst, err := safeJoin(dir, e.name)
+		if err != nil {
+			summary.fail(name, err)
+			return nil
+		}
+
+		if e.isDir {
+			existed := exists(dst)
+			if err := os.MkdirAll(dst, 0o750); err != nil {
+				summary.fail(name, err)
+				return nil
+			}
+			if e.hasMeta && (opts.Overwrite || !existed) {
+				dirs = append(dirs, dirMeta{path: dst, meta: e.meta})
+			}
+			return nil
+		}
+		if e.hasMeta && isSpecial(e.meta.Mode) {
+			restoreSpecial(ctx, name, dst, e.meta, chown, opts, summary)
+			return nil
+		}
+
+		if !opts.Overwrite && exists(dst) {
+			slog.DebugContext(ctx, "Skipping existing file", "path", dst)
+			summary.Skipped++
+			return nil
+		}
+
+		rc, err := e.open()
+		if err != nil {
+			summary.fail(name, err)
+			return nil
+		}
+		err = writeFile(dst, rc)
+		_ = rc.Close()
+		if err != nil {
+			slog.ErrorContext(ctx, "Error restoring file", "path", dst, "error", err)
+			summary.fail(name, err)
+			return nil
+		}
+		if e.hasMeta {
+			if mErr := e.meta.apply(dst, chown); mErr != nil {
+				slog.WarnContext(ctx, "Error restoring file metadata", "path", dst, "error", mErr)
+			}
+		}
+		summary.Restored++
+		return nil
+	}
+
+	if format != config.ArchiveFormatTar {
+		return zipEntries(path, extract)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	return tarEntries(gz, extract)
+}
+
+// restoreObject restores a single stored object. name is the key relative to the backup, sum its recorded checksum,
+// if any, and meta holds the recorded metadata of unarchived files by name.
+func (b *BackupManager) restoreObject(
+	ctx context.Context, obj storage.ObjectInfo, name, sum, workDir string, meta map[string]fileMeta, opts RestoreOptions,
+	summary *RestoreSummary,
+) {
+	format, base, isArchive := archiveFormatOf(name)
+
+	// Archives are extracted into a directory named after them, other objects restored to their own path.
+	rel := name
+	if isArchive {
+		rel = base
+	}
+	dst, err := safeJoin(opts.Target, rel)
+	if err != nil {
+		summary.fail(name, err)
+		return
+	}
+	if !isArchive && !opts.Overwrite && exists(dst) {
+		slog.DebugContext(ctx, "Skipping existing file", "path", dst)
+		summary.Skipped++
+		return
+	}
+
+	local := filepath.Join(workDir, filepath.Base(name))
+	slog.InfoContext(ctx, "Downloading object", "key", obj.Key)
+	if err := b.store.Download(ctx, obj.Key, local); err != nil {
+		slog.ErrorContext(ctx, "Error downloading object", "key", obj.Key, "error", err)
+		summary.fail(name, err)
+		return
+	}
+	defer func() {
+		_ = os.Remove(local)
+	}()
+
+	if err := verifyDownload(local, sum, obj.ETag); err != nil {
+		slog.ErrorContext(ctx, "Error verifying object", "key", obj.Key, "error", err)
+		summary.fail(name, err)
+		return
+	}
+
+	if !isArchive {
+		if err := moveFile(local, dst); err != nil {
+			summary.fail(name, err)
+			return
+		}
+		if m, ok := meta[name]; ok {
+			if mErr := m.apply(dst, canChown()); mErr != nil {
+				slog.WarnContext(ctx, "Error restoring file metadata", "path", dst, "error", mErr)
+			}
+		}
+		summary.Restored++
+		return
+	}
+
+	if strings.HasSuffix(name, encryptedExt) {
+		if opts.PrivateKey == "" {
+			summary.fail(name, ErrPrivateKeyRequired)
+			return
+		}
+
+		b.gpg.SetPrivateKey(opts.PrivateKey)
+		decrypted, err := b.gpg.DecryptFile(local, opts.Passphrase)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error decrypting archive", "key", obj.Key, "error", err)
+			summary.fail(name, err)
+			return
+		}
+		defer func() {
+			_ = wipeFile(decrypted)
+		}()
+		local = decrypted
+	}
+
+	if err := b.extractArchive(ctx, local, format, dst, opts, summary); err != nil {
+		slog.ErrorContext(ctx, "Error extracting archive", "key", obj.Key, "error", err)
+		summary.fail(name, err)
+	}
+}
+
+// expectRestore announces the size of the objects of backup to the progress of ctx, if any.
+func (b *BackupManager) expectRestore(ctx context.Context, objects []storage.ObjectInfo, backup string) {
+	p := storage.ProgressFrom(ctx)
+	if p == nil {
+		return
+	}
+	var size int64
+	for _, obj := range objects {
+		rel := b.store.TrimPrefix([]string{obj.Key})[0]
+		if timestamp, name, _ := b.splitKey(rel); timestamp == backup && name != "" {
+			size += obj.Size
+		}
+	}
+	p.Expect(size)
+}
+
+// Restore restores a backup into the target directory and reports what was restored.
+// A restore that is not complete returns a *RestoreError carrying the summary.
+func (b *BackupManager) Restore(ctx context.Context, opts RestoreOptions) (RestoreSummary, error) {
+	if legacy, key, ok := b.legacyBackup(opts.Backup); ok {
+		listed := opts.Backup
+		opts.Backup = key
+		summary, err := legacy.Restore(ctx, opts)
+		summary.Backup = listed
+		return summary, err
+	}
+
+	summary := RestoreSummary{Backup: opts.Backup, Target: opts.Target, Errors: map[string]string{}}
+
+	objects, err := b.store.ListObjects(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing objects", "error", err)
+		return summary, err
+	}
+
+	workDir, err := os.MkdirTemp("", constants.ProgramIdentifier+"-restore-")
+	if err != nil {
+		return summary, err
+	}
+	defer func() {
+		_ = os.RemoveAll(workDir)
+	}()
+
+	meta := b.loadMetadata(ctx, objects, opts.Backup, workDir)
+	sums := b.loadChecksums(ctx, objects, opts.Backup, workDir)
+	names := b.loadManifest(ctx, objects, opts.Backup, workDir, opts)
+	dirs := restoredDirs(meta, opts)
+	b.expectRestore(ctx, objects, opts.Backup)
+
+	found := false
+	for _, obj := range objects {
+		rel := b.store.TrimPrefix([]string{obj.Key})[0]
+		timestamp, name, _ := b.splitKey(rel)
+		if timestamp != opts.Backup || name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+
+		found = true
+		b.restoreObject(ctx, obj, restoredName(name, names), sums[name], workDir, meta, opts, &summary)
+	}
+	if found {
+		restoreSpecialFiles(ctx, meta, opts, &summary)
+	}
+	applyDirMeta(ctx, dirs, canChown())
+
+	if !found {
+		return summary, fmt.Errorf("%w: %s", ErrBackupNotFound, opts.Backup)
+	}
+
+	summary.Outcome = summary.outcome()
+	slog.InfoContext(ctx, "Restore finished", "backup", opts.Backup, "outcome", summary.Outcome,
+		"restored", summary.Restored, "skipped", summary.Skipped, "failed", summary.Failed, "checksumMismatches", summary.ChecksumMismatches)
+
+	if opts.Notify {
+		b.notifierStore.NotifyRestore(ctx, opts.Backup, opts.Target, summary.Outcome,
+			summary.Restored, summary.Skipped, summary.Failed, summary.ChecksumMismatches)
+	}
+
+	if summary.Outcome != RestoreComplete {
+		return summary, &RestoreError{Summary: summary}
+	}
+	return summary, nil
+}
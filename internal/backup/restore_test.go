@@ -0,0 +1,153 @@
+package backup
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupManager_Restore_RoundTrip(t *testing.T) {
+	files := map[string]string{"a.txt": "alpha", "nested/b.txt": "beta", "nested/deep/c.txt": "gamma"}
+
+	tests := []struct {
+		name   string
+		backup string
+	}{
+		{name: "files"},
+		{name: "zip archive", backup: "archive-dirs: true"},
+		{name: "tar archive", backup: "archive-dirs: true\narchive-format: tar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTree(t, files)
+			b, mem := newTestManager(t, []string{dir}, tt.backup)
+			require.NoError(t, b.Backup(t.Context()))
+
+			keys, err := b.ListBackups(t.Context())
+			require.NoError(t, err)
+			require.Len(t, keys, 1)
+
+			target := t.TempDir()
+			summary, err := b.Restore(t.Context(), RestoreOptions{Backup: keys[0], Target: target})
+			require.NoError(t, err)
+			assert.Equal(t, RestoreComplete, summary.Outcome)
+			assert.Equal(t, len(files), summary.Restored)
+			assert.Empty(t, summary.Errors)
+			for name, content := range files {
+				data, rErr := os.ReadFile(filepath.Join(target, "data", filepath.FromSlash(name)))
+				require.NoError(t, rErr)
+				assert.Equal(t, content, string(data), name)
+			}
+
+			// Restoring again keeps the restored files.
+			summary, err = b.Restore(t.Context(), RestoreOptions{Backup: keys[0], Target: target})
+			require.NoError(t, err)
+			assert.Equal(t, 0, summary.Restored)
+			assert.Equal(t, len(files), summary.Skipped)
+
+			// A corrupted object fails the restore with a checksum mismatch.
+			for _, key := range mem.keys() {
+				if strings.HasPrefix(key, "backups/host/"+keys[0]+"/") {
+					mem.objects[key][0] ^= 0xff
+					break
+				}
+			}
+			summary, err = b.Restore(t.Context(), RestoreOptions{Backup: keys[0], Target: t.TempDir(), Overwrite: true})
+			var rErr *RestoreError
+			require.ErrorAs(t, err, &rErr)
+			assert.NotEqual(t, RestoreComplete, summary.Outcome)
+			assert.Positive(t, summary.ChecksumMismatches+summary.Failed)
+		})
+	}
+}
+
+func TestBackupManager_Restore_Metadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		backup string
+	}{
+		{name: "files"},
+		{name: "zip archive", backup: "archive-dirs: true"},
+		{name: "tar archive", backup: "archive-dirs: true\narchive-format: tar"},
+	}
+
+	// The owner is only restored as root; it is then given to an owner the files are not created with.
+	const uid, gid = 4321, 4321
+	chown := canChown()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTree(t, map[string]string{"a.txt": "alpha", "nested/b.txt": "beta"})
+			fileTime := time.Date(2024, 1, 31, 13, 4, 5, 123456700, time.UTC)
+			dirTime := time.Date(2023, 6, 1, 8, 0, 0, 0, time.UTC)
+			require.NoError(t, os.Chmod(filepath.Join(dir, "a.txt"), 0o640))
+			require.NoError(t, os.Chtimes(filepath.Join(dir, "a.txt"), fileTime, fileTime))
+			require.NoError(t, os.Chmod(filepath.Join(dir, "nested"), 0o700))
+			require.NoError(t, os.Chtimes(filepath.Join(dir, "nested"), dirTime, dirTime))
+			if chown {
+				require.NoError(t, os.Lchown(filepath.Join(dir, "a.txt"), uid, gid))
+			}
+
+			b, _ := newTestManager(t, []string{dir}, tt.backup)
+			require.NoError(t, b.Backup(t.Context()))
+			keys, err := b.ListBackups(t.Context())
+			require.NoError(t, err)
+			require.Len(t, keys, 1)
+
+			target := t.TempDir()
+			summary, err := b.Restore(t.Context(), RestoreOptions{Backup: keys[0], Target: target})
+			require.NoError(t, err)
+			assert.Equal(t, RestoreComplete, summary.Outcome)
+
+			info, err := os.Stat(filepath.Join(target, "data", "a.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, fs.FileMode(0o640), info.Mode().Perm())
+			assert.True(t, info.ModTime().Equal(fileTime), "file modification time %s, expected %s", info.ModTime(), fileTime)
+			if chown {
+				gotUID, gotGID, ok := fileOwner(info)
+				require.True(t, ok)
+				assert.Equal(t, uid, gotUID)
+				assert.Equal(t, gid, gotGID)
+			} else {
+				t.Log("not running as root, the owner is not checked")
+			}
+
+			info, err = os.Stat(filepath.Join(target, "data", "nested"))
+			require.NoError(t, err)
+			assert.True(t, info.IsDir())
+			assert.Equal(t, fs.FileMode(0o700), info.Mode().Perm())
+			assert.True(t, info.ModTime().Equal(dirTime), "directory modification time %s, expected %s", info.ModTime(), dirTime)
+		})
+	}
+}
+
+func TestBackupManager_Restore_ArchiveTraversal(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.txt": "alpha"})
+	b, mem := newTestManager(t, []string{dir}, "archive-dirs: true")
+	require.NoError(t, b.Backup(t.Context()))
+	keys, err := b.ListBackups(t.Context())
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+
+	// An archive named "...zip" would be extracted into the parent of the target.
+	archive := "backups/host/" + keys[0] + "/data.zip"
+	require.Contains(t, mem.keys(), archive)
+	mem.objects["backups/host/"+keys[0]+"/...zip"] = mem.objects[archive]
+	delete(mem.objects, archive)
+
+	parent := t.TempDir()
+	target := filepath.Join(parent, "target")
+	summary, err := b.Restore(t.Context(), RestoreOptions{Backup: keys[0], Target: target})
+	var rErr *RestoreError
+	require.ErrorAs(t, err, &rErr)
+	require.Contains(t, summary.Errors, "...zip")
+	assert.Contains(t, summary.Errors["...zip"], ErrUnsafePath.Error())
+	assert.NoFileExists(t, filepath.Join(parent, "a.txt"))
+}
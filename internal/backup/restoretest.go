@@ -0,0 +1,188 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/constants"
+)
+
+// ErrRestoreTestFailed is returned when a restore test found problems.
+var ErrRestoreTestFailed = errors.New("restore test failed")
+
+// RestoreTestOptions controls a restore test.
+type RestoreTestOptions struct {
+	// Backup is the backup to test, as listed by ListBackups; the newest backup when empty.
+	Backup string
+
+	// PrivateKey and Passphrase decrypt encrypted archives, see RestoreOptions.
+	PrivateKey string
+	Passphrase string
+
+	// Notify sends the result to the configured notifiers.
+	Notify bool
+}
+
+// RestoreTestReport is the outcome of a restore test.
+type RestoreTestReport struct {
+	Backup   string        `json:"backup"`
+	Passed   bool          `json:"passed"`
+	Duration time.Duration `json:"duration"`
+
+	// Expected is the number of files listed by the file index of the backup, 0 for backups taken before indexes
+	// were stored.
+	Expected int `json:"expected"`
+
+	// Restored is the number of files restored.
+	Restored int `json:"restored"`
+
+	// Verified counts the restored files whose content was compared with a recorded checksum: their own for
+	// unarchived files, that of their archive otherwise.
+	Verified int `json:"verified"`
+
+	// Problems are the errors of the files that failed to restore or to validate, by path relative to the backup.
+	Problems map[string]string `json:"problems"`
+}
+
+// RestoreTest restores a backup into a temporary directory, which is removed afterwards, then checks that every file
+// of its file index was restored with its size and, when recorded, its checksum. It fails with ErrRestoreTestFailed
+// when a file did not restore or validate.
+func (b *BackupManager) RestoreTest(ctx context.Context, opts RestoreTestOptions) (RestoreTestReport, error) {
+	report := RestoreTestReport{Backup: opts.Backup, Problems: map[string]string{}}
+	if report.Backup == "" {
+		infos, err := b.backupDetails(ctx)
+		if err != nil {
+			return report, err
+		}
+		if len(infos) == 0 {
+			return report, fmt.Errorf("%w: no backups stored", ErrBackupNotFound)
+		}
+		report.Backup = infos[0].Key
+	}
+
+	target, err := os.MkdirTemp("", constants.ProgramIdentifier+"-restore-test-")
+	if err != nil {
+		return report, err
+	}
+	defer func() {
+		_ = os.RemoveAll(target)
+	}()
+
+	started := time.Now()
+	slog.InfoContext(ctx, "Testing restore", "backup", report.Backup, "target", target)
+	summary, err := b.Restore(ctx, RestoreOptions{
+		Backup:     report.Backup,
+		Target:     target,
+		PrivateKey: opts.PrivateKey,
+		Passphrase: opts.Passphrase,
+	})
+	var restoreErr *RestoreError
+	if err != nil && !errors.As(err, &restoreErr) {
+		return report, err
+	}
+	report.Restored = summary.Restored
+	maps.Copy(report.Problems, summary.Errors)
+
+	if err := b.checkRestored(ctx, target, &report); err != nil {
+		return report, err
+	}
+	report.Duration = time.Since(started)
+	report.Passed = len(report.Problems) == 0
+
+	slog.InfoContext(ctx, "Restore test finished", "backup", report.Backup, "passed", report.Passed,
+		"expected", report.Expected, "restored", report.Restored, "verified", report.Verified, "problems", len(report.Problems))
+	if opts.Notify {
+		b.notifierStore.NotifyRestoreTest(ctx, report.Backup, report.Expected, report.Restored, report.Verified, report.Problems)
+	}
+
+	if !report.Passed {
+		return report, fmt.Errorf("%w: %d problems", ErrRestoreTestFailed, len(report.Problems))
+	}
+	return report, nil
+}
+
+// checkRestored checks the files of the backup of report, restored into target, against its file index and
+// checksums, adding the files that are missing or differ to the problems of report.
+func (b *BackupManager) checkRestored(ctx context.Context, target string, report *RestoreTestReport) error {
+	objects, err := b.store.ListObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	workDir, err := os.MkdirTemp("", constants.ProgramIdentifier+"-restore-test-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(workDir)
+	}()
+
+	sums := b.loadChecksums(ctx, objects, report.Backup, workDir)
+	prefix := auxKey(report.Backup) + "/" + indexDir + "/"
+	for _, obj := range objects {
+		name, ok := strings.CutPrefix(b.store.TrimPrefix([]string{obj.Key})[0], prefix)
+		if !ok || !strings.HasSuffix(name, indexExt) {
+			continue
+		}
+		name = strings.TrimSuffix(name, indexExt)
+
+		entries, err := b.loadIndex(ctx, obj.Key, workDir)
+		if err != nil {
+			report.Problems[name] = fmt.Sprintf("reading file index: %v", err)
+			continue
+		}
+
+		// Archives are extracted into a directory named after them, unarchived dirs are restored as they are named.
+		dir := filepath.Join(target, name)
+		if _, base, ok := archiveFormatOf(name); ok {
+			dir = filepath.Join(target, base)
+		}
+		archiveSum := sums[name]
+		for _, e := range entries {
+			report.Expected++
+			if _, failed := report.Problems[e.Path]; failed {
+				continue
+			}
+			path := dir
+			if _, rest, nested := strings.Cut(e.Path, "/"); nested {
+				path = filepath.Join(dir, filepath.FromSlash(rest))
+			}
+
+			info, err := os.Lstat(path)
+			switch {
+			case err != nil:
+				report.Problems[e.Path] = "not restored"
+				continue
+			case info.Size() != e.Size:
+				report.Problems[e.Path] = fmt.Sprintf("restored %d bytes, backed up %d", info.Size(), e.Size)
+				continue
+			}
+
+			sum := sums[e.Path]
+			if sum == "" {
+				// Restore verified the archive holding the file, and extraction the checksum of each entry.
+				if archiveSum != "" {
+					report.Verified++
+				}
+				continue
+			}
+			got, err := fileChecksum(path)
+			switch {
+			case err != nil:
+				report.Problems[e.Path] = err.Error()
+			case got != sum:
+				report.Problems[e.Path] = ErrChecksumMismatch.Error()
+			default:
+				report.Verified++
+			}
+		}
+	}
+	return nil
+}
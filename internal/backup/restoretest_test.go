@@ -0,0 +1,47 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupManager_RestoreTest(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.txt": "alpha", "nested/b.txt": "beta"})
+	b, mem := newTestManager(t, []string{dir}, "")
+
+	_, err := b.RestoreTest(t.Context(), RestoreTestOptions{})
+	require.ErrorIs(t, err, ErrBackupNotFound)
+
+	mem.objects["backups/host/20200101000000/data/a.txt"] = []byte("old")
+	require.NoError(t, b.Backup(t.Context()))
+	keys, err := b.ListBackups(t.Context())
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	newest := keys[0]
+	require.NotEqual(t, "20200101000000", newest)
+
+	// Without a backup given, the newest one is tested.
+	report, err := b.RestoreTest(t.Context(), RestoreTestOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Passed)
+	assert.Equal(t, newest, report.Backup)
+	assert.Equal(t, 2, report.Expected)
+	assert.Equal(t, 2, report.Restored)
+	assert.Equal(t, 2, report.Verified)
+	assert.Empty(t, report.Problems)
+
+	// A corrupted object fails the test.
+	for _, key := range mem.keys() {
+		if strings.HasPrefix(key, "backups/host/"+newest+"/data/") {
+			mem.objects[key][0] ^= 0xff
+			break
+		}
+	}
+	report, err = b.RestoreTest(t.Context(), RestoreTestOptions{})
+	require.ErrorIs(t, err, ErrRestoreTestFailed)
+	assert.False(t, report.Passed)
+	assert.NotEmpty(t, report.Problems)
+}
@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hibare/arclift/internal/state"
+)
+
+// RunResult is the result of a backup or purge run of a job, as printed by the one-shot commands with --output json.
+type RunResult struct {
+	state.RunRecord
+
+	Hostname        string  `json:"hostname"`
+	DurationSeconds float64 `json:"duration_seconds"`
+
+	// Results are the results of the dirs and sources of a backup run.
+	Results []DirResult `json:"results,omitempty"`
+
+	// DeletedKeys are the backups deleted by a purge run. Those of the cold tier are only counted in Deleted.
+	DeletedKeys []string `json:"deleted_keys,omitempty"`
+}
+
+// DirResult is the result of backing up a dir or source in a run.
+type DirResult struct {
+	state.DirRecord
+
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// Results collects the results of the runs made with the context returned by WithResults, in the order they ended.
+type Results struct {
+	mu   sync.Mutex
+	runs []RunResult
+}
+
+type resultsKey struct{}
+
+// WithResults returns a copy of ctx collecting the results of the backup and purge runs made with it.
+func WithResults(ctx context.Context) (context.Context, *Results) {
+	r := &Results{}
+	return context.WithValue(ctx, resultsKey{}, r), r
+}
+
+// Runs returns the collected results.
+func (r *Results) Runs() []RunResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RunResult{}, r.runs...)
+}
+
+// addResult adds the result of run, whose dir results are results and which deleted the backups deleted, to the
+// results collected by ctx, if any.
+func (b *BackupManager) addResult(ctx context.Context, run state.RunRecord, results []state.DirRecord, deleted []string) {
+	r, ok := ctx.Value(resultsKey{}).(*Results)
+	if !ok {
+		return
+	}
+
+	res := RunResult{
+		RunRecord:       run,
+		Hostname:        b.cfg.Backup.Hostname,
+		DurationSeconds: run.Duration().Seconds(),
+		DeletedKeys:     deleted,
+	}
+	for _, rec := range results {
+		res.Results = append(res.Results, DirResult{DirRecord: rec, DurationSeconds: rec.Duration().Seconds()})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, res)
+}
@@ -0,0 +1,49 @@
+package backup
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hibare/arclift/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResults_JSON(t *testing.T) {
+	dir := writeTree(t, map[string]string{"a.txt": "alpha", "nested/b.txt": "beta"})
+	b, mem := newTestManager(t, []string{dir}, "retention-count: 1")
+	mem.objects["backups/host/20200101000000/data/a.txt"] = []byte("old")
+
+	ctx, results := WithResults(t.Context())
+	require.NoError(t, b.Backup(ctx))
+	require.NoError(t, b.PurgeOldBackups(ctx))
+
+	data, err := json.Marshal(results.Runs())
+	require.NoError(t, err)
+	var runs []map[string]any
+	require.NoError(t, json.Unmarshal(data, &runs))
+	require.Len(t, runs, 2)
+
+	backupRun, purgeRun := runs[0], runs[1]
+	assert.Equal(t, state.OperationBackup, backupRun["operation"])
+	assert.Equal(t, state.StatusSuccess, backupRun["status"])
+	assert.Equal(t, "host", backupRun["hostname"])
+	assert.InDelta(t, 9, backupRun["bytes"], 0)
+	assert.Contains(t, backupRun, "duration_seconds")
+	assert.Contains(t, backupRun, "started_at")
+	assert.Contains(t, backupRun, "finished_at")
+	assert.NotContains(t, backupRun, "deleted_keys")
+	require.Len(t, backupRun["results"], 1)
+	dirResult, ok := backupRun["results"].([]any)[0].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, dir, dirResult["dir"])
+	assert.Equal(t, state.StatusSuccess, dirResult["status"])
+	assert.InDelta(t, 2, dirResult["success_files"], 0)
+	assert.Contains(t, dirResult, "duration_seconds")
+
+	assert.Equal(t, state.OperationPurge, purgeRun["operation"])
+	assert.Equal(t, state.StatusSuccess, purgeRun["status"])
+	assert.InDelta(t, 1, purgeRun["deleted"], 0)
+	assert.Equal(t, []any{"20200101000000"}, purgeRun["deleted_keys"])
+	assert.NotContains(t, purgeRun, "results")
+}
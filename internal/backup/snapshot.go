@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/constants"
+)
+
+// stagingSnapshot is the name pattern, for os.MkdirTemp, of the directories volume snapshots are mounted on. Unlike
+// the other staging directories, cleanStaging leaves them alone, as one left behind by a crashed run may still be
+// mounted.
+const stagingSnapshot = constants.ProgramIdentifier + "-snapshot-*"
+
+// ErrSnapshotFailed is returned when the snapshot of a volume could not be taken or mounted.
+var ErrSnapshotFailed = errors.New("volume snapshot failed")
+
+// takeSnapshot snapshots the volume holding dir, as configured by snap, and returns the path of dir in the read-only
+// snapshot. The returned cleanup removes the snapshot and must always be called.
+func (b *BackupManager) takeSnapshot(ctx context.Context, dir string, snap config.SnapshotConfig) (string, func(), error) {
+	name := constants.ProgramIdentifier + "-snap-" + opaqueName()[:12]
+	slog.InfoContext(ctx, "Taking volume snapshot", "dir", dir, "type", snap.Type, "volume", snap.Volume, "snapshot", name)
+
+	switch snap.Type {
+	case config.SnapshotTypeZFS:
+		return zfsSnapshot(ctx, dir, name, snap)
+	case config.SnapshotTypeBtrfs:
+		return btrfsSnapshot(ctx, dir, name, snap)
+	default:
+		return lvmSnapshot(ctx, dir, name, snap)
+	}
+}
+
+// lvmSnapshot creates the LVM snapshot name of the logical volume of snap and mounts it read-only.
+func lvmSnapshot(ctx context.Context, dir, name string, snap config.SnapshotConfig) (string, func(), error) {
+	vg, _, _ := strings.Cut(snap.Volume, "/")
+	device := filepath.Join("/dev", vg, name)
+
+	sizeFlag := "--size"
+	if strings.Contains(snap.Size, "%") {
+		sizeFlag = "--extents"
+	}
+	if err := runSnapshotCommand(ctx, "lvcreate", "--snapshot", "--name", name, sizeFlag, snap.Size, snap.Volume); err != nil {
+		return "", func() {}, err
+	}
+
+	// Tearing down must outlive a cancelled run, or the snapshot keeps filling up.
+	cleanupCtx := context.WithoutCancel(ctx)
+	removeVolume := func() {
+		if err := runSnapshotCommand(cleanupCtx, "lvremove", "--force", vg+"/"+name); err != nil {
+			slog.ErrorContext(ctx, "Error removing volume snapshot", "snapshot", vg+"/"+name, "error", err)
+		}
+	}
+
+	mountDir, err := os.MkdirTemp("", stagingSnapshot)
+	if err != nil {
+		removeVolume()
+		return "", func() {}, err
+	}
+	removeMountDir := func() {
+		_ = os.Remove(mountDir)
+	}
+
+	opts := append([]string{"ro"}, snap.MountOptions...)
+	if err := runSnapshotCommand(ctx, "mount", "-o", strings.Join(opts, ","), device, mountDir); err != nil {
+		removeMountDir()
+		removeVolume()
+		return "", func() {}, err
+	}
+
+	cleanup := func() {
+		if err := runSnapshotCommand(cleanupCtx, "umount", mountDir); err != nil {
+			// The snapshot cannot be removed while mounted; leave both for an operator.
+			slog.ErrorContext(ctx, "Error unmounting volume snapshot", "dir", mountDir, "error", err)
+			return
+		}
+		removeMountDir()
+		removeVolume()
+	}
+	return filepath.Join(mountDir, snap.Root(dir)), cleanup, nil
+}
+
+// zfsSnapshot creates the ZFS snapshot name of the dataset of snap, which is read, without mounting it, through the
+// .zfs/snapshot directory of the dataset mount point even when that directory is hidden.
+func zfsSnapshot(ctx context.Context, dir, name string, snap config.SnapshotConfig) (string, func(), error) {
+	snapshot := snap.Volume + "@" + name
+	if err := runSnapshotCommand(ctx, "zfs", "snapshot", snapshot); err != nil {
+		return "", func() {}, err
+	}
+
+	cleanupCtx := context.WithoutCancel(ctx)
+	cleanup := func() {
+		if err := runSnapshotCommand(cleanupCtx, "zfs", "destroy", snapshot); err != nil {
+			slog.ErrorContext(ctx, "Error destroying volume snapshot", "snapshot", snapshot, "error", err)
+		}
+	}
+	return filepath.Join(snap.MountPoint, ".zfs", "snapshot", name, snap.Root(dir)), cleanup, nil
+}
+
+// btrfsSnapshot takes the read-only Btrfs snapshot of the subvolume at the mount point of snap as the hidden
+// directory .<name> inside it, as snapshots cannot span filesystems. Snapshots do not recurse into nested subvolumes, which are backed up empty.
+func btrfsSnapshot(ctx context.Context, dir, name string, snap config.SnapshotConfig) (string, func(), error) {
+	path := filepath.Join(snap.MountPoint, "."+name)
+	if err := runSnapshotCommand(ctx, "btrfs", "subvolume", "snapshot", "-r", snap.MountPoint, path); err != nil {
+		return "", func() {}, err
+	}
+
+	cleanupCtx := context.WithoutCancel(ctx)
+	cleanup := func() {
+		if err := runSnapshotCommand(cleanupCtx, "btrfs", "subvolume", "delete", path); err != nil {
+			slog.ErrorContext(ctx, "Error deleting volume snapshot", "snapshot", path, "error", err)
+		}
+	}
+	return filepath.Join(path, snap.Root(dir)), cleanup, nil
+}
+
+// runSnapshotCommand runs a command managing volume snapshots, returning its output in the error when it fails.
+func runSnapshotCommand(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w: %s", ErrSnapshotFailed, name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/presets"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/walk"
+)
+
+// dumpDir is the directory, relative to the backed up path, that database dumps are stored under.
+const dumpDir = ".arclift-dumps"
+
+var (
+	// ErrDumpFailed is returned when a database dump command fails.
+	ErrDumpFailed = errors.New("database dump failed")
+
+	// ErrBelowMinimum is returned when a backup is smaller than the min-size or min-files of its source.
+	ErrBelowMinimum = errors.New("backup is smaller than expected")
+)
+
+// source is a single path to back up together with what to include from it.
+type source struct {
+	dir   string
+	walk  walk.Options
+	dumps []presets.Dump
+
+	// snapshot, when set, makes the database write the files of walk.Extra before they are backed up.
+	snapshot func(ctx context.Context) error
+
+	// minSize and minFiles are the smallest stored size and file count expected of a backup, 0 for no minimum.
+	minSize  int64
+	minFiles int
+
+	hooks config.HooksConfig
+
+	// command, when set, makes src a command source: its output is uploaded as the single object name.
+	command []string
+	name    string
+
+	// volume, when set, makes src back up a snapshot of the volume holding dir.
+	volume *config.SnapshotConfig
+}
+
+// checkMinimum returns ErrBelowMinimum when resp is smaller than the minimum expected of src.
+func (src source) checkMinimum(resp storage.UploadDirResponse) error {
+	switch {
+	case src.minSize > 0 && resp.Bytes < src.minSize:
+		return fmt.Errorf("%w: %s stored %d bytes, min-size is %d", ErrBelowMinimum, src.dir, resp.Bytes, src.minSize)
+	case src.minFiles > 0 && resp.SuccessFiles < src.minFiles:
+		return fmt.Errorf("%w: %s backed up %d files, min-files is %d", ErrBelowMinimum, src.dir, resp.SuccessFiles, src.minFiles)
+	}
+	return nil
+}
+
+// sources returns the configured dirs followed by the configured sources, with presets resolved.
+func (b *BackupManager) sources() []source {
+	sources := make([]source, 0, len(b.cfg.Backup.Dirs)+len(b.cfg.Backup.Sources))
+	for _, dir := range b.cfg.Backup.Dirs {
+		sources = append(sources, source{dir: dir})
+	}
+
+	for _, sc := range b.cfg.Backup.Sources {
+		src := source{dir: sc.Path, minSize: sc.MinSizeBytes(), minFiles: sc.MinFiles, hooks: sc.Hooks, volume: sc.Snapshot}
+		if sc.Type == config.SourceTypeCommand {
+			src.command, src.name = sc.Command, sc.Name
+			sources = append(sources, src)
+			continue
+		}
+		if setDatabase(&src, sc) {
+			sources = append(sources, src)
+			continue
+		}
+
+		if preset, ok := presets.Get(sc.Preset); ok {
+			src.walk.Include = preset.Include
+			src.walk.Exclude = preset.Exclude
+			if preset.Dump != nil && len(preset.Dump.Command) > 0 {
+				src.dumps = []presets.Dump{*preset.Dump}
+			}
+		}
+		if len(sc.Include) > 0 {
+			src.walk.Include = sc.Include
+		}
+		src.walk.MaxDepth = sc.MaxDepth
+		src.walk.Exclude = append(append([]string{}, src.walk.Exclude...), sc.Exclude...)
+		if len(sc.DumpCommand) > 0 {
+			src.dumps = []presets.Dump{{Name: "database.dump", Command: sc.DumpCommand}}
+		}
+		sources = append(sources, src)
+	}
+	return sources
+}
+
+// prepare takes the database dumps of src, if any, and returns the walk options including them and the configured
+// handling of special files and mount points, and the file filters.
+// The returned cleanup removes the dumps and must always be called.
+func (b *BackupManager) prepare(ctx context.Context, src source) (walk.Options, func(), error) {
+	opts := src.walk
+	opts.RecordSpecial = b.cfg.Backup.SpecialFiles == config.SpecialFilesRecord
+	opts.OneFileSystem = b.cfg.Backup.OneFileSystem
+	opts.MaxFileSize = b.cfg.Backup.MaxFileSizeBytes()
+	opts.ModifiedAfter, opts.ModifiedBefore = b.cfg.Backup.ModifiedRange(time.Now())
+	if src.snapshot != nil {
+		if err := src.snapshot(ctx); err != nil {
+			return opts, func() {}, err
+		}
+	}
+	if len(src.dumps) == 0 {
+		return opts, func() {}, nil
+	}
+
+	workDir, err := os.MkdirTemp("", stagingDump)
+	if err != nil {
+		return opts, func() {}, err
+	}
+	cleanup := func() {
+		if !b.cfg.Backup.Encryption.Enabled {
+			_ = os.RemoveAll(workDir)
+			return
+		}
+		// The dump is plaintext; wipe it so an encrypted backup does not leave it behind on disk.
+		if wErr := wipeDir(workDir); wErr != nil {
+			slog.WarnContext(ctx, "Error wiping database dump", "dir", workDir, "error", wErr)
+		}
+	}
+
+	opts.Extra = slices.Clone(opts.Extra)
+	for _, dump := range src.dumps {
+		dumpPath := filepath.Join(workDir, dump.Name)
+		if err := takeDump(ctx, src.dir, dump, dumpPath); err != nil {
+			return opts, cleanup, err
+		}
+		opts.Extra = append(opts.Extra, walk.ExtraFile{
+			Name: dumpDir + "/" + dump.Name,
+			Path: dumpPath,
+		})
+	}
+	return opts, cleanup, nil
+}
+
+// takeDump runs the command of dump in dir and stores its output at dumpPath.
+func takeDump(ctx context.Context, dir string, dump presets.Dump, dumpPath string) error {
+	out, err := os.OpenFile(dumpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	err = runDump(ctx, dir, dump, out)
+	_ = out.Close()
+	return err
+}
+
+// runDump runs the command of dump in dir, writing its output to w.
+func runDump(ctx context.Context, dir string, dump presets.Dump, w io.Writer) error {
+	args := make([]string, 0, len(dump.Command))
+	for _, arg := range dump.Command {
+		args = append(args, strings.ReplaceAll(arg, "{path}", dir))
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec // dump commands come from presets and the config file
+	cmd.Dir = dir
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if len(dump.Env) > 0 {
+		cmd.Env = append(os.Environ(), dump.Env...)
+	}
+
+	slog.InfoContext(ctx, "Taking database dump", "dir", dir, "command", args[0], "name", dump.Name)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s: %w: %s", ErrDumpFailed, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"github.com/hibare/arclift/internal/units"
+	"github.com/hibare/arclift/internal/walk"
+)
+
+// ErrInsufficientSpace is returned when the temp directory does not have room for the archive of a dir.
+var ErrInsufficientSpace = errors.New("not enough free space to archive")
+
+// checkFreeSpace estimates the size of the archive of root and fails with ErrInsufficientSpace when the temp
+// directory it is written to has less free space than that plus the configured margin. The check is skipped when
+// disabled, or when free space is not known on this platform.
+func (b *BackupManager) checkFreeSpace(ctx context.Context, dir, root string, opts walk.Options, encrypted bool) error {
+	if !b.cfg.Backup.DiskCheck.Enabled {
+		return nil
+	}
+	staging := os.TempDir()
+	free, ok := freeSpace(staging)
+	if !ok {
+		return nil
+	}
+
+	// The archive is at most the size of its files, unless it is encrypted: the armored encryption encodes it in
+	// base64, adding a third.
+	size, err := walkedSize(ctx, root, opts)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		size += size / 3 //nolint:mnd // base64 encodes 3 bytes as 4
+	}
+	needed := size + b.cfg.Backup.DiskCheck.MarginBytes()
+	slog.DebugContext(ctx, "Checked free space", "dir", dir, "staging", staging, "free", free, "needed", needed)
+	if free < needed {
+		return fmt.Errorf("%w %s: %s free in %s, about %s needed", ErrInsufficientSpace, dir,
+			units.FormatBytes(free), staging, units.FormatBytes(needed))
+	}
+	return nil
+}
+
+// walkedSize returns the size of the files of root walked with opts.
+func walkedSize(ctx context.Context, root string, opts walk.Options) (int64, error) {
+	// Only the selection of opts applies; its callbacks are for the backup.
+	opts.Visit, opts.TooLarge, opts.RecordSpecial = nil, nil, false
+
+	var size int64
+	_, err := walk.Dir(ctx, root, opts, func(_, _ string, _ fs.DirEntry, f *os.File) error {
+		if f == nil {
+			return nil
+		}
+		if info, sErr := f.Stat(); sErr == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
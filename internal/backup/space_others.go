@@ -0,0 +1,6 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package backup
+
+// Free space is not known on this platform; archives are written without checking it.
+func freeSpace(string) (int64, bool) { return 0, false }
@@ -0,0 +1,16 @@
+package backup
+
+import "golang.org/x/sys/windows"
+
+// freeSpace returns the space available to the current user on the volume of dir.
+func freeSpace(dir string) (int64, bool) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, false
+	}
+	var available, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, &total, &totalFree); err != nil {
+		return 0, false
+	}
+	return int64(available), true //nolint:gosec // free space is far below the int64 range
+}
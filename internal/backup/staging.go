@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/units"
+)
+
+// Name patterns, for os.MkdirTemp, of the temp directories backups stage their files in. Those left behind by a run
+// that crashed are removed by cleanStaging.
+const (
+	stagingArchive = constants.ProgramIdentifier + "-archive-*"
+	stagingDump    = constants.ProgramIdentifier + "-dump-*"
+	stagingRemote  = constants.ProgramIdentifier + "-remote-*"
+	stagingStream  = constants.ProgramIdentifier + "-stream-*"
+)
+
+// cleanStaging removes the staging directories older than the configured max age from the temp directory. It runs
+// while the backup lock is held, so no other run of this host is using them. Failures are logged; they do not fail
+// the run.
+func (b *BackupManager) cleanStaging(ctx context.Context) {
+	if !b.cfg.Backup.TempCleanup.Enabled {
+		return
+	}
+	tempDir := os.TempDir()
+	cutoff := time.Now().Add(-b.cfg.Backup.TempCleanup.MaxAge)
+
+	var candidates []string
+	for _, pattern := range []string{stagingArchive, stagingDump, stagingRemote, stagingStream} {
+		matches, err := filepath.Glob(filepath.Join(tempDir, pattern))
+		if err != nil {
+			slog.WarnContext(ctx, "Error listing temp files", "pattern", pattern, "error", err)
+			continue
+		}
+		candidates = append(candidates, matches...)
+	}
+
+	removed, reclaimed := 0, int64(0)
+	for _, path := range candidates {
+		info, err := os.Lstat(path)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		size := diskUsage(path)
+		if err := b.removeStaged(path); err != nil {
+			slog.WarnContext(ctx, "Error removing orphaned temp file", "path", path, "error", err)
+			continue
+		}
+		removed++
+		reclaimed += size
+	}
+	if removed > 0 {
+		slog.InfoContext(ctx, "Removed orphaned temp files", "count", removed, "reclaimed", units.FormatBytes(reclaimed))
+	}
+}
+
+// removeStaged removes a staging directory. With encryption enabled, database dumps and remote copies may
+// be plaintext, so they are wiped.
+func (b *BackupManager) removeStaged(path string) error {
+	name := filepath.Base(path)
+	if b.cfg.Backup.Encryption.Enabled &&
+		(strings.HasPrefix(name, strings.TrimSuffix(stagingDump, "*")) || strings.HasPrefix(name, strings.TrimSuffix(stagingRemote, "*"))) {
+		return wipeDir(path)
+	}
+	return os.RemoveAll(path)
+}
+
+// diskUsage returns the size of the regular files under path.
+func diskUsage(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil //nolint:nilerr // unreadable entries are not counted
+		}
+		if info, iErr := d.Info(); iErr == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
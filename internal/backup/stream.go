@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/presets"
+	"github.com/hibare/arclift/internal/state"
+	"github.com/hibare/arclift/internal/storage"
+)
+
+// encryptedStreamExt is appended to the object name of an encrypted stream.
+const encryptedStreamExt = ".gpg"
+
+// ErrJobRequired is returned when streaming into several jobs, as a stream is stored in a single one.
+var ErrJobRequired = errors.New("select the job to store the stream in with --job")
+
+// uploadStream uploads what write writes as the single object name, encrypted when encryption is enabled. The
+// output is buffered in a temp file, so it is only uploaded once write has succeeded.
+func (b *BackupManager) uploadStream(ctx context.Context, dir, name string, write func(w io.Writer) error) (storage.UploadDirResponse, error) {
+	var recipients openpgp.EntityList
+	if b.cfg.Backup.Encryption.Enabled {
+		var err error
+		if recipients, err = b.encryptionRecipients(ctx); err != nil {
+			return storage.UploadDirResponse{}, err
+		}
+		name += encryptedStreamExt
+	}
+
+	workDir, err := os.MkdirTemp("", stagingStream)
+	if err != nil {
+		return storage.UploadDirResponse{}, err
+	}
+	defer func() {
+		_ = os.RemoveAll(workDir)
+	}()
+
+	uploadPath := filepath.Join(workDir, b.storedName(name, encryptedStreamExt))
+	if err := writeStream(uploadPath, recipients, write); err != nil {
+		slog.ErrorContext(ctx, "Error writing stream", "dir", dir, "name", name, "error", err)
+		return storage.UploadDirResponse{}, err
+	}
+
+	var size int64
+	if info, sErr := os.Stat(uploadPath); sErr == nil {
+		size = info.Size()
+	}
+
+	slog.InfoContext(ctx, "uploading stream", "dir", dir, "name", name, "encrypted", recipients != nil, "storage", b.store.Name())
+	key, err := b.store.UploadFile(ctx, uploadPath)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error uploading stream", "dir", dir, "error", err)
+		return storage.UploadDirResponse{}, err
+	}
+	if b.cfg.Backup.OpaqueNames {
+		if err := b.storeManifest(ctx, key, name, dir, recipients); err != nil {
+			slog.ErrorContext(ctx, "Error storing manifest", "dir", dir, "error", err)
+			b.deletePartial(ctx, key)
+			return storage.UploadDirResponse{}, err
+		}
+	}
+	return storage.UploadDirResponse{BaseKey: key, TotalFiles: 1, SuccessFiles: 1, Bytes: size}, nil
+}
+
+// writeStream stores what write writes at path, encrypting it to recipients when they are given.
+func writeStream(path string, recipients openpgp.EntityList, write func(w io.Writer) error) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if recipients == nil {
+		if err := write(f); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+
+	w, err := encryptWriter(f, recipients)
+	if err != nil {
+		return err
+	}
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// uploadCommand uploads the output of the command of a command source.
+func (b *BackupManager) uploadCommand(ctx context.Context, src source) (storage.UploadDirResponse, error) {
+	dump := presets.Dump{Name: src.name, Command: src.command}
+	return b.uploadStream(ctx, src.dir, src.name, func(w io.Writer) error {
+		return runDump(ctx, src.dir, dump, w)
+	})
+}
+
+// Stream backs up what is read from r as the single object name, such as the output of pg_dump piped into arclift.
+// It is recorded, notified and copied to the cold tier like the backup of a dir named name.
+func (b *BackupManager) Stream(ctx context.Context, name string, r io.Reader) error {
+	if err := config.ValidateStreamName(name); err != nil {
+		return err
+	}
+
+	ctx, unlock, err := b.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	run := state.RunRecord{Operation: state.OperationBackup, Job: b.cfg.Backup.Job, StartedAt: time.Now()}
+	resp, err := b.uploadStream(ctx, name, name, func(w io.Writer) error {
+		_, cErr := io.Copy(w, r)
+		return cErr
+	})
+	result := b.recordDir(ctx, name, resp, err, run.StartedAt)
+	run.Dirs = 1
+	run.Bytes = resp.Bytes
+
+	if err != nil {
+		run.FailedDirs = 1
+		b.notifierStore.NotifyBackupFailure(ctx, name, 0, 0, err)
+	} else {
+		if b.cold != nil {
+			if cErr := b.copyToCold(ctx, name, resp.BaseKey); cErr != nil {
+				run.ColdFailed++
+				b.notifierStore.NotifyBackupFailure(ctx, name, 0, resp.TotalFiles, cErr)
+			}
+		}
+		b.notifierStore.NotifyBackupSuccess(ctx, name, 0, resp.TotalFiles, resp.SuccessFiles, resp.BaseKey, nil)
+	}
+
+	b.recordRun(ctx, &run, nil)
+	results := []state.DirRecord{result}
+	keys := b.runKeys(results)
+	b.storeRunReport(context.WithoutCancel(ctx), run, results, keys)
+	b.storeConfig(context.WithoutCancel(ctx), keys)
+	return err
+}
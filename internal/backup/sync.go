@@ -0,0 +1,167 @@
+package backup
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // S3 ETags of single part uploads are MD5 digests
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/walk"
+)
+
+// ErrUnversioned is returned when in-place versioning syncs backups to storage that does not keep previous versions.
+var ErrUnversioned = errors.New("in-place versioning requires a bucket with versioning enabled")
+
+// syncTargets returns the newest backup holding each dir, by the name of the dir. Sync updates them in place.
+func (b *BackupManager) syncTargets(ctx context.Context) (map[string]string, error) {
+	times, err := b.backupTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	details, err := b.store.ListDetailed(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := map[string]string{}
+	for _, d := range details {
+		t, ok := times[d.Key]
+		if !ok {
+			continue
+		}
+		for _, name := range d.Names {
+			if target, found := targets[name]; !found || t.After(times[target]) {
+				targets[name] = d.Key
+			}
+		}
+	}
+	return targets, nil
+}
+
+// storedObjects returns the objects, among objects, of the dir named base in the backup key, by their key.
+func (b *BackupManager) storedObjects(objects []storage.ObjectInfo, key, base string) map[string]storage.ObjectInfo {
+	stored := map[string]storage.ObjectInfo{}
+	name := key + "/" + base
+	for _, obj := range objects {
+		if rel := b.store.TrimPrefix([]string{obj.Key})[0]; rel == name || strings.HasPrefix(rel, name+"/") {
+			stored[obj.Key] = obj
+		}
+	}
+	return stored
+}
+
+// syncedChecksums adds to the checksums of the files a sync of the backup key uploaded, by object key, the recorded
+// checksums of the stored files it left as they were. Files deleted by the sync are left out.
+func (b *BackupManager) syncedChecksums(
+	ctx context.Context, objects []storage.ObjectInfo, key string, stored map[string]storage.ObjectInfo, deleted map[string]bool,
+	sums map[string]string,
+) {
+	workDir, err := os.MkdirTemp("", constants.ProgramIdentifier+"-sync-")
+	if err != nil {
+		slog.WarnContext(ctx, "Error loading checksums", "key", key, "error", err)
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(workDir)
+	}()
+
+	recorded := b.loadChecksums(ctx, objects, key, workDir)
+	for objKey := range stored {
+		name := strings.TrimPrefix(b.store.TrimPrefix([]string{objKey})[0], key+"/")
+		if _, uploaded := sums[objKey]; uploaded || deleted[objKey] || recorded[name] == "" {
+			continue
+		}
+		sums[objKey] = recorded[name]
+	}
+}
+
+// unchanged reports whether f is stored as obj: their sizes match and, with the hash comparison, the MD5 of f is the
+// ETag of obj, otherwise f was last modified before obj was uploaded.
+func unchanged(f *os.File, obj storage.ObjectInfo, compare string) bool {
+	info, err := f.Stat()
+	if err != nil || info.Size() != obj.Size {
+		return false
+	}
+	if compare != config.SyncCompareHash {
+		return info.ModTime().Before(obj.LastModified)
+	}
+
+	// ETags of multipart uploads, ending with -<parts>, are not digests of the content.
+	if obj.ETag == "" || strings.Contains(obj.ETag, "-") {
+		return false
+	}
+	defer func() {
+		_, _ = f.Seek(0, io.SeekStart)
+	}()
+	h := md5.New() //nolint:gosec // S3 ETags of single part uploads are MD5 digests
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == obj.ETag
+}
+
+// syncBackup updates the newest backup of dir, whose files are read from root, in place: only the files that
+// changed since it was stored are uploaded and, with delete-removed, the stored files no longer backed up are
+// deleted. The storage keeps the previous versions of the files, see config.VersioningInPlace; it fails with
+// ErrUnversioned when it can tell it does not. Without a previous backup of dir, a new one is uploaded. ok is false
+// when no backup was updated.
+func (b *BackupManager) syncBackup(ctx context.Context, dir, root string, opts walk.Options) (storage.UploadDirResponse, bool, error) {
+	if v, ok := b.store.(storage.Versioner); ok {
+		versioned, err := v.Versioned(ctx)
+		if err != nil {
+			return storage.UploadDirResponse{}, false, err
+		}
+		if !versioned {
+			return storage.UploadDirResponse{}, false, ErrUnversioned
+		}
+	}
+
+	base := filepath.Base(filepath.Clean(root))
+	targets, err := b.syncTargets(ctx)
+	key := targets[base]
+	if err != nil || key == "" {
+		return storage.UploadDirResponse{}, false, err
+	}
+	objects, err := b.store.ListObjects(ctx)
+	if err != nil {
+		return storage.UploadDirResponse{}, false, err
+	}
+	stored := b.storedObjects(objects, key, base)
+
+	sync := b.cfg.Backup.Sync
+	walked := map[string]bool{}
+	slog.InfoContext(ctx, "Syncing directory", "dir", dir, "key", key, "compare", sync.Compare)
+	resp, err := b.store.SyncDir(ctx, root, key, opts, func(objKey string, f *os.File) bool {
+		walked[objKey] = true
+		obj, ok := stored[objKey]
+		return ok && unchanged(f, obj, sync.Compare)
+	})
+	if err != nil {
+		return resp, true, err
+	}
+	slog.InfoContext(ctx, "Synced directory", "dir", dir, "key", key,
+		"uploaded", resp.SuccessFiles-resp.Unchanged, "unchanged", resp.Unchanged)
+
+	deleted := map[string]bool{}
+	for objKey := range stored {
+		if !sync.DeleteRemoved || walked[objKey] {
+			continue
+		}
+		slog.DebugContext(ctx, "Deleting removed file", "key", objKey)
+		if dErr := b.store.Delete(ctx, b.store.TrimPrefix([]string{objKey})[0]); dErr != nil {
+			slog.WarnContext(ctx, "Error deleting removed file", "key", objKey, "error", dErr)
+			continue
+		}
+		deleted[objKey] = true
+	}
+	b.syncedChecksums(ctx, objects, key, stored, deleted, resp.Checksums)
+	return resp, true, nil
+}
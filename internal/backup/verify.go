@@ -0,0 +1,244 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/storage"
+)
+
+// checksumsDir is the directory, among the auxiliary objects of a backup, holding the SHA-256 checksums of the objects
+// of each backed up dir as <name>.json, keyed by their name relative to the backup.
+const checksumsDir = "checksums"
+
+var (
+	// ErrCorrupted is returned when verified backups do not match their checksums.
+	ErrCorrupted = errors.New("corrupted backups found")
+
+	// ErrObjectMissing is returned when an object whose checksum was recorded is no longer stored.
+	ErrObjectMissing = errors.New("object missing")
+)
+
+// CorruptObject is an object of a backup that failed verification.
+type CorruptObject struct {
+	Backup string `json:"backup"`
+	Object string `json:"object"`
+	Error  string `json:"error"`
+}
+
+// VerifyReport reports the verification of stored backups against their checksums.
+type VerifyReport struct {
+	// Backups and Objects are the numbers of backups and objects verified.
+	Backups int `json:"backups"`
+	Objects int `json:"objects"`
+
+	// Unverified counts the objects that were downloaded, but have no checksum to compare them with, such as those
+	// of backups taken before checksums were recorded.
+	Unverified int `json:"unverified"`
+
+	// Corrupted are the objects that could not be downloaded, do not match their checksum or are missing.
+	Corrupted []CorruptObject `json:"corrupted"`
+}
+
+// storeChecksums stores the checksums of the objects of the dir backed up at baseKey, by object key, in the
+// auxiliary objects of its backup. Failures are logged; the backup is then verified with ETags only.
+func (b *BackupManager) storeChecksums(ctx context.Context, baseKey string, sums map[string]string) {
+	rel := b.store.TrimPrefix([]string{baseKey})[0]
+	key, name, _ := b.splitKey(rel)
+	if name == "" || len(sums) == 0 {
+		return
+	}
+
+	named := make(map[string]string, len(sums))
+	for objKey, sum := range sums {
+		_, objName, _ := b.splitKey(b.store.TrimPrefix([]string{objKey})[0])
+		named[objName] = sum
+	}
+	body, err := json.Marshal(named)
+	if err != nil {
+		slog.WarnContext(ctx, "Error encoding checksums", "key", baseKey, "error", err)
+		return
+	}
+	if err := b.store.Put(ctx, auxKey(key)+"/"+checksumsDir+"/"+name+".json", bytes.NewReader(body)); err != nil {
+		slog.WarnContext(ctx, "Error storing checksums", "key", baseKey, "error", err)
+	}
+}
+
+// loadChecksums downloads and merges the checksums of the objects of backup, keyed by their name relative to the
+// backup. It is empty for backups taken before checksums were recorded.
+func (b *BackupManager) loadChecksums(ctx context.Context, objects []storage.ObjectInfo, backup, workDir string) map[string]string {
+	sums := map[string]string{}
+	prefix := auxKey(backup) + "/" + checksumsDir + "/"
+	local := filepath.Join(workDir, checksumsDir+".json")
+	for _, obj := range objects {
+		rel := b.store.TrimPrefix([]string{obj.Key})[0]
+		if !strings.HasPrefix(rel, prefix) || !strings.HasSuffix(rel, ".json") {
+			continue
+		}
+
+		if err := b.store.Download(ctx, obj.Key, local); err != nil {
+			slog.WarnContext(ctx, "Error downloading checksums", "key", obj.Key, "error", err)
+			continue
+		}
+		data, err := os.ReadFile(local)
+		_ = os.Remove(local)
+		if err == nil {
+			err = json.Unmarshal(data, &sums)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Error reading checksums", "key", obj.Key, "error", err)
+		}
+	}
+	return sums
+}
+
+// fileChecksum returns the SHA-256 checksum of the file at path, see storage.Checksum.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return storage.Checksum(f)
+}
+
+// Verify downloads every object of sample backups picked at random, or of every backup when sample is not positive,
+// and compares it with the checksum recorded when it was uploaded. Objects without a recorded checksum are compared
+// with their ETag when it is an MD5 digest. The corrupted backups are notified, and Verify fails with ErrCorrupted.
+func (b *BackupManager) Verify(ctx context.Context, sample int) (VerifyReport, error) {
+	report := VerifyReport{Corrupted: []CorruptObject{}}
+
+	times, err := b.backupTimes(ctx)
+	if err != nil {
+		return report, err
+	}
+	keys := slices.Collect(maps.Keys(times))
+	if sample > 0 && sample < len(keys) {
+		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+		keys = keys[:sample]
+	}
+	slices.SortFunc(keys, func(a, b string) int { return times[b].Compare(times[a]) })
+
+	objects, err := b.store.ListObjects(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing objects", "error", err)
+		return report, err
+	}
+
+	workDir, err := os.MkdirTemp("", constants.ProgramIdentifier+"-verify-")
+	if err != nil {
+		return report, err
+	}
+	defer func() {
+		_ = os.RemoveAll(workDir)
+	}()
+
+	slog.InfoContext(ctx, "Verifying backups", "backups", len(keys), "retained", len(times))
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		verified, unverified, corrupted := b.verifyBackup(ctx, objects, key, workDir)
+		if len(corrupted) > 0 && !b.retained(ctx, key) {
+			slog.InfoContext(ctx, "Backup was deleted while it was verified", "key", key)
+			continue
+		}
+
+		report.Backups++
+		report.Objects += verified
+		report.Unverified += unverified
+		report.Corrupted = append(report.Corrupted, corrupted...)
+		if len(corrupted) == 0 {
+			slog.InfoContext(ctx, "Verified backup", "key", key, "objects", verified, "unverified", unverified)
+			continue
+		}
+
+		slog.ErrorContext(ctx, "Backup is corrupted", "key", key, "objects", verified, "corrupted", len(corrupted))
+		errs := make(map[string]string, len(corrupted))
+		for _, c := range corrupted {
+			errs[c.Object] = c.Error
+		}
+		b.notifierStore.NotifyCorruption(ctx, key, verified, errs)
+	}
+
+	slog.InfoContext(ctx, "Verification finished", "backups", report.Backups, "objects", report.Objects,
+		"unverified", report.Unverified, "corrupted", len(report.Corrupted))
+	if len(report.Corrupted) > 0 {
+		return report, fmt.Errorf("%w: %d corrupted objects", ErrCorrupted, len(report.Corrupted))
+	}
+	return report, nil
+}
+
+// verifyBackup verifies the objects of the backup key, listed in objects, and returns the number of objects verified,
+// how many of them had no checksum, and those that failed verification.
+func (b *BackupManager) verifyBackup(
+	ctx context.Context, objects []storage.ObjectInfo, key, workDir string,
+) (int, int, []CorruptObject) {
+	sums := b.loadChecksums(ctx, objects, key, workDir)
+
+	var (
+		verified, unverified int
+		corrupted            []CorruptObject
+		seen                 = map[string]bool{}
+	)
+	for _, obj := range objects {
+		name, ok := strings.CutPrefix(b.store.TrimPrefix([]string{obj.Key})[0], key+"/")
+		if !ok || name == "" {
+			continue
+		}
+		seen[name] = true
+		verified++
+
+		checked, err := b.verifyObject(ctx, obj, sums[name], workDir)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error verifying object", "key", obj.Key, "error", err)
+			corrupted = append(corrupted, CorruptObject{Backup: key, Object: name, Error: err.Error()})
+		} else if !checked {
+			unverified++
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(sums)) {
+		if !seen[name] {
+			slog.ErrorContext(ctx, "Backed up object is missing", "key", key, "object", name)
+			corrupted = append(corrupted, CorruptObject{Backup: key, Object: name, Error: ErrObjectMissing.Error()})
+		}
+	}
+	return verified, unverified, corrupted
+}
+
+// verifyObject downloads obj and compares it with sum, its recorded checksum, or with its ETag without one. checked
+// is false when there was nothing to compare it with.
+func (b *BackupManager) verifyObject(ctx context.Context, obj storage.ObjectInfo, sum, workDir string) (bool, error) {
+	local := filepath.Join(workDir, "object")
+	if err := b.store.Download(ctx, obj.Key, local); err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = os.Remove(local)
+	}()
+	return sum != "" || digestETag(obj.ETag), verifyDownload(local, sum, obj.ETag)
+}
+
+// retained reports whether the backup key is still stored, so failures of a backup purged meanwhile are ignored.
+func (b *BackupManager) retained(ctx context.Context, key string) bool {
+	times, err := b.backupTimes(ctx)
+	if err != nil {
+		return true
+	}
+	_, ok := times[key]
+	return ok
+}
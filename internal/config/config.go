@@ -3,34 +3,241 @@ package config
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-viper/mapstructure/v2"
 	commonLogger "github.com/hibare/GoCommon/v2/pkg/logger"
 	commonRuntime "github.com/hibare/GoCommon/v2/pkg/os/runtime"
 	commonUtils "github.com/hibare/GoCommon/v2/pkg/utils"
 	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/naming"
+	"github.com/hibare/arclift/internal/presets"
+	"github.com/hibare/arclift/internal/units"
+	"github.com/robfig/cron/v3"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
 
+// RetryConfig is the retry policy of S3 requests failing with transient errors, such as 503 SlowDown or a reset
+// connection. The delay before each retry grows exponentially, with jitter, up to MaxBackoff.
+type RetryConfig struct {
+	MaxAttempts int           `mapstructure:"max-attempts" yaml:"max-attempts"`
+	MaxBackoff  time.Duration `mapstructure:"max-backoff"  yaml:"max-backoff"`
+}
+
+func (r *RetryConfig) validate() error {
+	switch {
+	case r.MaxAttempts == 0:
+		r.MaxAttempts = constants.DefaultRetryMaxAttempts
+	case r.MaxAttempts < 0:
+		return errors.New("retry max-attempts must be greater than 0")
+	}
+
+	switch {
+	case r.MaxBackoff == 0:
+		r.MaxBackoff = constants.DefaultRetryMaxBackoff
+	case r.MaxBackoff < 0:
+		return errors.New("retry max-backoff must not be negative")
+	}
+	return nil
+}
+
+// TimeoutsConfig bounds how long S3 requests may hang. Connect limits establishing a connection, including the TLS
+// handshake; Read limits how long an open connection may go without sending or receiving data; Request limits each
+// attempt of a request, including its transfer, and is disabled when zero.
+type TimeoutsConfig struct {
+	Connect time.Duration `mapstructure:"connect" yaml:"connect"`
+	Read    time.Duration `mapstructure:"read"    yaml:"read"`
+	Request time.Duration `mapstructure:"request" yaml:"request"`
+}
+
+func (t *TimeoutsConfig) validate() error {
+	if t.Connect < 0 || t.Read < 0 || t.Request < 0 {
+		return errors.New("timeouts must not be negative")
+	}
+	if t.Connect == 0 {
+		t.Connect = constants.DefaultS3ConnectTimeout
+	}
+	if t.Read == 0 {
+		t.Read = constants.DefaultS3ReadTimeout
+	}
+	return nil
+}
+
+// TLSConfig customizes the TLS connections to the S3 endpoint, such as a self-hosted MinIO with an internal CA.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of CA certificates trusted in addition to the system ones.
+	CAFile string `mapstructure:"ca-file" yaml:"ca-file"`
+
+	// CertFile and KeyFile are the PEM client certificate and key presented to endpoints requiring mutual TLS.
+	CertFile string `mapstructure:"cert-file" yaml:"cert-file"`
+	KeyFile  string `mapstructure:"key-file"  yaml:"key-file"`
+
+	// InsecureSkipVerify disables the verification of the endpoint certificate.
+	InsecureSkipVerify bool `mapstructure:"insecure-skip-verify" yaml:"insecure-skip-verify"`
+}
+
+func (t *TLSConfig) validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("tls cert-file and key-file must be set together")
+	}
+	if t.InsecureSkipVerify {
+		slog.Warn("TLS certificate verification of the S3 endpoint is DISABLED; connections can be intercepted. " +
+			"Set s3.tls.ca-file to trust a private CA instead")
+	}
+	return nil
+}
+
+// WebIdentityConfig exchanges an OIDC token for temporary credentials with AssumeRoleWithWebIdentity, such as the
+// service account token of a Kubernetes pod using IRSA.
+type WebIdentityConfig struct {
+	RoleARN     string `mapstructure:"role-arn"     yaml:"role-arn"`
+	TokenFile   string `mapstructure:"token-file"   yaml:"token-file"`
+	SessionName string `mapstructure:"session-name" yaml:"session-name"`
+}
+
+func (w *WebIdentityConfig) validate() error {
+	if (w.RoleARN == "") != (w.TokenFile == "") {
+		return errors.New("web-identity role-arn and token-file must be set together")
+	}
+	if w.RoleARN != "" && w.SessionName == "" {
+		w.SessionName = constants.ProgramIdentifier
+	}
+	return nil
+}
+
+// CreateBucketConfig creates the bucket on the first run when it does not exist, such as on a fresh MinIO deployment.
+type CreateBucketConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+
+	// Versioning enables versioning of a created bucket. ObjectLock creates it with Object Lock, which implies
+	// versioning. Neither changes an existing bucket.
+	Versioning bool `mapstructure:"versioning"  yaml:"versioning"`
+	ObjectLock bool `mapstructure:"object-lock" yaml:"object-lock"`
+}
+
+func (c *CreateBucketConfig) validate() error {
+	if !c.Enabled && (c.Versioning || c.ObjectLock) {
+		return errors.New("create-bucket versioning and object-lock require create-bucket enabled")
+	}
+	return nil
+}
+
 // S3Config is the configuration for the S3 client.
 type S3Config struct {
-	Endpoint  string `mapstructure:"endpoint"   yaml:"endpoint"`
-	Region    string `mapstructure:"region"     yaml:"region"`
-	AccessKey string `mapstructure:"access-key" yaml:"access-key"`
-	SecretKey string `mapstructure:"secret-key" yaml:"secret-key"`
-	Bucket    string `mapstructure:"bucket"     yaml:"bucket"`
-	Prefix    string `mapstructure:"prefix"     yaml:"prefix"`
+	Endpoint          string             `mapstructure:"endpoint"           yaml:"endpoint"`
+	Region            string             `mapstructure:"region"             yaml:"region"`
+	AccessKey         string             `mapstructure:"access-key"         yaml:"access-key"`
+	SecretKey         string             `mapstructure:"secret-key"         yaml:"secret-key"`
+	Profile           string             `mapstructure:"profile"            yaml:"profile"`
+	WebIdentity       WebIdentityConfig  `mapstructure:"web-identity"       yaml:"web-identity"`
+	Bucket            string             `mapstructure:"bucket"             yaml:"bucket"`
+	Prefix            string             `mapstructure:"prefix"             yaml:"prefix"`
+	StorageClass      string             `mapstructure:"storage-class"      yaml:"storage-class"`
+	UploadConcurrency int                `mapstructure:"upload-concurrency" yaml:"upload-concurrency"`
+	Retry             RetryConfig        `mapstructure:"retry"              yaml:"retry"`
+	Timeouts          TimeoutsConfig     `mapstructure:"timeouts"           yaml:"timeouts"`
+	TLS               TLSConfig          `mapstructure:"tls"                yaml:"tls"`
+	CreateBucket      CreateBucketConfig `mapstructure:"create-bucket"      yaml:"create-bucket"`
+}
+
+func (s *S3Config) validate() error {
+	// Without static keys the standard AWS credential chain is used: env vars, the shared config profile, then the
+	// instance or task role.
+	switch {
+	case (s.AccessKey == "") != (s.SecretKey == ""):
+		return errors.New("access-key and secret-key must be set together")
+	case s.AccessKey != "" && s.Profile != "":
+		return errors.New("profile cannot be combined with access-key and secret-key")
+	case s.WebIdentity.RoleARN != "" && (s.AccessKey != "" || s.Profile != ""):
+		return errors.New("web-identity cannot be combined with access-key, secret-key or profile")
+	}
+
+	if err := s.WebIdentity.validate(); err != nil {
+		return err
+	}
+
+	switch {
+	case s.UploadConcurrency == 0:
+		s.UploadConcurrency = constants.DefaultUploadConcurrency
+	case s.UploadConcurrency < 0:
+		return errors.New("upload-concurrency must be greater than 0")
+	}
+
+	if err := s.Retry.validate(); err != nil {
+		return err
+	}
+
+	if err := s.Timeouts.validate(); err != nil {
+		return err
+	}
+
+	if err := s.TLS.validate(); err != nil {
+		return err
+	}
+
+	if err := s.CreateBucket.validate(); err != nil {
+		return err
+	}
+
+	return validateStorageClass(s.StorageClass)
+}
+
+// validateStorageClass checks class against the storage classes known to S3. An empty class is the bucket default.
+func validateStorageClass(class string) error {
+	if class == "" || slices.Contains(types.StorageClass("").Values(), types.StorageClass(class)) {
+		return nil
+	}
+	return fmt.Errorf("invalid storage-class: %s", class)
+}
+
+// DefaultColdStorageClass is the storage class of the cold tier when none is configured.
+const DefaultColdStorageClass = string(types.StorageClassGlacierIr)
+
+// ColdTierConfig copies every backup to a second, long term location with its own retention.
+type ColdTierConfig struct {
+	Enabled        bool   `mapstructure:"enabled"         yaml:"enabled"`
+	Bucket         string `mapstructure:"bucket"          yaml:"bucket"`
+	Prefix         string `mapstructure:"prefix"          yaml:"prefix"`
+	StorageClass   string `mapstructure:"storage-class"   yaml:"storage-class"`
+	RetentionCount int    `mapstructure:"retention-count" yaml:"retention-count"`
 }
 
 // GPGConfig is the configuration for the GPG client.
 type GPGConfig struct {
 	KeyServer string `mapstructure:"key-server" yaml:"key-server"`
-	KeyID     string `mapstructure:"key-id"     yaml:"key-id"`
+
+	// KeyServers are fallback key servers, tried in order when the key cannot be fetched from KeyServer.
+	KeyServers []string `mapstructure:"key-servers" yaml:"key-servers"`
+
+	KeyID string `mapstructure:"key-id" yaml:"key-id"`
+
+	// Timeout bounds each attempt at fetching the key from a key server.
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout"`
+
+	// ExpectedFingerprint pins the fingerprint of the key; a fetched key with another fingerprint is rejected.
+	ExpectedFingerprint string `mapstructure:"expected-fingerprint" yaml:"expected-fingerprint"`
+}
+
+// Servers returns the key servers the key is fetched from, in the order they are tried.
+func (g GPGConfig) Servers() []string {
+	var servers []string
+	for _, s := range append([]string{g.KeyServer}, g.KeyServers...) {
+		if s != "" && !slices.Contains(servers, s) {
+			servers = append(servers, s)
+		}
+	}
+	return servers
 }
 
 // Encryption is the configuration for the encryption.
@@ -39,20 +246,580 @@ type Encryption struct {
 	GPG     GPGConfig `mapstructure:"gpg"     yaml:"gpg"`
 }
 
+// Quota actions applied when a stored size quota is exceeded.
+const (
+	QuotaActionWarn  = "warn"
+	QuotaActionBlock = "block"
+	QuotaActionPurge = "purge"
+)
+
+// Formats of the archives written with archive-dirs.
+const (
+	// ArchiveFormatZip writes zip archives, using Zip64 for files over 4GB.
+	ArchiveFormatZip = "zip"
+
+	// ArchiveFormatTar writes gzipped PAX tar archives, recording files over 8GB, long paths and sub-second
+	// modification times in PAX headers.
+	ArchiveFormatTar = "tar"
+)
+
+// Handling of special files, the FIFOs and device nodes found in backed up dirs. Sockets are always skipped, as
+// they only mean something to the process listening on them.
+const (
+	// SpecialFilesSkip skips special files, counting them in the skipped entries of a backup.
+	SpecialFilesSkip = "skip"
+
+	// SpecialFilesRecord records special files with their metadata, so FIFOs are recreated when restoring.
+	SpecialFilesRecord = "record"
+)
+
+// DirQuota limits the stored size of a single backup directory.
+type DirQuota struct {
+	Dir           string `mapstructure:"dir"             yaml:"dir"`
+	MaxStoredSize string `mapstructure:"max-stored-size" yaml:"max-stored-size"`
+}
+
+// SourceConfig is a backup source, optionally based on an application preset.
+type SourceConfig struct {
+	Type    string   `mapstructure:"type"    yaml:"type,omitempty"`
+	Preset  string   `mapstructure:"preset"  yaml:"preset"`
+	Path    string   `mapstructure:"path"    yaml:"path"`
+	Exclude []string `mapstructure:"exclude" yaml:"exclude"`
+
+	// Include lists the paths, relative to Path, that are backed up, such as a few files of a directory grouped into
+	// a single archive. It replaces the paths included by the preset; empty backs up everything.
+	Include []string `mapstructure:"include" yaml:"include,omitempty"`
+
+	// MaxDepth, when positive, is the number of directory levels below Path that are backed up; deeper entries,
+	// such as nested build outputs, are left out.
+	MaxDepth    int      `mapstructure:"max-depth"    yaml:"max-depth,omitempty"`
+	DumpCommand []string `mapstructure:"dump-command" yaml:"dump-command"`
+	Cron        string   `mapstructure:"cron"         yaml:"cron,omitempty"`
+
+	// MinSize and MinFiles are the smallest stored size and file count expected of a backup of the source. A smaller
+	// backup, such as one of an unmounted volume, is reported as failed.
+	MinSize  string `mapstructure:"min-size"  yaml:"min-size,omitempty"`
+	MinFiles int    `mapstructure:"min-files" yaml:"min-files,omitempty"`
+
+	Hooks HooksConfig `mapstructure:"hooks" yaml:"hooks,omitempty"`
+
+	// Postgres is the connection of a postgres source.
+	Postgres PostgresConfig `mapstructure:"postgres" yaml:"postgres,omitempty"`
+
+	// MySQL is the connection of a mysql source.
+	MySQL MySQLConfig `mapstructure:"mysql" yaml:"mysql,omitempty"`
+
+	// Redis is the connection of a redis source.
+	Redis RedisConfig `mapstructure:"redis" yaml:"redis,omitempty"`
+
+	// Command is run in the path of a command source, and its output is uploaded as the object Name.
+	Command []string `mapstructure:"command" yaml:"command,omitempty"`
+	Name    string   `mapstructure:"name"    yaml:"name,omitempty"`
+
+	// Snapshot, when set, makes a dir source back up a snapshot of the volume holding it.
+	Snapshot *SnapshotConfig `mapstructure:"snapshot" yaml:"snapshot,omitempty"`
+
+	// Hostname, when set, stores the backups of the source as if the host of that name had made them, such as a
+	// share mounted from another server. See Config.Jobs.
+	Hostname string `mapstructure:"hostname" yaml:"hostname,omitempty"`
+}
+
+// MinSizeBytes returns the minimum stored size of a backup of the source in bytes, or 0 when there is none.
+func (s *SourceConfig) MinSizeBytes() int64 {
+	if s.MinSize == "" {
+		return 0
+	}
+	size, _ := units.ParseBytes(s.MinSize)
+	return size
+}
+
+func (s *SourceConfig) validate() error {
+	if s.Path == "" {
+		return errors.New("sources entry is missing path")
+	}
+
+	if IsRemote(s.Path) {
+		return fmt.Errorf("source %s: remote paths are only supported in dirs", s.Path)
+	}
+
+	if strings.Contains(s.Hostname, "/") {
+		return fmt.Errorf("source %s: hostname must not contain '/'", s.Path)
+	}
+
+	if err := s.validateType(); err != nil {
+		return err
+	}
+
+	for _, pattern := range s.Exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q for %s: %w", pattern, s.Path, err)
+		}
+	}
+	for _, inc := range s.Include {
+		if !filepath.IsLocal(inc) {
+			return fmt.Errorf("invalid include %q for %s: it must be a path relative to and below the source path", inc, s.Path)
+		}
+	}
+
+	if s.Cron != "" {
+		if _, err := cron.ParseStandard(s.Cron); err != nil {
+			return fmt.Errorf("invalid cron %q for %s: %w", s.Cron, s.Path, err)
+		}
+	}
+
+	if s.MinSize != "" {
+		if _, err := units.ParseBytes(s.MinSize); err != nil {
+			return fmt.Errorf("invalid min-size for %s: %w", s.Path, err)
+		}
+	}
+	if s.MinFiles < 0 {
+		return fmt.Errorf("min-files for %s must not be negative", s.Path)
+	}
+	if s.MaxDepth < 0 {
+		return fmt.Errorf("max-depth for %s must not be negative", s.Path)
+	}
+
+	if err := s.Hooks.validate(); err != nil {
+		return fmt.Errorf("%s: %w", s.Path, err)
+	}
+
+	if s.Snapshot != nil {
+		if s.Type != "" && s.Type != SourceTypeDir {
+			return fmt.Errorf("%s source %s does not support snapshot", s.Type, s.Path)
+		}
+		if err := s.Snapshot.validate(filepath.Clean(s.Path)); err != nil {
+			return fmt.Errorf("%s: %w", s.Path, err)
+		}
+	}
+
+	if s.Preset == "" {
+		return nil
+	}
+
+	preset, ok := presets.Get(s.Preset)
+	if !ok {
+		return fmt.Errorf("unknown preset %q for %s, supported: %s", s.Preset, s.Path, strings.Join(presets.Names(), ", "))
+	}
+	if preset.NeedsDump && len(s.DumpCommand) == 0 {
+		slog.Warn("Preset keeps data in a database; set dump-command to include a database dump", "preset", s.Preset, "path", s.Path)
+	}
+
+	return nil
+}
+
+// LeaseConfig is the configuration of the lease taken in the bucket, so only one of several instances storing to
+// the same prefix runs a backup or purge at a time.
+type LeaseConfig struct {
+	Enabled bool          `mapstructure:"enabled" yaml:"enabled"`
+	TTL     time.Duration `mapstructure:"ttl"     yaml:"ttl"`
+}
+
+// WatchConfig is the configuration of watch mode, which backs up paths soon after their files change.
+type WatchConfig struct {
+	Enabled  bool          `mapstructure:"enabled"  yaml:"enabled"`
+	Interval time.Duration `mapstructure:"interval" yaml:"interval"`
+}
+
+// Comparisons of SyncConfig, telling whether a stored file changed.
+const (
+	SyncCompareMtime = "mtime"
+	SyncCompareHash  = "hash"
+)
+
+// SyncConfig is the configuration of sync mode, in which unarchived backups update the newest backup of each dir in
+// place, uploading only the files that changed since, instead of storing a new copy of every file.
+type SyncConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+
+	// Compare is how files are compared to their stored objects: mtime, by size and modification time against the
+	// upload time, or hash, by size and MD5 against the ETag.
+	Compare string `mapstructure:"compare" yaml:"compare"`
+
+	// DeleteRemoved deletes the stored files that are no longer backed up, such as removed or excluded files.
+	DeleteRemoved bool `mapstructure:"delete-removed" yaml:"delete-removed"`
+}
+
+// Versioning strategies of unarchived backups, see BackupConfig.Versioning.
+const (
+	VersioningPrefix  = "prefix"
+	VersioningInPlace = "in-place"
+)
+
+func (s *SyncConfig) validate(modifiedWithin, modifiedBefore time.Duration) error {
+	switch s.Compare {
+	case "":
+		s.Compare = SyncCompareMtime
+	case SyncCompareMtime, SyncCompareHash:
+	default:
+		return fmt.Errorf("invalid sync compare %q, supported: %s, %s", s.Compare, SyncCompareMtime, SyncCompareHash)
+	}
+
+	if s.Enabled && (modifiedWithin > 0 || modifiedBefore > 0) {
+		return errors.New("sync cannot be combined with modified-within or modified-before")
+	}
+	return nil
+}
+
+// DiskCheckConfig is the configuration of the free space check of the temp directory made before archiving a dir.
+type DiskCheckConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+
+	// Margin is the free space left on top of the estimated archive size, such as "1GB".
+	Margin string `mapstructure:"margin" yaml:"margin"`
+}
+
+// MarginBytes returns the margin in bytes.
+func (d *DiskCheckConfig) MarginBytes() int64 {
+	size, _ := units.ParseBytes(d.Margin)
+	return size
+}
+
+// TempCleanupConfig is the configuration of the removal of the temp files left behind by backups that crashed.
+type TempCleanupConfig struct {
+	Enabled bool          `mapstructure:"enabled" yaml:"enabled"`
+	MaxAge  time.Duration `mapstructure:"max-age" yaml:"max-age"`
+}
+
+// IO scheduling classes of PriorityConfig.
+const (
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+// maxNice is the lowest CPU priority, as a niceness.
+const maxNice = 19
+
+// PriorityConfig lowers the CPU and IO priority of the scheduler, so backups do not slow down the services of the
+// host.
+type PriorityConfig struct {
+	// Nice is the niceness of the scheduler and the commands it runs, from 0 (unchanged) to 19 (lowest priority).
+	Nice int `mapstructure:"nice" yaml:"nice"`
+
+	// IOClass is the IO scheduling class, best-effort at its lowest level or idle; empty leaves it unchanged.
+	IOClass string `mapstructure:"io-class" yaml:"io-class"`
+
+	// MaxProcs caps the number of CPUs running the scheduler at once, such as for compression; 0 uses every CPU.
+	MaxProcs int `mapstructure:"max-procs" yaml:"max-procs"`
+}
+
+func (p *PriorityConfig) validate() error {
+	if p.Nice < 0 || p.Nice > maxNice {
+		return fmt.Errorf("priority nice must be between 0 and %d", maxNice)
+	}
+	switch p.IOClass {
+	case "", IOClassBestEffort, IOClassIdle:
+	default:
+		return fmt.Errorf("invalid priority io-class %q, supported: %s, %s", p.IOClass, IOClassBestEffort, IOClassIdle)
+	}
+	if p.MaxProcs < 0 {
+		return errors.New("priority max-procs must not be negative")
+	}
+	return nil
+}
+
 // BackupConfig is the configuration for the backup.
 type BackupConfig struct {
-	Dirs           []string   `mapstructure:"dirs"             yaml:"dirs"`
-	Hostname       string     `mapstructure:"hostname"         yaml:"hostname"`
-	RetentionCount int        `mapstructure:"retention-count"  yaml:"retention-count"`
-	DateTimeLayout string     `mapstructure:"date-time-layout" yaml:"date-time-layout"`
-	Cron           string     `mapstructure:"cron"             yaml:"cron"`
-	ArchiveDirs    bool       `mapstructure:"archive-dirs"     yaml:"archive-dirs"`
-	Encryption     Encryption `mapstructure:"encryption"       yaml:"encryption"`
+	Dirs                []string             `mapstructure:"dirs"                  yaml:"dirs"`
+	Hostname            string               `mapstructure:"hostname"              yaml:"hostname"`
+	RetentionCount      int                  `mapstructure:"retention-count"       yaml:"retention-count"`
+	DateTimeLayout      string               `mapstructure:"date-time-layout"      yaml:"date-time-layout"`
+	Cron                string               `mapstructure:"cron"                  yaml:"cron"`
+	ArchiveDirs         bool                 `mapstructure:"archive-dirs"          yaml:"archive-dirs"`
+	ArchiveFormat       string               `mapstructure:"archive-format"        yaml:"archive-format"`
+	Sync                SyncConfig           `mapstructure:"sync"                  yaml:"sync"`
+	Versioning          string               `mapstructure:"versioning"            yaml:"versioning"`
+	Encryption          Encryption           `mapstructure:"encryption"            yaml:"encryption"`
+	MaxStoredSize       string               `mapstructure:"max-stored-size"       yaml:"max-stored-size"`
+	DirQuotas           []DirQuota           `mapstructure:"dir-quotas"            yaml:"dir-quotas"`
+	QuotaAction         string               `mapstructure:"quota-action"          yaml:"quota-action"`
+	Label               string               `mapstructure:"label"                 yaml:"label"`
+	Labels              map[string]string    `mapstructure:"labels"                yaml:"labels,omitempty"`
+	ArchiveNameTemplate string               `mapstructure:"archive-name-template" yaml:"archive-name-template"`
+	KeyTemplate         string               `mapstructure:"key-template"          yaml:"key-template"`
+	Timezone            string               `mapstructure:"timezone"              yaml:"timezone"`
+	Jitter              time.Duration        `mapstructure:"jitter"                yaml:"jitter"`
+	Timeout             time.Duration        `mapstructure:"timeout"               yaml:"timeout"`
+	DirTimeout          time.Duration        `mapstructure:"dir-timeout"           yaml:"dir-timeout"`
+	RunOnStart          bool                 `mapstructure:"run-on-start"          yaml:"run-on-start"`
+	IncludeConfig       bool                 `mapstructure:"include-config"        yaml:"include-config"`
+	OpaqueNames         bool                 `mapstructure:"opaque-names"          yaml:"opaque-names"`
+	SkipUnchanged       bool                 `mapstructure:"skip-unchanged"        yaml:"skip-unchanged"`
+	Lease               LeaseConfig          `mapstructure:"lease"                 yaml:"lease"`
+	Watch               WatchConfig          `mapstructure:"watch"                 yaml:"watch"`
+	DiskCheck           DiskCheckConfig      `mapstructure:"disk-check"            yaml:"disk-check"`
+	TempCleanup         TempCleanupConfig    `mapstructure:"temp-cleanup"          yaml:"temp-cleanup"`
+	Priority            PriorityConfig       `mapstructure:"priority"              yaml:"priority"`
+	Sources             []SourceConfig       `mapstructure:"sources"               yaml:"sources"`
+	Hooks               HooksConfig          `mapstructure:"hooks"                 yaml:"hooks,omitempty"`
+	SSH                 SSHConfig            `mapstructure:"ssh"                   yaml:"ssh"`
+	SpecialFiles        string               `mapstructure:"special-files"         yaml:"special-files"`
+	OneFileSystem       bool                 `mapstructure:"one-file-system"       yaml:"one-file-system"`
+	Strict              bool                 `mapstructure:"strict"                yaml:"strict"`
+	MaxFileSize         string               `mapstructure:"max-file-size"         yaml:"max-file-size"`
+	ModifiedWithin      time.Duration        `mapstructure:"modified-within"       yaml:"modified-within"`
+	ModifiedBefore      time.Duration        `mapstructure:"modified-before"       yaml:"modified-before"`
+	Cold                ColdTierConfig       `mapstructure:"cold"                  yaml:"cold"`
+	LegacyLayouts       []LegacyLayoutConfig `mapstructure:"legacy-layouts"        yaml:"legacy-layouts,omitempty"`
+	Jobs                []JobConfig          `mapstructure:"jobs"                  yaml:"jobs"`
+	Hosts               []HostConfig         `mapstructure:"hosts"                 yaml:"hosts,omitempty"`
+
+	// Job is the name of the job a derived configuration belongs to, see Config.Jobs.
+	Job string `mapstructure:"-" yaml:"-"`
+}
+
+// Paths returns every backed up path: the plain dirs followed by the source paths, then those of the jobs.
+func (b *BackupConfig) Paths() []string {
+	paths := b.ownPaths()
+	for _, job := range b.Jobs {
+		paths = append(paths, job.Dirs...)
+		for _, s := range job.Sources {
+			paths = append(paths, s.Path)
+		}
+	}
+	return paths
+}
+
+// ownPaths returns the top level dirs followed by the source paths, excluding those of the jobs.
+func (b *BackupConfig) ownPaths() []string {
+	paths := slices.Clone(b.Dirs)
+	for _, s := range b.Sources {
+		paths = append(paths, s.Path)
+	}
+	return paths
+}
+
+// Schedule is a cron expression and the paths backed up on it.
+type Schedule struct {
+	Cron  string
+	Paths []string
+}
+
+// Schedules groups the backed up paths by cron expression, in configuration order. Dirs and sources without
+// their own cron use the backup cron.
+func (b *BackupConfig) Schedules() []Schedule {
+	var schedules []Schedule
+	add := func(expr, path string) {
+		if expr == "" {
+			expr = b.Cron
+		}
+		for i := range schedules {
+			if schedules[i].Cron == expr {
+				schedules[i].Paths = append(schedules[i].Paths, path)
+				return
+			}
+		}
+		schedules = append(schedules, Schedule{Cron: expr, Paths: []string{path}})
+	}
+
+	for _, dir := range b.Dirs {
+		add("", dir)
+	}
+	for _, s := range b.Sources {
+		add(s.Cron, s.Path)
+	}
+	return schedules
+}
+
+// CheckPaths reports every backed up path that does not exist or is neither a directory nor a regular file. Remote
+// dirs are not checked.
+func (b *BackupConfig) CheckPaths() error {
+	var errs []error
+	for _, path := range b.Paths() {
+		if IsRemote(path) {
+			continue
+		}
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("backup path %s: %w", path, err))
+		case !info.IsDir() && !info.Mode().IsRegular():
+			errs = append(errs, fmt.Errorf("backup path %s is not a directory or regular file", path))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ArchiveNamePlaceholders are the placeholders supported by archive-name-template.
+var ArchiveNamePlaceholders = []string{"dir", "path", "hostname", "timestamp", "label"}
+
+func (b *BackupConfig) validateArchiveName() error {
+	if b.ArchiveNameTemplate == "" {
+		b.ArchiveNameTemplate = constants.DefaultArchiveNameTemplate
+	}
+
+	if err := naming.Validate(b.ArchiveNameTemplate, ArchiveNamePlaceholders); err != nil {
+		return fmt.Errorf("invalid archive-name-template: %w", err)
+	}
+
+	if !b.ArchiveDirs || slices.Contains(naming.Placeholders(b.ArchiveNameTemplate), "path") {
+		return nil
+	}
+
+	seen := map[string]string{}
+	for _, dir := range b.ownPaths() {
+		base := filepath.Base(filepath.Clean(dir))
+		if other, ok := seen[base]; ok {
+			slog.Warn("Directories share the same name and will produce colliding archive names; add {path} to archive-name-template",
+				"dirs", []string{other, dir})
+		}
+		seen[base] = dir
+	}
+
+	return nil
+}
+
+// validateRunTimeouts checks the timeout of a backup run and that of each of its dirs, 0 for none.
+func validateRunTimeouts(timeout, dirTimeout time.Duration) error {
+	if timeout < 0 || dirTimeout < 0 {
+		return errors.New("timeout and dir-timeout must not be negative")
+	}
+	return nil
+}
+
+// validateTimestamps checks the time zone and layout backup timestamps are formatted with.
+func (b *BackupConfig) validateTimestamps() error {
+	if _, err := time.LoadLocation(b.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", b.Timezone, err)
+	}
+
+	if b.DateTimeLayout == "" {
+		b.DateTimeLayout = constants.DefaultDateTimeLayout
+	}
+	return naming.ValidateTimeLayout(b.DateTimeLayout)
+}
+
+// Location returns the time zone cron expressions and backup timestamps use. An empty timezone is UTC.
+func (b *BackupConfig) Location() *time.Location {
+	loc, err := time.LoadLocation(b.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// MaxStoredSizeBytes returns the host wide stored size quota in bytes, or 0 when unlimited.
+func (b *BackupConfig) MaxStoredSizeBytes() int64 {
+	if b.MaxStoredSize == "" {
+		return 0
+	}
+	size, _ := units.ParseBytes(b.MaxStoredSize)
+	return size
+}
+
+// MaxFileSizeBytes returns the size above which files are left out of backups in bytes, or 0 when unlimited.
+func (b *BackupConfig) MaxFileSizeBytes() int64 {
+	if b.MaxFileSize == "" {
+		return 0
+	}
+	size, _ := units.ParseBytes(b.MaxFileSize)
+	return size
+}
+
+// DirMaxStoredSizeBytes returns the stored size quota of dir in bytes, or 0 when unlimited.
+func (b *BackupConfig) DirMaxStoredSizeBytes(dir string) int64 {
+	for _, q := range b.DirQuotas {
+		if filepath.Clean(q.Dir) == filepath.Clean(dir) {
+			size, _ := units.ParseBytes(q.MaxStoredSize)
+			return size
+		}
+	}
+	return 0
+}
+
+// ModifiedRange returns the range of modification times of the files backed up at now: after is zero without
+// modified-within and before is zero without modified-before.
+func (b *BackupConfig) ModifiedRange(now time.Time) (time.Time, time.Time) {
+	var after, before time.Time
+	if b.ModifiedWithin > 0 {
+		after = now.Add(-b.ModifiedWithin)
+	}
+	if b.ModifiedBefore > 0 {
+		before = now.Add(-b.ModifiedBefore)
+	}
+	return after, before
+}
+
+// validateFilters validates the file filters of the backup section or of a job.
+func validateFilters(maxFileSize string, modifiedWithin, modifiedBefore time.Duration) error {
+	if maxFileSize != "" {
+		if _, err := units.ParseBytes(maxFileSize); err != nil {
+			return fmt.Errorf("invalid max-file-size: %w", err)
+		}
+	}
+	if modifiedWithin < 0 || modifiedBefore < 0 {
+		return errors.New("modified-within and modified-before must not be negative")
+	}
+	if modifiedWithin > 0 && modifiedBefore > 0 && modifiedWithin <= modifiedBefore {
+		return fmt.Errorf("modified-within %s must be longer than modified-before %s, or no file is backed up", modifiedWithin, modifiedBefore)
+	}
+	return nil
+}
+
+// validateHost validates how backups use the host: the temp directory archives are staged in and the priority.
+func (b *BackupConfig) validateHost() error {
+	if b.DiskCheck.Margin != "" {
+		if _, err := units.ParseBytes(b.DiskCheck.Margin); err != nil {
+			return fmt.Errorf("invalid disk-check margin: %w", err)
+		}
+	}
+
+	switch {
+	case b.TempCleanup.MaxAge == 0:
+		b.TempCleanup.MaxAge = constants.DefaultTempCleanupMaxAge
+	case b.TempCleanup.MaxAge < 0:
+		return errors.New("temp-cleanup max-age must not be negative")
+	}
+
+	return b.Priority.validate()
+}
+
+func (b *BackupConfig) validateQuotas() error {
+	if b.MaxStoredSize != "" {
+		if _, err := units.ParseBytes(b.MaxStoredSize); err != nil {
+			return fmt.Errorf("invalid max-stored-size: %w", err)
+		}
+	}
+
+	for _, q := range b.DirQuotas {
+		if q.Dir == "" {
+			return errors.New("dir-quotas entry is missing dir")
+		}
+		if _, err := units.ParseBytes(q.MaxStoredSize); err != nil {
+			return fmt.Errorf("invalid max-stored-size for %s: %w", q.Dir, err)
+		}
+		if !slices.Contains(b.Paths(), q.Dir) {
+			slog.Warn("Quota configured for a directory that is not backed up", "dir", q.Dir)
+		}
+	}
+
+	switch b.QuotaAction {
+	case "":
+		b.QuotaAction = QuotaActionWarn
+	case QuotaActionWarn, QuotaActionBlock, QuotaActionPurge:
+	default:
+		return fmt.Errorf("invalid quota-action: %s", b.QuotaAction)
+	}
+
+	return nil
 }
 
 func (b *BackupConfig) validate() error {
-	if len(b.Dirs) == 0 {
-		return errors.New("dirs is required")
+	if len(b.Dirs) == 0 && len(b.Sources) == 0 && len(b.Jobs) == 0 {
+		return errors.New("dirs is required when no sources or jobs are configured")
+	}
+
+	for i := range b.Sources {
+		if err := b.Sources[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := b.Hooks.validate(); err != nil {
+		return err
+	}
+
+	if err := b.SSH.validate(b.Paths()); err != nil {
+		return err
 	}
 
 	if b.RetentionCount <= 0 {
@@ -63,22 +830,145 @@ func (b *BackupConfig) validate() error {
 		return errors.New("cron is required")
 	}
 
-	// ToDo: Add cron validation
+	if _, err := cron.ParseStandard(b.Cron); err != nil {
+		return fmt.Errorf("invalid cron %q: %w", b.Cron, err)
+	}
+
+	if err := b.validateTimestamps(); err != nil {
+		return err
+	}
+
+	if b.Jitter < 0 {
+		return errors.New("jitter must not be negative")
+	}
+	if err := validateRunTimeouts(b.Timeout, b.DirTimeout); err != nil {
+		return err
+	}
+
+	switch {
+	case b.Lease.TTL == 0:
+		b.Lease.TTL = constants.DefaultLeaseTTL
+	case b.Lease.TTL < 0:
+		return errors.New("lease ttl must not be negative")
+	}
+
+	switch b.ArchiveFormat {
+	case "":
+		b.ArchiveFormat = ArchiveFormatZip
+	case ArchiveFormatZip, ArchiveFormatTar:
+	default:
+		return fmt.Errorf("invalid archive-format %q, supported: %s, %s", b.ArchiveFormat, ArchiveFormatZip, ArchiveFormatTar)
+	}
+
+	switch b.SpecialFiles {
+	case "":
+		b.SpecialFiles = SpecialFilesSkip
+	case SpecialFilesSkip, SpecialFilesRecord:
+	default:
+		return fmt.Errorf("invalid special-files %q, supported: %s, %s", b.SpecialFiles, SpecialFilesSkip, SpecialFilesRecord)
+	}
+
+	switch {
+	case b.Watch.Interval == 0:
+		b.Watch.Interval = constants.DefaultWatchInterval
+	case b.Watch.Interval < 0:
+		return errors.New("watch interval must not be negative")
+	}
+
+	if err := b.validateQuotas(); err != nil {
+		return err
+	}
+
+	if err := validateFilters(b.MaxFileSize, b.ModifiedWithin, b.ModifiedBefore); err != nil {
+		return err
+	}
+
+	if err := b.validateVersioning(); err != nil {
+		return err
+	}
+
+	if err := b.Sync.validate(b.ModifiedWithin, b.ModifiedBefore); err != nil {
+		return err
+	}
 
+	if err := b.validateHost(); err != nil {
+		return err
+	}
+
+	if err := b.validateArchiveName(); err != nil {
+		return err
+	}
+
+	if err := validateEncryption(&b.Encryption, b.ArchiveDirs); err != nil {
+		return err
+	}
+
+	if err := validateLabels(b.Labels); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateVersioning resolves the versioning strategy of unarchived backups: prefix stores every run under its own
+// timestamped prefix, in-place syncs the newest backup of each dir and relies on S3 bucket versioning to keep the
+// previous versions of files. Empty selects in-place when sync is enabled, which requires it.
+func (b *BackupConfig) validateVersioning() error {
+	switch b.Versioning {
+	case "":
+		b.Versioning = VersioningPrefix
+		if b.Sync.Enabled {
+			b.Versioning = VersioningInPlace
+		}
+	case VersioningInPlace:
+		b.Sync.Enabled = true
+	case VersioningPrefix:
+		if b.Sync.Enabled {
+			return fmt.Errorf("sync requires versioning %s", VersioningInPlace)
+		}
+	default:
+		return fmt.Errorf("unknown versioning %q, supported: %s, %s", b.Versioning, VersioningPrefix, VersioningInPlace)
+	}
+
+	if b.Versioning == VersioningInPlace && b.Cold.Enabled {
+		return fmt.Errorf("versioning %s cannot be combined with the cold tier", VersioningInPlace)
+	}
+	return nil
+}
+
+// validateEncryption disables encryption when it cannot be applied.
+func validateEncryption(e *Encryption, archiveDirs bool) error {
 	// Check if encryption is enabled & encryption config is enabled.
-	if b.Encryption.Enabled && !b.ArchiveDirs {
+	if e.Enabled && !archiveDirs {
 		slog.Warn("Backup encryption is only available when archive dirs are enabled. Disabling encryption")
-		b.Encryption.Enabled = false
-	} else if b.Encryption.Enabled {
-		if b.Encryption.GPG.KeyServer == "" || b.Encryption.GPG.KeyID == "" {
+		e.Enabled = false
+	} else if e.Enabled {
+		if len(e.GPG.Servers()) == 0 || e.GPG.KeyID == "" {
 			slog.Error("Encryption is enabled but GPG key server or key ID is missing")
-			b.Encryption.Enabled = false
+			e.Enabled = false
 		}
 	}
+	if e.GPG.Timeout <= 0 {
+		e.GPG.Timeout = constants.DefaultKeyServerTimeout
+	}
 
+	if e.GPG.ExpectedFingerprint == "" {
+		return nil
+	}
+	fingerprint := normalizeFingerprint(e.GPG.ExpectedFingerprint)
+	if _, err := hex.DecodeString(fingerprint); err != nil || (len(fingerprint) != 40 && len(fingerprint) != 64) {
+		return fmt.Errorf("invalid gpg expected-fingerprint %q, use the 40 or 64 hex digits of the key fingerprint", e.GPG.ExpectedFingerprint)
+	}
+	e.GPG.ExpectedFingerprint = fingerprint
 	return nil
 }
 
+// normalizeFingerprint returns fingerprint, as printed by gpg, as uppercase hex digits.
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.TrimPrefix(strings.TrimPrefix(fingerprint, "0x"), "0X")
+	return strings.ToUpper(strings.ReplaceAll(fingerprint, " ", ""))
+}
+
 // DiscordNotifierConfig is the configuration for the Discord notifier.
 type DiscordNotifierConfig struct {
 	Enabled bool   `mapstructure:"enabled" yaml:"enabled"`
@@ -95,15 +985,53 @@ func (d *DiscordNotifierConfig) validate() error {
 
 // NotifiersConfig is the configuration for the notifiers.
 type NotifiersConfig struct {
-	Enabled bool                  `mapstructure:"enabled" yaml:"enabled"`
-	Discord DiscordNotifierConfig `mapstructure:"discord" yaml:"discord"`
+	Enabled       bool                  `mapstructure:"enabled"        yaml:"enabled"`
+	ReportSkipped bool                  `mapstructure:"report-skipped" yaml:"report-skipped"`
+	Timeout       time.Duration         `mapstructure:"timeout"        yaml:"timeout"`
+	Discord       DiscordNotifierConfig `mapstructure:"discord"        yaml:"discord"`
+	Sentry        SentryNotifierConfig  `mapstructure:"sentry"         yaml:"sentry"`
+
+	// External holds the sections of the notifiers registered through the library, keyed by notifier name.
+	External map[string]any `mapstructure:",remain" yaml:",inline"`
+}
+
+// Decode decodes the section of the external notifier name, notifiers.<name>, into out. It reports false when the
+// section is not set.
+func (n *NotifiersConfig) Decode(name string, out any) (bool, error) {
+	section, ok := n.External[name]
+	if !ok {
+		return false, nil
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := decoder.Decode(section); err != nil {
+		return false, fmt.Errorf("invalid notifiers.%s: %w", name, err)
+	}
+	return true, nil
 }
 
 func (n *NotifiersConfig) validate() error {
+	if n.Timeout < 0 {
+		return errors.New("notifiers timeout must not be negative")
+	}
+	if n.Timeout == 0 {
+		n.Timeout = constants.DefaultNotifierTimeout
+	}
+
 	if err := n.Discord.validate(); err != nil {
 		return err
 	}
-	return nil
+	return n.Sentry.validate()
 }
 
 // LoggerConfig is the configuration for the logger.
@@ -124,19 +1052,143 @@ func (l *LoggerConfig) validate() error {
 	return nil
 }
 
+// StateConfig is the configuration for the local state store. An empty dir disables state persistence.
+type StateConfig struct {
+	Dir string `mapstructure:"dir" yaml:"dir"`
+}
+
+// LockPath returns the path of the lock serializing backups and purges on this host: in the state dir, or in the
+// temp directory when state persistence is disabled.
+func (s StateConfig) LockPath() string {
+	return filepath.Join(s.localDir(), constants.ProgramIdentifier+".lock")
+}
+
+// GPGKeyPath returns the path the GPG public key keyID is cached at between runs, next to the lock.
+func (s StateConfig) GPGKeyPath(keyID string) string {
+	return filepath.Join(s.localDir(), "gpg", keyID+".asc")
+}
+
+// UpdateNoticePath returns the path recording the last update notice added to a notification, next to the lock.
+func (s StateConfig) UpdateNoticePath() string {
+	return filepath.Join(s.localDir(), "update-notice")
+}
+
+// localDir returns the state dir, or the temp directory when state persistence is disabled.
+func (s StateConfig) localDir() string {
+	if s.Dir == "" {
+		return os.TempDir()
+	}
+	return s.Dir
+}
+
+// DashboardConfig is the configuration for the embedded web dashboard.
+type DashboardConfig struct {
+	Enabled  bool   `mapstructure:"enabled"  yaml:"enabled"`
+	Listen   string `mapstructure:"listen"   yaml:"listen"`
+	Username string `mapstructure:"username" yaml:"username"`
+	Password string `mapstructure:"password" yaml:"password"`
+}
+
+func (d *DashboardConfig) validate() error {
+	if !d.Enabled {
+		return nil
+	}
+
+	if d.Listen == "" {
+		return errors.New("dashboard listen address is required")
+	}
+
+	if (d.Username == "") != (d.Password == "") {
+		return errors.New("dashboard username and password must be set together")
+	}
+
+	if d.Username == "" {
+		slog.Warn("Dashboard is enabled without authentication; anyone with network access can trigger backups")
+	}
+
+	return nil
+}
+
 // Config is the configuration for the program.
 type Config struct {
-	S3        S3Config        `mapstructure:"s3"        yaml:"s3"`
-	Backup    BackupConfig    `mapstructure:"backup"    yaml:"backup"`
-	Notifiers NotifiersConfig `mapstructure:"notifiers" yaml:"notifiers"`
-	Logger    LoggerConfig    `mapstructure:"logger"    yaml:"logger"`
+	Version     int               `mapstructure:"version"   yaml:"version"`
+	Include     []string          `mapstructure:"include"   yaml:"include,omitempty"`
+	S3          S3Config          `mapstructure:"s3"        yaml:"s3"`
+	Storage     StorageConfig     `mapstructure:"storage"   yaml:"storage"`
+	Backup      BackupConfig      `mapstructure:"backup"    yaml:"backup"`
+	Notifiers   NotifiersConfig   `mapstructure:"notifiers" yaml:"notifiers"`
+	Logger      LoggerConfig      `mapstructure:"logger"    yaml:"logger"`
+	State       StateConfig       `mapstructure:"state"     yaml:"state"`
+	Dashboard   DashboardConfig   `mapstructure:"dashboard" yaml:"dashboard"`
+	Proxy       ProxyConfig       `mapstructure:"proxy"        yaml:"proxy"`
+	Verify      VerifyConfig      `mapstructure:"verify"       yaml:"verify"`
+	UpdateCheck UpdateCheckConfig `mapstructure:"update-check" yaml:"update-check"`
+}
+
+func (c *Config) validateColdTier() error {
+	cold := &c.Backup.Cold
+	if !cold.Enabled {
+		return nil
+	}
+
+	if cold.Bucket == "" {
+		cold.Bucket = c.S3.Bucket
+	}
+	if cold.Bucket == c.S3.Bucket && strings.Trim(cold.Prefix, "/") == strings.Trim(c.S3.Prefix, "/") {
+		return errors.New("cold tier must use a different bucket or prefix than s3")
+	}
+	if cold.RetentionCount <= 0 {
+		return errors.New("cold retention-count must be greater than 0")
+	}
+	if cold.StorageClass == "" {
+		cold.StorageClass = DefaultColdStorageClass
+	}
+	return validateStorageClass(cold.StorageClass)
+}
+
+// ColdTier returns the configuration of the cold tier: a copy of c storing to the cold bucket and prefix with
+// the cold retention. It returns nil when the cold tier is disabled.
+func (c *Config) ColdTier() *Config {
+	if !c.Backup.Cold.Enabled {
+		return nil
+	}
+
+	cold := *c
+	cold.S3.Bucket = c.Backup.Cold.Bucket
+	cold.S3.Prefix = c.Backup.Cold.Prefix
+	cold.S3.StorageClass = c.Backup.Cold.StorageClass
+	cold.Backup.RetentionCount = c.Backup.Cold.RetentionCount
+	cold.Backup.Cold = ColdTierConfig{}
+	return &cold
+}
+
+func (c *Config) validateVersion() error {
+	if c.Version > CurrentVersion {
+		return fmt.Errorf("config version %d is newer than the supported version %d", c.Version, CurrentVersion)
+	}
+	if c.Version < CurrentVersion {
+		slog.Warn("Config file uses an old schema; run `config migrate` to update it", "version", c.Version, "current", CurrentVersion)
+	}
+	return nil
 }
 
 func (c *Config) validate() error {
 	validators := []func() error{
+		c.validateVersion,
+		c.S3.validate,
+		c.Storage.validate,
 		c.Logger.validate,
 		c.Backup.validate,
 		c.Notifiers.validate,
+		c.Dashboard.validate,
+		c.Proxy.validate,
+		c.Verify.validate,
+		c.UpdateCheck.validate,
+		c.validateColdTier,
+		c.validateStorageBackend,
+		c.validateJobs,
+		c.validateOpaqueNames,
+		c.validateKeyTemplate,
 	}
 
 	for _, validate := range validators {
@@ -148,6 +1200,14 @@ func (c *Config) validate() error {
 	return nil
 }
 
+// defaultStateDir returns the platform specific directory for persisted state.
+func defaultStateDir(runtime commonRuntime.RuntimeIface) string {
+	if runtime.GetGOOS() == "linux" {
+		return filepath.Join(constants.StateRootLinux, constants.ProgramIdentifier)
+	}
+	return filepath.Join(runtime.GetConfigDir(), constants.ProgramIdentifier, "state")
+}
+
 func (c *Config) getViper(ctx context.Context, path string) *viper.Viper {
 	v := viper.New()
 	v.SetConfigName(commonRuntime.ConfigFileName)
@@ -169,23 +1229,114 @@ func (c *Config) getViper(ctx context.Context, path string) *viper.Viper {
 	v.AutomaticEnv()
 
 	envBindings := map[string]string{
-		"s3.endpoint":                      "s3.endpoint",
-		"s3.region":                        "s3.region",
-		"s3.access-key":                    "s3.access-key",
-		"s3.secret-key":                    "s3.secret-key",
-		"s3.bucket":                        "s3.bucket",
-		"s3.prefix":                        "s3.prefix",
-		"backup.retention-count":           "backup.retention-count",
-		"backup.date-time-layout":          "backup.date-time-layout",
-		"backup.cron":                      "backup.cron",
-		"backup.archive-dirs":              "backup.archive-dirs",
-		"Backup.Encryption.Enabled":        "backup.encryption.enabled",
-		"backup.encryption.gpg.key-server": "backup.encryption.gpg.key-server",
-		"backup.encryption.gpg.key-id":     "backup.encryption.gpg.key-id",
-		"notifiers.discord.enabled":        "notifiers.discord.enabled",
-		"notifiers.discord.webhook":        "notifiers.discord.webhook",
-		"logger.level":                     "logger.level",
-		"logger.mode":                      "logger.mode",
+		"version":                                    "version",
+		"s3.endpoint":                                "s3.endpoint",
+		"s3.region":                                  "s3.region",
+		"s3.access-key":                              "s3.access-key",
+		"s3.secret-key":                              "s3.secret-key",
+		"s3.profile":                                 "s3.profile",
+		"s3.web-identity.role-arn":                   "s3.web-identity.role-arn",
+		"s3.web-identity.token-file":                 "s3.web-identity.token-file",
+		"s3.web-identity.session-name":               "s3.web-identity.session-name",
+		"s3.bucket":                                  "s3.bucket",
+		"s3.prefix":                                  "s3.prefix",
+		"s3.storage-class":                           "s3.storage-class",
+		"s3.upload-concurrency":                      "s3.upload-concurrency",
+		"s3.retry.max-attempts":                      "s3.retry.max-attempts",
+		"s3.retry.max-backoff":                       "s3.retry.max-backoff",
+		"s3.timeouts.connect":                        "s3.timeouts.connect",
+		"s3.timeouts.read":                           "s3.timeouts.read",
+		"s3.timeouts.request":                        "s3.timeouts.request",
+		"s3.tls.ca-file":                             "s3.tls.ca-file",
+		"s3.tls.cert-file":                           "s3.tls.cert-file",
+		"s3.tls.key-file":                            "s3.tls.key-file",
+		"s3.tls.insecure-skip-verify":                "s3.tls.insecure-skip-verify",
+		"s3.create-bucket.enabled":                   "s3.create-bucket.enabled",
+		"s3.create-bucket.versioning":                "s3.create-bucket.versioning",
+		"s3.create-bucket.object-lock":               "s3.create-bucket.object-lock",
+		"backup.retention-count":                     "backup.retention-count",
+		"backup.date-time-layout":                    "backup.date-time-layout",
+		"backup.cron":                                "backup.cron",
+		"backup.archive-dirs":                        "backup.archive-dirs",
+		"backup.archive-format":                      "backup.archive-format",
+		"backup.special-files":                       "backup.special-files",
+		"backup.one-file-system":                     "backup.one-file-system",
+		"backup.strict":                              "backup.strict",
+		"backup.sync.enabled":                        "backup.sync.enabled",
+		"backup.sync.compare":                        "backup.sync.compare",
+		"backup.sync.delete-removed":                 "backup.sync.delete-removed",
+		"backup.versioning":                          "backup.versioning",
+		"backup.max-file-size":                       "backup.max-file-size",
+		"backup.modified-within":                     "backup.modified-within",
+		"backup.modified-before":                     "backup.modified-before",
+		"Backup.Encryption.Enabled":                  "backup.encryption.enabled",
+		"backup.encryption.gpg.key-server":           "backup.encryption.gpg.key-server",
+		"backup.encryption.gpg.key-servers":          "backup.encryption.gpg.key-servers",
+		"backup.encryption.gpg.key-id":               "backup.encryption.gpg.key-id",
+		"backup.encryption.gpg.timeout":              "backup.encryption.gpg.timeout",
+		"backup.encryption.gpg.expected-fingerprint": "backup.encryption.gpg.expected-fingerprint",
+		"backup.max-stored-size":                     "backup.max-stored-size",
+		"backup.quota-action":                        "backup.quota-action",
+		"backup.label":                               "backup.label",
+		"backup.archive-name-template":               "backup.archive-name-template",
+		"backup.key-template":                        "backup.key-template",
+		"backup.timezone":                            "backup.timezone",
+		"backup.timeout":                             "backup.timeout",
+		"backup.dir-timeout":                         "backup.dir-timeout",
+		"backup.jitter":                              "backup.jitter",
+		"backup.run-on-start":                        "backup.run-on-start",
+		"backup.include-config":                      "backup.include-config",
+		"backup.opaque-names":                        "backup.opaque-names",
+		"backup.skip-unchanged":                      "backup.skip-unchanged",
+		"backup.lease.enabled":                       "backup.lease.enabled",
+		"backup.lease.ttl":                           "backup.lease.ttl",
+		"backup.disk-check.enabled":                  "backup.disk-check.enabled",
+		"backup.disk-check.margin":                   "backup.disk-check.margin",
+		"backup.temp-cleanup.enabled":                "backup.temp-cleanup.enabled",
+		"backup.temp-cleanup.max-age":                "backup.temp-cleanup.max-age",
+		"backup.priority.nice":                       "backup.priority.nice",
+		"backup.priority.io-class":                   "backup.priority.io-class",
+		"backup.priority.max-procs":                  "backup.priority.max-procs",
+		"backup.hooks.on-failure":                    "backup.hooks.on-failure",
+		"backup.ssh.command":                         "backup.ssh.command",
+		"backup.ssh.tar-format":                      "backup.ssh.tar-format",
+		"backup.watch.enabled":                       "backup.watch.enabled",
+		"backup.watch.interval":                      "backup.watch.interval",
+		"backup.cold.enabled":                        "backup.cold.enabled",
+		"backup.cold.bucket":                         "backup.cold.bucket",
+		"backup.cold.prefix":                         "backup.cold.prefix",
+		"backup.cold.storage-class":                  "backup.cold.storage-class",
+		"backup.cold.retention-count":                "backup.cold.retention-count",
+		"notifiers.report-skipped":                   "notifiers.report-skipped",
+		"notifiers.timeout":                          "notifiers.timeout",
+		"notifiers.discord.enabled":                  "notifiers.discord.enabled",
+		"notifiers.discord.webhook":                  "notifiers.discord.webhook",
+		"notifiers.sentry.enabled":                   "notifiers.sentry.enabled",
+		"notifiers.sentry.dsn":                       "notifiers.sentry.dsn",
+		"notifiers.sentry.environment":               "notifiers.sentry.environment",
+		"logger.level":                               "logger.level",
+		"logger.mode":                                "logger.mode",
+		"state.dir":                                  "state.dir",
+		"dashboard.enabled":                          "dashboard.enabled",
+		"dashboard.listen":                           "dashboard.listen",
+		"dashboard.username":                         "dashboard.username",
+		"dashboard.password":                         "dashboard.password",
+		"storage.backend":                            "storage.backend",
+		"storage.exec.command":                       "storage.exec.command",
+		"storage.rclone.url":                         "storage.rclone.url",
+		"storage.rclone.remote":                      "storage.rclone.remote",
+		"storage.rclone.username":                    "storage.rclone.username",
+		"storage.rclone.password":                    "storage.rclone.password",
+		"proxy.url":                                  "proxy.url",
+		"proxy.no-proxy":                             "proxy.no-proxy",
+		"verify.cron":                                "verify.cron",
+		"verify.sample":                              "verify.sample",
+		"verify.object-lock.cron":                    "verify.object-lock.cron",
+		"verify.object-lock.mode":                    "verify.object-lock.mode",
+		"verify.object-lock.min-retention":           "verify.object-lock.min-retention",
+		"update-check.enabled":                       "update-check.enabled",
+		"update-check.channel":                       "update-check.channel",
+		"update-check.notify-every":                  "update-check.notify-every",
 	}
 
 	for configKey, envVar := range envBindings {
@@ -197,33 +1348,156 @@ func (c *Config) getViper(ctx context.Context, path string) *viper.Viper {
 		}
 	}
 
-	// Add default values.
-	v.SetDefault("s3.endpoint", "")
-	v.SetDefault("s3.region", "")
-	v.SetDefault("s3.access-key", "")
-	v.SetDefault("s3.secret-key", "")
-	v.SetDefault("s3.bucket", "")
-	v.SetDefault("s3.prefix", "")
-	v.SetDefault("backup.dirs", []string{})
-	v.SetDefault("backup.retention-count", constants.DefaultRetentionCount)
-	v.SetDefault("backup.date-time-layout", constants.DefaultDateTimeLayout)
-	v.SetDefault("backup.cron", constants.DefaultCron)
-	v.SetDefault("backup.hostname", commonUtils.GetHostname())
-	v.SetDefault("backup.archive-dirs", false)
-	v.SetDefault("backup.encryption.enabled", false)
-	v.SetDefault("backup.encryption.gpg.key-server", "")
-	v.SetDefault("backup.encryption.gpg.key-id", "")
-	v.SetDefault("notifiers.enabled", false)
-	v.SetDefault("notifiers.discord.enabled", false)
-	v.SetDefault("notifiers.discord.webhook", "")
-	v.SetDefault("logger.level", commonLogger.DefaultLoggerLevel)
-	v.SetDefault("logger.mode", commonLogger.DefaultLoggerMode)
+	setDefaults(v, defaultStateDir(runtime))
 
 	return v
 }
 
-// LoadConfig loads the configuration from the config file.
+// setDefaults sets the default value of every config key. stateDir is the default state directory of the platform.
+func setDefaults(v *viper.Viper, stateDir string) {
+	defaults := map[string]any{
+		// Files without a version predate it, such as those of GoS3Backup, and need migrating.
+		"version":                                    0,
+		"s3.endpoint":                                "",
+		"s3.region":                                  "",
+		"s3.upload-concurrency":                      constants.DefaultUploadConcurrency,
+		"s3.retry.max-attempts":                      constants.DefaultRetryMaxAttempts,
+		"s3.retry.max-backoff":                       constants.DefaultRetryMaxBackoff,
+		"s3.timeouts.connect":                        constants.DefaultS3ConnectTimeout,
+		"s3.timeouts.read":                           constants.DefaultS3ReadTimeout,
+		"s3.timeouts.request":                        time.Duration(0),
+		"s3.tls.ca-file":                             "",
+		"s3.tls.cert-file":                           "",
+		"s3.tls.key-file":                            "",
+		"s3.tls.insecure-skip-verify":                false,
+		"s3.create-bucket.enabled":                   false,
+		"s3.create-bucket.versioning":                false,
+		"s3.create-bucket.object-lock":               false,
+		"s3.access-key":                              "",
+		"s3.secret-key":                              "",
+		"s3.profile":                                 "",
+		"s3.web-identity.role-arn":                   "",
+		"s3.web-identity.token-file":                 "",
+		"s3.web-identity.session-name":               "",
+		"s3.bucket":                                  "",
+		"s3.prefix":                                  "",
+		"s3.storage-class":                           "",
+		"backup.dirs":                                []string{},
+		"backup.retention-count":                     constants.DefaultRetentionCount,
+		"backup.date-time-layout":                    constants.DefaultDateTimeLayout,
+		"backup.cron":                                constants.DefaultCron,
+		"backup.hostname":                            commonUtils.GetHostname(),
+		"backup.archive-dirs":                        false,
+		"backup.archive-format":                      ArchiveFormatZip,
+		"backup.special-files":                       SpecialFilesSkip,
+		"backup.one-file-system":                     false,
+		"backup.strict":                              true,
+		"backup.sync.enabled":                        false,
+		"backup.sync.compare":                        SyncCompareMtime,
+		"backup.sync.delete-removed":                 false,
+		"backup.versioning":                          "",
+		"backup.max-file-size":                       "",
+		"backup.modified-within":                     time.Duration(0),
+		"backup.modified-before":                     time.Duration(0),
+		"backup.encryption.enabled":                  false,
+		"backup.encryption.gpg.key-server":           "",
+		"backup.encryption.gpg.key-servers":          []string{},
+		"backup.encryption.gpg.key-id":               "",
+		"backup.encryption.gpg.timeout":              constants.DefaultKeyServerTimeout,
+		"backup.encryption.gpg.expected-fingerprint": "",
+		"backup.max-stored-size":                     "",
+		"backup.dir-quotas":                          []DirQuota{},
+		"backup.quota-action":                        QuotaActionWarn,
+		"backup.label":                               "",
+		"backup.labels":                              map[string]string{},
+		"backup.archive-name-template":               constants.DefaultArchiveNameTemplate,
+		"backup.key-template":                        constants.DefaultKeyTemplate,
+		"backup.timezone":                            constants.DefaultTimezone,
+		"backup.jitter":                              time.Duration(0),
+		"backup.timeout":                             time.Duration(0),
+		"backup.dir-timeout":                         time.Duration(0),
+		"backup.run-on-start":                        false,
+		"backup.include-config":                      false,
+		"backup.opaque-names":                        false,
+		"backup.skip-unchanged":                      false,
+		"backup.lease.enabled":                       false,
+		"backup.lease.ttl":                           constants.DefaultLeaseTTL,
+		"backup.disk-check.enabled":                  true,
+		"backup.disk-check.margin":                   constants.DefaultDiskCheckMargin,
+		"backup.temp-cleanup.enabled":                true,
+		"backup.temp-cleanup.max-age":                constants.DefaultTempCleanupMaxAge,
+		"backup.priority.nice":                       0,
+		"backup.priority.io-class":                   "",
+		"backup.priority.max-procs":                  0,
+		"backup.watch.enabled":                       false,
+		"backup.watch.interval":                      constants.DefaultWatchInterval,
+		"backup.sources":                             []SourceConfig{},
+		"backup.hooks.pre":                           []string{},
+		"backup.hooks.post":                          []string{},
+		"backup.hooks.on-failure":                    "",
+		"backup.ssh.command":                         "ssh",
+		"backup.ssh.options":                         []string{},
+		"backup.ssh.tar-format":                      TarFormatPAX,
+		"backup.jobs":                                []JobConfig{},
+		"backup.legacy-layouts":                      []LegacyLayoutConfig{},
+		"backup.hosts":                               []HostConfig{},
+		"backup.cold.enabled":                        false,
+		"backup.cold.bucket":                         "",
+		"backup.cold.prefix":                         "",
+		"backup.cold.storage-class":                  DefaultColdStorageClass,
+		"backup.cold.retention-count":                0,
+		"notifiers.enabled":                          false,
+		"notifiers.report-skipped":                   false,
+		"notifiers.timeout":                          constants.DefaultNotifierTimeout,
+		"notifiers.discord.enabled":                  false,
+		"notifiers.discord.webhook":                  "",
+		"notifiers.sentry.enabled":                   false,
+		"notifiers.sentry.dsn":                       "",
+		"notifiers.sentry.environment":               "",
+		"logger.level":                               commonLogger.DefaultLoggerLevel,
+		"logger.mode":                                commonLogger.DefaultLoggerMode,
+		"state.dir":                                  stateDir,
+		"dashboard.enabled":                          false,
+		"dashboard.listen":                           constants.DefaultDashboardListen,
+		"dashboard.username":                         "",
+		"dashboard.password":                         "",
+		"storage.backend":                            StorageBackendS3,
+		"storage.exec.command":                       "",
+		"storage.exec.args":                          []string{},
+		"storage.exec.timeout":                       time.Duration(0),
+		"storage.rclone.url":                         constants.DefaultRcloneURL,
+		"storage.rclone.remote":                      "",
+		"storage.rclone.username":                    "",
+		"storage.rclone.password":                    "",
+		"storage.rclone.timeout":                     constants.DefaultRcloneTimeout,
+		"proxy.url":                                  "",
+		"proxy.no-proxy":                             []string{},
+		"verify.cron":                                "",
+		"verify.sample":                              0,
+		"verify.object-lock.cron":                    "",
+		"verify.object-lock.mode":                    "",
+		"verify.object-lock.min-retention":           time.Duration(0),
+		"update-check.enabled":                       true,
+		"update-check.channel":                       UpdateChannelStable,
+		"update-check.notify-every":                  constants.DefaultUpdateNoticeEvery,
+	}
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+}
+
+// LoadConfig loads the configuration from the config file, which may be the URL of a remote file, see FetchConfig.
 func LoadConfig(ctx context.Context, configPath string) (*Config, error) {
+	remote := IsConfigURL(configPath)
+	if remote {
+		// The proxy of the config file is not known yet; the proxy environment variables apply.
+		path, err := FetchConfig(ctx, configPath, ProxyConfig{})
+		if err != nil {
+			return nil, err
+		}
+		configPath = path
+	}
+
 	cfg := &Config{}
 	v := cfg.getViper(ctx, configPath)
 
@@ -232,41 +1506,74 @@ func LoadConfig(ctx context.Context, configPath string) (*Config, error) {
 		var notFoundErr viper.ConfigFileNotFoundError
 		if errors.As(err, &notFoundErr) {
 			slog.WarnContext(ctx, "No config file found, relying on env vars/defaults")
+			// There is no file to migrate.
+			v.SetDefault("version", CurrentVersion)
 		} else {
 			return nil, err
 		}
 	} else {
 		slog.InfoContext(ctx, "Using config file", slog.String("file", v.ConfigFileUsed()))
+		if err := mergeIncludes(ctx, v, v.ConfigFileUsed(), remote); err != nil {
+			return nil, err
+		}
 	}
 
-	// Unmarshal into Current.
+	// Unmarshal into the config.
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, err
 	}
 
+	if err := cfg.resolveSecretRefs(ctx); err != nil {
+		return nil, err
+	}
+
+	if LoggerLevelOverride != "" {
+		cfg.Logger.Level = LoggerLevelOverride
+	}
+
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
 
 	// Initialize logger.
-	commonLogger.InitLogger(&cfg.Logger.Level, &cfg.Logger.Mode)
+	cfg.initLogger()
 
 	return cfg, nil
 }
 
-// Current is the current configuration.
-var Current *Config
+// current is the current configuration, replaced when the daemon reloads it while jobs read it.
+var current atomic.Pointer[Config]
+
+// Current returns the current configuration, nil before it is loaded.
+func Current() *Config {
+	return current.Load()
+}
+
+// SetCurrent replaces the current configuration with cfg.
+func SetCurrent(cfg *Config) {
+	current.Store(cfg)
+}
+
+// LogToStderr, when set, makes the loaded configs log to stderr instead of stdout, for commands printing their output
+// in a machine-readable format.
+var LogToStderr bool
+
+// LoggerLevelOverride, when set, replaces logger.level in every loaded config, for the flags setting the level of one
+// invocation.
+var LoggerLevelOverride string
 
 // GetConfig gets the current configuration.
 func GetConfig(ctx context.Context, configPath string) (*Config, error) {
-	if Current == nil {
-		var err error
-		Current, err = LoadConfig(ctx, configPath)
-		if err != nil {
-			return nil, err
-		}
+	if cfg := current.Load(); cfg != nil {
+		return cfg, nil
+	}
+
+	cfg, err := LoadConfig(ctx, configPath)
+	if err != nil {
+		return nil, err
 	}
-	return Current, nil
+	current.CompareAndSwap(nil, cfg)
+	return current.Load(), nil
 }
 
 // GenerateConfigFile generates a new config file.
@@ -278,6 +1585,7 @@ func GenerateConfigFile(ctx context.Context, configPath string) (string, error)
 	if err := v.Unmarshal(cfg); err != nil {
 		return "", fmt.Errorf("failed to unmarshal defaults: %w", err)
 	}
+	cfg.Version = CurrentVersion
 
 	// Marshal the config struct to YAML
 	yamlBytes, err := yaml.Marshal(cfg)
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Source types. A directory source backs up the files of its path; a database source only backs up the dumps taken
+// by the database's own tool; a command source backs up the output of its command as a single object.
+const (
+	SourceTypeDir      = "dir"
+	SourceTypePostgres = "postgres"
+	SourceTypeMySQL    = "mysql"
+	SourceTypeRedis    = "redis"
+	SourceTypeCommand  = "command"
+)
+
+// sourceTypes are the supported source types, in the order they are listed in errors.
+var sourceTypes = []string{SourceTypeDir, SourceTypePostgres, SourceTypeMySQL, SourceTypeRedis, SourceTypeCommand}
+
+// validateDatabaseNames checks that databases can be used as dump file names.
+func validateDatabaseNames(kind string, databases []string) error {
+	for _, db := range databases {
+		if db == "" || strings.ContainsAny(db, `/\`) {
+			return fmt.Errorf("invalid %s database name %q", kind, db)
+		}
+	}
+	return nil
+}
+
+// PostgresConfig is the connection of a postgres source. Each database is dumped with pg_dump; without databases, the
+// whole cluster is dumped with pg_dumpall.
+type PostgresConfig struct {
+	Host      string   `mapstructure:"host"      yaml:"host,omitempty"`
+	Port      int      `mapstructure:"port"      yaml:"port,omitempty"`
+	User      string   `mapstructure:"user"      yaml:"user,omitempty"`
+	Password  string   `mapstructure:"password"  yaml:"password,omitempty"`
+	Databases []string `mapstructure:"databases" yaml:"databases,omitempty"`
+
+	// Options are extra arguments of pg_dump and pg_dumpall, such as --clean.
+	Options []string `mapstructure:"options" yaml:"options,omitempty"`
+}
+
+func (p *PostgresConfig) validate() error {
+	if p.Port < 0 {
+		return errors.New("postgres port must not be negative")
+	}
+	return validateDatabaseNames(SourceTypePostgres, p.Databases)
+}
+
+// MySQLConfig is the connection of a mysql source, which also covers MariaDB. Each database is dumped with mysqldump;
+// without databases, every database is dumped together.
+type MySQLConfig struct {
+	Host      string   `mapstructure:"host"      yaml:"host,omitempty"`
+	Port      int      `mapstructure:"port"      yaml:"port,omitempty"`
+	User      string   `mapstructure:"user"      yaml:"user,omitempty"`
+	Password  string   `mapstructure:"password"  yaml:"password,omitempty"`
+	Databases []string `mapstructure:"databases" yaml:"databases,omitempty"`
+
+	// Options are extra arguments of mysqldump, such as --routines.
+	Options []string `mapstructure:"options" yaml:"options,omitempty"`
+}
+
+func (m *MySQLConfig) validate() error {
+	if m.Port < 0 {
+		return errors.New("mysql port must not be negative")
+	}
+	return validateDatabaseNames(SourceTypeMySQL, m.Databases)
+}
+
+// RedisConfig is the connection of a redis source. Without an RDB path, a snapshot is transferred from the server with
+// redis-cli --rdb; with one, the server writes it with BGSAVE and the file is backed up.
+type RedisConfig struct {
+	Host     string `mapstructure:"host"     yaml:"host,omitempty"`
+	Port     int    `mapstructure:"port"     yaml:"port,omitempty"`
+	Socket   string `mapstructure:"socket"   yaml:"socket,omitempty"`
+	User     string `mapstructure:"user"     yaml:"user,omitempty"`
+	Password string `mapstructure:"password" yaml:"password,omitempty"`
+	RDBPath  string `mapstructure:"rdb-path" yaml:"rdb-path,omitempty"`
+}
+
+func (r *RedisConfig) validate() error {
+	if r.Port < 0 {
+		return errors.New("redis port must not be negative")
+	}
+	if r.Socket != "" && (r.Host != "" || r.Port != 0) {
+		return errors.New("redis socket cannot be combined with host and port")
+	}
+	return nil
+}
+
+// validateType checks the type of s and the settings of database and command sources.
+func (s *SourceConfig) validateType() error {
+	var validate func() error
+	switch s.Type {
+	case "", SourceTypeDir:
+		return nil
+	case SourceTypePostgres:
+		validate = s.Postgres.validate
+	case SourceTypeMySQL:
+		validate = s.MySQL.validate
+	case SourceTypeRedis:
+		validate = s.Redis.validate
+	case SourceTypeCommand:
+		validate = s.validateCommand
+	default:
+		return fmt.Errorf("unknown source type %q for %s, supported: %s", s.Type, s.Path, strings.Join(sourceTypes, ", "))
+	}
+
+	if s.Preset != "" || len(s.Exclude) > 0 || len(s.Include) > 0 || len(s.DumpCommand) > 0 {
+		return fmt.Errorf("%s source %s does not support preset, exclude, include or dump-command", s.Type, s.Path)
+	}
+	if err := validate(); err != nil {
+		return fmt.Errorf("%s: %w", s.Path, err)
+	}
+	return nil
+}
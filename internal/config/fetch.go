@@ -0,0 +1,255 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	"github.com/hibare/arclift/internal/constants"
+)
+
+// configURLSchemes are the URL schemes of config files fetched from a remote location, see FetchConfig.
+var configURLSchemes = []string{"s3", "https", "http"}
+
+// maxConfigRedirects is the number of redirects followed when fetching a config file.
+const maxConfigRedirects = 10
+
+var (
+	// ErrConfigURL is returned when a config file URL cannot be fetched.
+	ErrConfigURL = errors.New("invalid config url")
+
+	// ErrInsecureConfigURL is returned when a config file would be fetched over plain HTTP without
+	// AllowInsecureConfigURL.
+	ErrInsecureConfigURL = errors.New("insecure config url")
+)
+
+// AllowInsecureConfigURL, when set, allows config files to be fetched over plain HTTP. The config file runs hooks and
+// storage commands, so anyone able to tamper with the connection could run commands on the host.
+var AllowInsecureConfigURL bool
+
+// IsConfigURL reports whether configPath is the URL of a remote config file, such as s3://bucket/arclift.yaml or
+// https://config.example.com/arclift.yaml, rather than a local path.
+func IsConfigURL(configPath string) bool {
+	scheme, _, ok := strings.Cut(configPath, "://")
+	if !ok {
+		return false
+	}
+	for _, s := range configURLSchemes {
+		if strings.EqualFold(scheme, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchedConfig is the local copy of a remote config file, stored in the cache dir with the ETag it was fetched with.
+type fetchedConfig struct {
+	path string
+}
+
+func newFetchedConfig(rawURL string) (fetchedConfig, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, constants.ProgramIdentifier, "config")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fetchedConfig{}, err
+	}
+
+	sum := sha256.Sum256([]byte(rawURL))
+	return fetchedConfig{path: filepath.Join(dir, hex.EncodeToString(sum[:8])+".yaml")}, nil
+}
+
+func (f fetchedConfig) etagPath() string {
+	return f.path + ".etag"
+}
+
+// etag returns the ETag of the cached copy, "" when there is none.
+func (f fetchedConfig) etag() string {
+	if _, err := os.Stat(f.path); err != nil {
+		return ""
+	}
+	etag, err := os.ReadFile(f.etagPath())
+	if err != nil {
+		return ""
+	}
+	return string(etag)
+}
+
+// store replaces the cached copy with body, atomically so an interrupted fetch keeps the previous copy.
+func (f fetchedConfig) store(body io.Reader, etag string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return err
+	}
+	return os.WriteFile(f.etagPath(), []byte(etag), 0o600)
+}
+
+// checkSecureURL refuses the plain HTTP URL u, the config file URL or an endpoint of it, unless
+// AllowInsecureConfigURL is set.
+func checkSecureURL(u *url.URL) error {
+	if !strings.EqualFold(u.Scheme, "http") || AllowInsecureConfigURL {
+		return nil
+	}
+	return fmt.Errorf("%w %q: plain http can be tampered with in transit, use https or pass --allow-insecure-config-url",
+		ErrInsecureConfigURL, u.Redacted())
+}
+
+// FetchConfig downloads the config file at rawURL, through proxy, and returns the path of its local copy. The copy is
+// cached, and only downloaded again when the ETag of the remote file changed. When the remote file cannot be fetched,
+// the cached copy is used, so hosts keep running on their last known configuration. Plain HTTP is refused, see
+// AllowInsecureConfigURL.
+//
+// S3 objects, s3://bucket/key, are fetched with the default AWS credential chain; the region and endpoint query
+// parameters select the region and an S3 compatible endpoint, such as s3://bucket/key?endpoint=https://minio:9000.
+func FetchConfig(ctx context.Context, rawURL string, proxy ProxyConfig) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrConfigURL, err)
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return "", fmt.Errorf("%w %q: use s3://bucket/key or https://host/path", ErrConfigURL, u.Redacted())
+	}
+	if err := checkSecureURL(u); err != nil {
+		return "", err
+	}
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" && strings.EqualFold(u.Scheme, "s3") {
+		eu, err := url.Parse(endpoint)
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid endpoint: %w", ErrConfigURL, err)
+		}
+		if err := checkSecureURL(eu); err != nil {
+			return "", err
+		}
+	}
+	client := &http.Client{
+		Transport: proxy.Transport(),
+		Timeout:   constants.DefaultConfigFetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxConfigRedirects {
+				return errors.New("stopped after too many redirects")
+			}
+			// A redirect must not downgrade the connection to plain HTTP.
+			return checkSecureURL(req.URL)
+		},
+	}
+
+	cached, err := newFetchedConfig(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, constants.DefaultConfigFetchTimeout)
+	defer cancel()
+
+	fetch := fetchHTTP
+	if strings.EqualFold(u.Scheme, "s3") {
+		fetch = fetchS3
+	}
+	if err := fetch(ctx, client, u, cached); err != nil {
+		if _, sErr := os.Stat(cached.path); sErr != nil {
+			return "", fmt.Errorf("failed to fetch config %s: %w", u.Redacted(), err)
+		}
+		slog.WarnContext(ctx, "Error fetching config, using the cached copy", "url", u.Redacted(), "error", err)
+	}
+	return cached.path, nil
+}
+
+// fetchHTTP refreshes cached from the http(s) URL u.
+func fetchHTTP(ctx context.Context, client *http.Client, u *url.URL, cached fetchedConfig) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if etag := cached.etag(); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		slog.DebugContext(ctx, "Config unchanged", "url", u.Redacted())
+		return nil
+	case http.StatusOK:
+		return cached.store(resp.Body, resp.Header.Get("ETag"))
+	default:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
+
+// fetchS3 refreshes cached from the S3 object u.
+func fetchS3(ctx context.Context, client *http.Client, u *url.URL, cached fetchedConfig) error {
+	query := u.Query()
+	loadOpts := []func(*awsConfig.LoadOptions) error{awsConfig.WithHTTPClient(client)}
+	if region := query.Get("region"); region != "" {
+		loadOpts = append(loadOpts, awsConfig.WithRegion(region))
+	}
+	awsCfg, err := awsConfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return err
+	}
+
+	api := awsS3.NewFromConfig(awsCfg, func(o *awsS3.Options) {
+		if endpoint := query.Get("endpoint"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	input := &awsS3.GetObjectInput{
+		Bucket: aws.String(u.Host),
+		Key:    aws.String(strings.TrimPrefix(u.Path, "/")),
+	}
+	if etag := cached.etag(); etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+
+	out, err := api.GetObject(ctx, input)
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotModified" {
+			slog.DebugContext(ctx, "Config unchanged", "url", u.Redacted())
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		_ = out.Body.Close()
+	}()
+	return cached.store(out.Body, aws.ToString(out.ETag))
+}
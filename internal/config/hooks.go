@@ -0,0 +1,40 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Hook failure policies, applied when a pre hook fails.
+const (
+	// HookOnFailureAbort fails the backup the hook runs before.
+	HookOnFailureAbort = "abort"
+
+	// HookOnFailureWarn logs the failure and backs up anyway.
+	HookOnFailureWarn = "warn"
+)
+
+// HooksConfig holds the commands run before and after the backup of a source, or of every source of a job, such as
+// stopping a service or taking a dump. {path} in their arguments is replaced by the source path.
+type HooksConfig struct {
+	Pre       []string `mapstructure:"pre"        yaml:"pre,omitempty"`
+	Post      []string `mapstructure:"post"       yaml:"post,omitempty"`
+	OnFailure string   `mapstructure:"on-failure" yaml:"on-failure,omitempty"`
+}
+
+func (h *HooksConfig) validate() error {
+	switch h.OnFailure {
+	case "":
+		h.OnFailure = HookOnFailureAbort
+	case HookOnFailureAbort, HookOnFailureWarn:
+	default:
+		return fmt.Errorf("invalid hooks on-failure %q, supported: %s, %s", h.OnFailure, HookOnFailureAbort, HookOnFailureWarn)
+	}
+
+	for _, command := range [][]string{h.Pre, h.Post} {
+		if len(command) > 0 && command[0] == "" {
+			return errors.New("hook command must not be empty")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// dropInDir is the directory, next to the config file, whose *.yaml files override it, see mergeIncludes.
+const dropInDir = "config.d"
+
+// includedFiles returns the files included by the config file at path: the include entries, which may be globs,
+// relative to the directory of path, or config file URLs, fetched through proxy. remote is true when path is the local
+// copy of a remote config file, whose directory holds no included files.
+func includedFiles(ctx context.Context, path string, includes []string, remote bool, proxy ProxyConfig) ([]string, error) {
+	var files []string
+	for _, include := range includes {
+		if IsConfigURL(include) {
+			fetched, err := FetchConfig(ctx, include, proxy)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, fetched)
+			continue
+		}
+
+		if !filepath.IsAbs(include) {
+			if remote {
+				return nil, fmt.Errorf("include %s: a remote config file can only include absolute paths and URLs", include)
+			}
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		matches, err := filepath.Glob(include)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include %q: %w", include, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(include, "*?[") {
+			return nil, fmt.Errorf("include %s: %w", include, os.ErrNotExist)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// mergeIncludes merges the files included by the config file at path into v, which has read it. Included files are
+// defaults: the config file overrides them, and the *.yaml files of its config.d directory, in name order, override
+// the config file. Lists are replaced, not appended to. Included files cannot include other files. The !encrypted values
+// of every file are decrypted, see decryptConfig.
+func mergeIncludes(ctx context.Context, v *viper.Viper, path string, remote bool) error {
+	// Included files are fetched through the proxy of the config file.
+	proxy := ProxyConfig{URL: v.GetString("proxy.url"), NoProxy: v.GetStringSlice("proxy.no-proxy")}
+	if err := proxy.validate(); err != nil {
+		return err
+	}
+	includes, err := includedFiles(ctx, path, v.GetStringSlice("include"), remote, proxy)
+	if err != nil {
+		return err
+	}
+
+	var dropIns []string
+	if !remote {
+		if dropIns, err = filepath.Glob(filepath.Join(filepath.Dir(path), dropInDir, "*.yaml")); err != nil {
+			return err
+		}
+	}
+	layers := slices.Concat(includes, []string{path}, dropIns)
+	if err := v.ReadConfig(bytes.NewReader(nil)); err != nil {
+		return err
+	}
+	for _, layer := range layers {
+		data, err := os.ReadFile(layer)
+		if err != nil {
+			return err
+		}
+		if data, err = decryptConfig(data); err != nil {
+			return fmt.Errorf("config file %s: %w", layer, err)
+		}
+		if err := v.MergeConfig(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("failed to merge config file %s: %w", layer, err)
+		}
+		if layer != path {
+			slog.InfoContext(ctx, "Merged config file", slog.String("file", layer))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// DefaultJobName is the name of the job formed by the top level backup dirs and sources.
+const DefaultJobName = "default"
+
+// HostJobSeparator separates the job name from the hostname in the name of the job backing up the sources of a job
+// stored under another hostname, such as default@nas. Configured job names cannot contain it.
+const HostJobSeparator = "@"
+
+var jobNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// JobConfig is a named backup job. Options left unset are inherited from the backup section.
+type JobConfig struct {
+	Name           string            `mapstructure:"name"            yaml:"name"`
+	Dirs           []string          `mapstructure:"dirs"            yaml:"dirs"`
+	Sources        []SourceConfig    `mapstructure:"sources"         yaml:"sources,omitempty"`
+	Prefix         string            `mapstructure:"prefix"          yaml:"prefix,omitempty"`
+	Hostname       string            `mapstructure:"hostname"        yaml:"hostname,omitempty"`
+	RetentionCount int               `mapstructure:"retention-count" yaml:"retention-count,omitempty"`
+	Cron           string            `mapstructure:"cron"            yaml:"cron,omitempty"`
+	ArchiveDirs    *bool             `mapstructure:"archive-dirs"    yaml:"archive-dirs,omitempty"`
+	Encryption     *Encryption       `mapstructure:"encryption"      yaml:"encryption,omitempty"`
+	Labels         map[string]string `mapstructure:"labels"          yaml:"labels,omitempty"`
+	Hooks          HooksConfig       `mapstructure:"hooks"           yaml:"hooks,omitempty"`
+	MaxFileSize    string            `mapstructure:"max-file-size"   yaml:"max-file-size,omitempty"`
+	ModifiedWithin time.Duration     `mapstructure:"modified-within" yaml:"modified-within,omitempty"`
+	ModifiedBefore time.Duration     `mapstructure:"modified-before" yaml:"modified-before,omitempty"`
+	Timeout        time.Duration     `mapstructure:"timeout"         yaml:"timeout,omitempty"`
+	DirTimeout     time.Duration     `mapstructure:"dir-timeout"     yaml:"dir-timeout,omitempty"`
+}
+
+func (j *JobConfig) validate(b *BackupConfig) error {
+	if !jobNamePattern.MatchString(j.Name) {
+		return fmt.Errorf("invalid job name %q: use letters, digits, '.', '_' and '-'", j.Name)
+	}
+
+	if strings.Contains(j.Hostname, "/") {
+		return fmt.Errorf("job %s: hostname must not contain '/'", j.Name)
+	}
+
+	if len(j.Dirs) == 0 && len(j.Sources) == 0 {
+		return fmt.Errorf("job %s: dirs is required when no sources are configured", j.Name)
+	}
+
+	for i := range j.Sources {
+		if err := j.Sources[i].validate(); err != nil {
+			return fmt.Errorf("job %s: %w", j.Name, err)
+		}
+	}
+
+	if j.RetentionCount < 0 {
+		return fmt.Errorf("job %s: retention-count must not be negative", j.Name)
+	}
+
+	if err := validateFilters(j.MaxFileSize, j.ModifiedWithin, j.ModifiedBefore); err != nil {
+		return fmt.Errorf("job %s: %w", j.Name, err)
+	}
+	if err := validateRunTimeouts(j.Timeout, j.DirTimeout); err != nil {
+		return fmt.Errorf("job %s: %w", j.Name, err)
+	}
+
+	if j.Cron != "" {
+		if _, err := cron.ParseStandard(j.Cron); err != nil {
+			return fmt.Errorf("job %s: invalid cron %q: %w", j.Name, j.Cron, err)
+		}
+	}
+
+	if j.Encryption != nil {
+		archiveDirs := b.ArchiveDirs
+		if j.ArchiveDirs != nil {
+			archiveDirs = *j.ArchiveDirs
+		}
+		if err := validateEncryption(j.Encryption, archiveDirs); err != nil {
+			return fmt.Errorf("job %s: %w", j.Name, err)
+		}
+	}
+
+	if err := validateLabels(j.Labels); err != nil {
+		return fmt.Errorf("job %s: %w", j.Name, err)
+	}
+
+	if err := j.Hooks.validate(); err != nil {
+		return fmt.Errorf("job %s: %w", j.Name, err)
+	}
+
+	return nil
+}
+
+func (c *Config) validateJobs() error {
+	names := []string{DefaultJobName}
+	for i := range c.Backup.Jobs {
+		job := &c.Backup.Jobs[i]
+		if err := job.validate(&c.Backup); err != nil {
+			return err
+		}
+
+		if slices.Contains(names, job.Name) {
+			return fmt.Errorf("duplicate job name %s", job.Name)
+		}
+		names = append(names, job.Name)
+	}
+
+	if err := c.validateHosts(); err != nil {
+		return err
+	}
+
+	// The default job stores to s3.prefix; every other job must use its own prefix or hostname.
+	prefixes := map[string]string{}
+	for _, job := range c.Jobs() {
+		prefix := path.Join(strings.Trim(job.S3.Prefix, "/"), job.Backup.Hostname)
+		if other, ok := prefixes[prefix]; ok {
+			return fmt.Errorf("jobs %s and %s use the same prefix and hostname %q", other, job.Backup.Job, prefix)
+		}
+		prefixes[prefix] = job.Backup.Job
+	}
+
+	for _, job := range c.Jobs() {
+		if job.Backup.Job == DefaultJobName {
+			continue
+		}
+		if err := job.Backup.validateArchiveName(); err != nil {
+			return fmt.Errorf("job %s: %w", job.Backup.Job, err)
+		}
+		// The filters of a job combine with those it inherits.
+		if err := validateFilters(job.Backup.MaxFileSize, job.Backup.ModifiedWithin, job.Backup.ModifiedBefore); err != nil {
+			return fmt.Errorf("job %s: %w", job.Backup.Job, err)
+		}
+		if err := job.Backup.Sync.validate(job.Backup.ModifiedWithin, job.Backup.ModifiedBefore); err != nil {
+			return fmt.Errorf("job %s: %w", job.Backup.Job, err)
+		}
+	}
+
+	return nil
+}
+
+// jobPrefix returns the storage prefix of job. By default it is the job name below s3.prefix, or s3.prefix itself
+// for a job with its own hostname, so its backups are laid out as if that host had made them.
+func (c *Config) jobPrefix(job *JobConfig) string {
+	switch {
+	case job.Prefix != "":
+		return job.Prefix
+	case job.Hostname != "":
+		return c.S3.Prefix
+	default:
+		return path.Join(c.S3.Prefix, job.Name)
+	}
+}
+
+// jobHostname returns the hostname job stores its backups under.
+func (c *Config) jobHostname(job *JobConfig) string {
+	if job.Hostname != "" {
+		return job.Hostname
+	}
+	return c.Backup.Hostname
+}
+
+// HostConfig holds the settings of the backups stored under a hostname, whichever jobs back them up.
+type HostConfig struct {
+	Hostname       string `mapstructure:"hostname"        yaml:"hostname"`
+	RetentionCount int    `mapstructure:"retention-count" yaml:"retention-count,omitempty"`
+}
+
+func (c *Config) validateHosts() error {
+	hostnames := map[string]bool{}
+	for _, job := range c.Jobs() {
+		hostnames[job.Backup.Hostname] = true
+	}
+
+	seen := map[string]bool{}
+	for _, h := range c.Backup.Hosts {
+		switch {
+		case h.Hostname == "":
+			return errors.New("hosts entry is missing hostname")
+		case strings.Contains(h.Hostname, "/"):
+			return fmt.Errorf("host %s: hostname must not contain '/'", h.Hostname)
+		case seen[h.Hostname]:
+			return fmt.Errorf("duplicate host %s", h.Hostname)
+		case h.RetentionCount < 0:
+			return fmt.Errorf("host %s: retention-count must not be negative", h.Hostname)
+		}
+		seen[h.Hostname] = true
+		if !hostnames[h.Hostname] {
+			slog.Warn("Settings configured for a hostname no job stores backups under", "hostname", h.Hostname)
+		}
+	}
+	return nil
+}
+
+var (
+	// ErrUnknownJob is returned when a job name is not configured.
+	ErrUnknownJob = errors.New("unknown job")
+
+	// ErrUnknownHostname is returned when no job stores backups under a hostname.
+	ErrUnknownHostname = errors.New("unknown hostname")
+)
+
+// Jobs returns the configuration of every job, each a copy of c with the backup section replaced by the job
+// settings. The top level dirs and sources form the job named DefaultJobName. The sources of a job stored under
+// another hostname form a job of their own, see splitHosts.
+func (c *Config) Jobs() []*Config {
+	var jobs []*Config
+	if len(c.Backup.Dirs) > 0 || len(c.Backup.Sources) > 0 {
+		job := *c
+		job.Backup.Jobs = nil
+		job.Backup.Job = DefaultJobName
+		jobs = append(jobs, c.splitHosts(&job, 0)...)
+	}
+
+	for i := range c.Backup.Jobs {
+		jc := &c.Backup.Jobs[i]
+
+		job := *c
+		job.S3.Prefix = c.jobPrefix(jc)
+		job.Backup.Hostname = c.jobHostname(jc)
+		job.Backup.Job = jc.Name
+		job.Backup.Jobs = nil
+		job.Backup.Dirs = jc.Dirs
+		job.Backup.Sources = jc.Sources
+		// Hooks are specific to what a job backs up, so the backup hooks are not inherited.
+		job.Backup.Hooks = jc.Hooks
+		if jc.RetentionCount > 0 {
+			job.Backup.RetentionCount = jc.RetentionCount
+		}
+		if jc.Cron != "" {
+			job.Backup.Cron = jc.Cron
+		}
+		if jc.MaxFileSize != "" {
+			job.Backup.MaxFileSize = jc.MaxFileSize
+		}
+		if jc.ModifiedWithin > 0 {
+			job.Backup.ModifiedWithin = jc.ModifiedWithin
+		}
+		if jc.ModifiedBefore > 0 {
+			job.Backup.ModifiedBefore = jc.ModifiedBefore
+		}
+		if jc.Timeout > 0 {
+			job.Backup.Timeout = jc.Timeout
+		}
+		if jc.DirTimeout > 0 {
+			job.Backup.DirTimeout = jc.DirTimeout
+		}
+		if jc.ArchiveDirs != nil {
+			job.Backup.ArchiveDirs = *jc.ArchiveDirs
+		}
+		if jc.Encryption != nil {
+			job.Backup.Encryption = *jc.Encryption
+		}
+		if len(jc.Labels) > 0 {
+			job.Backup.Labels = maps.Clone(c.Backup.Labels)
+			if job.Backup.Labels == nil {
+				job.Backup.Labels = map[string]string{}
+			}
+			maps.Copy(job.Backup.Labels, jc.Labels)
+		}
+		if job.Backup.Cold.Enabled && jc.Hostname == "" {
+			job.Backup.Cold.Prefix = path.Join(c.Backup.Cold.Prefix, jc.Name)
+		}
+		jobs = append(jobs, c.splitHosts(&job, jc.RetentionCount)...)
+	}
+	return jobs
+}
+
+// splitHosts returns job, without the sources stored under another hostname, followed by a job for each of those
+// hostnames, named <job>@<hostname>, backing up its sources to the prefix of job with the settings of job. job is
+// left out when it has nothing else to back up. Unless the job sets its own retention, retention is that of the
+// hosts entry of the hostname of each job, if any.
+func (c *Config) splitHosts(job *Config, retention int) []*Config {
+	var own []SourceConfig
+	var hostnames []string
+	byHost := map[string][]SourceConfig{}
+	for _, s := range job.Backup.Sources {
+		if s.Hostname == "" || s.Hostname == job.Backup.Hostname {
+			own = append(own, s)
+			continue
+		}
+		if _, ok := byHost[s.Hostname]; !ok {
+			hostnames = append(hostnames, s.Hostname)
+		}
+		byHost[s.Hostname] = append(byHost[s.Hostname], s)
+	}
+
+	var jobs []*Config
+	if len(hostnames) == 0 || len(job.Backup.Dirs) > 0 || len(own) > 0 {
+		jobs = append(jobs, job)
+	}
+	for _, hostname := range hostnames {
+		hostJob := *job
+		hostJob.Backup.Job = job.Backup.Job + HostJobSeparator + hostname
+		hostJob.Backup.Hostname = hostname
+		hostJob.Backup.Dirs = nil
+		hostJob.Backup.Sources = byHost[hostname]
+		jobs = append(jobs, &hostJob)
+	}
+	job.Backup.Sources = own
+
+	if retention == 0 {
+		for _, j := range jobs {
+			for _, h := range c.Backup.Hosts {
+				if h.Hostname == j.Backup.Hostname && h.RetentionCount > 0 {
+					j.Backup.RetentionCount = h.RetentionCount
+				}
+			}
+		}
+	}
+	return jobs
+}
+
+// Job returns the configuration of the named job, see Jobs.
+func (c *Config) Job(name string) (*Config, error) {
+	for _, job := range c.Jobs() {
+		if job.Backup.Job == name {
+			return job, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+}
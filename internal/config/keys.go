@@ -0,0 +1,126 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/naming"
+)
+
+// KeyLayout returns the parsed key template, with the prefix, hostname and job of c.
+func (c *Config) KeyLayout() (naming.KeyLayout, error) {
+	tmpl := c.Backup.KeyTemplate
+	if tmpl == "" {
+		tmpl = constants.DefaultKeyTemplate
+	}
+	layout := c.Backup.DateTimeLayout
+	if layout == "" {
+		layout = constants.DefaultDateTimeLayout
+	}
+
+	return naming.NewKeyLayout(tmpl, layout, c.Backup.Location(), naming.Vars{
+		"prefix":   c.S3.Prefix,
+		"hostname": c.Backup.KeyHostname(),
+		"job":      c.Backup.Job,
+	})
+}
+
+// LegacyLayoutConfig is a key layout backups were stored with before, such as under the prefix, hostname or
+// date-time layout of an older release. Its backups are listed, restored and purged alongside the current ones.
+// Empty fields are those of the current layout.
+type LegacyLayoutConfig struct {
+	Name           string `mapstructure:"name"             yaml:"name"`
+	Prefix         string `mapstructure:"prefix"           yaml:"prefix,omitempty"`
+	Hostname       string `mapstructure:"hostname"         yaml:"hostname,omitempty"`
+	KeyTemplate    string `mapstructure:"key-template"     yaml:"key-template,omitempty"`
+	DateTimeLayout string `mapstructure:"date-time-layout" yaml:"date-time-layout,omitempty"`
+}
+
+// LegacyLayout returns the configuration of a legacy layout: a copy of c storing to the legacy prefix and key layout.
+func (c *Config) LegacyLayout(l LegacyLayoutConfig) *Config {
+	legacy := *c
+	if l.Prefix != "" {
+		legacy.S3.Prefix = l.Prefix
+	}
+	if l.Hostname != "" {
+		legacy.Backup.Hostname = l.Hostname
+	}
+	if l.KeyTemplate != "" {
+		legacy.Backup.KeyTemplate = l.KeyTemplate
+	}
+	if l.DateTimeLayout != "" {
+		legacy.Backup.DateTimeLayout = l.DateTimeLayout
+	}
+	legacy.Backup.OpaqueNames = false
+	legacy.Backup.Cold = ColdTierConfig{}
+	legacy.Backup.LegacyLayouts = nil
+	return &legacy
+}
+
+// AddLegacyLayout adds the legacy layout l, such as one given on the command line, validated like the configured ones.
+func (c *Config) AddLegacyLayout(l LegacyLayoutConfig) error {
+	layouts := c.Backup.LegacyLayouts
+	c.Backup.LegacyLayouts = append(slices.Clip(layouts), l)
+	if err := c.validateKeyTemplate(); err != nil {
+		c.Backup.LegacyLayouts = layouts
+		return err
+	}
+	return nil
+}
+
+// validateKeyTemplate checks that the key template identifies the backups of every job, and that the legacy layouts
+// identify backups other than the current ones.
+func (c *Config) validateKeyTemplate() error {
+	if c.Backup.KeyTemplate == "" {
+		c.Backup.KeyTemplate = constants.DefaultKeyTemplate
+	}
+
+	names := map[string]bool{}
+	for _, l := range c.Backup.LegacyLayouts {
+		switch {
+		case l.Name == "":
+			return errors.New("legacy-layouts entry is missing name")
+		case strings.Contains(l.Name, "/"):
+			return fmt.Errorf("legacy layout name %q must not contain '/'", l.Name)
+		case names[l.Name]:
+			return fmt.Errorf("duplicate legacy layout name %q", l.Name)
+		}
+		names[l.Name] = true
+	}
+
+	for _, job := range c.Jobs() {
+		layout, err := job.KeyLayout()
+		if err != nil {
+			return err
+		}
+		if !layout.SortsChronologically() {
+			return fmt.Errorf("%w %q: backup names must sort chronologically, put {date} before {time}",
+				naming.ErrInvalidKeyTemplate, job.Backup.KeyTemplate)
+		}
+		if cold := job.ColdTier(); cold != nil {
+			coldLayout, err := cold.KeyLayout()
+			if err != nil {
+				return err
+			}
+			// Backups are copied to the cold tier under the same key, date partition included.
+			if _, ok := coldLayout.Parse(layout.Format(time.Now())); !ok {
+				return fmt.Errorf("backup.cold.prefix %q must partition backups by date like s3.prefix %q",
+					job.Backup.Cold.Prefix, job.S3.Prefix)
+			}
+		}
+		for _, l := range job.Backup.LegacyLayouts {
+			legacy, err := job.LegacyLayout(l).KeyLayout()
+			if err != nil {
+				return fmt.Errorf("legacy layout %s: %w", l.Name, err)
+			}
+			if legacy == layout {
+				return fmt.Errorf("legacy layout %s: same as the current key layout", l.Name)
+			}
+		}
+	}
+	return nil
+}
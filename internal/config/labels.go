@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hibare/arclift/internal/naming"
+)
+
+// Limits of S3 object tags, which labels are stored as.
+const (
+	maxLabels          = 10
+	maxLabelKeyLength  = 128
+	maxLabelValLength  = 256
+	reservedLabelScope = "aws:"
+)
+
+var labelPattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// LabelPlaceholders are the placeholders supported by label values.
+var LabelPlaceholders = []string{"hostname", "job"}
+
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("at most %d labels are supported, got %d", maxLabels, len(labels))
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		value := labels[key]
+		if err := naming.Validate(value, LabelPlaceholders); err != nil {
+			return fmt.Errorf("label %s: %w", key, err)
+		}
+		switch {
+		case key == "" || utf8.RuneCountInString(key) > maxLabelKeyLength:
+			return fmt.Errorf("label key %q must be 1 to %d characters", key, maxLabelKeyLength)
+		case strings.HasPrefix(strings.ToLower(key), reservedLabelScope):
+			return fmt.Errorf("label key %q must not start with %s", key, reservedLabelScope)
+		case utf8.RuneCountInString(value) > maxLabelValLength:
+			return fmt.Errorf("label %s: value must be at most %d characters", key, maxLabelValLength)
+		case !labelPattern.MatchString(key) || !labelPattern.MatchString(naming.Render(value, naming.Vars{"hostname": "", "job": ""})):
+			return fmt.Errorf("label %s: use letters, digits, spaces and _ . : / = + - @", key)
+		}
+	}
+	return nil
+}
+
+// RenderedLabels returns the labels with the {hostname} and {job} placeholders of their values replaced.
+func (b *BackupConfig) RenderedLabels() map[string]string {
+	if len(b.Labels) == 0 {
+		return nil
+	}
+	vars := naming.Vars{"hostname": b.Hostname, "job": b.Job}
+	labels := make(map[string]string, len(b.Labels))
+	for key, value := range b.Labels {
+		labels[key] = naming.Render(value, vars)
+	}
+	return labels
+}
+
+// Tagging returns the rendered labels in the URL query form used for S3 object tags, or "" when there are none.
+func (b *BackupConfig) Tagging() string {
+	if len(b.Labels) == 0 {
+		return ""
+	}
+	values := url.Values{}
+	for key, value := range b.RenderedLabels() {
+		values.Set(key, value)
+	}
+	return values.Encode()
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config schema version written by this release.
+const CurrentVersion = 1
+
+// migration upgrades a config document from version to version+1 and describes each change it made.
+type migration struct {
+	version int
+	apply   func(root *yaml.Node) []string
+}
+
+// migrations are applied in order to documents older than CurrentVersion.
+var migrations = []migration{
+	// Version 0 is every config written before schema versioning, including configs of GoS3Backup, which
+	// accepted snake_case and camelCase keys such as retention_count or accessKey.
+	{version: 0, apply: func(root *yaml.Node) []string {
+		return normalizeKeys(root, reflect.TypeFor[Config](), "")
+	}},
+}
+
+// ErrConfigTooNew is returned when migrating a config written by a newer release.
+var ErrConfigTooNew = errors.New("config version is newer than supported")
+
+// ResolveConfigFile returns the local config file LoadConfig reads for configPath.
+func ResolveConfigFile(ctx context.Context, configPath string) (string, error) {
+	if IsConfigURL(configPath) {
+		return "", fmt.Errorf("%w %q: remote config files are changed at their source", ErrConfigURL, configPath)
+	}
+	v := (&Config{}).getViper(ctx, configPath)
+	if err := v.ReadInConfig(); err != nil {
+		var notFoundErr viper.ConfigFileNotFoundError
+		if errors.As(err, &notFoundErr) {
+			return "", os.ErrNotExist
+		}
+		return "", err
+	}
+	return v.ConfigFileUsed(), nil
+}
+
+// Migrate upgrades the config document in data to CurrentVersion, keeping comments and key order.
+// It returns the migrated document and the changes made; no changes means data is already current.
+func Migrate(data []byte) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, errors.New("config is not a YAML mapping")
+	}
+	root := doc.Content[0]
+
+	version := 0
+	if node := mappingValue(root, "version"); node != nil {
+		var err error
+		if version, err = strconv.Atoi(node.Value); err != nil {
+			return nil, nil, fmt.Errorf("invalid config version %q", node.Value)
+		}
+	}
+	if version > CurrentVersion {
+		return nil, nil, fmt.Errorf("%w: %d, supported: %d", ErrConfigTooNew, version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return data, nil, nil
+	}
+
+	var changes []string
+	for _, m := range migrations {
+		if m.version >= version {
+			changes = append(changes, m.apply(root)...)
+		}
+	}
+	setVersion(root, CurrentVersion)
+	changes = append(changes, fmt.Sprintf("set version: %d -> %d", version, CurrentVersion))
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2) //nolint:mnd // two space indentation, as written by config init
+	if err := enc.Encode(&doc); err != nil {
+		return nil, nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), changes, nil
+}
+
+// MigrateFile migrates the config file at path in place, keeping the original next to it with a .bak suffix.
+// With dryRun the file is left untouched and only the changes are returned.
+func MigrateFile(path string, dryRun bool) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated, changes, err := Migrate(data)
+	if err != nil || len(changes) == 0 || dryRun {
+		return changes, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path+".bak", data, info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to back up config: %w", err)
+	}
+	if err := os.WriteFile(path, migrated, info.Mode().Perm()); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// mappingValue returns the value of key in the mapping node m, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setVersion sets the version key of root, adding it at the top when missing.
+func setVersion(root *yaml.Node, version int) {
+	if node := mappingValue(root, "version"); node != nil {
+		node.Value = strconv.Itoa(version)
+		node.Tag = "!!int"
+		return
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	if len(root.Content) > 0 {
+		// Keep the comment at the top of the file above the new key.
+		key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+	}
+	root.Content = append([]*yaml.Node{key, {Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}}, root.Content...)
+}
+
+// canonicalKey converts snake_case and camelCase keys to the kebab-case used by the config.
+func canonicalKey(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		switch {
+		case r == '_':
+			b.WriteRune('-')
+		case unicode.IsUpper(r):
+			if i > 0 && key[i-1] != '_' && key[i-1] != '-' {
+				b.WriteRune('-')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizeKeys renames keys of the mapping node m that only differ from a field of t by their case style.
+func normalizeKeys(m *yaml.Node, t reflect.Type, path string) []string {
+	if m.Kind != yaml.MappingNode || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := map[string]reflect.Type{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if tag, _, _ := strings.Cut(f.Tag.Get("yaml"), ","); tag != "" && tag != "-" {
+			fields[tag] = f.Type
+		}
+	}
+
+	var changes []string
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		keyNode, value := m.Content[i], m.Content[i+1]
+
+		key := keyNode.Value
+		if _, ok := fields[key]; !ok {
+			if canonical := canonicalKey(key); fields[canonical] != nil && mappingValue(m, canonical) == nil {
+				changes = append(changes, fmt.Sprintf("rename %s%s -> %s%s", path, key, path, canonical))
+				keyNode.Value = canonical
+				key = canonical
+			}
+		}
+
+		ft, ok := fields[key]
+		if !ok {
+			continue
+		}
+		switch {
+		case ft.Kind() == reflect.Struct:
+			changes = append(changes, normalizeKeys(value, ft, path+key+".")...)
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct && value.Kind == yaml.SequenceNode:
+			for j, item := range value.Content {
+				changes = append(changes, normalizeKeys(item, ft.Elem(), fmt.Sprintf("%s%s[%d].", path, key, j))...)
+			}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// opaqueHostnameLength is the number of hex digits of the digest storing the hostname with opaque names.
+const opaqueHostnameLength = 16
+
+// KeyHostname returns the hostname backups are stored under: the hostname, or a digest of it with opaque names, so
+// the bucket listing does not reveal it.
+func (b *BackupConfig) KeyHostname() string {
+	if !b.OpaqueNames {
+		return b.Hostname
+	}
+	sum := sha256.Sum256([]byte(b.Hostname))
+	return hex.EncodeToString(sum[:])[:opaqueHostnameLength]
+}
+
+// validateOpaqueNames checks that every job with opaque names stores encrypted archives, whose names are mapped in
+// the encrypted manifest, and nothing that names its dirs in the clear.
+func (c *Config) validateOpaqueNames() error {
+	if !c.Backup.OpaqueNames {
+		return nil
+	}
+	if c.Backup.IncludeConfig {
+		return errors.New("opaque-names cannot be combined with include-config, the stored config names the dirs")
+	}
+	if len(c.Backup.DirQuotas) > 0 {
+		return errors.New("opaque-names cannot be combined with dir-quotas, which match archives by name")
+	}
+
+	for _, job := range c.Jobs() {
+		if !job.Backup.ArchiveDirs || !job.Backup.Encryption.Enabled {
+			return fmt.Errorf("job %s: opaque-names requires archive-dirs and encryption", job.Backup.Job)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// proxySchemes are the proxy URL schemes supported by net/http.
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// ProxyConfig is the outbound proxy of the S3 and notifier requests. Without a URL, the HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY environment variables are used.
+type ProxyConfig struct {
+	URL     string   `mapstructure:"url"      yaml:"url"`
+	NoProxy []string `mapstructure:"no-proxy" yaml:"no-proxy"`
+}
+
+func (p *ProxyConfig) validate() error {
+	if p.URL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy url: %w", err)
+	}
+	if !slices.Contains(proxySchemes, u.Scheme) || u.Host == "" {
+		return fmt.Errorf("invalid proxy url %q, use a %s URL such as http://proxy:3128", u.Redacted(), strings.Join(proxySchemes, ", "))
+	}
+	return nil
+}
+
+// bypass reports whether requests to host skip the proxy, which is when it matches a no-proxy entry: "*", a host
+// name or IP address, or a domain such as ".example.com" or "example.com" matching its subdomains too.
+func (p *ProxyConfig) bypass(host string) bool {
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		domain := strings.TrimPrefix(entry, ".")
+		if entry == "*" || host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Func returns the proxy selection function of the HTTP transports.
+func (p *ProxyConfig) Func() func(*http.Request) (*url.URL, error) {
+	if p.URL == "" {
+		return http.ProxyFromEnvironment
+	}
+
+	proxyURL, _ := url.Parse(p.URL) // validated
+	return func(req *http.Request) (*url.URL, error) {
+		if p.bypass(req.URL.Host) {
+			return nil, nil //nolint:nilnil // a nil URL sends the request directly
+		}
+		return proxyURL, nil
+	}
+}
+
+// Transport returns a clone of the default HTTP transport sending requests through the proxy.
+func (p *ProxyConfig) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:errcheck // the default transport is an *http.Transport
+	transport.Proxy = p.Func()
+	return transport
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// remoteScheme is the URL scheme of remote dirs, backed up over SSH.
+const remoteScheme = "ssh://"
+
+// SSHConfig configures how remote dirs, given as ssh://[user@]host[:port]/path, are fetched. The ssh client of the
+// host is used, so its config, keys, agent and known hosts apply.
+type SSHConfig struct {
+	// Command is the ssh client, "ssh" by default.
+	Command string `mapstructure:"command" yaml:"command"`
+
+	// Options are extra arguments of the ssh client, such as ["-i", "/etc/arclift/id_ed25519"].
+	Options []string `mapstructure:"options" yaml:"options"`
+
+	// TarFormat is the format of the tar streamed by the remote host, see TarFormatPAX.
+	TarFormat string `mapstructure:"tar-format" yaml:"tar-format"`
+}
+
+// Formats of the tar streamed by remote hosts.
+const (
+	// TarFormatPAX records files over 8GB, long paths and sub-second modification times. GNU tar and bsdtar
+	// support it.
+	TarFormatPAX = "pax"
+
+	// TarFormatGNU records files over 8GB and long paths, with modification times to the second.
+	TarFormatGNU = "gnu"
+
+	// TarFormatNative leaves the format to the remote tar, for implementations without --format such as BusyBox.
+	TarFormatNative = "native"
+)
+
+// IsRemote reports whether path is a remote dir backed up over SSH.
+func IsRemote(path string) bool {
+	return strings.HasPrefix(path, remoteScheme)
+}
+
+// RemoteDir is a parsed remote dir.
+type RemoteDir struct {
+	// Destination is the ssh destination, [user@]host.
+	Destination string
+
+	// Port is the ssh port, "" for the default.
+	Port string
+
+	// Path is the absolute path of the dir on the remote host.
+	Path string
+}
+
+// ParseRemote parses a remote dir, ssh://[user@]host[:port]/path.
+func ParseRemote(path string) (RemoteDir, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return RemoteDir{}, fmt.Errorf("invalid remote dir %q: %w", path, err)
+	}
+	if u.Hostname() == "" {
+		return RemoteDir{}, fmt.Errorf("invalid remote dir %q: missing host", path)
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		return RemoteDir{}, fmt.Errorf("invalid remote dir %q: missing path", path)
+	}
+	if _, ok := u.User.Password(); ok {
+		return RemoteDir{}, fmt.Errorf("invalid remote dir %q: passwords are not supported, use ssh keys", path)
+	}
+
+	dest := u.Hostname()
+	if u.User != nil {
+		dest = u.User.Username() + "@" + dest
+	}
+	return RemoteDir{Destination: dest, Port: u.Port(), Path: u.Path}, nil
+}
+
+func (s *SSHConfig) validate(paths []string) error {
+	if s.Command == "" {
+		s.Command = "ssh"
+	}
+	switch s.TarFormat {
+	case "":
+		s.TarFormat = TarFormatPAX
+	case TarFormatPAX, TarFormatGNU, TarFormatNative:
+	default:
+		return fmt.Errorf("unknown ssh tar-format %q, supported: %s, %s, %s", s.TarFormat, TarFormatPAX, TarFormatGNU, TarFormatNative)
+	}
+
+	var errs []error
+	for _, path := range paths {
+		if !IsRemote(path) {
+			continue
+		}
+		if _, err := ParseRemote(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
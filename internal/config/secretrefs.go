@@ -0,0 +1,175 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/hibare/arclift/internal/constants"
+)
+
+// Schemes of the config values resolved from AWS, see resolveSecretRefs.
+const (
+	secretsManagerScheme = "awssm://"
+	parameterStoreScheme = "ssm://"
+)
+
+// ErrSecretRef is returned when a config value references a secret that cannot be resolved.
+var ErrSecretRef = errors.New("invalid secret reference")
+
+// isSecretRef reports whether value references a secret of AWS Secrets Manager or SSM Parameter Store.
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretsManagerScheme) || strings.HasPrefix(value, parameterStoreScheme)
+}
+
+// secretRefs returns the config values that may reference a secret: the S3 keys, the webhooks, the Sentry DSN, the passwords
+// and the proxy URL.
+func (c *Config) secretRefs() []*string {
+	refs := []*string{
+		&c.S3.AccessKey,
+		&c.S3.SecretKey,
+		&c.Notifiers.Discord.Webhook,
+		&c.Notifiers.Sentry.DSN,
+		&c.Dashboard.Password,
+		&c.Storage.Rclone.Password,
+		&c.Proxy.URL,
+	}
+	addSources := func(sources []SourceConfig) {
+		for i := range sources {
+			s := &sources[i]
+			refs = append(refs, &s.Postgres.Password, &s.MySQL.Password, &s.Redis.Password)
+		}
+	}
+	addSources(c.Backup.Sources)
+	for i := range c.Backup.Jobs {
+		addSources(c.Backup.Jobs[i].Sources)
+	}
+	return refs
+}
+
+// resolveSecretRefs replaces the config values referencing a secret, awssm://<secret id>[#<json key>] or
+// ssm://<parameter name>, with the secret. The secrets are read with the credentials of the S3 storage, unless those
+// are secret references themselves, in which case the default AWS credential chain is used.
+func (c *Config) resolveSecretRefs(ctx context.Context) error {
+	var refs []*string
+	for _, ref := range c.secretRefs() {
+		if isSecretRef(*ref) {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	awsCfg, err := c.secretsAWSConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	var sm *secretsmanager.Client
+	var params *ssm.Client
+	for _, ref := range refs {
+		var value string
+		if id, ok := strings.CutPrefix(*ref, secretsManagerScheme); ok {
+			if sm == nil {
+				sm = secretsmanager.NewFromConfig(awsCfg)
+			}
+			value, err = getSecretValue(ctx, sm, id)
+		} else {
+			if params == nil {
+				params = ssm.NewFromConfig(awsCfg)
+			}
+			value, err = getParameter(ctx, params, strings.TrimPrefix(*ref, parameterStoreScheme))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", *ref, err)
+		}
+		*ref = value
+	}
+	return nil
+}
+
+// secretsAWSConfig returns the AWS config secrets are read with.
+func (c *Config) secretsAWSConfig(ctx context.Context) (aws.Config, error) {
+	var loadOpts []func(*awsConfig.LoadOptions) error
+	if c.S3.Region != "" {
+		loadOpts = append(loadOpts, awsConfig.WithRegion(c.S3.Region))
+	}
+	if c.S3.Profile != "" {
+		loadOpts = append(loadOpts, awsConfig.WithSharedConfigProfile(c.S3.Profile))
+	}
+	if c.S3.AccessKey != "" && c.S3.SecretKey != "" && !isSecretRef(c.S3.AccessKey) && !isSecretRef(c.S3.SecretKey) {
+		loadOpts = append(loadOpts, awsConfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(c.S3.AccessKey, c.S3.SecretKey, "")))
+	}
+
+	awsCfg, err := awsConfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	if wi := c.S3.WebIdentity; wi.RoleARN != "" {
+		sessionName := wi.SessionName
+		if sessionName == "" {
+			sessionName = constants.ProgramIdentifier
+		}
+		provider := stscreds.NewWebIdentityRoleProvider(sts.NewFromConfig(awsCfg), wi.RoleARN,
+			stscreds.IdentityTokenFile(wi.TokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = sessionName
+			})
+		awsCfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	return awsCfg, nil
+}
+
+// getSecretValue returns the secret id of Secrets Manager, or the value of its JSON key when id ends with #<key>.
+func getSecretValue(ctx context.Context, api *secretsmanager.Client, id string) (string, error) {
+	id, key, hasKey := strings.Cut(id, "#")
+	if id == "" {
+		return "", fmt.Errorf("%w: missing secret id", ErrSecretRef)
+	}
+
+	out, err := api.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	if err != nil {
+		return "", err
+	}
+	value := aws.ToString(out.SecretString)
+	if !hasKey {
+		return value, nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("%w: secret %s is not a JSON object", ErrSecretRef, id)
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("%w: secret %s has no key %s", ErrSecretRef, id, key)
+	}
+	if s, ok := field.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(field), nil
+}
+
+// getParameter returns the decrypted value of the parameter name of Parameter Store.
+func getParameter(ctx context.Context, api *ssm.Client, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("%w: missing parameter name", ErrSecretRef)
+	}
+
+	out, err := api.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(name), WithDecryption: aws.Bool(true)})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Parameter.Value), nil
+}
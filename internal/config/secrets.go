@@ -0,0 +1,138 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/hibare/arclift/internal/constants"
+	"gopkg.in/yaml.v3"
+)
+
+// encryptedTag marks the config values encrypted with EncryptValue, such as `secret-key: !encrypted ww0E...`.
+const encryptedTag = "!encrypted"
+
+// Environment variables holding the passphrase of encrypted config values: the passphrase itself, or the path of a
+// file containing it.
+var (
+	passphraseEnv     = strings.ToUpper(constants.ProgramIdentifier) + "_CONFIG_PASSPHRASE"
+	passphraseFileEnv = strings.ToUpper(constants.ProgramIdentifier) + "_CONFIG_PASSPHRASE_FILE"
+)
+
+// ErrNoPassphrase is returned when a config file holds encrypted values but no passphrase is set.
+var ErrNoPassphrase = errors.New("config holds encrypted values, set " + passphraseEnv + " or " + passphraseFileEnv)
+
+// configPassphrase returns the passphrase of encrypted config values, from the environment.
+func configPassphrase() ([]byte, error) {
+	if path := os.Getenv(passphraseFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config passphrase: %w", err)
+		}
+		return bytes.TrimRight(data, "\r\n"), nil
+	}
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	return nil, ErrNoPassphrase
+}
+
+// EncryptValue encrypts value with the config passphrase, for use as an !encrypted config value. The value is an
+// OpenPGP symmetrically encrypted message, base64 encoded, so it can also be produced with
+// `gpg --symmetric | base64 -w0`.
+func EncryptValue(value string) (string, error) {
+	passphrase, err := configPassphrase()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	w, err := openpgp.SymmetricallyEncrypt(&buf, passphrase, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(w, value); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decryptValue decrypts a value encrypted with EncryptValue.
+func decryptValue(value string, passphrase []byte) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+	if err != nil {
+		return "", err
+	}
+
+	tried := false
+	md, err := openpgp.ReadMessage(bytes.NewReader(data), nil, func([]openpgp.Key, bool) ([]byte, error) {
+		// The prompt is called again as long as the passphrase is wrong.
+		if tried {
+			return nil, errors.New("wrong passphrase")
+		}
+		tried = true
+		return passphrase, nil
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	plain, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// decryptConfig returns the config document data with its !encrypted values decrypted. Documents without encrypted
+// values are returned unchanged.
+func decryptConfig(data []byte) ([]byte, error) {
+	if !bytes.Contains(data, []byte(encryptedTag)) {
+		return data, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var passphrase []byte
+	var walk func(n *yaml.Node, path string) error
+	walk = func(n *yaml.Node, path string) error {
+		if n.Kind == yaml.ScalarNode && n.Tag == encryptedTag {
+			if passphrase == nil {
+				var err error
+				if passphrase, err = configPassphrase(); err != nil {
+					return err
+				}
+			}
+			plain, err := decryptValue(n.Value, passphrase)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt %s: %w", path, err)
+			}
+			n.Tag, n.Value, n.Style = "!!str", plain, 0
+			return nil
+		}
+		for i, child := range n.Content {
+			childPath := path
+			if n.Kind == yaml.MappingNode && i%2 == 1 {
+				childPath = strings.TrimPrefix(path+"."+n.Content[i-1].Value, ".")
+			}
+			if err := walk(child, childPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(&doc, ""); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(&doc)
+}
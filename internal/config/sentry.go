@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// SentryNotifierConfig is the configuration of the Sentry notifier, which reports failed backups, restores and
+// verifications, and panics, to Sentry or another service accepting Sentry events, such as GlitchTip.
+type SentryNotifierConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+
+	// DSN is the client key of the Sentry project, as https://<public key>@<host>/<project id>.
+	DSN string `mapstructure:"dsn" yaml:"dsn"`
+
+	// Environment tells apart the events of instances sharing a project, such as production and staging.
+	Environment string `mapstructure:"environment" yaml:"environment,omitempty"`
+}
+
+// Endpoint returns the URL events are sent to and the public key authenticating them, parsed from the DSN.
+func (s *SentryNotifierConfig) Endpoint() (string, string, error) {
+	u, err := url.Parse(s.DSN)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid sentry dsn: %w", err)
+	}
+	project := path.Base(u.Path)
+	valid := (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.User != nil && u.User.Username() != "" &&
+		project != "/" && project != "."
+	if !valid {
+		return "", "", fmt.Errorf("invalid sentry dsn %q, expected https://<public key>@<host>/<project id>", u.Redacted())
+	}
+
+	endpoint := url.URL{Scheme: u.Scheme, Host: u.Host, Path: strings.TrimSuffix(path.Dir(u.Path), "/") + "/api/" + project + "/envelope/"}
+	return endpoint.String(), u.User.Username(), nil
+}
+
+func (s *SentryNotifierConfig) validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	if s.DSN == "" {
+		slog.Warn("Sentry notifier is enabled but dsn is not set. Disabling Sentry notifier")
+		s.Enabled = false
+		return nil
+	}
+	_, _, err := s.Endpoint()
+	return err
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Snapshot types of SnapshotConfig.
+const (
+	// SnapshotTypeLVM snapshots an LVM logical volume with lvcreate and mounts the snapshot read-only.
+	SnapshotTypeLVM = "lvm"
+
+	// SnapshotTypeZFS snapshots a ZFS dataset, which is read through its .zfs/snapshot directory.
+	SnapshotTypeZFS = "zfs"
+
+	// SnapshotTypeBtrfs takes a read-only snapshot of a Btrfs subvolume inside it.
+	SnapshotTypeBtrfs = "btrfs"
+)
+
+// DefaultSnapshotSize is the copy-on-write space of an LVM snapshot without a size: a tenth of the volume.
+const DefaultSnapshotSize = "10%ORIGIN"
+
+// snapshotTypes are the supported snapshot types, in the order they are listed in errors.
+var snapshotTypes = []string{SnapshotTypeLVM, SnapshotTypeZFS, SnapshotTypeBtrfs}
+
+// SnapshotConfig makes a dir source back up a snapshot of the volume holding it instead of the live files, so busy
+// filesystems are backed up in a consistent state without stopping the applications writing to them. The snapshot is
+// taken right before the source is backed up, after its pre hooks, and removed once it is.
+type SnapshotConfig struct {
+	Type string `mapstructure:"type" yaml:"type"`
+
+	// Volume is the volume holding the source: the logical volume as vg/lv for lvm, the dataset as pool/fs for zfs.
+	// Btrfs snapshots the subvolume at MountPoint.
+	Volume string `mapstructure:"volume" yaml:"volume,omitempty"`
+
+	// Size is the space an LVM snapshot keeps the blocks changed meanwhile in, as accepted by lvcreate: a size such
+	// as 1G, or a share of the volume such as 20%ORIGIN. The snapshot becomes unusable, failing the backup, once full.
+	Size string `mapstructure:"size" yaml:"size,omitempty"`
+
+	// MountPoint is where the volume is mounted, or the Btrfs subvolume, at or above the source path, which it
+	// defaults to.
+	MountPoint string `mapstructure:"mount-point" yaml:"mount-point,omitempty"`
+
+	// MountOptions are added to the options an LVM snapshot is mounted with, read-only, such as nouuid for XFS.
+	MountOptions []string `mapstructure:"mount-options" yaml:"mount-options,omitempty"`
+}
+
+// Root returns the path of the source at path relative to the root of the snapshotted volume.
+func (s *SnapshotConfig) Root(path string) string {
+	rel, err := filepath.Rel(s.MountPoint, path)
+	if err != nil {
+		return "."
+	}
+	return rel
+}
+
+func (s *SnapshotConfig) validate(path string) error {
+	if !slices.Contains(snapshotTypes, s.Type) {
+		return fmt.Errorf("unknown snapshot type %q, supported: %s", s.Type, strings.Join(snapshotTypes, ", "))
+	}
+	if s.Type != SnapshotTypeZFS && runtime.GOOS != "linux" {
+		return fmt.Errorf("%s snapshots are only supported on Linux", s.Type)
+	}
+	if err := s.validateVolume(); err != nil {
+		return err
+	}
+
+	if s.MountPoint == "" {
+		s.MountPoint = path
+	}
+	if !filepath.IsAbs(s.MountPoint) {
+		return errors.New("snapshot mount-point must be an absolute path")
+	}
+	if rel, err := filepath.Rel(s.MountPoint, path); err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("snapshot mount-point %s does not hold %s", s.MountPoint, path)
+	}
+	for _, opt := range s.MountOptions {
+		if opt == "" || strings.Contains(opt, ",") {
+			return fmt.Errorf("invalid snapshot mount option %q", opt)
+		}
+	}
+	return nil
+}
+
+// validateVolume checks the volume of s, and that the LVM settings are only set for lvm.
+func (s *SnapshotConfig) validateVolume() error {
+	if s.Type != SnapshotTypeLVM && (s.Size != "" || len(s.MountOptions) > 0) {
+		return fmt.Errorf("%s snapshots do not support size or mount-options", s.Type)
+	}
+
+	switch s.Type {
+	case SnapshotTypeLVM:
+		vg, lv, ok := strings.Cut(s.Volume, "/")
+		if !ok || vg == "" || lv == "" || strings.Contains(lv, "/") {
+			return fmt.Errorf("invalid snapshot volume %q, expected vg/lv", s.Volume)
+		}
+		if s.Size == "" {
+			s.Size = DefaultSnapshotSize
+		}
+	case SnapshotTypeZFS:
+		if s.Volume == "" || strings.HasPrefix(s.Volume, "/") || strings.Contains(s.Volume, "@") {
+			return fmt.Errorf("invalid snapshot volume %q, expected a dataset such as pool/fs", s.Volume)
+		}
+	case SnapshotTypeBtrfs:
+		if s.Volume != "" {
+			return errors.New("btrfs snapshots take the subvolume from mount-point, not volume")
+		}
+	}
+	return nil
+}
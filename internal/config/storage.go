@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/constants"
+)
+
+// Storage backends.
+const (
+	StorageBackendS3     = "s3"
+	StorageBackendExec   = "exec"
+	StorageBackendRclone = "rclone"
+)
+
+var storageBackends = []string{StorageBackendS3, StorageBackendExec, StorageBackendRclone}
+
+// ExecStorageConfig is the configuration of the exec storage backend: a program storing the backups, run once per
+// operation with the operation and its key appended to Args.
+type ExecStorageConfig struct {
+	Command string   `mapstructure:"command" yaml:"command"`
+	Args    []string `mapstructure:"args"    yaml:"args"`
+
+	// Timeout bounds every run of the program; zero does not.
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout"`
+}
+
+// RcloneStorageConfig is the configuration of the rclone storage backend: a remote of an rclone daemon, started with
+// `rclone rcd --rc-serve`.
+type RcloneStorageConfig struct {
+	URL      string `mapstructure:"url"      yaml:"url"`
+	Remote   string `mapstructure:"remote"   yaml:"remote"`
+	Username string `mapstructure:"username" yaml:"username"`
+	Password string `mapstructure:"password" yaml:"password"`
+
+	// Timeout bounds the wait for each response of the daemon once the request is sent, so a hung daemon fails the
+	// operation; the transfer of the response is not bounded, so large downloads are not cut short.
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout"`
+}
+
+func (r *RcloneStorageConfig) validate() error {
+	if r.URL == "" {
+		r.URL = constants.DefaultRcloneURL
+	}
+	switch {
+	case r.Timeout == 0:
+		r.Timeout = constants.DefaultRcloneTimeout
+	case r.Timeout < 0:
+		return errors.New("storage rclone timeout must not be negative")
+	}
+	if u, err := url.Parse(r.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid storage rclone url: %s", r.URL)
+	}
+	if !strings.Contains(r.Remote, ":") {
+		return fmt.Errorf("invalid storage rclone remote %q: use <remote>:[path], such as gdrive:backups", r.Remote)
+	}
+	if (r.Username == "") != (r.Password == "") {
+		return errors.New("storage rclone username and password must be set together")
+	}
+	return nil
+}
+
+// StorageConfig selects the storage backend. The S3 backend is configured under s3; s3.prefix and the key template
+// name the backups of every backend.
+type StorageConfig struct {
+	Backend string              `mapstructure:"backend" yaml:"backend"`
+	Exec    ExecStorageConfig   `mapstructure:"exec"    yaml:"exec"`
+	Rclone  RcloneStorageConfig `mapstructure:"rclone"  yaml:"rclone"`
+}
+
+func (s *StorageConfig) validate() error {
+	if s.Backend == "" {
+		s.Backend = StorageBackendS3
+	}
+	if !slices.Contains(storageBackends, s.Backend) {
+		return fmt.Errorf("unknown storage backend %q, supported: %s", s.Backend, strings.Join(storageBackends, ", "))
+	}
+
+	switch s.Backend {
+	case StorageBackendExec:
+		if s.Exec.Command == "" {
+			return errors.New("storage exec command is required")
+		}
+		if s.Exec.Timeout < 0 {
+			return errors.New("storage exec timeout must not be negative")
+		}
+	case StorageBackendRclone:
+		return s.Rclone.validate()
+	}
+	return nil
+}
+
+// validateStorageBackend rejects the features only the S3 backend supports.
+func (c *Config) validateStorageBackend() error {
+	if c.Storage.Backend == StorageBackendS3 {
+		return nil
+	}
+
+	switch {
+	case c.Backup.Cold.Enabled:
+		return fmt.Errorf("backup cold tier requires the %s storage backend", StorageBackendS3)
+	case c.Backup.Lease.Enabled:
+		return fmt.Errorf("backup lease requires the %s storage backend", StorageBackendS3)
+	case c.Backup.Versioning == VersioningInPlace:
+		return fmt.Errorf("backup versioning %s requires the %s storage backend", VersioningInPlace, StorageBackendS3)
+	case c.Verify.ObjectLock.Cron != "":
+		return fmt.Errorf("verify object-lock requires the %s storage backend", StorageBackendS3)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ValidateStreamName checks that name, the object name of a stream or command source, is a single key segment.
+func ValidateStreamName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid stream name %q, it must be a file name", name)
+	}
+	return nil
+}
+
+// validateCommand checks the settings of a command source, defaulting its name to the base of its path.
+func (s *SourceConfig) validateCommand() error {
+	if len(s.Command) == 0 {
+		return fmt.Errorf("command source %s is missing command", s.Path)
+	}
+	if s.Name == "" {
+		s.Name = filepath.Base(filepath.Clean(s.Path))
+	}
+	if err := ValidateStreamName(s.Name); err != nil {
+		return fmt.Errorf("%s: %w", s.Path, err)
+	}
+	return nil
+}
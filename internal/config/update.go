@@ -0,0 +1,49 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Release channels of UpdateCheckConfig.
+const (
+	// UpdateChannelStable only considers stable releases.
+	UpdateChannelStable = "stable"
+
+	// UpdateChannelPrerelease considers pre-releases too, so release candidates are announced.
+	UpdateChannelPrerelease = "prerelease"
+)
+
+// updateChannels are the supported release channels, in the order they are listed in errors.
+var updateChannels = []string{UpdateChannelStable, UpdateChannelPrerelease}
+
+// UpdateCheckConfig is the configuration of the check for new releases of arclift on GitHub, which runs when a
+// command loads the config and daily in the daemon. Available updates are logged and added to the Discord
+// notifications.
+type UpdateCheckConfig struct {
+	// Enabled turns the check on; disable it on hosts that cannot or must not reach GitHub.
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+
+	// Channel is the release channel updates are looked for on: stable or prerelease.
+	Channel string `mapstructure:"channel" yaml:"channel"`
+
+	// NotifyEvery is the minimum time between notifications carrying the update notice, so it is not repeated in
+	// every one of them; 0 adds it to every notification.
+	NotifyEvery time.Duration `mapstructure:"notify-every" yaml:"notify-every"`
+}
+
+func (u *UpdateCheckConfig) validate() error {
+	if u.Channel == "" {
+		u.Channel = UpdateChannelStable
+	}
+	if !slices.Contains(updateChannels, u.Channel) {
+		return fmt.Errorf("unknown update-check channel %q, supported: %s", u.Channel, strings.Join(updateChannels, ", "))
+	}
+	if u.NotifyEvery < 0 {
+		return errors.New("update-check notify-every must not be negative")
+	}
+	return nil
+}
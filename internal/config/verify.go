@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Object Lock modes of ObjectLockCheckConfig.
+const (
+	ObjectLockModeGovernance = "governance"
+	ObjectLockModeCompliance = "compliance"
+)
+
+// VerifyConfig is the configuration of the integrity scrub, which downloads stored backups again and compares them
+// with the checksums recorded when they were uploaded, so corruption is found before a restore needs them.
+type VerifyConfig struct {
+	// Cron is the schedule of the scrub in the daemon; empty disables it.
+	Cron string `mapstructure:"cron" yaml:"cron"`
+
+	// Sample is the number of backups, picked at random, each scrub verifies; 0 verifies every retained backup.
+	Sample int `mapstructure:"sample" yaml:"sample"`
+
+	ObjectLock ObjectLockCheckConfig `mapstructure:"object-lock" yaml:"object-lock"`
+}
+
+// ObjectLockCheckConfig is the configuration of the immutability check, which confirms that the objects of the
+// retained backups are still protected by S3 Object Lock and were not replaced since they were stored. It only reads
+// object metadata.
+type ObjectLockCheckConfig struct {
+	// Cron is the schedule of the check in the daemon; empty disables it.
+	Cron string `mapstructure:"cron" yaml:"cron"`
+
+	// Mode is the retention mode objects must be locked in, governance or compliance; empty accepts either.
+	Mode string `mapstructure:"mode" yaml:"mode"`
+
+	// MinRetention is how long after a backup was taken its objects must at least be locked; 0 only requires their
+	// retention not to have expired.
+	MinRetention time.Duration `mapstructure:"min-retention" yaml:"min-retention"`
+}
+
+func (v *VerifyConfig) validate() error {
+	if v.Sample < 0 {
+		return errors.New("verify sample must not be negative")
+	}
+	if v.Cron != "" {
+		if _, err := cron.ParseStandard(v.Cron); err != nil {
+			return fmt.Errorf("invalid verify cron %q: %w", v.Cron, err)
+		}
+	}
+	return v.ObjectLock.validate()
+}
+
+func (o *ObjectLockCheckConfig) validate() error {
+	switch o.Mode {
+	case "", ObjectLockModeGovernance, ObjectLockModeCompliance:
+	default:
+		return fmt.Errorf("unknown verify object-lock mode %q, supported: %s, %s", o.Mode, ObjectLockModeGovernance, ObjectLockModeCompliance)
+	}
+	if o.MinRetention < 0 {
+		return errors.New("verify object-lock min-retention must not be negative")
+	}
+	if o.Cron == "" {
+		return nil
+	}
+	if _, err := cron.ParseStandard(o.Cron); err != nil {
+		return fmt.Errorf("invalid verify object-lock cron %q: %w", o.Cron, err)
+	}
+	return nil
+}
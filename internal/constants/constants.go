@@ -1,12 +1,43 @@
 package constants
 
+import "time"
+
+const (
+	ProgramIdentifier          = "arclift"
+	ProgramPrettyIdentifier    = "Arclift"
+	DefaultDateTimeLayout      = "20060102150405"
+	DefaultRetentionCount      = 30
+	DefaultCron                = "0 0 * * *"
+	VersionCheckCron           = "0 0 * * *"
+	NotAvailable               = "N/A"
+	GithubOwner                = "hibare"
+	StateRootLinux             = "/var/lib"
+	DefaultDashboardListen     = "127.0.0.1:8080"
+	DefaultRcloneURL           = "http://localhost:5572"
+	ServiceDescription         = "Arclift Backup Service"
+	LaunchdLabel               = "com.hibare.arclift"
+	DefaultArchiveNameTemplate = "{dir}"
+	DefaultKeyTemplate         = "{prefix}/{hostname}/{timestamp}"
+	DefaultTimezone            = "UTC"
+	DefaultNotifierTimeout     = 30 * time.Second
+	DefaultLeaseTTL            = 10 * time.Minute
+	DefaultWatchInterval       = 5 * time.Minute
+	DefaultUploadConcurrency   = 4
+	DefaultRetryMaxAttempts    = 5
+	DefaultRetryMaxBackoff     = 20 * time.Second
+	DefaultS3ConnectTimeout    = 30 * time.Second
+	DefaultS3ReadTimeout       = 2 * time.Minute
+	DefaultDiskCheckMargin     = "1GiB"
+	DefaultTempCleanupMaxAge   = 24 * time.Hour
+	DefaultConfigFetchTimeout  = 30 * time.Second
+	DefaultKeyServerTimeout    = 15 * time.Second
+	DefaultUpdateNoticeEvery   = 24 * time.Hour
+	DefaultRcloneTimeout       = 5 * time.Minute
+)
+
+// Process exit codes.
 const (
-	ProgramIdentifier       = "arclift"
-	ProgramPrettyIdentifier = "Arclift"
-	DefaultDateTimeLayout   = "20060102150405"
-	DefaultRetentionCount   = 30
-	DefaultCron             = "0 0 * * *"
-	VersionCheckCron        = "0 0 * * *"
-	NotAvailable            = "N/A"
-	GithubOwner             = "hibare"
+	ExitCodeFailure        = 1
+	ExitCodeRestorePartial = 2
+	ExitCodeBackupPartial  = 2
 )
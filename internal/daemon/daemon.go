@@ -0,0 +1,138 @@
+// Package daemon runs the scheduler outside a service manager: it detaches it from the terminal and records its
+// process id in a pid file, which arclift stop uses to stop it.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/hibare/arclift/internal/lock"
+)
+
+// detachedEnv is set in the environment of the detached process, so it does not detach again.
+const detachedEnv = "ARCLIFT_DETACHED"
+
+const pollInterval = 500 * time.Millisecond
+
+var (
+	// ErrRunning is returned when the pid file belongs to a running process.
+	ErrRunning = errors.New("arclift is already running")
+
+	// ErrNotRunning is returned when no running process holds the pid file.
+	ErrNotRunning = errors.New("arclift is not running")
+
+	// ErrStopTimeout is returned when the process did not exit in time after being asked to stop.
+	ErrStopTimeout = errors.New("timed out waiting for arclift to stop")
+)
+
+// WritePIDFile records the process id in the pid file at path and locks it until release is called or the process
+// exits. A pid file left behind by a process that is no longer running is replaced.
+func WritePIDFile(path string) (func(), error) {
+	l, err := lock.TryAcquire(path)
+	if errors.Is(err, lock.ErrLocked) {
+		return nil, fmt.Errorf("%w with pid %s (pid file %s)", ErrRunning, lock.Holder(path), path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write pid file: %w", err)
+	}
+
+	return func() {
+		// Remove the file while it is still locked, so it never names another process.
+		_ = os.Remove(path)
+		_ = l.Release()
+	}, nil
+}
+
+// running reports whether a process holds the pid file at path. A stale pid file is removed.
+func running(path string) (bool, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	l, err := lock.TryAcquire(path)
+	if errors.Is(err, lock.ErrLocked) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_ = os.Remove(path)
+	return false, l.Release()
+}
+
+// Stop asks the process recorded in the pid file at path to stop, and waits up to timeout for it to exit.
+func Stop(ctx context.Context, path string, timeout time.Duration) (int, error) {
+	if ok, err := running(path); err != nil || !ok {
+		if err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: no process holds %s", ErrNotRunning, path)
+	}
+
+	pid, err := strconv.Atoi(lock.Holder(path))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read pid file %s: %w", path, err)
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return pid, err
+	}
+	if err := terminate(p); err != nil {
+		return pid, fmt.Errorf("failed to stop process %d: %w", pid, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return pid, ErrStopTimeout
+		case <-ticker.C:
+		}
+
+		if ok, err := running(path); err != nil || !ok {
+			return pid, err
+		}
+	}
+}
+
+// Detached reports whether this process was started by Detach.
+func Detached() bool {
+	return os.Getenv(detachedEnv) != ""
+}
+
+// Detach starts arclift again with args in the background, detached from the terminal, with its output appended to
+// logFile. It returns the process id of the detached process.
+func Detach(ctx context.Context, args []string, logFile string) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve executable: %w", err)
+	}
+
+	out, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	// The detached process outlives ctx.
+	cmd := exec.CommandContext(context.WithoutCancel(ctx), exe, args...) //nolint:gosec // re-executes this binary
+	cmd.Env = append(os.Environ(), detachedEnv+"=1")
+	cmd.Stdout, cmd.Stderr = out, out
+	cmd.SysProcAttr = detachAttr()
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start detached process: %w", err)
+	}
+
+	pid := cmd.Process.Pid
+	return pid, cmd.Process.Release()
+}
@@ -0,0 +1,17 @@
+//go:build !unix && !windows
+
+package daemon
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func detachAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func terminate(*os.Process) error {
+	return errors.New("stopping a detached process is not supported on this platform")
+}
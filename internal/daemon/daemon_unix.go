@@ -0,0 +1,18 @@
+//go:build unix
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// detachAttr starts the process in a new session, so it has no controlling terminal and survives its hangup.
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// terminate asks p to stop cleanly; running backups are interrupted and cleaned up.
+func terminate(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,19 @@
+package daemon
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// detachAttr starts the process without a console, in its own process group so console signals do not reach it.
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: windows.CREATE_NEW_PROCESS_GROUP | windows.DETACHED_PROCESS}
+}
+
+// terminate asks p to stop cleanly. Windows cannot signal another process without a shared console.
+func terminate(*os.Process) error {
+	return errors.New("stopping a detached process is not supported on Windows, run arclift as a service instead")
+}
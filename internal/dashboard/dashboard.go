@@ -0,0 +1,294 @@
+// Package dashboard provides an embedded web UI for inspecting and operating the backup daemon.
+package dashboard
+
+import (
+	"context"
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hibare/GoCommon/v2/pkg/datetime"
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/state"
+	"github.com/hibare/arclift/internal/units"
+	"github.com/hibare/arclift/internal/version"
+)
+
+const (
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 5 * time.Second
+
+	// maxRecentRuns is the number of journal entries shown on the dashboard.
+	maxRecentRuns = 20
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var (
+	// ErrOperationInProgress is returned when an action is triggered while another one is running.
+	ErrOperationInProgress = errors.New("another operation is in progress")
+
+	// ErrShuttingDown is returned when an action is triggered while the dashboard stops.
+	ErrShuttingDown = errors.New("dashboard is shutting down")
+)
+
+// DirView is the dashboard view of a single configured directory.
+type DirView struct {
+	Dir     string            `json:"dir"`
+	Last    *state.DirRecord  `json:"last,omitempty"`
+	History []state.DirRecord `json:"history"`
+}
+
+// StatusView is the data rendered by the dashboard.
+type StatusView struct {
+	Hostname    string            `json:"hostname"`
+	Version     string            `json:"version"`
+	Cron        string            `json:"cron"`
+	Bucket      string            `json:"bucket"`
+	Retention   int               `json:"retention"`
+	Backups     []string          `json:"backups"`
+	BackupsErr  string            `json:"backups_error,omitempty"`
+	Dirs        []DirView         `json:"dirs"`
+	Runs        []state.RunRecord `json:"runs"`
+	Busy        bool              `json:"busy"`
+	GeneratedAt time.Time         `json:"generated_at"`
+}
+
+// Server serves the dashboard.
+type Server struct {
+	mu         sync.RWMutex
+	cfg        *config.Config
+	bm         backup.BackupManagerIface
+	stateStore state.StoreIface
+	tmpl       *template.Template
+	busy       atomic.Bool
+
+	// ctx is the lifetime of the server, which the triggered operations run under, and ops tracks them so Run waits
+	// for them to stop.
+	ctx context.Context //nolint:containedctx // operations outlive the request that triggered them
+	ops sync.WaitGroup
+}
+
+// Reload switches the dashboard to a reloaded configuration and its backup manager. The listen address and the
+// credentials are only read when the dashboard starts.
+func (s *Server) Reload(cfg *config.Config, bm backup.BackupManagerIface) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg, s.bm = cfg, bm
+}
+
+func (s *Server) current() (*config.Config, backup.BackupManagerIface) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg, s.bm
+}
+
+func (s *Server) status(ctx context.Context) StatusView {
+	cfg, bm := s.current()
+	view := StatusView{
+		Hostname:    cfg.Backup.Hostname,
+		Version:     version.V.GetCurrentVersion(),
+		Cron:        cfg.Backup.Cron,
+		Bucket:      cfg.S3.Bucket,
+		Retention:   cfg.Backup.RetentionCount,
+		Busy:        s.busy.Load(),
+		GeneratedAt: time.Now(),
+	}
+
+	backups, err := bm.ListBackups(ctx)
+	if err != nil {
+		view.BackupsErr = err.Error()
+	}
+	view.Backups = backups
+
+	st, err := s.stateStore.Load(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to load state", "error", err)
+	}
+
+	for _, dir := range cfg.Backup.Paths() {
+		dv := DirView{Dir: dir, History: st.Dirs[dir]}
+		if len(dv.History) > 0 {
+			dv.Last = &dv.History[0]
+		}
+		view.Dirs = append(view.Dirs, dv)
+	}
+
+	view.Runs = st.Runs
+	if len(view.Runs) > maxRecentRuns {
+		view.Runs = view.Runs[:maxRecentRuns]
+	}
+
+	return view
+}
+
+// trigger runs fn in the background unless another operation is already running. The operation outlives the HTTP
+// request, but not the server: it is cancelled when the server stops, and Run waits for it.
+func (s *Server) trigger(name string, fn func(ctx context.Context) error) error {
+	if !s.busy.CompareAndSwap(false, true) {
+		return ErrOperationInProgress
+	}
+
+	// The operation is tracked under the lock, so it is either seen by Run before it waits, or refused.
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ctx := s.ctx
+	if ctx.Err() != nil {
+		s.busy.Store(false)
+		return ErrShuttingDown
+	}
+
+	s.ops.Go(func() {
+		defer s.busy.Store(false)
+
+		slog.InfoContext(ctx, "Dashboard triggered operation", "operation", name)
+		if err := fn(ctx); err != nil {
+			slog.ErrorContext(ctx, "Dashboard operation failed", "operation", name, "error", err)
+		}
+	})
+
+	return nil
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.tmpl.ExecuteTemplate(w, "index.html", s.status(r.Context())); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to render dashboard", "error", err)
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.status(r.Context())); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to encode status", "error", err)
+	}
+}
+
+func (s *Server) handleAction(name string, fn func(ctx context.Context) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := s.trigger(name, fn); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, ErrShuttingDown) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+func (s *Server) withAuth(next http.Handler) http.Handler {
+	cfg, _ := s.current()
+	username, password := cfg.Dashboard.Username, cfg.Dashboard.Password
+	if username == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(u), []byte(username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="arclift"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Handler returns the HTTP handler serving the dashboard.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/api/status", s.handleStatus)
+	mux.HandleFunc("/actions/backup", s.handleAction("backup", func(ctx context.Context) error {
+		_, bm := s.current()
+		return bm.Backup(ctx)
+	}))
+	mux.HandleFunc("/actions/purge", s.handleAction("purge", func(ctx context.Context) error {
+		_, bm := s.current()
+		return bm.PurgeOldBackups(ctx)
+	}))
+	// Browsers send the Origin or Sec-Fetch-Site headers, so actions posted by a form of another site, even with cached
+	// credentials, are refused. Clients such as arclift orchestrate send neither.
+	return s.withAuth(http.NewCrossOriginProtection().Handler(mux))
+}
+
+// Run serves the dashboard until ctx is cancelled. Operations triggered from the dashboard run under ctx; once it is
+// cancelled, Run returns after they stopped.
+func (s *Server) Run(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+	defer func() {
+		// ctx is cancelled, or the server never served, so no operation starts once the lock is released.
+		s.mu.Lock()
+		s.mu.Unlock() //nolint:staticcheck // empty critical section, see trigger
+		s.ops.Wait()
+	}()
+
+	cfg, _ := s.current()
+	listen := cfg.Dashboard.Listen
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx) //nolint:contextcheck // parent context is already cancelled
+	}()
+
+	slog.InfoContext(ctx, "Dashboard listening", "address", listen)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NewServer creates a new dashboard server.
+func NewServer(cfg *config.Config, bm backup.BackupManagerIface, stateStore state.StoreIface) (*Server, error) {
+	tmpl, err := template.New("").Funcs(template.FuncMap{
+		"humanize": datetime.HumanizeTime,
+		"duration": func(r interface{ Duration() time.Duration }) string { return r.Duration().Round(time.Second).String() },
+		"bytes":    units.FormatBytes,
+		"inc":      func(i int) int { return i + 1 },
+	}).ParseFS(templatesFS, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		cfg:        cfg,
+		bm:         bm,
+		stateStore: stateStore,
+		tmpl:       tmpl,
+		ctx:        context.Background(),
+	}, nil
+}
@@ -0,0 +1,109 @@
+package dashboard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hibare/arclift/internal/backup"
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeManager is a backup manager whose backups run fn.
+type fakeManager struct {
+	backup.BackupManagerIface
+	fn func(ctx context.Context) error
+}
+
+func (m *fakeManager) Backup(ctx context.Context) error {
+	return m.fn(ctx)
+}
+
+func newTestServer(t *testing.T, fn func(ctx context.Context) error) *Server {
+	t.Helper()
+
+	cfg := &config.Config{Dashboard: config.DashboardConfig{Enabled: true, Listen: "127.0.0.1:0"}}
+	s, err := NewServer(cfg, &fakeManager{fn: fn}, state.NewStore(t.TempDir()))
+	require.NoError(t, err)
+	return s
+}
+
+func TestServer_Actions_CrossOrigin(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{name: "api client", wantStatus: http.StatusSeeOther},
+		{name: "same origin", headers: map[string]string{"Sec-Fetch-Site": "same-origin"}, wantStatus: http.StatusSeeOther},
+		{name: "same origin header", headers: map[string]string{"Origin": "http://dashboard.local"}, wantStatus: http.StatusSeeOther},
+		{name: "cross site", headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, wantStatus: http.StatusForbidden},
+		{name: "cross origin header", headers: map[string]string{"Origin": "https://evil.example"}, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called atomic.Bool
+			s := newTestServer(t, func(context.Context) error {
+				called.Store(true)
+				return nil
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "http://dashboard.local/actions/backup", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			s.ops.Wait()
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantStatus == http.StatusSeeOther, called.Load())
+		})
+	}
+}
+
+func TestServer_Run_WaitsForOperations(t *testing.T) {
+	var stopped atomic.Bool
+	s := newTestServer(t, func(ctx context.Context) error {
+		<-ctx.Done()
+		// Cleaning up after the cancellation takes a while; Run must wait for it.
+		time.Sleep(50 * time.Millisecond)
+		stopped.Store(true)
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.ctx == ctx
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, s.trigger("backup", func(ctx context.Context) error {
+		_, bm := s.current()
+		return bm.Backup(ctx)
+	}))
+	require.ErrorIs(t, s.trigger("purge", func(context.Context) error { return nil }), ErrOperationInProgress)
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after its context was cancelled")
+	}
+	assert.True(t, stopped.Load(), "Run returned before the triggered backup stopped")
+
+	require.ErrorIs(t, s.trigger("backup", func(context.Context) error { return nil }), ErrShuttingDown)
+}
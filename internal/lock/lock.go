@@ -0,0 +1,87 @@
+// Package lock provides an advisory file lock serializing arclift operations on a host.
+package lock
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const pollInterval = time.Second
+
+// ErrLocked is returned by TryAcquire when another process holds the lock.
+var ErrLocked = errors.New("lock is held by another process")
+
+// Lock is an acquired file lock. The lock is released when the process exits, even if it crashes.
+type Lock struct {
+	f *os.File
+}
+
+// TryAcquire acquires the lock at path without waiting. It returns ErrLocked when another process holds it.
+func TryAcquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	// Record the holder for the processes waiting on the lock.
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Acquire acquires the lock at path, waiting until the process holding it releases it or ctx is cancelled.
+func Acquire(ctx context.Context, path string) (*Lock, error) {
+	l, err := TryAcquire(path)
+	if !errors.Is(err, ErrLocked) {
+		return l, err
+	}
+
+	slog.InfoContext(ctx, "Waiting for another arclift operation to finish", "lock", path, "pid", Holder(path))
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		if l, err = TryAcquire(path); !errors.Is(err, ErrLocked) {
+			return l, err
+		}
+	}
+}
+
+// Holder returns the process id recorded in the lock at path, or "" when unknown.
+func Holder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Release releases the lock.
+func (l *Lock) Release() error {
+	if err := unlockFile(l.f); err != nil {
+		_ = l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
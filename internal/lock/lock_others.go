@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package lock
+
+import "os"
+
+// File locks are not supported on this platform; operations are not serialized.
+func lockFile(*os.File) error { return nil }
+
+func unlockFile(*os.File) error { return nil }
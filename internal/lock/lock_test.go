@@ -0,0 +1,69 @@
+package lock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTryAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "arclift.lock")
+
+	l, err := TryAcquire(path)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(os.Getpid()), Holder(path))
+
+	_, err = TryAcquire(path)
+	require.ErrorIs(t, err, ErrLocked)
+
+	require.NoError(t, l.Release())
+	l, err = TryAcquire(path)
+	require.NoError(t, err)
+	require.NoError(t, l.Release())
+}
+
+func TestAcquire_WaitsForRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arclift.lock")
+	held, err := TryAcquire(path)
+	require.NoError(t, err)
+
+	acquired := make(chan *Lock, 1)
+	go func() {
+		l, aErr := Acquire(t.Context(), path)
+		assert.NoError(t, aErr)
+		acquired <- l
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired a lock that is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, held.Release())
+	select {
+	case l := <-acquired:
+		require.NotNil(t, l)
+		require.NoError(t, l.Release())
+	case <-time.After(5 * pollInterval):
+		t.Fatal("lock not acquired after it was released")
+	}
+}
+
+func TestAcquire_Cancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arclift.lock")
+	held, err := TryAcquire(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = held.Release() })
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, err = Acquire(ctx, path)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
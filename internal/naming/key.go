@@ -0,0 +1,287 @@
+package naming
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// KeyPlaceholders are the placeholders supported by key templates.
+var KeyPlaceholders = []string{"prefix", "hostname", "job", "timestamp", "date", "time"}
+
+// PartitionPlaceholders are the placeholders partitioning backups by date into directories, in s3.prefix or the
+// directories of key templates. They are replaced by the zero-padded year, month and day of the backup time.
+var PartitionPlaceholders = []string{"year", "month", "day"}
+
+// Time layouts of the {date} and {time} key placeholders. {timestamp} uses the configured date-time layout.
+const (
+	keyDateLayout = "2006-01-02"
+	keyTimeLayout = "150405"
+)
+
+// partitionLayouts are the time layouts of the partition placeholders.
+var partitionLayouts = Vars{"year": "2006", "month": "01", "day": "02"}
+
+var (
+	// ErrInvalidKeyTemplate is returned when a key template cannot identify backups.
+	ErrInvalidKeyTemplate = errors.New("invalid key template")
+
+	// ErrInvalidTimeLayout is returned when a date-time layout cannot name backups.
+	ErrInvalidTimeLayout = errors.New("invalid date-time layout")
+
+	// keyRoundTrip is formatted and parsed back to check that a key template identifies backups to the second.
+	keyRoundTrip = time.Date(2001, time.February, 3, 16, 5, 6, 0, time.UTC)
+
+	// sortProbes are times in chronological order around the boundaries where an unpadded or badly ordered layout
+	// stops sorting chronologically, such as day 9 to 10 or 11 AM to 1 PM.
+	sortProbes = probeTimes([][]int{
+		{2001, 2009, 2010},
+		{1, 2, 9, 10, 12},
+		{1, 9, 10, 28},
+		{0, 1, 9, 10, 11, 12, 13, 23},
+		{0, 9, 10, 59},
+		{0, 9, 10, 59},
+	})
+)
+
+// probeTimes returns every combination of the years, months, days, hours, minutes and seconds of fields, in
+// chronological order.
+func probeTimes(fields [][]int) []time.Time {
+	combinations := [][]int{nil}
+	for _, values := range fields {
+		var next [][]int
+		for _, c := range combinations {
+			for _, v := range values {
+				next = append(next, append(slices.Clip(c), v))
+			}
+		}
+		combinations = next
+	}
+
+	times := make([]time.Time, 0, len(combinations))
+	for _, c := range combinations {
+		times = append(times, time.Date(c[0], time.Month(c[1]), c[2], c[3], c[4], c[5], 0, time.UTC))
+	}
+	return times
+}
+
+// sortsChronologically reports whether times formatted with layout sort in the order of the times, as listing and
+// retention expect of backup names.
+func sortsChronologically(layout string) bool {
+	prev := ""
+	for _, t := range sortProbes {
+		name := t.Format(layout)
+		if name <= prev {
+			return false
+		}
+		prev = name
+	}
+	return true
+}
+
+// ValidateTimeLayout checks that the time layout of backup timestamps identifies a backup time to the second, and
+// that timestamps sort in the order of the times they were taken at.
+func ValidateTimeLayout(layout string) error {
+	if t, err := time.Parse(layout, keyRoundTrip.Format(layout)); err != nil || !t.Equal(keyRoundTrip) {
+		return fmt.Errorf("%w %q: timestamps must identify the backup time to the second", ErrInvalidTimeLayout, layout)
+	}
+	if !sortsChronologically(layout) {
+		return fmt.Errorf("%w %q: timestamps must sort chronologically, use zero-padded numbers from the year down to "+
+			"the second, such as 20060102150405", ErrInvalidTimeLayout, layout)
+	}
+	return nil
+}
+
+// KeyLayout is a parsed key template: the directory holding the backups and the pattern of the backup names in it.
+// A backup name is the key segment identifying a backup, such as "20240131000000". With date partitions, the key of
+// a backup, relative to Dir, also holds the directories below the first partition, such as "2024/01/20240131000000".
+type KeyLayout struct {
+	// Dir is the directory holding the backups, ending with a slash, or "" for the bucket root. With date partitions,
+	// it ends before the first of them.
+	Dir string
+
+	// partition is the template of the directories between Dir and the backup name, ending with a slash, or "".
+	partition string
+
+	head   string
+	tail   string
+	layout string
+	loc    *time.Location
+
+	// shared is set when the backup names hold other placeholders than the time ones, such as {hostname}.
+	shared bool
+}
+
+func isTimePlaceholder(name string) bool {
+	return name == "timestamp" || name == "date" || name == "time"
+}
+
+// NewKeyLayout parses the key template tmpl. The time placeholders must all be in the last segment, which names the
+// backups; the other placeholders are replaced by vars. The directories, including the prefix of vars, may be
+// partitioned by date with the partition placeholders. timestampLayout is the time layout of {timestamp}, and backup
+// times are formatted in loc.
+func NewKeyLayout(tmpl, timestampLayout string, loc *time.Location, vars Vars) (KeyLayout, error) {
+	if err := Validate(tmpl, slices.Concat(KeyPlaceholders, PartitionPlaceholders)); err != nil {
+		return KeyLayout{}, err
+	}
+
+	segments := strings.Split(tmpl, "/")
+	name := segments[len(segments)-1]
+	if slices.ContainsFunc(Placeholders(name), isPartitionPlaceholder) {
+		return KeyLayout{}, fmt.Errorf("%w %q: {year}, {month} and {day} partition directories, not backup names", ErrInvalidKeyTemplate, tmpl)
+	}
+
+	var dir, partition []string
+	for _, segment := range segments[:len(segments)-1] {
+		if slices.ContainsFunc(Placeholders(segment), isTimePlaceholder) {
+			return KeyLayout{}, fmt.Errorf("%w %q: {timestamp}, {date} and {time} must be in the last segment", ErrInvalidKeyTemplate, tmpl)
+		}
+		for _, part := range strings.Split(Render(segment, vars), "/") {
+			if err := Validate(part, PartitionPlaceholders); err != nil {
+				return KeyLayout{}, fmt.Errorf("%w %q: %w", ErrInvalidKeyTemplate, tmpl, err)
+			}
+			switch {
+			case part == "":
+			case len(partition) > 0 || slices.ContainsFunc(Placeholders(part), isPartitionPlaceholder):
+				partition = append(partition, part)
+			default:
+				dir = append(dir, part)
+			}
+		}
+	}
+	if err := validatePartition(partition); err != nil {
+		return KeyLayout{}, fmt.Errorf("%w %q: %w", ErrInvalidKeyTemplate, tmpl, err)
+	}
+
+	matches := placeholderRe.FindAllStringSubmatchIndex(name, -1)
+	timeMatches := slices.DeleteFunc(slices.Clone(matches), func(m []int) bool { return !isTimePlaceholder(name[m[2]:m[3]]) })
+	if len(timeMatches) == 0 {
+		return KeyLayout{}, fmt.Errorf("%w %q: the last segment must contain {timestamp}, or {date} and {time}", ErrInvalidKeyTemplate, tmpl)
+	}
+	first, last := timeMatches[0][0], timeMatches[len(timeMatches)-1][1]
+	if slices.ContainsFunc(Placeholders(name[first:last]), func(p string) bool { return !isTimePlaceholder(p) }) {
+		return KeyLayout{}, fmt.Errorf("%w %q: only separators may appear between {timestamp}, {date} and {time}", ErrInvalidKeyTemplate, tmpl)
+	}
+
+	k := KeyLayout{
+		head:   Render(name[:first], vars),
+		tail:   Render(name[last:], vars),
+		layout: Render(name[first:last], Vars{"timestamp": timestampLayout, "date": keyDateLayout, "time": keyTimeLayout}),
+		loc:    loc,
+		shared: slices.ContainsFunc(Placeholders(name), func(p string) bool { return !isTimePlaceholder(p) }),
+	}
+	if len(dir) > 0 {
+		k.Dir = strings.Join(dir, "/") + "/"
+	}
+	if len(partition) > 0 {
+		k.partition = strings.Join(partition, "/") + "/"
+	}
+
+	if strings.Contains(k.head+k.tail+k.layout, "/") {
+		return KeyLayout{}, fmt.Errorf("%w %q: the backup name must not contain '/'", ErrInvalidKeyTemplate, tmpl)
+	}
+	if t, ok := k.Parse(k.Format(keyRoundTrip)); !ok || !t.Equal(keyRoundTrip) {
+		return KeyLayout{}, fmt.Errorf("%w %q: backup names must identify the backup time to the second", ErrInvalidKeyTemplate, tmpl)
+	}
+	return k, nil
+}
+
+func isPartitionPlaceholder(name string) bool {
+	return slices.Contains(PartitionPlaceholders, name)
+}
+
+// validatePartition checks that the partition directories use {year}, {month} and {day} once each at most, from the
+// year down, so the directories of a year hold its months and those of a month its days.
+func validatePartition(partition []string) error {
+	var used []string
+	for _, part := range partition {
+		used = append(used, slices.DeleteFunc(Placeholders(part), func(p string) bool { return !isPartitionPlaceholder(p) })...)
+	}
+	if len(used) > len(PartitionPlaceholders) || !slices.Equal(used, PartitionPlaceholders[:len(used)]) {
+		return errors.New("date partitions must be {year}, {year}/{month} or {year}/{month}/{day}, in that order")
+	}
+	return nil
+}
+
+// Partitioned reports whether backups are partitioned by date, so their keys span several segments below Dir.
+func (k KeyLayout) Partitioned() bool {
+	return k.partition != ""
+}
+
+// Shared reports whether the backup names hold the hostname or other values of the template, as when the backups of
+// several hosts share Dir and are told apart by their names.
+func (k KeyLayout) Shared() bool {
+	return k.shared
+}
+
+// Cut splits rel, a key relative to Dir, into the key of the backup it is under and the rest, like strings.Cut at
+// the slash following the backup key. Without date partitions, the backup key is the first segment of rel.
+func (k KeyLayout) Cut(rel string) (key, rest string, found bool) {
+	end := -1
+	for range strings.Count(k.partition, "/") + 1 {
+		i := strings.Index(rel[end+1:], "/")
+		if i < 0 {
+			return rel, "", false
+		}
+		end += i + 1
+	}
+	return rel[:end], rel[end+1:], true
+}
+
+// SortsChronologically reports whether backup names sort in the order the backups were taken, as listing across jobs
+// expects. Layouts of existing backups, such as legacy layouts, need not.
+func (k KeyLayout) SortsChronologically() bool {
+	return sortsChronologically(k.layout)
+}
+
+// Format returns the key, relative to Dir, of the backup taken at t: its name below its date partition, if any.
+func (k KeyLayout) Format(t time.Time) string {
+	return k.formatPartition(t) + k.head + t.In(k.loc).Format(k.layout) + k.tail
+}
+
+// formatPartition returns the date partition of the backups taken at t.
+func (k KeyLayout) formatPartition(t time.Time) string {
+	if k.partition == "" {
+		return ""
+	}
+	t = t.In(k.loc)
+	return placeholderRe.ReplaceAllStringFunc(k.partition, func(m string) string {
+		if layout, ok := partitionLayouts[m[1:len(m)-1]]; ok {
+			return t.Format(layout)
+		}
+		return m
+	})
+}
+
+// Parse returns the time of the backup at key, relative to Dir. ok is false when key does not follow the layout, such
+// as the backups of another host sharing Dir, or those filed under the partition of another date.
+func (k KeyLayout) Parse(key string) (time.Time, bool) {
+	partition := ""
+	if k.partition != "" {
+		i := strings.LastIndex(key, "/")
+		if i < 0 {
+			return time.Time{}, false
+		}
+		partition, key = key[:i+1], key[i+1:]
+	}
+	t, ok := k.parseName(key)
+	if !ok || partition != k.formatPartition(t) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// parseName returns the time of the backup named name.
+func (k KeyLayout) parseName(name string) (time.Time, bool) {
+	rest, ok := strings.CutPrefix(name, k.head)
+	if !ok {
+		return time.Time{}, false
+	}
+	if rest, ok = strings.CutSuffix(rest, k.tail); !ok {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(k.layout, rest, k.loc)
+	return t, err == nil
+}
@@ -0,0 +1,61 @@
+// Package naming renders the `{placeholder}` templates used for archive names and keys.
+package naming
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownPlaceholder is returned when a template references an unsupported placeholder.
+var ErrUnknownPlaceholder = errors.New("unknown placeholder")
+
+var placeholderRe = regexp.MustCompile(`\{([a-z0-9_-]+)\}`)
+
+// Vars maps placeholder names (without braces) to their values.
+type Vars map[string]string
+
+// Placeholders returns the placeholder names referenced by tmpl, in order of appearance.
+func Placeholders(tmpl string) []string {
+	var names []string
+	for _, m := range placeholderRe.FindAllStringSubmatch(tmpl, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// Validate checks that tmpl only references allowed placeholders.
+func Validate(tmpl string, allowed []string) error {
+	for _, name := range Placeholders(tmpl) {
+		if !slices.Contains(allowed, name) {
+			return fmt.Errorf("%w {%s}, supported: {%s}", ErrUnknownPlaceholder, name, strings.Join(allowed, "}, {"))
+		}
+	}
+	return nil
+}
+
+// Render replaces every known placeholder in tmpl with its value. Unknown placeholders are left untouched.
+func Render(tmpl string, vars Vars) string {
+	return placeholderRe.ReplaceAllStringFunc(tmpl, func(m string) string {
+		if v, ok := vars[m[1:len(m)-1]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// SanitizePath turns a filesystem path into a single name segment, e.g. "/var/lib/pg" becomes "var-lib-pg".
+func SanitizePath(p string) string {
+	p = filepath.ToSlash(filepath.Clean(p))
+	p = strings.TrimPrefix(p, filepath.VolumeName(p))
+	p = strings.Trim(p, "/")
+	return SanitizeSegment(p)
+}
+
+// SanitizeSegment replaces characters that would split or break an object name.
+func SanitizeSegment(s string) string {
+	return strings.NewReplacer("/", "-", "\\", "-", ":", "-").Replace(s)
+}
@@ -5,33 +5,73 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
+	commonHTTP "github.com/hibare/GoCommon/v2/pkg/http"
 	"github.com/hibare/GoCommon/v2/pkg/notifiers/discord"
 	"github.com/hibare/arclift/internal/config"
 	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/units"
 	"github.com/hibare/arclift/internal/version"
+	"github.com/hibare/arclift/internal/walk"
 )
 
 const (
 	successColor         = 1498748
 	failureColor         = 14554702
 	deletionFailureColor = 14590998
+	quotaExceededColor   = 16098851
+	interruptedColor     = 10070709
+	unchangedColor       = 9807270
+	corruptionColor      = 10038562
 )
 
+// maxListedObjects is the number of failed objects or files listed in a notification, which has a limited size.
+const maxListedObjects = 10
+
+// listErrors lists the first maxListedObjects errors, by name, one per line.
+func listErrors(errs map[string]string) string {
+	names := slices.Sorted(maps.Keys(errs))
+	lines := make([]string, 0, min(len(names), maxListedObjects)+1)
+	for _, name := range names[:min(len(names), maxListedObjects)] {
+		lines = append(lines, fmt.Sprintf("`%s`: %s", name, errs[name]))
+	}
+	if len(names) > maxListedObjects {
+		lines = append(lines, fmt.Sprintf("and %d more", len(names)-maxListedObjects))
+	}
+	return strings.Join(lines, "\n")
+}
+
+var restoreColors = map[string]int{
+	"complete": successColor,
+	"partial":  quotaExceededColor,
+	"failed":   failureColor,
+}
+
 // Discord sends notifications to a Discord channel via webhook.
 type Discord struct {
 	Cfg    *config.Config
 	client discord.ClientIface
 }
 
+// Name returns the name of the notifier.
+func (d *Discord) Name() string {
+	return "discord"
+}
+
 // Enabled checks if the Discord notifier is enabled in the configuration.
 func (d *Discord) Enabled() bool {
 	return d.Cfg.Notifiers.Discord.Enabled
 }
 
 // NotifyBackupSuccess sends a success notification to the Discord channel.
-func (d *Discord) NotifyBackupSuccess(ctx context.Context, directory string, totalDirs, totalFiles, successFiles int, key string) error {
+func (d *Discord) NotifyBackupSuccess(
+	ctx context.Context, directory string, totalDirs, totalFiles, successFiles int, key string, skipped map[string]int,
+) error {
 	message := discord.Message{
 		Embeds: []discord.Embed{
 			{
@@ -62,12 +102,43 @@ func (d *Discord) NotifyBackupSuccess(ctx context.Context, directory string, tot
 		Content:    fmt.Sprintf("**Backup Successful** - *%s*", d.Cfg.Backup.Hostname),
 	}
 
-	if version.V.IsUpdateAvailable() {
-		if err := message.AddFooter(version.V.GetUpdateNotification()); err != nil {
-			slog.Error("error adding footer to message", "error", err)
-		}
+	if len(skipped) > 0 {
+		message.Embeds[0].Fields = append(message.Embeds[0].Fields, discord.EmbedField{
+			Name:   "Skipped",
+			Value:  walk.Report(skipped).String(),
+			Inline: false,
+		})
 	}
 
+	d.addUpdateNotice(&message)
+
+	return d.client.Send(ctx, &message)
+}
+
+// NotifyBackupUnchanged sends a notification that an unchanged directory was skipped to the Discord channel.
+func (d *Discord) NotifyBackupUnchanged(ctx context.Context, directory, key string) error {
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Title:       "Directory",
+				Description: directory,
+				Color:       unchangedColor,
+				Fields: []discord.EmbedField{
+					{
+						Name:   "Last Backup",
+						Value:  key,
+						Inline: false,
+					},
+				},
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramPrettyIdentifier,
+		Content:    fmt.Sprintf("**No Changes, Backup Skipped** - *%s*", d.Cfg.Backup.Hostname),
+	}
+
+	d.addUpdateNotice(&message)
+
 	return d.client.Send(ctx, &message)
 }
 
@@ -103,11 +174,7 @@ func (d *Discord) NotifyBackupFailure(ctx context.Context, directory string, tot
 		Content:    fmt.Sprintf("**Backup Failed** - *%s*", d.Cfg.Backup.Hostname),
 	}
 
-	if version.V.IsUpdateAvailable() {
-		if err := message.AddFooter(version.V.GetUpdateNotification()); err != nil {
-			slog.Error("error adding footer to message", "error", err)
-		}
-	}
+	d.addUpdateNotice(&message)
 
 	return d.client.Send(ctx, &message)
 }
@@ -134,19 +201,272 @@ func (d *Discord) NotifyBackupDeleteFailure(ctx context.Context, key string, err
 		Content:    fmt.Sprintf("**Backup Deletion Failed** - *%s*", d.Cfg.Backup.Hostname),
 	}
 
-	if version.V.IsUpdateAvailable() {
-		if err := message.AddFooter(version.V.GetUpdateNotification()); err != nil {
-			slog.Error("error adding footer to message", "error", err)
-		}
+	d.addUpdateNotice(&message)
+
+	return d.client.Send(ctx, &message)
+}
+
+// NotifyBackupInterrupted sends a backup interruption notification to the Discord channel.
+func (d *Discord) NotifyBackupInterrupted(ctx context.Context, job string, dirs, failedDirs int) error {
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Title:       "Job",
+				Description: job,
+				Color:       interruptedColor,
+				Fields: []discord.EmbedField{
+					{
+						Name:   "Dirs Processed",
+						Value:  strconv.Itoa(dirs),
+						Inline: true,
+					},
+					{
+						Name:   "Dirs Failed",
+						Value:  strconv.Itoa(failedDirs),
+						Inline: true,
+					},
+				},
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramPrettyIdentifier,
+		Content:    fmt.Sprintf("**Backup Interrupted** - *%s*", d.Cfg.Backup.Hostname),
+	}
+
+	d.addUpdateNotice(&message)
+
+	return d.client.Send(ctx, &message)
+}
+
+// NotifyQuotaExceeded sends a stored size quota notification to the Discord channel.
+func (d *Discord) NotifyQuotaExceeded(ctx context.Context, scope string, used, limit int64, action string) error {
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Title:       "Scope",
+				Description: scope,
+				Color:       quotaExceededColor,
+				Fields: []discord.EmbedField{
+					{
+						Name:   "Used",
+						Value:  units.FormatBytes(used),
+						Inline: true,
+					},
+					{
+						Name:   "Limit",
+						Value:  units.FormatBytes(limit),
+						Inline: true,
+					},
+					{
+						Name:   "Action",
+						Value:  action,
+						Inline: true,
+					},
+				},
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramPrettyIdentifier,
+		Content:    fmt.Sprintf("**Backup Quota Exceeded** - *%s*", d.Cfg.Backup.Hostname),
+	}
+
+	d.addUpdateNotice(&message)
+
+	return d.client.Send(ctx, &message)
+}
+
+// NotifyRestore sends a restore summary notification to the Discord channel.
+func (d *Discord) NotifyRestore(ctx context.Context, key, target, outcome string, restored, skipped, failed, mismatches int) error {
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Title:       "Backup",
+				Description: key,
+				Color:       restoreColors[outcome],
+				Fields: []discord.EmbedField{
+					{
+						Name:   "Target",
+						Value:  target,
+						Inline: false,
+					},
+					{
+						Name:   "Restored",
+						Value:  strconv.Itoa(restored),
+						Inline: true,
+					},
+					{
+						Name:   "Skipped",
+						Value:  strconv.Itoa(skipped),
+						Inline: true,
+					},
+					{
+						Name:   "Failed",
+						Value:  strconv.Itoa(failed),
+						Inline: true,
+					},
+					{
+						Name:   "Checksum Mismatches",
+						Value:  strconv.Itoa(mismatches),
+						Inline: true,
+					},
+				},
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramPrettyIdentifier,
+		Content:    fmt.Sprintf("**Restore Finished (%s)** - *%s*", outcome, d.Cfg.Backup.Hostname),
+	}
+
+	d.addUpdateNotice(&message)
+
+	return d.client.Send(ctx, &message)
+}
+
+// NotifyCorruption sends a notification that a verified backup is corrupted to the Discord channel.
+func (d *Discord) NotifyCorruption(ctx context.Context, key string, objects int, corrupted map[string]string) error {
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Title:       "Backup",
+				Description: key,
+				Color:       corruptionColor,
+				Fields: []discord.EmbedField{
+					{
+						Name:   "Verified Objects",
+						Value:  strconv.Itoa(objects),
+						Inline: true,
+					},
+					{
+						Name:   "Corrupted Objects",
+						Value:  strconv.Itoa(len(corrupted)),
+						Inline: true,
+					},
+					{
+						Name:   "Objects",
+						Value:  listErrors(corrupted),
+						Inline: false,
+					},
+				},
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramPrettyIdentifier,
+		Content:    fmt.Sprintf("**Backup Corrupted** - *%s*", d.Cfg.Backup.Hostname),
+	}
+
+	d.addUpdateNotice(&message)
+
+	return d.client.Send(ctx, &message)
+}
+
+// NotifyImmutability sends a notification that a backup is not protected by Object Lock as expected to the Discord
+// channel.
+func (d *Discord) NotifyImmutability(ctx context.Context, key string, objects int, problems map[string]string) error {
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Title:       "Backup",
+				Description: key,
+				Color:       corruptionColor,
+				Fields: []discord.EmbedField{
+					{
+						Name:   "Checked Objects",
+						Value:  strconv.Itoa(objects),
+						Inline: true,
+					},
+					{
+						Name:   "Unprotected Objects",
+						Value:  strconv.Itoa(len(problems)),
+						Inline: true,
+					},
+					{
+						Name:   "Objects",
+						Value:  listErrors(problems),
+						Inline: false,
+					},
+				},
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramPrettyIdentifier,
+		Content:    fmt.Sprintf("**Backup Not Immutable** - *%s*", d.Cfg.Backup.Hostname),
+	}
+
+	d.addUpdateNotice(&message)
+
+	return d.client.Send(ctx, &message)
+}
+
+// NotifyRestoreTest sends the result of a restore test to the Discord channel.
+func (d *Discord) NotifyRestoreTest(ctx context.Context, key string, expected, restored, verified int, problems map[string]string) error {
+	color, content := successColor, "**Restore Test Passed**"
+	if len(problems) > 0 {
+		color, content = failureColor, "**Restore Test Failed**"
+	}
+
+	fields := []discord.EmbedField{
+		{
+			Name:   "Expected Files",
+			Value:  strconv.Itoa(expected),
+			Inline: true,
+		},
+		{
+			Name:   "Restored Files",
+			Value:  strconv.Itoa(restored),
+			Inline: true,
+		},
+		{
+			Name:   "Verified Files",
+			Value:  strconv.Itoa(verified),
+			Inline: true,
+		},
+	}
+	if len(problems) > 0 {
+		fields = append(fields, discord.EmbedField{
+			Name:   "Problems",
+			Value:  listErrors(problems),
+			Inline: false,
+		})
+	}
+
+	message := discord.Message{
+		Embeds: []discord.Embed{
+			{
+				Title:       "Backup",
+				Description: key,
+				Color:       color,
+				Fields:      fields,
+			},
+		},
+		Components: []discord.Component{},
+		Username:   constants.ProgramPrettyIdentifier,
+		Content:    fmt.Sprintf("%s - *%s*", content, d.Cfg.Backup.Hostname),
 	}
 
+	d.addUpdateNotice(&message)
+
 	return d.client.Send(ctx, &message)
 }
 
+// addUpdateNotice adds the update notice to the footer of message, when one is due, see version.UpdateNotice.
+func (d *Discord) addUpdateNotice(message *discord.Message) {
+	notice := version.UpdateNotice(d.Cfg)
+	if notice == "" {
+		return
+	}
+	if err := message.AddFooter(notice); err != nil {
+		slog.Error("error adding footer to message", "error", err)
+	}
+}
+
 // NewDiscordNotifier creates a new Discord notifier instance.
 func NewDiscordNotifier(cfg *config.Config) (*Discord, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:errcheck // the default transport is an *http.Transport
+	transport.Proxy = cfg.Proxy.Func()
+
 	client, err := discord.NewClient(discord.Options{
 		WebhookURL: cfg.Notifiers.Discord.Webhook,
+		HTTPClient: &http.Client{Timeout: commonHTTP.DefaultHTTPClientTimeout, Transport: transport},
 	})
 	if err != nil {
 		return nil, err
@@ -4,11 +4,13 @@ package notifiers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/hibare/arclift/internal/config"
-	"github.com/hibare/arclift/internal/notifiers/discord"
 )
 
 var (
@@ -22,18 +24,33 @@ var (
 // NotifiersIface defines the interface that all notifier implementations must satisfy.
 // revive:disable-next-line exported
 type NotifiersIface interface {
+	Name() string
 	Enabled() bool
-	NotifyBackupSuccess(ctx context.Context, directory string, totalDirs, totalFiles, successFiles int, key string) error
+	NotifyBackupSuccess(ctx context.Context, directory string, totalDirs, totalFiles, successFiles int, key string, skipped map[string]int) error
+	NotifyBackupUnchanged(ctx context.Context, directory, key string) error
 	NotifyBackupFailure(ctx context.Context, directory string, totalDirs, totalFiles int, err error) error
 	NotifyBackupDeleteFailure(ctx context.Context, key string, err error) error
+	NotifyBackupInterrupted(ctx context.Context, job string, dirs, failedDirs int) error
+	NotifyQuotaExceeded(ctx context.Context, scope string, used, limit int64, action string) error
+	NotifyRestore(ctx context.Context, key, target, outcome string, restored, skipped, failed, mismatches int) error
+	NotifyCorruption(ctx context.Context, key string, objects int, corrupted map[string]string) error
+	NotifyRestoreTest(ctx context.Context, key string, expected, restored, verified int, problems map[string]string) error
+	NotifyImmutability(ctx context.Context, key string, objects int, problems map[string]string) error
 }
 
 // NotifierStoreIface defines the interface for managing multiple notifiers.
 type NotifierStoreIface interface {
 	Enabled() bool
-	NotifyBackupSuccess(ctx context.Context, directory string, totalDirs, totalFiles, successFiles int, key string)
+	NotifyBackupSuccess(ctx context.Context, directory string, totalDirs, totalFiles, successFiles int, key string, skipped map[string]int)
+	NotifyBackupUnchanged(ctx context.Context, directory, key string)
 	NotifyBackupFailure(ctx context.Context, directory string, totalDirs, totalFiles int, err error)
 	NotifyBackupDeleteFailure(ctx context.Context, key string, err error)
+	NotifyBackupInterrupted(ctx context.Context, job string, dirs, failedDirs int)
+	NotifyQuotaExceeded(ctx context.Context, scope string, used, limit int64, action string)
+	NotifyRestore(ctx context.Context, key, target, outcome string, restored, skipped, failed, mismatches int)
+	NotifyCorruption(ctx context.Context, key string, objects int, corrupted map[string]string)
+	NotifyRestoreTest(ctx context.Context, key string, expected, restored, verified int, problems map[string]string)
+	NotifyImmutability(ctx context.Context, key string, objects int, problems map[string]string)
 	InitStore() error
 }
 
@@ -55,66 +72,139 @@ func (n *Notifier) Enabled() bool {
 	return n.cfg.Notifiers.Enabled
 }
 
-// NotifyBackupSuccess sends a backup success notification using all enabled notifiers.
-func (n *Notifier) NotifyBackupSuccess(ctx context.Context, directory string, totalDirs, totalFiles, successFiles int, key string) {
+// dispatch sends event to every enabled notifier concurrently, bounding each by the configured timeout so a
+// slow notifier does not delay the others. It waits for all of them and returns their errors joined.
+func (n *Notifier) dispatch(ctx context.Context, event string, send func(ctx context.Context, nf NotifiersIface) error) error {
 	if !n.Enabled() {
-		slog.ErrorContext(ctx, "Notifiers are disabled; skipping NotifyBackupSuccess")
+		slog.DebugContext(ctx, "Notifiers are disabled; skipping "+event)
+		return nil
 	}
 
-	for _, notifier := range n.store {
+	n.mu.RLock()
+	store := slices.Clone(n.store)
+	n.mu.RUnlock()
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(store))
+	)
+	for i, notifier := range store {
 		if !notifier.Enabled() {
-			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyBackupSuccess")
+			slog.DebugContext(ctx, "Notifier disabled; skipping "+event, "notifier", notifier.Name())
 			continue
 		}
-		if err := notifier.NotifyBackupSuccess(ctx, directory, totalDirs, totalFiles, successFiles, key); err != nil {
-			slog.ErrorContext(ctx, "Failed to send NotifyBackupSuccess", "error", err)
-		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			nCtx, cancel := context.WithTimeout(ctx, n.cfg.Notifiers.Timeout)
+			defer cancel()
+
+			if err := send(nCtx, notifier); err != nil {
+				slog.ErrorContext(ctx, "Failed to send "+event, "notifier", notifier.Name(), "error", err)
+				errs[i] = fmt.Errorf("%s: %w", notifier.Name(), err)
+			}
+		}()
 	}
+	wg.Wait()
+
+	err := errors.Join(errs...)
+	if err != nil {
+		slog.WarnContext(ctx, "Some notifiers failed", "event", event, "notifiers", len(store), "error", err)
+	}
+	return err
+}
+
+// NotifyBackupSuccess sends a backup success notification using all enabled notifiers.
+func (n *Notifier) NotifyBackupSuccess(ctx context.Context, directory string, totalDirs, totalFiles, successFiles int, key string, skipped map[string]int) {
+	_ = n.dispatch(ctx, "NotifyBackupSuccess", func(ctx context.Context, nf NotifiersIface) error {
+		return nf.NotifyBackupSuccess(ctx, directory, totalDirs, totalFiles, successFiles, key, skipped)
+	})
+}
+
+// NotifyBackupUnchanged sends a notification that an unchanged directory was skipped using all enabled notifiers.
+func (n *Notifier) NotifyBackupUnchanged(ctx context.Context, directory, key string) {
+	_ = n.dispatch(ctx, "NotifyBackupUnchanged", func(ctx context.Context, nf NotifiersIface) error {
+		return nf.NotifyBackupUnchanged(ctx, directory, key)
+	})
 }
 
 // NotifyBackupFailure sends a backup failure notification using all enabled notifiers.
 func (n *Notifier) NotifyBackupFailure(ctx context.Context, directory string, totalDirs, totalFiles int, bErr error) {
-	if !n.Enabled() {
-		slog.ErrorContext(ctx, "Notifiers are disabled; skipping NotifyBackupFailure")
-	}
-
-	for _, notifier := range n.store {
-		if !notifier.Enabled() {
-			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyBackupFailure")
-			continue
-		}
-		if err := notifier.NotifyBackupFailure(ctx, directory, totalDirs, totalFiles, bErr); err != nil {
-			slog.ErrorContext(ctx, "Failed to send NotifyBackupFailure", "error", err)
-		}
-	}
+	_ = n.dispatch(ctx, "NotifyBackupFailure", func(ctx context.Context, nf NotifiersIface) error {
+		return nf.NotifyBackupFailure(ctx, directory, totalDirs, totalFiles, bErr)
+	})
 }
 
 // NotifyBackupDeleteFailure sends a backup deletion failure notification using all enabled notifiers.
 func (n *Notifier) NotifyBackupDeleteFailure(ctx context.Context, key string, bErr error) {
-	if !n.Enabled() {
-		slog.ErrorContext(ctx, "Notifiers are disabled; skipping NotifyBackupDeleteFailure")
-	}
+	_ = n.dispatch(ctx, "NotifyBackupDeleteFailure", func(ctx context.Context, nf NotifiersIface) error {
+		return nf.NotifyBackupDeleteFailure(ctx, key, bErr)
+	})
+}
 
-	for _, notifier := range n.store {
-		if !notifier.Enabled() {
-			slog.DebugContext(ctx, "Notifier disabled; skipping NotifyBackupDeleteFailure")
-			continue
-		}
-		if err := notifier.NotifyBackupDeleteFailure(ctx, key, bErr); err != nil {
-			slog.ErrorContext(ctx, "Failed to send NotifyBackupDeleteFailure", "error", err)
-		}
-	}
+// NotifyBackupInterrupted sends a backup interruption notification using all enabled notifiers.
+func (n *Notifier) NotifyBackupInterrupted(ctx context.Context, job string, dirs, failedDirs int) {
+	_ = n.dispatch(ctx, "NotifyBackupInterrupted", func(ctx context.Context, nf NotifiersIface) error {
+		return nf.NotifyBackupInterrupted(ctx, job, dirs, failedDirs)
+	})
+}
+
+// NotifyQuotaExceeded sends a stored size quota notification using all enabled notifiers.
+func (n *Notifier) NotifyQuotaExceeded(ctx context.Context, scope string, used, limit int64, action string) {
+	_ = n.dispatch(ctx, "NotifyQuotaExceeded", func(ctx context.Context, nf NotifiersIface) error {
+		return nf.NotifyQuotaExceeded(ctx, scope, used, limit, action)
+	})
+}
+
+// NotifyRestore sends a restore summary notification using all enabled notifiers.
+func (n *Notifier) NotifyRestore(ctx context.Context, key, target, outcome string, restored, skipped, failed, mismatches int) {
+	_ = n.dispatch(ctx, "NotifyRestore", func(ctx context.Context, nf NotifiersIface) error {
+		return nf.NotifyRestore(ctx, key, target, outcome, restored, skipped, failed, mismatches)
+	})
 }
 
-// InitStore initializes and registers all available notifiers.
+// NotifyCorruption sends a notification that a verified backup is corrupted using all enabled notifiers.
+func (n *Notifier) NotifyCorruption(ctx context.Context, key string, objects int, corrupted map[string]string) {
+	_ = n.dispatch(ctx, "NotifyCorruption", func(ctx context.Context, nf NotifiersIface) error {
+		return nf.NotifyCorruption(ctx, key, objects, corrupted)
+	})
+}
+
+// NotifyRestoreTest sends the result of a restore test using all enabled notifiers.
+func (n *Notifier) NotifyRestoreTest(ctx context.Context, key string, expected, restored, verified int, problems map[string]string) {
+	_ = n.dispatch(ctx, "NotifyRestoreTest", func(ctx context.Context, nf NotifiersIface) error {
+		return nf.NotifyRestoreTest(ctx, key, expected, restored, verified, problems)
+	})
+}
+
+// NotifyImmutability sends a notification that a backup is not protected by Object Lock as expected using all enabled
+// notifiers.
+func (n *Notifier) NotifyImmutability(ctx context.Context, key string, objects int, problems map[string]string) {
+	_ = n.dispatch(ctx, "NotifyImmutability", func(ctx context.Context, nf NotifiersIface) error {
+		return nf.NotifyImmutability(ctx, key, objects, problems)
+	})
+}
+
+// InitStore creates the notifiers of every registered factory, in name order, and registers the enabled ones. Config
+// sections of notifiers that are not registered are an error, so a typo does not silently disable a notifier.
 func (n *Notifier) InitStore() error {
-	if n.cfg.Notifiers.Discord.Enabled {
-		d, err := discord.NewDiscordNotifier(n.cfg)
-		if err != nil {
-			return err
+	for name := range n.cfg.Notifiers.External {
+		if _, ok := factory(name); !ok {
+			return fmt.Errorf("%w %q, supported: %s", ErrUnknownNotifier, name, strings.Join(Registered(), ", "))
 		}
+	}
 
-		n.register(d)
+	for _, name := range Registered() {
+		f, _ := factory(name)
+		nf, err := f(n.cfg)
+		if err != nil {
+			return fmt.Errorf("%s notifier: %w", name, err)
+		}
+		if nf != nil {
+			n.register(nf)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,51 @@
+package notifiers
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNotifier is a notifier counting the backup success notifications it sends.
+type fakeNotifier struct {
+	NotifiersIface
+	enabled bool
+	sent    atomic.Int32
+}
+
+func (f *fakeNotifier) Name() string { return "fake" }
+
+func (f *fakeNotifier) Enabled() bool { return f.enabled }
+
+func (f *fakeNotifier) NotifyBackupSuccess(context.Context, string, int, int, int, string, map[string]int) error {
+	f.sent.Add(1)
+	return nil
+}
+
+func TestNotifier_Dispatch_Enabled(t *testing.T) {
+	tests := []struct {
+		name              string
+		notifiersEnabled  bool
+		notifierEnabled   bool
+		wantNotifications int32
+	}{
+		{name: "enabled", notifiersEnabled: true, notifierEnabled: true, wantNotifications: 1},
+		{name: "notifier disabled", notifiersEnabled: true},
+		{name: "notifiers disabled", notifierEnabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &Notifier{cfg: &config.Config{Notifiers: config.NotifiersConfig{Enabled: tt.notifiersEnabled, Timeout: time.Second}}}
+			nf := &fakeNotifier{enabled: tt.notifierEnabled}
+			n.register(nf)
+
+			n.NotifyBackupSuccess(t.Context(), "/data", 1, 1, 1, "key", nil)
+			assert.Equal(t, tt.wantNotifications, nf.sent.Load())
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package notifiers
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/notifiers/discord"
+	"github.com/hibare/arclift/internal/notifiers/sentry"
+)
+
+// ErrUnknownNotifier is returned when the config has a section for a notifier that is not registered.
+var ErrUnknownNotifier = errors.New("unknown notifier")
+
+// Factory creates the notifier configured under notifiers.<name>. It returns a nil notifier when that notifier is not
+// configured or not enabled. External notifiers read their section with config.NotifiersConfig.Decode.
+type Factory func(cfg *config.Config) (NotifiersIface, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{
+		"discord": newDiscord,
+		"sentry":  newSentry,
+	}
+)
+
+// Register registers the notifier factory under name, the key of its config section. It panics when name is already
+// registered, so it is meant to be called from an init function.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if _, ok := factories[name]; ok {
+		panic("notifier already registered: " + name)
+	}
+	factories[name] = factory
+}
+
+// Registered returns the names of the registered notifiers, sorted.
+func Registered() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	return slices.Sorted(maps.Keys(factories))
+}
+
+func factory(name string) (Factory, bool) {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	f, ok := factories[name]
+	return f, ok
+}
+
+func newDiscord(cfg *config.Config) (NotifiersIface, error) {
+	if !cfg.Notifiers.Discord.Enabled {
+		return nil, nil //nolint:nilnil // a nil notifier is not enabled
+	}
+	return discord.NewDiscordNotifier(cfg)
+}
+
+func newSentry(cfg *config.Config) (NotifiersIface, error) {
+	if !cfg.Notifiers.Sentry.Enabled {
+		return nil, nil //nolint:nilnil // a nil notifier is not enabled
+	}
+	return sentry.NewSentryNotifier(cfg)
+}
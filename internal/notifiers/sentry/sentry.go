@@ -0,0 +1,287 @@
+// Package sentry reports failures and panics to Sentry, or another service accepting Sentry events.
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/constants"
+	"github.com/hibare/arclift/internal/units"
+	"github.com/hibare/arclift/internal/version"
+)
+
+// Levels of events.
+const (
+	levelFatal   = "fatal"
+	levelError   = "error"
+	levelWarning = "warning"
+)
+
+// maxListedObjects is the number of failed objects or files listed in an event.
+const maxListedObjects = 50
+
+// exception is a panic, see event.
+type exception struct {
+	Type      string    `json:"type"`
+	Value     string    `json:"value"`
+	Mechanism mechanism `json:"mechanism"`
+}
+
+type mechanism struct {
+	Type    string `json:"type"`
+	Handled bool   `json:"handled"`
+}
+
+// event is a Sentry event. Events are grouped by message, so those of each dir or backup are tracked apart.
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name"`
+	Release     string            `json:"release"`
+	Environment string            `json:"environment,omitempty"`
+	Message     string            `json:"message"`
+	Tags        map[string]string `json:"tags"`
+	Extra       map[string]any    `json:"extra,omitempty"`
+	Exception   *struct {
+		Values []exception `json:"values"`
+	} `json:"exception,omitempty"`
+}
+
+// Sentry sends the failures of backups, restores and verifications to a Sentry project. Successful operations are
+// not reported.
+type Sentry struct {
+	Cfg      *config.Config
+	endpoint string
+	key      string
+	client   *http.Client
+}
+
+// Name returns the name of the notifier.
+func (s *Sentry) Name() string {
+	return "sentry"
+}
+
+// Enabled checks if the Sentry notifier is enabled in the configuration.
+func (s *Sentry) Enabled() bool {
+	return s.Cfg.Notifiers.Sentry.Enabled
+}
+
+// NotifyBackupSuccess does nothing, as only failures are reported.
+func (s *Sentry) NotifyBackupSuccess(context.Context, string, int, int, int, string, map[string]int) error {
+	return nil
+}
+
+// NotifyBackupUnchanged does nothing, as only failures are reported.
+func (s *Sentry) NotifyBackupUnchanged(context.Context, string, string) error {
+	return nil
+}
+
+// NotifyBackupFailure reports the failed backup of directory.
+func (s *Sentry) NotifyBackupFailure(ctx context.Context, directory string, totalDirs, totalFiles int, err error) error {
+	ev := s.newEvent(levelError, "Backup of "+directory+" failed", "backup_failure")
+	ev.Tags["dir"] = directory
+	ev.Extra["error"] = err.Error()
+	ev.Extra["total_dirs"] = totalDirs
+	ev.Extra["total_files"] = totalFiles
+	return s.send(ctx, ev)
+}
+
+// NotifyBackupDeleteFailure reports that the old backup at key could not be deleted.
+func (s *Sentry) NotifyBackupDeleteFailure(ctx context.Context, key string, err error) error {
+	ev := s.newEvent(levelError, "Deleting old backups failed", "delete_failure")
+	ev.Extra["key"] = key
+	ev.Extra["error"] = err.Error()
+	return s.send(ctx, ev)
+}
+
+// NotifyBackupInterrupted reports that the backup of job was stopped before it completed.
+func (s *Sentry) NotifyBackupInterrupted(ctx context.Context, job string, dirs, failedDirs int) error {
+	ev := s.newEvent(levelWarning, "Backup interrupted", "backup_interrupted")
+	ev.Extra["dirs"] = dirs
+	ev.Extra["failed_dirs"] = failedDirs
+	return s.send(ctx, ev)
+}
+
+// NotifyQuotaExceeded reports that a stored size quota was exceeded.
+func (s *Sentry) NotifyQuotaExceeded(ctx context.Context, scope string, used, limit int64, action string) error {
+	ev := s.newEvent(levelWarning, "Quota exceeded for "+scope, "quota_exceeded")
+	ev.Extra["used"] = units.FormatBytes(used)
+	ev.Extra["limit"] = units.FormatBytes(limit)
+	ev.Extra["action"] = action
+	return s.send(ctx, ev)
+}
+
+// NotifyRestore reports a restore that did not complete.
+func (s *Sentry) NotifyRestore(ctx context.Context, key, target, outcome string, restored, skipped, failed, mismatches int) error {
+	if failed == 0 && mismatches == 0 {
+		return nil
+	}
+	ev := s.newEvent(levelError, "Restore of "+key+" "+outcome, "restore_failure")
+	ev.Extra["target"] = target
+	ev.Extra["restored"] = restored
+	ev.Extra["skipped"] = skipped
+	ev.Extra["failed"] = failed
+	ev.Extra["checksum_mismatches"] = mismatches
+	return s.send(ctx, ev)
+}
+
+// NotifyCorruption reports the corrupted objects found in the backup at key.
+func (s *Sentry) NotifyCorruption(ctx context.Context, key string, objects int, corrupted map[string]string) error {
+	ev := s.newEvent(levelError, "Corrupted backup "+key, "corruption")
+	ev.Extra["objects"] = objects
+	ev.Extra["corrupted"] = listErrors(corrupted)
+	return s.send(ctx, ev)
+}
+
+// NotifyRestoreTest reports a restore test of the backup at key that found problems.
+func (s *Sentry) NotifyRestoreTest(ctx context.Context, key string, expected, restored, verified int, problems map[string]string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	ev := s.newEvent(levelError, "Restore test of "+key+" failed", "restore_test_failure")
+	ev.Extra["expected"] = expected
+	ev.Extra["restored"] = restored
+	ev.Extra["verified"] = verified
+	ev.Extra["problems"] = listErrors(problems)
+	return s.send(ctx, ev)
+}
+
+// NotifyImmutability reports the objects of the backup at key that are not protected by Object Lock as expected.
+func (s *Sentry) NotifyImmutability(ctx context.Context, key string, objects int, problems map[string]string) error {
+	ev := s.newEvent(levelError, "Backup "+key+" is not immutable", "immutability_failure")
+	ev.Extra["objects"] = objects
+	ev.Extra["problems"] = listErrors(problems)
+	return s.send(ctx, ev)
+}
+
+// CapturePanic reports recovered, a panic with the stack of the panicking goroutine, when the Sentry notifier of cfg
+// is enabled. It waits for the report for at most the notifiers timeout.
+func CapturePanic(cfg *config.Config, recovered any, stack []byte) error {
+	if cfg == nil || !cfg.Notifiers.Sentry.Enabled {
+		return nil
+	}
+	s, err := NewSentryNotifier(cfg)
+	if err != nil {
+		return err
+	}
+
+	ev := s.newEvent(levelFatal, fmt.Sprintf("panic: %v", recovered), "panic")
+	ev.Exception = &struct {
+		Values []exception `json:"values"`
+	}{Values: []exception{{
+		Type:      fmt.Sprintf("%T", recovered),
+		Value:     fmt.Sprint(recovered),
+		Mechanism: mechanism{Type: "go.panic", Handled: false},
+	}}}
+	ev.Extra["stack"] = string(stack)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Notifiers.Timeout)
+	defer cancel()
+	return s.send(ctx, ev)
+}
+
+// newEvent returns an event of the host, job and version of arclift, tagged with kind.
+func (s *Sentry) newEvent(level, message, kind string) event {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	tags := map[string]string{"event": kind}
+	if s.Cfg.Backup.Job != "" {
+		tags["job"] = s.Cfg.Backup.Job
+	}
+	return event{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   time.Now().UTC(),
+		Platform:    "go",
+		Level:       level,
+		Logger:      constants.ProgramIdentifier,
+		ServerName:  s.Cfg.Backup.Hostname,
+		Release:     constants.ProgramIdentifier + "@" + version.CurrentVersion,
+		Environment: s.Cfg.Notifiers.Sentry.Environment,
+		Message:     message,
+		Tags:        tags,
+		Extra:       map[string]any{},
+	}
+}
+
+// send sends ev as an envelope, the format of the Sentry ingestion API.
+func (s *Sentry) send(ctx context.Context, ev event) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	header, err := json.Marshal(map[string]string{"event_id": ev.EventID, "sent_at": time.Now().UTC().Format(time.RFC3339)})
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	body.Write(header)
+	fmt.Fprintf(&body, "\n{\"type\":\"event\",\"length\":%d}\n", len(payload))
+	body.Write(payload)
+	body.WriteByte('\n')
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s/%s, sentry_key=%s",
+		constants.ProgramIdentifier, version.CurrentVersion, s.key))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("sentry returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// listErrors lists the first maxListedObjects errors, by name, one per line.
+func listErrors(errs map[string]string) string {
+	names := slices.Sorted(maps.Keys(errs))
+	lines := make([]string, 0, min(len(names), maxListedObjects)+1)
+	for _, name := range names[:min(len(names), maxListedObjects)] {
+		lines = append(lines, name+": "+errs[name])
+	}
+	if len(names) > maxListedObjects {
+		lines = append(lines, fmt.Sprintf("and %d more", len(names)-maxListedObjects))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// NewSentryNotifier creates a Sentry notifier sending to the project of the configured DSN.
+func NewSentryNotifier(cfg *config.Config) (*Sentry, error) {
+	endpoint, key, err := cfg.Notifiers.Sentry.Endpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:errcheck // the default transport is an *http.Transport
+	transport.Proxy = cfg.Proxy.Func()
+
+	return &Sentry{
+		Cfg:      cfg,
+		endpoint: endpoint,
+		key:      key,
+		client:   &http.Client{Transport: transport},
+	}, nil
+}
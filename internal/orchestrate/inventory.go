@@ -0,0 +1,94 @@
+// Package orchestrate triggers backups on a fleet of hosts running arclift and collects their results.
+package orchestrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Methods used to reach a host.
+const (
+	MethodSSH = "ssh"
+	MethodAPI = "api"
+)
+
+const defaultCommand = "arclift"
+
+// Host is a single inventory entry. Exactly one of SSH or API must be set.
+type Host struct {
+	Name string `yaml:"name"`
+
+	// SSH is the ssh destination, e.g. "admin@web1.example.com".
+	SSH          string `yaml:"ssh"`
+	Port         int    `yaml:"port"`
+	IdentityFile string `yaml:"identity-file"`
+	Command      string `yaml:"command"`
+	Config       string `yaml:"config"`
+
+	// API is the base URL of the host's dashboard, e.g. "http://nas.lan:8080".
+	API      string `yaml:"api"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Method returns how the host is reached.
+func (h Host) Method() string {
+	if h.API != "" {
+		return MethodAPI
+	}
+	return MethodSSH
+}
+
+func (h *Host) validate() error {
+	if h.Name == "" {
+		h.Name = h.SSH + h.API
+	}
+	if h.Name == "" {
+		return errors.New("inventory host is missing ssh or api")
+	}
+	if (h.SSH == "") == (h.API == "") {
+		return fmt.Errorf("inventory host %s must set exactly one of ssh or api", h.Name)
+	}
+	if h.Command == "" {
+		h.Command = defaultCommand
+	}
+	return nil
+}
+
+// Inventory is the list of hosts to orchestrate.
+type Inventory struct {
+	Hosts []Host `yaml:"hosts"`
+}
+
+// LoadInventory reads an inventory file. Environment variables such as ${API_PASSWORD} are expanded.
+func LoadInventory(path string) (*Inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &Inventory{}
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), inv); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory: %w", err)
+	}
+
+	if len(inv.Hosts) == 0 {
+		return nil, errors.New("inventory has no hosts")
+	}
+
+	seen := map[string]bool{}
+	for i := range inv.Hosts {
+		if err := inv.Hosts[i].validate(); err != nil {
+			return nil, err
+		}
+		if seen[inv.Hosts[i].Name] {
+			return nil, fmt.Errorf("duplicate inventory host %s", inv.Hosts[i].Name)
+		}
+		seen[inv.Hosts[i].Name] = true
+	}
+
+	return inv, nil
+}
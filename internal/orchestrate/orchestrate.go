@@ -0,0 +1,270 @@
+package orchestrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hibare/arclift/internal/state"
+)
+
+const (
+	pollInterval     = 5 * time.Second
+	maxResponseBytes = 1 << 20
+)
+
+// ErrBackupInProgress is returned when a host is already running an operation.
+var ErrBackupInProgress = errors.New("host is already running an operation")
+
+// Result is the outcome of orchestrating a single host.
+type Result struct {
+	Host       string           `json:"host"`
+	Method     string           `json:"method"`
+	Status     string           `json:"status"`
+	Error      string           `json:"error,omitempty"`
+	Run        *state.RunRecord `json:"run,omitempty"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+}
+
+// Options controls an orchestration run.
+type Options struct {
+	// Parallel is the number of hosts backed up at the same time.
+	Parallel int
+
+	// Timeout bounds the backup of a single host.
+	Timeout time.Duration
+}
+
+// Run triggers a backup on every host, at most opts.Parallel at a time, and returns the results in inventory order.
+func Run(ctx context.Context, inv *Inventory, opts Options) []Result {
+	if opts.Parallel <= 0 {
+		opts.Parallel = 1
+	}
+
+	results := make([]Result, len(inv.Hosts))
+	sem := make(chan struct{}, opts.Parallel)
+	var wg sync.WaitGroup
+
+	for i, host := range inv.Hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = runHost(ctx, host, opts.Timeout)
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
+
+func runHost(ctx context.Context, host Host, timeout time.Duration) Result {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	res := Result{Host: host.Name, Method: host.Method(), StartedAt: time.Now()}
+
+	var (
+		run *state.RunRecord
+		err error
+	)
+	if host.Method() == MethodAPI {
+		run, err = runAPI(ctx, host)
+	} else {
+		run, err = runSSH(ctx, host)
+	}
+
+	res.FinishedAt = time.Now()
+	res.Run = run
+	switch {
+	case err != nil:
+		res.Status = state.StatusFailure
+		res.Error = err.Error()
+	case run != nil:
+		res.Status = run.Status
+		res.Error = run.Error
+	default:
+		res.Status = state.StatusSuccess
+	}
+	return res
+}
+
+// sshArgs builds the ssh invocation running the remote arclift with args.
+func sshArgs(host Host, args ...string) []string {
+	sshArgs := []string{"-o", "BatchMode=yes"}
+	if host.Port > 0 {
+		sshArgs = append(sshArgs, "-p", strconv.Itoa(host.Port))
+	}
+	if host.IdentityFile != "" {
+		sshArgs = append(sshArgs, "-i", host.IdentityFile)
+	}
+
+	remote := []string{host.Command}
+	if host.Config != "" {
+		remote = append(remote, "-c", host.Config)
+	}
+	remote = append(remote, args...)
+
+	quoted := make([]string, 0, len(remote))
+	for _, arg := range remote {
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+
+	return append(sshArgs, host.SSH, "--", strings.Join(quoted, " "))
+}
+
+func ssh(ctx context.Context, host Host, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ssh", sshArgs(host, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.Bytes(), fmt.Errorf("%w: %s", err, lastLine(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// lastLine returns the last non-empty line of s, which is where arclift and ssh report the failure.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// runSSH runs the backup over ssh and reads the resulting run from the remote run history.
+func runSSH(ctx context.Context, host Host) (*state.RunRecord, error) {
+	_, backupErr := ssh(ctx, host, "backup")
+
+	out, err := ssh(ctx, host, "backup", "history", "--output", "json", "--limit", "1")
+	if err != nil {
+		if backupErr != nil {
+			return nil, backupErr
+		}
+		// The backup succeeded, but the host keeps no history.
+		return nil, nil //nolint:nilnil // a missing history is not an error
+	}
+
+	var runs []state.RunRecord
+	if jErr := json.Unmarshal(lastJSON(out), &runs); jErr != nil || len(runs) == 0 {
+		return nil, backupErr
+	}
+	return &runs[0], backupErr
+}
+
+// lastJSON returns the JSON document in out, skipping any log lines printed before it.
+func lastJSON(out []byte) []byte {
+	if i := bytes.IndexByte(out, '['); i >= 0 {
+		return out[i:]
+	}
+	return out
+}
+
+// status is the subset of the dashboard status used for orchestration.
+type status struct {
+	Busy bool              `json:"busy"`
+	Runs []state.RunRecord `json:"runs"`
+}
+
+func apiRequest(ctx context.Context, host Host, method, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(host.API, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if host.Username != "" {
+		req.SetBasicAuth(host.Username, host.Password)
+	}
+
+	client := &http.Client{
+		// Action endpoints redirect to the HTML dashboard; the redirect itself is the success signal.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return client.Do(req)
+}
+
+func apiStatus(ctx context.Context, host Host) (status, error) {
+	var st status
+
+	resp, err := apiRequest(ctx, host, http.MethodGet, "/api/status")
+	if err != nil {
+		return st, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return st, fmt.Errorf("status request failed: %s", resp.Status)
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&st)
+	return st, err
+}
+
+// latestBackup returns the most recent backup run, if any.
+func (s status) latestBackup() *state.RunRecord {
+	for i := range s.Runs {
+		if s.Runs[i].Operation == state.OperationBackup {
+			return &s.Runs[i]
+		}
+	}
+	return nil
+}
+
+// runAPI triggers the backup through the dashboard and polls its status until the run is recorded.
+func runAPI(ctx context.Context, host Host) (*state.RunRecord, error) {
+	before, err := apiStatus(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if before.Busy {
+		return nil, ErrBackupInProgress
+	}
+	previous := before.latestBackup()
+
+	resp, err := apiRequest(ctx, host, http.MethodPost, "/actions/backup")
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return nil, ErrBackupInProgress
+	case resp.StatusCode >= http.StatusBadRequest:
+		return nil, fmt.Errorf("backup request failed: %s", resp.Status)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		st, sErr := apiStatus(ctx, host)
+		if sErr != nil || st.Busy {
+			continue
+		}
+
+		latest := st.latestBackup()
+		if latest == nil || (previous != nil && !latest.StartedAt.After(previous.StartedAt)) {
+			// Not busy and no new run: the host keeps no history, so the outcome is unknown beyond completion.
+			return nil, nil //nolint:nilnil // a missing history is not an error
+		}
+		return latest, nil
+	}
+}
@@ -0,0 +1,200 @@
+package orchestrate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hibare/arclift/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		host Host
+		args []string
+		want []string
+	}{
+		{
+			name: "defaults",
+			host: Host{SSH: "admin@web1", Command: "arclift"},
+			args: []string{"backup"},
+			want: []string{"-o", "BatchMode=yes", "admin@web1", "--", "'arclift' 'backup'"},
+		},
+		{
+			name: "port and identity",
+			host: Host{SSH: "web1", Port: 2222, IdentityFile: "/keys/id_ed25519", Command: "arclift"},
+			args: []string{"backup"},
+			want: []string{
+				"-o", "BatchMode=yes", "-p", "2222", "-i", "/keys/id_ed25519",
+				"web1", "--", "'arclift' 'backup'",
+			},
+		},
+		{
+			name: "config",
+			host: Host{SSH: "web1", Command: "/usr/local/bin/arclift", Config: "/etc/arclift/config.yaml"},
+			args: []string{"backup", "history", "--limit", "1"},
+			want: []string{
+				"-o", "BatchMode=yes", "web1", "--",
+				"'/usr/local/bin/arclift' '-c' '/etc/arclift/config.yaml' 'backup' 'history' '--limit' '1'",
+			},
+		},
+		{
+			name: "quoting",
+			host: Host{SSH: "web1", Command: "arclift", Config: "/home/o'brien/my config.yaml"},
+			args: []string{"backup"},
+			want: []string{
+				"-o", "BatchMode=yes", "web1", "--",
+				`'arclift' '-c' '/home/o'\''brien/my config.yaml' 'backup'`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sshArgs(tt.host, tt.args...))
+		})
+	}
+}
+
+// fakeSSH puts an ssh on PATH that fails for "unreachable" destinations, fails the backup of "failing" ones and
+// answers history requests with a recorded run.
+func fakeSSH(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ssh is a shell script")
+	}
+
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$*" in
+*unreachable*)
+	echo "ssh: connect to host unreachable port 22: Connection refused" >&2
+	exit 255 ;;
+*failing*history*)
+	echo '[{"operation":"backup","status":"failure","error":"upload failed"}]' ;;
+*history*)
+	echo 'level=INFO msg="loaded config"'
+	echo '[{"operation":"backup","status":"success","bytes":42}]' ;;
+*failing*)
+	echo "upload failed" >&2
+	exit 1 ;;
+esac
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0o755)) //nolint:gosec // test executable
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// dashboard serves /api/status with statusCode and body, and answers backup requests with backupCode.
+func dashboard(t *testing.T, statusCode int, body string, backupCode int) string {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(body))
+	})
+	mux.HandleFunc("POST /actions/backup", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(backupCode)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestRun_Results(t *testing.T) {
+	fakeSSH(t)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	idle := `{"busy": false, "runs": []}`
+	tests := []struct {
+		name   string
+		host   Host
+		status string
+		err    string
+		run    *state.RunRecord
+	}{
+		{
+			name:   "ssh success",
+			host:   Host{Name: "web1", SSH: "web1"},
+			status: state.StatusSuccess,
+			run:    &state.RunRecord{Operation: state.OperationBackup, Status: state.StatusSuccess, Bytes: 42},
+		},
+		{
+			name:   "ssh backup failed",
+			host:   Host{Name: "web2", SSH: "failing"},
+			status: state.StatusFailure,
+			err:    "upload failed",
+			run:    &state.RunRecord{Operation: state.OperationBackup, Status: state.StatusFailure, Error: "upload failed"},
+		},
+		{
+			name:   "ssh unreachable",
+			host:   Host{Name: "web3", SSH: "unreachable"},
+			status: state.StatusFailure,
+			err:    "exit status 255: ssh: connect to host unreachable port 22: Connection refused",
+		},
+		{
+			name:   "api unreachable",
+			host:   Host{Name: "nas1", API: closed.URL},
+			status: state.StatusFailure,
+			err:    "connection refused",
+		},
+		{
+			name:   "api status failed",
+			host:   Host{Name: "nas2", API: dashboard(t, http.StatusInternalServerError, "", http.StatusSeeOther)},
+			status: state.StatusFailure,
+			err:    "status request failed: 500 Internal Server Error",
+		},
+		{
+			name:   "api busy",
+			host:   Host{Name: "nas3", API: dashboard(t, http.StatusOK, `{"busy": true}`, http.StatusSeeOther)},
+			status: state.StatusFailure,
+			err:    ErrBackupInProgress.Error(),
+		},
+		{
+			name:   "api conflict",
+			host:   Host{Name: "nas4", API: dashboard(t, http.StatusOK, idle, http.StatusConflict)},
+			status: state.StatusFailure,
+			err:    ErrBackupInProgress.Error(),
+		},
+		{
+			name:   "api backup rejected",
+			host:   Host{Name: "nas5", API: dashboard(t, http.StatusOK, idle, http.StatusForbidden)},
+			status: state.StatusFailure,
+			err:    "backup request failed: 403 Forbidden",
+		},
+	}
+
+	inv := &Inventory{}
+	for _, tt := range tests {
+		require.NoError(t, tt.host.validate())
+		inv.Hosts = append(inv.Hosts, tt.host)
+	}
+
+	results := Run(t.Context(), inv, Options{Parallel: 3})
+	require.Len(t, results, len(tests))
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := results[i]
+			assert.Equal(t, tt.host.Name, res.Host)
+			assert.Equal(t, tt.host.Method(), res.Method)
+			assert.Equal(t, tt.status, res.Status)
+			if tt.err == "" {
+				assert.Empty(t, res.Error)
+			} else {
+				assert.Contains(t, res.Error, tt.err)
+			}
+			assert.Equal(t, tt.run, res.Run)
+			assert.False(t, res.FinishedAt.Before(res.StartedAt))
+		})
+	}
+}
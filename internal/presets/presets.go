@@ -0,0 +1,89 @@
+// Package presets defines backup source presets for common self-hosted applications.
+package presets
+
+import (
+	"sort"
+)
+
+// Dump is a database dump taken before the source is backed up.
+type Dump struct {
+	// Name is the file name of the dump inside the backup.
+	Name string
+
+	// Command is run inside the source path; its stdout is stored as the dump. "{path}" is replaced by the source path.
+	Command []string
+
+	// Env is added to the environment of Command, such as the password of the database.
+	Env []string
+}
+
+// Preset describes which parts of an application install are backed up.
+type Preset struct {
+	Name string
+
+	// Include lists the paths, relative to the source path, that are backed up. Empty includes everything.
+	Include []string
+
+	// Exclude lists glob patterns of caches and other disposable data. Patterns containing a "/" match the path
+	// relative to the source path, others match the base name.
+	Exclude []string
+
+	// Dump is the database dump taken by default, if the application has a standard tool for it.
+	Dump *Dump
+
+	// NeedsDump reports that the application keeps state in a database that Dump does not cover.
+	NeedsDump bool
+}
+
+var registry = map[string]Preset{
+	"wordpress": {
+		Name: "wordpress",
+		Exclude: []string{
+			"wp-content/cache",
+			"wp-content/upgrade",
+			"wp-content/uploads/cache",
+			"*.log",
+		},
+		Dump: &Dump{
+			Name:    "wordpress.sql",
+			Command: []string{"wp", "db", "export", "-", "--path={path}", "--allow-root"},
+		},
+	},
+	"nextcloud": {
+		Name:    "nextcloud",
+		Include: []string{"config", "data", "themes"},
+		Exclude: []string{
+			"data/*/cache",
+			"data/*/uploads",
+			"data/appdata_*/preview",
+			"data/updater-*",
+			"data/nextcloud.log",
+		},
+		NeedsDump: true,
+	},
+	"gitlab": {
+		Name:    "gitlab",
+		Include: []string{"backups"},
+		Dump: &Dump{
+			// gitlab-backup writes its own archive into the backups directory; stdout is kept as the log of the run.
+			Name:    "gitlab-backup.log",
+			Command: []string{"gitlab-backup", "create", "STRATEGY=copy"},
+		},
+	},
+}
+
+// Get returns the preset with the given name.
+func Get(name string) (Preset, bool) {
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names returns the names of all presets, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,35 @@
+// Package priority lowers the CPU and IO priority of the process, so backups do not slow down the services of the
+// host.
+package priority
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+
+	"github.com/hibare/arclift/internal/config"
+)
+
+// ErrUnsupported is returned when a priority setting is not supported on this platform.
+var ErrUnsupported = errors.New("not supported on this platform")
+
+// Apply applies cfg to the process and the commands it starts. Priorities can only be lowered: an unprivileged
+// process cannot raise them back, so a lowered priority lasts for the life of the process. Settings that cannot be
+// applied are logged.
+func Apply(ctx context.Context, cfg config.PriorityConfig) {
+	if cfg.MaxProcs > 0 {
+		runtime.GOMAXPROCS(cfg.MaxProcs)
+	}
+	if cfg.Nice > 0 {
+		if err := setNice(cfg.Nice); err != nil {
+			slog.WarnContext(ctx, "Failed to lower CPU priority", "nice", cfg.Nice, "error", err)
+		}
+	}
+	if cfg.IOClass != "" {
+		if err := setIOClass(cfg.IOClass); err != nil {
+			slog.WarnContext(ctx, "Failed to lower IO priority", "io-class", cfg.IOClass, "error", err)
+		}
+	}
+	slog.DebugContext(ctx, "Applied priority", "nice", cfg.Nice, "io-class", cfg.IOClass, "max-procs", runtime.GOMAXPROCS(0))
+}
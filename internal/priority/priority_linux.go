@@ -0,0 +1,69 @@
+package priority
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/hibare/arclift/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// IO priorities of ioprio_set(2).
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+	ioprioLowestBE   = 7
+)
+
+// threads returns the ids of the threads of the process. Linux applies priorities per thread; the threads the Go
+// runtime starts later inherit them from the thread starting them.
+func threads() ([]int, error) {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return nil, err
+	}
+	tids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if tid, aErr := strconv.Atoi(e.Name()); aErr == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
+
+// forEachThread calls set with the id of every thread of the process, returning the errors joined.
+func forEachThread(set func(tid int) error) error {
+	tids, err := threads()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, tid := range tids {
+		if sErr := set(tid); sErr != nil && !errors.Is(sErr, unix.ESRCH) {
+			errs = append(errs, sErr)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func setNice(nice int) error {
+	return forEachThread(func(tid int) error {
+		return unix.Setpriority(unix.PRIO_PROCESS, tid, nice)
+	})
+}
+
+func setIOClass(class string) error {
+	prio := ioprioClassBE<<ioprioClassShift | ioprioLowestBE
+	if class == config.IOClassIdle {
+		prio = ioprioClassIdle << ioprioClassShift
+	}
+	return forEachThread(func(tid int) error {
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio)); errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
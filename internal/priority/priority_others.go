@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package priority
+
+func setNice(int) error { return ErrUnsupported }
+
+func setIOClass(string) error { return ErrUnsupported }
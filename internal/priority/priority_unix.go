@@ -0,0 +1,14 @@
+//go:build unix && !linux
+
+package priority
+
+import "syscall"
+
+func setNice(nice int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, nice)
+}
+
+// IO scheduling classes are only supported on Linux.
+func setIOClass(string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,20 @@
+package priority
+
+import (
+	"github.com/hibare/arclift/internal/config"
+	"golang.org/x/sys/windows"
+)
+
+// setNice lowers the priority class of the process, which has no finer levels than below normal.
+func setNice(int) error {
+	return windows.SetPriorityClass(windows.CurrentProcess(), windows.BELOW_NORMAL_PRIORITY_CLASS)
+}
+
+// setIOClass enters background mode for the idle class, which also lowers the CPU priority to the lowest; the
+// best-effort class is the default IO priority of Windows.
+func setIOClass(class string) error {
+	if class != config.IOClassIdle {
+		return nil
+	}
+	return windows.SetPriorityClass(windows.CurrentProcess(), windows.PROCESS_MODE_BACKGROUND_BEGIN)
+}
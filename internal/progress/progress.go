@@ -0,0 +1,115 @@
+// Package progress renders the progress of transfers on a terminal.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hibare/arclift/internal/units"
+)
+
+const (
+	// renderInterval is the shortest time between two renders, so fast transfers do not flood the terminal.
+	renderInterval = 200 * time.Millisecond
+
+	barWidth = 24
+	percent  = 100
+)
+
+// Bar is a progress bar showing the bytes transferred, the percentage of those expected, the speed and the time
+// left. It implements storage.Progress and redraws a single line of its writer.
+type Bar struct {
+	w     io.Writer
+	start time.Time
+
+	mu       sync.Mutex
+	label    string
+	total    int64
+	done     int64
+	rendered time.Time
+}
+
+// New returns a bar rendering to w, which is normally a terminal.
+func New(w io.Writer) *Bar {
+	return &Bar{w: w, start: time.Now()}
+}
+
+// Expect adds total to the bytes expected.
+func (b *Bar) Expect(total int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.total += total
+	b.render(time.Now(), false)
+}
+
+// SetLabel shows label, such as the dir being transferred, before the bar.
+func (b *Bar) SetLabel(label string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.label = label
+	b.render(time.Now(), true)
+}
+
+// Add adds n to the bytes transferred.
+func (b *Bar) Add(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done += n
+	b.render(time.Now(), false)
+}
+
+// Finish renders the final state of the bar and ends its line.
+func (b *Bar) Finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done == 0 && b.total == 0 {
+		return
+	}
+	b.render(time.Now(), true)
+	_, _ = fmt.Fprintln(b.w)
+}
+
+// render redraws the bar, at most once per renderInterval unless force is set.
+func (b *Bar) render(now time.Time, force bool) {
+	if !force && now.Sub(b.rendered) < renderInterval {
+		return
+	}
+	b.rendered = now
+	line := b.line(now)
+	if b.label != "" {
+		line = b.label + "  " + line
+	}
+	_, _ = fmt.Fprint(b.w, "\r\033[K"+line)
+}
+
+// line formats the bar at now.
+func (b *Bar) line(now time.Time) string {
+	done := max(b.done, 0)
+	elapsed := now.Sub(b.start)
+	var speed int64
+	if elapsed > 0 {
+		speed = int64(float64(done) / elapsed.Seconds())
+	}
+	rate := units.FormatBytes(speed) + "/s"
+
+	if b.total <= 0 {
+		return fmt.Sprintf("%s  %s", units.FormatBytes(done), rate)
+	}
+
+	ratio := min(float64(done)/float64(b.total), 1)
+	filled := int(ratio * barWidth)
+	bar := "[" + strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled) + "]"
+
+	eta := "--"
+	switch {
+	case done >= b.total:
+		eta = "0s"
+	case speed > 0:
+		eta = (time.Duration((b.total-done)/speed) * time.Second).String()
+	}
+	return fmt.Sprintf("%s %3.0f%%  %s / %s  %s  ETA %s",
+		bar, ratio*percent, units.FormatBytes(done), units.FormatBytes(b.total), rate, eta)
+}
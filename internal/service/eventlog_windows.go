@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/hibare/arclift/internal/constants"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventID is the event ID of every entry written to the event log. Sources registered with
+// eventlog.InstallAsEventCreate support IDs 1 to 1000.
+const eventID = 1
+
+// elog is the event log of the service, set while the process runs as a Windows service.
+var elog *eventlog.Log
+
+// eventLogHandler writes the records handled by next to the Windows event log as well.
+type eventLogHandler struct {
+	next  slog.Handler
+	attrs []slog.Attr
+}
+
+func (h *eventLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *eventLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var msg strings.Builder
+	msg.WriteString(r.Message)
+	write := func(a slog.Attr) bool {
+		fmt.Fprintf(&msg, " %s=%v", a.Key, a.Value)
+		return true
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(write)
+
+	switch {
+	case r.Level >= slog.LevelError:
+		_ = elog.Error(eventID, msg.String())
+	case r.Level >= slog.LevelWarn:
+		_ = elog.Warning(eventID, msg.String())
+	default:
+		_ = elog.Info(eventID, msg.String())
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *eventLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &eventLogHandler{next: h.next.WithAttrs(attrs), attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+func (h *eventLogHandler) WithGroup(name string) slog.Handler {
+	return &eventLogHandler{next: h.next.WithGroup(name), attrs: h.attrs}
+}
+
+// AttachEventLog makes the default logger write to the Windows event log too when the process runs as a service.
+// It must be called again whenever the default logger is replaced, such as after loading the config.
+func AttachEventLog() {
+	if elog == nil {
+		return
+	}
+	if _, ok := slog.Default().Handler().(*eventLogHandler); ok {
+		return
+	}
+	slog.SetDefault(slog.New(&eventLogHandler{next: slog.Default().Handler()}))
+}
+
+// installEventLog registers the event log source of the service.
+func installEventLog() error {
+	return eventlog.InstallAsEventCreate(constants.ProgramIdentifier, eventlog.Error|eventlog.Warning|eventlog.Info)
+}
+
+// removeEventLog removes the event log source of the service.
+func removeEventLog() error {
+	return eventlog.Remove(constants.ProgramIdentifier)
+}
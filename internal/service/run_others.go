@@ -0,0 +1,13 @@
+//go:build !windows
+
+package service
+
+import "context"
+
+// RunIfService runs fn under the platform service manager when required. Only Windows needs this.
+func RunIfService(context.Context, func(context.Context) error) (bool, error) {
+	return false, nil
+}
+
+// AttachEventLog makes the default logger write to the platform event log. Only Windows services have one.
+func AttachEventLog() {}
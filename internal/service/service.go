@@ -0,0 +1,82 @@
+// Package service registers arclift with the platform service manager so the scheduler runs at boot.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	commonRuntime "github.com/hibare/GoCommon/v2/pkg/os/runtime"
+	"github.com/hibare/arclift/internal/constants"
+)
+
+// ErrUnsupportedPlatform is returned when the running platform has no supported service manager.
+var ErrUnsupportedPlatform = errors.New("service installation is not supported on this platform")
+
+// Options describes how the service is registered.
+type Options struct {
+	// Executable is the absolute path of the arclift binary.
+	Executable string
+
+	// ConfigPath is the absolute path of the config file passed to the scheduler.
+	ConfigPath string
+}
+
+// Args returns the command line arguments the service manager starts arclift with.
+func (o Options) Args() []string {
+	return []string{"-c", o.ConfigPath}
+}
+
+// NewOptions resolves the executable and config paths used by the service.
+func NewOptions(configPath string) (Options, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return Options{}, fmt.Errorf("failed to resolve executable: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return Options{}, fmt.Errorf("failed to resolve executable: %w", err)
+	}
+
+	if configPath == "" {
+		runtime := commonRuntime.New()
+		configPath = filepath.Join(runtime.GetConfigDir(), constants.ProgramIdentifier,
+			fmt.Sprintf("%s.%s", commonRuntime.ConfigFileName, commonRuntime.ConfigFileExtension))
+	}
+	if configPath, err = filepath.Abs(configPath); err != nil {
+		return Options{}, fmt.Errorf("failed to resolve config path: %w", err)
+	}
+
+	return Options{Executable: exe, ConfigPath: configPath}, nil
+}
+
+// Install registers arclift with the service manager and starts it.
+func Install(ctx context.Context, opts Options) error {
+	return install(ctx, opts)
+}
+
+// Uninstall stops arclift and removes it from the service manager.
+func Uninstall(ctx context.Context) error {
+	return uninstall(ctx)
+}
+
+// Start starts the installed service.
+func Start(ctx context.Context) error {
+	return start(ctx)
+}
+
+// Stop stops the running service.
+func Stop(ctx context.Context) error {
+	return stop(ctx)
+}
+
+// run executes a service manager command, including its output in the returned error.
+func run(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %v: %w: %s", name, args, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/hibare/arclift/internal/constants"
+)
+
+const (
+	launchdDaemonDir = "/Library/LaunchDaemons"
+	launchdLogPath   = "/var/log/arclift.log"
+)
+
+var launchdPlistTemplate = template.Must(template.New("plist").Funcs(template.FuncMap{
+	"xml": func(s string) (string, error) {
+		var b bytes.Buffer
+		err := xml.EscapeText(&b, []byte(s))
+		return b.String(), err
+	},
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{ xml .Label }}</string>
+	<key>ProgramArguments</key>
+	<array>
+{{- range .Args }}
+		<string>{{ xml . }}</string>
+{{- end }}
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>{{ xml .LogPath }}</string>
+	<key>StandardErrorPath</key>
+	<string>{{ xml .LogPath }}</string>
+</dict>
+</plist>
+`))
+
+func launchdPlistPath() string {
+	return filepath.Join(launchdDaemonDir, constants.LaunchdLabel+".plist")
+}
+
+func install(ctx context.Context, opts Options) error {
+	var plist bytes.Buffer
+	if err := launchdPlistTemplate.Execute(&plist, map[string]any{
+		"Label":   constants.LaunchdLabel,
+		"Args":    append([]string{opts.Executable}, opts.Args()...),
+		"LogPath": launchdLogPath,
+	}); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(launchdPlistPath(), plist.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write launchd plist: %w", err)
+	}
+
+	return run(ctx, "launchctl", "load", "-w", launchdPlistPath())
+}
+
+func uninstall(ctx context.Context) error {
+	if _, err := os.Stat(launchdPlistPath()); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("service is not installed: %s", launchdPlistPath())
+	}
+
+	if err := run(ctx, "launchctl", "unload", "-w", launchdPlistPath()); err != nil {
+		return err
+	}
+	if err := os.Remove(launchdPlistPath()); err != nil {
+		return fmt.Errorf("failed to remove launchd plist: %w", err)
+	}
+	return nil
+}
+
+// start loads the daemon, which starts it since it runs at load. launchctl stop would only restart a KeepAlive daemon.
+func start(ctx context.Context) error {
+	return run(ctx, "launchctl", "load", launchdPlistPath())
+}
+
+// stop unloads the daemon until the next boot or start.
+func stop(ctx context.Context) error {
+	return run(ctx, "launchctl", "unload", launchdPlistPath())
+}
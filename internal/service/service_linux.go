@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hibare/arclift/internal/constants"
+)
+
+const systemdUnitDir = "/etc/systemd/system"
+
+var systemdUnitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description={{ .Description }}
+After=network.target
+StartLimitIntervalSec=0
+
+[Service]
+Type=simple
+Restart=always
+ExecStart={{ .ExecStart }}
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+func systemdUnitName() string {
+	return constants.ProgramIdentifier + ".service"
+}
+
+func install(ctx context.Context, opts Options) error {
+	args := make([]string, 0, len(opts.Args())+1)
+	for _, arg := range append([]string{opts.Executable}, opts.Args()...) {
+		args = append(args, strconv.Quote(arg))
+	}
+
+	var unit bytes.Buffer
+	if err := systemdUnitTemplate.Execute(&unit, map[string]string{
+		"Description": constants.ServiceDescription,
+		"ExecStart":   strings.Join(args, " "),
+	}); err != nil {
+		return err
+	}
+
+	unitPath := filepath.Join(systemdUnitDir, systemdUnitName())
+	if err := os.WriteFile(unitPath, unit.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write unit file: %w", err)
+	}
+
+	if err := run(ctx, "systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	return run(ctx, "systemctl", "enable", "--now", systemdUnitName())
+}
+
+func uninstall(ctx context.Context) error {
+	unitPath := filepath.Join(systemdUnitDir, systemdUnitName())
+	if _, err := os.Stat(unitPath); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("service is not installed: %s", unitPath)
+	}
+
+	if err := run(ctx, "systemctl", "disable", "--now", systemdUnitName()); err != nil {
+		return err
+	}
+	if err := os.Remove(unitPath); err != nil {
+		return fmt.Errorf("failed to remove unit file: %w", err)
+	}
+	return run(ctx, "systemctl", "daemon-reload")
+}
+
+func start(ctx context.Context) error {
+	return run(ctx, "systemctl", "start", systemdUnitName())
+}
+
+func stop(ctx context.Context) error {
+	return run(ctx, "systemctl", "stop", systemdUnitName())
+}
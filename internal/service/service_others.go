@@ -0,0 +1,21 @@
+//go:build !linux && !darwin && !windows
+
+package service
+
+import "context"
+
+func install(context.Context, Options) error {
+	return ErrUnsupportedPlatform
+}
+
+func uninstall(context.Context) error {
+	return ErrUnsupportedPlatform
+}
+
+func start(context.Context) error {
+	return ErrUnsupportedPlatform
+}
+
+func stop(context.Context) error {
+	return ErrUnsupportedPlatform
+}
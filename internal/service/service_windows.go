@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/hibare/arclift/internal/constants"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// openService connects to the service manager and opens the arclift service. close releases both.
+func openService() (*mgr.Service, func(), error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+
+	s, err := m.OpenService(constants.ProgramIdentifier)
+	if err != nil {
+		_ = m.Disconnect()
+		return nil, nil, fmt.Errorf("service is not installed: %w", err)
+	}
+
+	return s, func() {
+		_ = s.Close()
+		_ = m.Disconnect()
+	}, nil
+}
+
+func install(_ context.Context, opts Options) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer func() {
+		_ = m.Disconnect()
+	}()
+
+	if s, oErr := m.OpenService(constants.ProgramIdentifier); oErr == nil {
+		_ = s.Close()
+		return fmt.Errorf("service %s is already installed", constants.ProgramIdentifier)
+	}
+
+	s, err := m.CreateService(constants.ProgramIdentifier, opts.Executable, mgr.Config{
+		DisplayName: constants.ProgramPrettyIdentifier,
+		Description: constants.ServiceDescription,
+		StartType:   mgr.StartAutomatic,
+	}, opts.Args()...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer func() {
+		_ = s.Close()
+	}()
+
+	if err := installEventLog(); err != nil {
+		slog.Warn("Failed to register event log source", "error", err)
+	}
+
+	return s.Start()
+}
+
+func uninstall(_ context.Context) error {
+	s, closeService, err := openService()
+	if err != nil {
+		return err
+	}
+	defer closeService()
+
+	// The service may already be stopped, deletion proceeds regardless.
+	_, _ = s.Control(svc.Stop)
+
+	if err := removeEventLog(); err != nil {
+		slog.Warn("Failed to remove event log source", "error", err)
+	}
+
+	return s.Delete()
+}
+
+func start(_ context.Context) error {
+	s, closeService, err := openService()
+	if err != nil {
+		return err
+	}
+	defer closeService()
+
+	return s.Start()
+}
+
+func stop(_ context.Context) error {
+	s, closeService, err := openService()
+	if err != nil {
+		return err
+	}
+	defer closeService()
+
+	if _, err := s.Control(svc.Stop); err != nil {
+		return fmt.Errorf("failed to stop service: %w", err)
+	}
+	return nil
+}
+
+// handler adapts the scheduler to the Windows service control manager.
+type handler struct {
+	ctx context.Context //nolint:containedctx // the SCM callback has no context parameter
+	run func(context.Context) error
+}
+
+func (h *handler) Execute(_ []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(h.ctx)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- h.run(ctx)
+	}()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case err := <-errCh:
+			if err != nil {
+				slog.ErrorContext(ctx, "Service stopped with error", "error", err)
+				return false, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd { //nolint:exhaustive // only the accepted commands are handled
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				cancel()
+				<-errCh
+				return false, 0
+			}
+		}
+	}
+}
+
+// RunIfService runs fn under the Windows service control manager when the process was started as a service.
+// It reports whether the process was running as a service.
+func RunIfService(ctx context.Context, fn func(context.Context) error) (bool, error) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return false, err
+	}
+
+	// Log to the event log as well, since a service has no console.
+	if elog, err = eventlog.Open(constants.ProgramIdentifier); err == nil {
+		defer func() {
+			_ = elog.Close()
+		}()
+		AttachEventLog()
+	}
+
+	return true, svc.Run(constants.ProgramIdentifier, &handler{ctx: ctx, run: fn})
+}
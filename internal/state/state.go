@@ -0,0 +1,272 @@
+// Package state provides a small JSON backed store for persisting local run state.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	stateFileName = "state.json"
+
+	// maxDirHistory is the number of records retained per directory.
+	maxDirHistory = 50
+
+	// maxRunHistory is the number of run records retained in the journal.
+	maxRunHistory = 200
+)
+
+const (
+	// StatusSuccess marks a successful operation.
+	StatusSuccess = "success"
+
+	// StatusFailure marks a failed operation.
+	StatusFailure = "failure"
+
+	// StatusPartial marks a run where only some of the work succeeded.
+	StatusPartial = "partial"
+
+	// StatusInterrupted marks a run stopped before it completed, e.g. by a shutdown.
+	StatusInterrupted = "interrupted"
+
+	// StatusUnchanged marks a directory that was not backed up again, as it did not change since its last backup.
+	StatusUnchanged = "unchanged"
+)
+
+const (
+	// OperationBackup is a backup run.
+	OperationBackup = "backup"
+
+	// OperationPurge is a purge run.
+	OperationPurge = "purge"
+)
+
+// DirRecord is the result of backing up a single directory.
+type DirRecord struct {
+	Dir          string         `json:"dir"`
+	Key          string         `json:"key,omitempty"`
+	Status       string         `json:"status"`
+	Error        string         `json:"error,omitempty"`
+	TotalDirs    int            `json:"total_dirs"`
+	TotalFiles   int            `json:"total_files"`
+	SuccessFiles int            `json:"success_files"`
+	Bytes        int64          `json:"bytes"`
+	Skipped      map[string]int `json:"skipped,omitempty"`
+	TooLarge     []SkippedFile  `json:"too_large,omitempty"`
+	Fingerprint  string         `json:"fingerprint,omitempty"`
+	StartedAt    time.Time      `json:"started_at"`
+	FinishedAt   time.Time      `json:"finished_at"`
+}
+
+// SkippedFile is a file left out of a backup.
+type SkippedFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Duration returns how long the directory backup took.
+func (r DirRecord) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// RunRecord is a journal entry for a single backup or purge run.
+type RunRecord struct {
+	Operation  string         `json:"operation"`
+	Job        string         `json:"job,omitempty"`
+	Status     string         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	Dirs       int            `json:"dirs,omitempty"`
+	FailedDirs int            `json:"failed_dirs,omitempty"`
+	Unchanged  int            `json:"unchanged,omitempty"`
+	Deleted    int            `json:"deleted,omitempty"`
+	Failed     int            `json:"failed,omitempty"`
+	Bytes      int64          `json:"bytes"`
+	Skipped    map[string]int `json:"skipped,omitempty"`
+	ColdFailed int            `json:"cold_failed,omitempty"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+}
+
+// AddSkipped adds per category skipped entry counts to the run.
+func (r *RunRecord) AddSkipped(skipped map[string]int) {
+	for category, n := range skipped {
+		if r.Skipped == nil {
+			r.Skipped = map[string]int{}
+		}
+		r.Skipped[category] += n
+	}
+}
+
+// Duration returns how long the run took.
+func (r RunRecord) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// errCorrupt is returned when the state file cannot be decoded.
+var errCorrupt = errors.New("corrupt state file")
+
+// State is the persisted state.
+type State struct {
+	Dirs map[string][]DirRecord `json:"dirs"`
+	Runs []RunRecord            `json:"runs"`
+}
+
+// StoreIface defines the interface for the local state store.
+type StoreIface interface {
+	// RecordDir appends a directory result to the history of that directory.
+	RecordDir(ctx context.Context, rec DirRecord) error
+
+	// RecordRun appends a run to the journal.
+	RecordRun(ctx context.Context, rec RunRecord) error
+
+	// Load returns a snapshot of the persisted state.
+	Load(ctx context.Context) (State, error)
+}
+
+// Store persists state as a JSON file inside a directory.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func (s *Store) path() string {
+	return filepath.Join(s.dir, stateFileName)
+}
+
+func (s *Store) read() (State, error) {
+	st := State{Dirs: map[string][]DirRecord{}}
+
+	data, err := os.ReadFile(s.path())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return st, nil
+		}
+		return st, err
+	}
+
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("%w: %w", errCorrupt, err)
+	}
+
+	if st.Dirs == nil {
+		st.Dirs = map[string][]DirRecord{}
+	}
+
+	return st, nil
+}
+
+func (s *Store) write(st State) error {
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated state file.
+	tmp, err := os.CreateTemp(s.dir, stateFileName+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path())
+}
+
+// readForUpdate reads the state to append a record to. A corrupt state file is moved aside, so its history can still
+// be recovered by hand, and the state starts fresh; other errors are returned, so the history is not overwritten.
+func (s *Store) readForUpdate(ctx context.Context) (State, error) {
+	st, err := s.read()
+	if !errors.Is(err, errCorrupt) {
+		return st, err
+	}
+
+	moved := s.path() + ".corrupt-" + time.Now().Format("20060102150405")
+	if rErr := os.Rename(s.path(), moved); rErr != nil {
+		return st, errors.Join(err, rErr)
+	}
+	slog.WarnContext(ctx, "Moved corrupt state aside, starting fresh", "path", moved, "error", err)
+	return State{Dirs: map[string][]DirRecord{}}, nil
+}
+
+// RecordDir appends a directory result to the history of that directory.
+func (s *Store) RecordDir(ctx context.Context, rec DirRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.readForUpdate(ctx)
+	if err != nil {
+		return err
+	}
+
+	history := append([]DirRecord{rec}, st.Dirs[rec.Dir]...)
+	if len(history) > maxDirHistory {
+		history = history[:maxDirHistory]
+	}
+	st.Dirs[rec.Dir] = history
+
+	return s.write(st)
+}
+
+// RecordRun appends a run to the journal.
+func (s *Store) RecordRun(ctx context.Context, rec RunRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.readForUpdate(ctx)
+	if err != nil {
+		return err
+	}
+
+	st.Runs = append([]RunRecord{rec}, st.Runs...)
+	if len(st.Runs) > maxRunHistory {
+		st.Runs = st.Runs[:maxRunHistory]
+	}
+
+	return s.write(st)
+}
+
+// Load returns a snapshot of the persisted state.
+func (s *Store) Load(_ context.Context) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+// noopStore is used when state persistence is disabled.
+type noopStore struct{}
+
+func (noopStore) RecordDir(context.Context, DirRecord) error { return nil }
+
+func (noopStore) RecordRun(context.Context, RunRecord) error { return nil }
+
+func (noopStore) Load(context.Context) (State, error) {
+	return State{Dirs: map[string][]DirRecord{}}, nil
+}
+
+// NewStore creates a new state store rooted at dir. An empty dir returns a store that persists nothing.
+func NewStore(dir string) StoreIface {
+	if dir == "" {
+		return noopStore{}
+	}
+	return &Store{dir: dir}
+}
@@ -0,0 +1,84 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Record(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	st, err := s.Load(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, st.Dirs)
+	assert.Empty(t, st.Runs)
+
+	for i := range maxDirHistory + 2 {
+		require.NoError(t, s.RecordDir(t.Context(), DirRecord{Dir: "/data", Status: StatusSuccess, TotalFiles: i}))
+	}
+	require.NoError(t, s.RecordDir(t.Context(), DirRecord{Dir: "/home", Status: StatusFailure, Error: "upload failed"}))
+	require.NoError(t, s.RecordRun(t.Context(), RunRecord{Operation: OperationBackup, Status: StatusSuccess}))
+	require.NoError(t, s.RecordRun(t.Context(), RunRecord{Operation: OperationPurge, Status: StatusSuccess}))
+
+	st, err = s.Load(t.Context())
+	require.NoError(t, err)
+	require.Len(t, st.Dirs["/data"], maxDirHistory, "the history of a dir is capped")
+	assert.Equal(t, maxDirHistory+1, st.Dirs["/data"][0].TotalFiles, "newest first")
+	require.Len(t, st.Dirs["/home"], 1)
+	assert.Equal(t, "upload failed", st.Dirs["/home"][0].Error)
+	require.Len(t, st.Runs, 2)
+	assert.Equal(t, OperationPurge, st.Runs[0].Operation, "newest first")
+}
+
+func TestStore_Record_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, stateFileName)
+	require.NoError(t, os.WriteFile(path, []byte(`{"dirs": {`), 0o600))
+	s := NewStore(dir)
+
+	_, err := s.Load(t.Context())
+	require.ErrorIs(t, err, errCorrupt)
+
+	// The corrupt file is kept aside, and the state starts fresh.
+	require.NoError(t, s.RecordDir(t.Context(), DirRecord{Dir: "/data", Status: StatusSuccess}))
+	moved, err := filepath.Glob(path + ".corrupt-*")
+	require.NoError(t, err)
+	require.Len(t, moved, 1)
+	data, err := os.ReadFile(moved[0])
+	require.NoError(t, err)
+	assert.Equal(t, `{"dirs": {`, string(data))
+
+	st, err := s.Load(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, st.Dirs["/data"], 1)
+}
+
+func TestStore_Record_Unreadable(t *testing.T) {
+	dir := t.TempDir()
+	// A directory in place of the state file cannot be read, whoever runs the test.
+	path := filepath.Join(dir, stateFileName)
+	require.NoError(t, os.Mkdir(path, 0o750))
+	s := NewStore(dir)
+
+	require.Error(t, s.RecordDir(t.Context(), DirRecord{Dir: "/data", Status: StatusSuccess}))
+	require.Error(t, s.RecordRun(t.Context(), RunRecord{Operation: OperationBackup, Status: StatusSuccess}))
+	assert.DirExists(t, path, "the state is not replaced")
+	moved, err := filepath.Glob(path + ".corrupt-*")
+	require.NoError(t, err)
+	assert.Empty(t, moved)
+}
+
+func TestNewStore_Disabled(t *testing.T) {
+	s := NewStore("")
+	require.NoError(t, s.RecordDir(t.Context(), DirRecord{Dir: "/data"}))
+	require.NoError(t, s.RecordRun(t.Context(), RunRecord{Operation: OperationBackup}))
+
+	st, err := s.Load(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, st.Dirs)
+	assert.Empty(t, st.Runs)
+}
@@ -0,0 +1,163 @@
+// Package exec provides an implementation of storage interface that runs a user provided program for every storage
+// operation, so backups can be stored in destinations arclift does not support.
+//
+// The program is run with the configured arguments followed by the operation and its operand:
+//
+//	<command> [args...] upload <key>    stores the object read from stdin at key
+//	<command> [args...] download <key>  writes the object at key to stdout
+//	<command> [args...] list <prefix>   writes the objects whose key starts with prefix, recursively, to stdout
+//	<command> [args...] delete <key>    deletes the object at key; deleting a missing object succeeds
+//
+// list writes a JSON array of objects such as {"key": "host/20240131000000/etc.tar.gz", "size": 1024,
+// "last_modified": "2024-01-31T00:00:00Z", "etag": "..."}; only key is required. An operation fails when the program
+// exits with a non-zero status; its stderr is logged. Exiting with 75 (EX_TEMPFAIL), such as when the destination is
+// unreachable, reports the storage as unavailable, see storage.ErrStorageUnavailable.
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	osExec "os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/storage/objects"
+)
+
+// Operations of the protocol, see the package documentation.
+const (
+	opUpload   = "upload"
+	opDownload = "download"
+	opList     = "list"
+	opDelete   = "delete"
+)
+
+// ErrCommandFailed is returned when the storage program exits with a non-zero status.
+var ErrCommandFailed = errors.New("storage command failed")
+
+// exitUnavailable is the exit status, EX_TEMPFAIL of sysexits.h, of a storage program reporting the storage as
+// unavailable.
+const exitUnavailable = 75
+
+// object is an entry of the list output.
+type object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	ETag         string    `json:"etag"`
+}
+
+// Exec is an object store running the configured program, see objects.Store.
+type Exec struct {
+	cfg  config.ExecStorageConfig
+	path string
+}
+
+// Init resolves the storage program.
+func (e *Exec) Init(_ context.Context) error {
+	path, err := osExec.LookPath(e.cfg.Command)
+	if err != nil {
+		return fmt.Errorf("storage command: %w", err)
+	}
+	e.path = path
+	return nil
+}
+
+// Name returns the name of the storage backend.
+func (e *Exec) Name() string {
+	return fmt.Sprintf("exec (%s)", filepath.Base(e.cfg.Command))
+}
+
+// run runs the storage program for op on operand, with stdin and stdout connected to the given reader and writer.
+func (e *Exec) run(ctx context.Context, stdin io.Reader, stdout io.Writer, op, operand string) error {
+	parent := ctx
+	if e.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.cfg.Timeout)
+		defer cancel()
+	}
+
+	args := append(slices.Clone(e.cfg.Args), op, operand)
+	cmd := osExec.CommandContext(ctx, e.path, args...)
+	var stderr bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, &stderr
+
+	err := cmd.Run()
+	msg := strings.TrimSpace(stderr.String())
+	if msg != "" {
+		slog.DebugContext(ctx, "Storage command output", "operation", op, "key", operand, "stderr", msg)
+	}
+
+	var exitErr *osExec.ExitError
+	// The program exceeding the storage timeout is taken as a hung destination.
+	if (errors.As(err, &exitErr) && exitErr.ExitCode() == exitUnavailable) || (err != nil && ctx.Err() != nil && parent.Err() == nil) {
+		err = fmt.Errorf("%w: %w", storage.ErrStorageUnavailable, err)
+	}
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &exitErr) && msg != "":
+		// The last line of stderr usually tells why the program failed.
+		msg = msg[strings.LastIndex(msg, "\n")+1:]
+		return fmt.Errorf("%w: %s %s: %w: %s", ErrCommandFailed, op, operand, err, msg)
+	case errors.As(err, &exitErr):
+		return fmt.Errorf("%w: %s %s: %w", ErrCommandFailed, op, operand, err)
+	default:
+		return fmt.Errorf("%s %s: %w", op, operand, err)
+	}
+}
+
+// Put stores body at key.
+func (e *Exec) Put(ctx context.Context, key string, body io.Reader) error {
+	return e.run(ctx, body, io.Discard, opUpload, key)
+}
+
+// Get returns the object at key, streamed from the storage program. Reading it fails when the program does.
+func (e *Exec) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(e.run(ctx, nil, pw, opDownload, key))
+	}()
+	return pr, nil
+}
+
+// List returns every object whose key starts with prefix.
+func (e *Exec) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	var out bytes.Buffer
+	if err := e.run(ctx, nil, &out, opList, prefix); err != nil {
+		return nil, err
+	}
+
+	var listed []object
+	if err := json.Unmarshal(out.Bytes(), &listed); err != nil {
+		return nil, fmt.Errorf("%w: invalid list output: %w", ErrCommandFailed, err)
+	}
+
+	infos := make([]storage.ObjectInfo, 0, len(listed))
+	for _, o := range listed {
+		if !strings.HasPrefix(o.Key, prefix) {
+			continue
+		}
+		infos = append(infos, storage.ObjectInfo{Key: o.Key, Size: o.Size, LastModified: o.LastModified, ETag: o.ETag})
+	}
+	return infos, nil
+}
+
+// Delete deletes the object at key.
+func (e *Exec) Delete(ctx context.Context, key string) error {
+	return e.run(ctx, nil, io.Discard, opDelete, key)
+}
+
+// NewExecStorage creates a new storage running the exec program configured in cfg.
+func NewExecStorage(cfg *config.Config) *objects.Storage {
+	return objects.New(cfg, &Exec{cfg: cfg.Storage.Exec})
+}
@@ -0,0 +1,329 @@
+// Package objects implements the storage interface on top of a minimal object store, so a storage backend only has
+// to upload, download, list and delete single objects.
+package objects
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/naming"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/walk"
+)
+
+// Store is a flat object store. Keys are full object keys, with the host prefix.
+type Store interface {
+	// Init prepares the store
+	Init(ctx context.Context) error
+
+	// Name returns the name of the store
+	Name() string
+
+	// Put stores body at key
+	Put(ctx context.Context, key string, body io.Reader) error
+
+	// Get returns the object at key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// List returns every object whose key starts with prefix, recursively
+	List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error)
+
+	// Delete deletes the object at key; deleting a missing object succeeds
+	Delete(ctx context.Context, key string) error
+}
+
+// Storage implements the StorageIface on a Store, naming backups with the key layout of the config.
+type Storage struct {
+	cfg   *config.Config
+	store Store
+	keys  naming.KeyLayout
+}
+
+// Init prepares the store and parses the key layout.
+func (s *Storage) Init(ctx context.Context) error {
+	if err := s.store.Init(ctx); err != nil {
+		return err
+	}
+
+	keys, err := s.cfg.KeyLayout()
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
+
+// Name returns the name of the store.
+func (s *Storage) Name() string {
+	return s.store.Name()
+}
+
+// hostPrefix returns the prefix holding every backup of this host, ending with a slash, see config.KeyLayout.
+func (s *Storage) hostPrefix() string {
+	return s.keys.Dir
+}
+
+// timestampedPrefix returns the prefix of a new backup, ending with a slash.
+func (s *Storage) timestampedPrefix() string {
+	return s.keys.Dir + s.keys.Format(time.Now()) + "/"
+}
+
+// upload stores body at key, reporting its progress.
+func (s *Storage) upload(ctx context.Context, key string, body io.Reader) error {
+	slog.DebugContext(ctx, "Uploading object", "key", key, "storage", s.Name())
+	return s.store.Put(ctx, key, storage.ProgressReader(ctx, body))
+}
+
+// Put writes body to the object at key, relative to the host prefix.
+func (s *Storage) Put(ctx context.Context, key string, body io.Reader) error {
+	return s.store.Put(ctx, s.hostPrefix()+key, body)
+}
+
+// UploadFile uploads a local file and returns the remote key.
+func (s *Storage) UploadFile(ctx context.Context, localPath string) (string, error) {
+	key := s.timestampedPrefix() + filepath.Base(localPath)
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if err := s.upload(ctx, key, f); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// UploadDir uploads a local directory, one file at a time, and returns the remote key. Entries that cannot be
+// uploaded, such as sockets and unreadable files, are skipped and reported.
+func (s *Storage) UploadDir(ctx context.Context, localPath string, opts walk.Options) (storage.UploadDirResponse, error) {
+	return s.uploadDir(ctx, localPath, s.timestampedPrefix(), opts, nil)
+}
+
+// SyncDir uploads the files of a local directory that unchanged does not report as stored into the backup at key.
+func (s *Storage) SyncDir(
+	ctx context.Context, localPath, key string, opts walk.Options, unchanged storage.Unchanged,
+) (storage.UploadDirResponse, error) {
+	return s.uploadDir(ctx, localPath, s.hostPrefix()+key+"/", opts, unchanged)
+}
+
+// uploadDir uploads a local directory under prefix, skipping the files unchanged, when set, reports as stored.
+func (s *Storage) uploadDir(
+	ctx context.Context, localPath, prefix string, opts walk.Options, unchanged storage.Unchanged,
+) (storage.UploadDirResponse, error) {
+	localPath = filepath.Clean(localPath)
+	base := filepath.Base(localPath)
+
+	resp := storage.UploadDirResponse{FailedFiles: map[string]error{}, Checksums: map[string]string{}}
+	skipped, err := walk.Dir(ctx, localPath, opts, func(path, rel string, _ fs.DirEntry, f *os.File) error {
+		if f == nil {
+			resp.TotalDirs++
+			return nil
+		}
+		resp.TotalFiles++
+
+		key := prefix + base + "/" + rel
+		if path == localPath {
+			// A single file is stored as the backup itself.
+			key = prefix + base
+		}
+		if unchanged != nil && unchanged(key, f) {
+			resp.Unchanged++
+			resp.SuccessFiles++
+			return nil
+		}
+		sum, cErr := storage.Checksum(f)
+		if cErr != nil {
+			resp.FailedFiles[path] = cErr
+			return nil
+		}
+		if uErr := s.upload(ctx, key, f); uErr != nil {
+			resp.FailedFiles[path] = uErr
+			return nil
+		}
+		resp.Checksums[key] = sum
+		resp.SuccessFiles++
+		if info, sErr := f.Stat(); sErr == nil {
+			resp.Bytes += info.Size()
+		}
+		return nil
+	})
+
+	resp.Skipped = skipped
+	if resp.SuccessFiles > 0 {
+		resp.BaseKey = prefix + base
+	}
+	// On error, BaseKey holds the partial upload, if any, for the caller to clean up.
+	return resp, err
+}
+
+// below returns the objects at key and below it. A listing of the prefix key also matches siblings such as <key>2/,
+// which belong to other backups.
+func (s *Storage) below(ctx context.Context, key string) ([]storage.ObjectInfo, error) {
+	objects, err := s.store.List(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	dir := strings.TrimSuffix(key, "/") + "/"
+	return slices.DeleteFunc(objects, func(o storage.ObjectInfo) bool {
+		return o.Key != key && !strings.HasPrefix(o.Key, dir)
+	}), nil
+}
+
+// List returns the backups and auxiliary directories under the host prefix, like a delimited S3 listing: backups
+// stored as a single object by their key, and the others by the key of their directory, ending with a slash, see
+// storage.ListedKey.
+func (s *Storage) List(ctx context.Context) ([]string, error) {
+	prefix := s.hostPrefix()
+	objects, err := s.store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var keys []string
+	for _, obj := range objects {
+		rel := storage.ListedKey(s.keys, strings.TrimPrefix(obj.Key, prefix))
+		if rel == "" || seen[rel] || !storage.Owns(s.keys, rel) {
+			continue
+		}
+		seen[rel] = true
+		keys = append(keys, prefix+rel)
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// ListObjects returns every object, recursively, under the host prefix, sorted by key.
+func (s *Storage) ListObjects(ctx context.Context) ([]storage.ObjectInfo, error) {
+	prefix := s.hostPrefix()
+	objects, err := s.store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	objects = slices.DeleteFunc(objects, func(o storage.ObjectInfo) bool {
+		return !strings.HasPrefix(o.Key, prefix) || !storage.Owns(s.keys, strings.TrimPrefix(o.Key, prefix))
+	})
+	slices.SortFunc(objects, func(a, b storage.ObjectInfo) int { return strings.Compare(a.Key, b.Key) })
+	return objects, nil
+}
+
+// ListDetailed returns the size and object count of every backup under the host prefix.
+func (s *Storage) ListDetailed(ctx context.Context) ([]storage.BackupDetail, error) {
+	objects, err := s.ListObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return storage.Details(s.keys, s.hostPrefix(), objects), nil
+}
+
+// Download downloads the object at key to localPath, creating parent directories as needed.
+func (s *Storage) Download(ctx context.Context, key, localPath string) error {
+	body, err := s.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o750); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, storage.ProgressReader(ctx, body)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Copy copies the objects under key to the same backup key in dst.
+func (s *Storage) Copy(ctx context.Context, key string, dst storage.StorageIface) error {
+	return s.copyObjects(ctx, key, dst, func(objKey string) string {
+		return strings.TrimPrefix(objKey, s.hostPrefix())
+	})
+}
+
+// CopyTo copies the objects under key, relative to the host prefix, to dstKey, relative to the host prefix of dst.
+func (s *Storage) CopyTo(ctx context.Context, key string, dst storage.StorageIface, dstKey string) error {
+	srcKey := s.hostPrefix() + key
+	return s.copyObjects(ctx, srcKey, dst, func(objKey string) string {
+		return dstKey + strings.TrimPrefix(objKey, srcKey)
+	})
+}
+
+// copyObjects copies key and the objects below it to dst, at the keys, relative to the host prefix of dst, returned
+// by dstKey. Every object is downloaded and written to dst with Put, so dst may be any storage backend.
+func (s *Storage) copyObjects(ctx context.Context, key string, dst storage.StorageIface, dstKey func(objKey string) string) error {
+	objects, err := s.below(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		to := dstKey(obj.Key)
+		slog.DebugContext(ctx, "Copying object", "key", obj.Key, "storage", dst.Name(), "dst", to)
+		body, err := s.store.Get(ctx, obj.Key)
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %w", obj.Key, err)
+		}
+		err = dst.Put(ctx, to, body)
+		_ = body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %w", obj.Key, err)
+		}
+	}
+	return nil
+}
+
+// Delete deletes the backup at key, relative to the host prefix, and every object below it. Only listed objects are
+// deleted, so stores backed by a file system are not asked to delete directories.
+func (s *Storage) Delete(ctx context.Context, timestamp string) error {
+	objects, err := s.below(ctx, path.Join(s.hostPrefix(), timestamp))
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := s.store.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TrimPrefix trims the host prefix from the given keys, if present.
+func (s *Storage) TrimPrefix(keys []string) []string {
+	trimmed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		trimmed = append(trimmed, strings.TrimSuffix(strings.TrimPrefix(key, s.hostPrefix()), "/"))
+	}
+	return trimmed
+}
+
+// New creates a new Storage storing the backups of cfg in store.
+func New(cfg *config.Config, store Store) *Storage {
+	return &Storage{
+		cfg:   cfg,
+		store: store,
+	}
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// Progress receives the progress of the transfers made with a context, see WithProgress. It must be safe for
+// concurrent use, as files may be transferred concurrently.
+type Progress interface {
+	// Expect announces total more bytes to be transferred.
+	Expect(total int64)
+
+	// Add reports n more bytes transferred. n is negative when a transfer is rewound to be retried.
+	Add(n int64)
+}
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx whose uploads and downloads of backup data are reported to p. Backends report
+// the bytes they transfer; callers that know how much will be transferred announce it with Expect.
+func WithProgress(ctx context.Context, p Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// ProgressFrom returns the progress of ctx, or nil when the transfers made with it are not reported.
+func ProgressFrom(ctx context.Context) Progress {
+	p, _ := ctx.Value(progressKey{}).(Progress)
+	return p
+}
+
+// ProgressReader returns r reporting the bytes read to the progress of ctx, or r itself when there is none. When r is
+// an io.ReadSeeker, so is the returned reader: clients that rewind a body to retry it are not counted twice.
+func ProgressReader(ctx context.Context, r io.Reader) io.Reader {
+	p := ProgressFrom(ctx)
+	if p == nil {
+		return r
+	}
+	pr := &progressReader{r: r, p: p}
+	if s, ok := r.(io.Seeker); ok {
+		return &progressReadSeeker{progressReader: pr, s: s}
+	}
+	return pr
+}
+
+type progressReader struct {
+	r   io.Reader
+	p   Progress
+	pos int64
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.pos += int64(n)
+	r.p.Add(int64(n))
+	return n, err
+}
+
+type progressReadSeeker struct {
+	*progressReader
+	s io.Seeker
+}
+
+func (r *progressReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.s.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	r.p.Add(pos - r.pos)
+	r.pos = pos
+	return pos, nil
+}
@@ -0,0 +1,230 @@
+// Package rclone provides an implementation of storage interface driving an rclone remote control daemon, so any
+// rclone remote, such as Google Drive, Dropbox or OneDrive, can store backups.
+//
+// The daemon is started with `rclone rcd --rc-serve`; --rc-serve is required to download objects.
+package rclone
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/storage/objects"
+)
+
+var (
+	// ErrRclone is returned when the rclone daemon fails a request.
+	ErrRclone = errors.New("rclone request failed")
+
+	// errNotFound is returned when the directory or object of a request does not exist, which rclone answers with
+	// 404. Listing and deleting ignore it.
+	errNotFound = errors.New("not found")
+)
+
+// listItem is an entry of the operations/list response.
+type listItem struct {
+	Path    string    `json:"Path"`
+	Size    int64     `json:"Size"`
+	ModTime time.Time `json:"ModTime"`
+	IsDir   bool      `json:"IsDir"`
+}
+
+// Rclone is an object store on the configured remote of an rclone daemon, see objects.Store.
+type Rclone struct {
+	cfg    config.RcloneStorageConfig
+	client *http.Client
+}
+
+// Init checks that the daemon is reachable.
+func (r *Rclone) Init(ctx context.Context) error {
+	if err := r.call(ctx, "rc/noop", map[string]any{}, nil); err != nil {
+		return fmt.Errorf("failed to reach rclone at %s: %w", r.cfg.URL, err)
+	}
+	return nil
+}
+
+// Name returns the name of the storage backend.
+func (r *Rclone) Name() string {
+	return fmt.Sprintf("rclone (%s)", r.cfg.Remote)
+}
+
+// do sends req with the configured credentials, returning the response when it succeeds.
+func (r *Rclone) do(req *http.Request) (*http.Response, error) {
+	if r.cfg.Username != "" {
+		req.SetBasicAuth(r.cfg.Username, r.cfg.Password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		if req.Context().Err() == nil {
+			err = fmt.Errorf("%w: %w", storage.ErrStorageUnavailable, err)
+		}
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var rcErr struct {
+		Error string `json:"error"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(body, &rcErr) != nil || rcErr.Error == "" {
+		rcErr.Error = strings.TrimSpace(string(body))
+	}
+	err = fmt.Errorf("%w: %s %s: %s: %s", ErrRclone, req.Method, req.URL.Path, resp.Status, rcErr.Error)
+	// rclone fails requests with 500; the gateway errors come from a proxy in front of an unreachable daemon.
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		err = fmt.Errorf("%w: %w", errNotFound, err)
+	case resp.StatusCode >= http.StatusBadGateway:
+		err = fmt.Errorf("%w: %w", storage.ErrStorageUnavailable, err)
+	}
+	return nil, err
+}
+
+// call calls the rc method with the JSON params, decoding the response into out unless it is nil.
+func (r *Rclone) call(ctx context.Context, method string, params any, out any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(r.cfg.URL, "/")+"/"+method,
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Put stores body at key with operations/uploadfile, streaming it as a multipart form.
+func (r *Rclone) Put(ctx context.Context, key string, body io.Reader) error {
+	dir, name := path.Split(key)
+	query := url.Values{"fs": {r.cfg.Remote}, "remote": {strings.TrimSuffix(dir, "/")}}
+
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		part, err := form.CreateFormFile("file0", name)
+		if err == nil {
+			_, err = io.Copy(part, body)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(r.cfg.URL, "/")+"/operations/uploadfile?"+query.Encode(), pr)
+	if err != nil {
+		_ = pr.CloseWithError(err)
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := r.do(req)
+	_ = pr.CloseWithError(err)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Get returns the object at key, served by the daemon started with --rc-serve.
+func (r *Rclone) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	target := strings.TrimSuffix(r.cfg.URL, "/") + "/" + url.PathEscape("["+r.cfg.Remote+"]") + "/" +
+		(&url.URL{Path: key}).EscapedPath()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// fs returns the rclone fs of dir, relative to the configured remote.
+func (r *Rclone) fs(dir string) string {
+	if dir == "" || strings.HasSuffix(r.cfg.Remote, ":") || strings.HasSuffix(r.cfg.Remote, "/") {
+		return r.cfg.Remote + dir
+	}
+	return r.cfg.Remote + "/" + dir
+}
+
+// List returns every object whose key starts with prefix. The directory of prefix is listed recursively, as rclone
+// lists directories rather than key prefixes.
+func (r *Rclone) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	dir, _ := path.Split(prefix)
+	var out struct {
+		List []listItem `json:"list"`
+	}
+	// Listed paths are relative to the fs, so it is the directory itself.
+	err := r.call(ctx, "operations/list", map[string]any{
+		"fs":     r.fs(strings.TrimSuffix(dir, "/")),
+		"remote": "",
+		"opt":    map[string]any{"recurse": true, "filesOnly": true, "noMimeType": true},
+	}, &out)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var objects []storage.ObjectInfo
+	for _, item := range out.List {
+		key := dir + item.Path
+		if item.IsDir || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		objects = append(objects, storage.ObjectInfo{Key: key, Size: item.Size, LastModified: item.ModTime})
+	}
+	return objects, nil
+}
+
+// Delete deletes the object at key.
+func (r *Rclone) Delete(ctx context.Context, key string) error {
+	err := r.call(ctx, "operations/deletefile", map[string]any{"fs": r.cfg.Remote, "remote": key}, nil)
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	return err
+}
+
+// NewRcloneStorage creates a new storage on the rclone remote configured in cfg, reached through the configured proxy.
+func NewRcloneStorage(cfg *config.Config) *objects.Storage {
+	transport := cfg.Proxy.Transport()
+	transport.ResponseHeaderTimeout = cfg.Storage.Rclone.Timeout
+	return objects.New(cfg, &Rclone{cfg: cfg.Storage.Rclone, client: &http.Client{Transport: transport}})
+}
@@ -0,0 +1,74 @@
+package rclone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRclone returns an rclone store on a daemon failing every request with status and the rc error message.
+func newTestRclone(t *testing.T, status int, message string) *Rclone {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": message, "status": status, "path": r.URL.Path})
+	}))
+	t.Cleanup(srv.Close)
+
+	return &Rclone{cfg: config.RcloneStorageConfig{URL: srv.URL, Remote: "remote:backups"}, client: srv.Client()}
+}
+
+func TestRclone_NotFound(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		message string
+		wantErr error
+	}{
+		{name: "missing", status: http.StatusNotFound, message: "object not found"},
+		{name: "missing, other message", status: http.StatusNotFound, message: "file does not exist"},
+		{name: "failed", status: http.StatusInternalServerError, message: "object not found in cache", wantErr: ErrRclone},
+		{name: "unavailable", status: http.StatusBadGateway, message: "bad gateway", wantErr: storage.ErrStorageUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRclone(t, tt.status, tt.message)
+
+			objects, err := r.List(t.Context(), "host/")
+			deleteErr := r.Delete(t.Context(), "host/20240101000000/data.tar.gz")
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				assert.Empty(t, objects)
+				require.NoError(t, deleteErr)
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+			require.ErrorIs(t, deleteErr, tt.wantErr)
+		})
+	}
+}
+
+func TestNewRcloneStorage_Proxy(t *testing.T) {
+	var proxied bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.Host == "rclone.internal:5572"
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(proxy.Close)
+
+	s := NewRcloneStorage(&config.Config{
+		Storage: config.StorageConfig{Rclone: config.RcloneStorageConfig{URL: "http://rclone.internal:5572", Remote: "remote:"}},
+		Proxy:   config.ProxyConfig{URL: proxy.URL},
+	})
+	require.NoError(t, s.Init(t.Context()))
+	assert.True(t, proxied, "the rc request went through the proxy")
+}
@@ -0,0 +1,75 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// defaultRegion is the region whose buckets are created without a location constraint.
+const defaultRegion = "us-east-1"
+
+// isBucketMissing reports whether err is the failure of a request to a bucket that does not exist.
+func isBucketMissing(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "NotFound", "NoSuchBucket":
+		return true
+	}
+	return false
+}
+
+// ensureBucket creates the bucket, in the configured region and with the configured versioning and Object Lock, when
+// it does not exist. An existing bucket is left as it is.
+func (s *S3) ensureBucket(ctx context.Context) error {
+	bucket := s.cfg.S3.Bucket
+	create := s.cfg.S3.CreateBucket
+
+	_, err := s.api.HeadBucket(ctx, &awsS3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		return nil
+	}
+	if !isBucketMissing(err) {
+		return fmt.Errorf("checking bucket %s: %w", bucket, err)
+	}
+
+	input := &awsS3.CreateBucketInput{Bucket: aws.String(bucket)}
+	if region := s.cfg.S3.Region; region != "" && region != defaultRegion {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	if create.ObjectLock {
+		input.ObjectLockEnabledForBucket = aws.Bool(true)
+	}
+
+	if _, err := s.api.CreateBucket(ctx, input); err != nil {
+		var owned *types.BucketAlreadyOwnedByYou
+		if !errors.As(err, &owned) {
+			return fmt.Errorf("creating bucket %s: %w", bucket, err)
+		}
+		// Another instance created it meanwhile.
+		return nil
+	}
+	slog.InfoContext(ctx, "Created bucket", "bucket", bucket, "region", s.cfg.S3.Region, "object_lock", create.ObjectLock)
+
+	if create.Versioning && !create.ObjectLock {
+		_, err := s.api.PutBucketVersioning(ctx, &awsS3.PutBucketVersioningInput{
+			Bucket:                  aws.String(bucket),
+			VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+		})
+		if err != nil {
+			return fmt.Errorf("enabling versioning of bucket %s: %w", bucket, err)
+		}
+	}
+	return nil
+}
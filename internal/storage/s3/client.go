@@ -0,0 +1,112 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/storage"
+)
+
+// newAPIClient builds the AWS SDK client of the storage.
+func newAPIClient(ctx context.Context, cfg *config.Config) (*s3.Client, error) {
+	optFns := []func(*s3.Options){
+		func(o *s3.Options) {
+			// Every request, including each file of an unarchived backup, is retried on its own. Retries are not
+			// rate limited, so a burst of throttling during a large upload does not exhaust them.
+			o.Retryer = retry.NewStandard(func(so *retry.StandardOptions) {
+				so.MaxAttempts = cfg.S3.Retry.MaxAttempts
+				so.MaxBackoff = cfg.S3.Retry.MaxBackoff
+				so.RateLimiter = ratelimit.None
+			})
+			o.APIOptions = append(o.APIOptions, addUnavailable)
+		},
+	}
+
+	if cfg.S3.Region != "" {
+		optFns = append(optFns, func(o *s3.Options) {
+			o.Region = cfg.S3.Region
+		})
+	}
+
+	if cfg.S3.AccessKey != "" && cfg.S3.SecretKey != "" {
+		optFns = append(optFns, func(o *s3.Options) {
+			o.Credentials = credentials.NewStaticCredentialsProvider(cfg.S3.AccessKey, cfg.S3.SecretKey, "")
+		})
+	}
+
+	if cfg.S3.Endpoint != "" {
+		optFns = append(optFns, func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(cfg.S3.Endpoint)
+		})
+	}
+
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	loadOpts := []func(*awsConfig.LoadOptions) error{awsConfig.WithHTTPClient(httpClient)}
+	if cfg.S3.Profile != "" {
+		loadOpts = append(loadOpts, awsConfig.WithSharedConfigProfile(cfg.S3.Profile))
+	}
+
+	awsCfg, err := awsConfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if wi := cfg.S3.WebIdentity; wi.RoleARN != "" {
+		stsClient := sts.NewFromConfig(awsCfg, func(o *sts.Options) {
+			if cfg.S3.Region != "" {
+				o.Region = cfg.S3.Region
+			}
+		})
+		provider := stscreds.NewWebIdentityRoleProvider(stsClient, wi.RoleARN, stscreds.IdentityTokenFile(wi.TokenFile),
+			func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = wi.SessionName
+			})
+		// The cache refreshes the credentials before they expire, re-reading the token file which may have rotated.
+		awsCfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return s3.NewFromConfig(awsCfg, optFns...), nil
+}
+
+// addUnavailable adds a middleware wrapping the errors of requests that could not reach S3, or that S3 failed on its
+// side, once retries are exhausted, with storage.ErrStorageUnavailable.
+func addUnavailable(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("ArcliftUnavailable",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+			middleware.InitializeOutput, middleware.Metadata, error,
+		) {
+			out, md, err := next.HandleInitialize(ctx, in)
+			// Errors of a cancelled or timed out run are not the storage's.
+			if err != nil && ctx.Err() == nil && unavailable(err) {
+				err = fmt.Errorf("%w: %w", storage.ErrStorageUnavailable, err)
+			}
+			return out, md, err
+		}), middleware.Before)
+}
+
+// unavailable reports whether err is a network error or a server error.
+func unavailable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var respErr *smithyhttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() >= 500
+}
@@ -0,0 +1,227 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/hibare/arclift/internal/storage"
+)
+
+// leaseRenewals is the number of times a lease is renewed within its ttl, so a slow renewal does not let it expire.
+const leaseRenewals = 3
+
+// leaseBody is the content of a lease object.
+type leaseBody struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// lease is a lease held in S3 as an object written with conditional requests.
+type lease struct {
+	s   *S3
+	key string
+	ttl time.Duration
+
+	mu      sync.Mutex
+	etag    string
+	expires time.Time
+
+	stop chan struct{}
+	done chan struct{}
+	lost chan struct{}
+}
+
+// isPreconditionFailed reports whether err is the failure of a conditional request.
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return true
+	}
+	return false
+}
+
+func (l *lease) owner() string {
+	return fmt.Sprintf("%s (pid %d)", l.s.cfg.Backup.Hostname, os.Getpid())
+}
+
+// put writes the lease object, only if it does not exist when etag is empty, or if it is still at etag otherwise.
+func (l *lease) put(ctx context.Context, etag string) (string, error) {
+	body, err := json.Marshal(leaseBody{Owner: l.owner(), ExpiresAt: time.Now().Add(l.ttl)})
+	if err != nil {
+		return "", err
+	}
+
+	input := &awsS3.PutObjectInput{
+		Bucket: aws.String(l.s.cfg.S3.Bucket),
+		Key:    aws.String(l.key),
+		Body:   bytes.NewReader(body),
+	}
+	if etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(etag)
+	}
+
+	out, err := l.s.api.PutObject(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+// current returns the lease object and its etag.
+func (l *lease) current(ctx context.Context) (leaseBody, string, error) {
+	var body leaseBody
+	out, err := l.s.api.GetObject(ctx, &awsS3.GetObjectInput{
+		Bucket: aws.String(l.s.cfg.S3.Bucket),
+		Key:    aws.String(l.key),
+	})
+	if err != nil {
+		return body, "", err
+	}
+	defer func() {
+		_ = out.Body.Close()
+	}()
+
+	// An unreadable lease is treated as expired.
+	_ = json.NewDecoder(out.Body).Decode(&body)
+	return body, aws.ToString(out.ETag), nil
+}
+
+func (l *lease) acquire(ctx context.Context) error {
+	start := time.Now()
+	etag, err := l.put(ctx, "")
+	if err == nil {
+		l.etag, l.expires = etag, start.Add(l.ttl)
+		return nil
+	}
+	if !isPreconditionFailed(err) {
+		return err
+	}
+
+	held, heldETag, err := l.current(ctx)
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			// Released in the meantime; the caller tries again on its next run.
+			return storage.ErrLeaseHeld
+		}
+		return err
+	}
+	if time.Now().Before(held.ExpiresAt) {
+		return fmt.Errorf("%w: %s until %s", storage.ErrLeaseHeld, held.Owner, held.ExpiresAt.Format(time.RFC3339))
+	}
+
+	slog.WarnContext(ctx, "Taking over expired lease", "key", l.key, "owner", held.Owner, "expired_at", held.ExpiresAt)
+	start = time.Now()
+	if etag, err = l.put(ctx, heldETag); err != nil {
+		if isPreconditionFailed(err) {
+			return storage.ErrLeaseHeld
+		}
+		return err
+	}
+	l.etag, l.expires = etag, start.Add(l.ttl)
+	return nil
+}
+
+// renew extends the lease until it is released. It closes lost and stops once another instance took the lease
+// over, or when the renewals failed until the lease expired.
+func (l *lease) renew(ctx context.Context) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.ttl / leaseRenewals)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		l.mu.Lock()
+		start := time.Now()
+		etag, err := l.put(ctx, l.etag)
+		if err == nil {
+			l.etag, l.expires = etag, start.Add(l.ttl)
+		}
+		expired := !time.Now().Before(l.expires)
+		l.mu.Unlock()
+		if err == nil {
+			continue
+		}
+
+		if isPreconditionFailed(err) || expired {
+			slog.ErrorContext(ctx, "Lost lease", "key", l.key, "error", err)
+			close(l.lost)
+			return
+		}
+		slog.ErrorContext(ctx, "Failed to renew lease", "key", l.key, "error", err)
+	}
+}
+
+// Lost returns a channel closed once the lease is lost.
+func (l *lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release stops renewing the lease and deletes it, unless another instance took it over.
+func (l *lease) Release(ctx context.Context) error {
+	close(l.stop)
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	input := &awsS3.DeleteObjectInput{
+		Bucket:  aws.String(l.s.cfg.S3.Bucket),
+		Key:     aws.String(l.key),
+		IfMatch: aws.String(l.etag),
+	}
+	_, err := l.s.api.DeleteObject(ctx, input)
+	switch {
+	case err == nil:
+		return nil
+	case isPreconditionFailed(err):
+		slog.WarnContext(ctx, "Lease was taken over by another instance", "key", l.key)
+		return nil
+	}
+
+	// Not every S3 compatible backend supports conditional deletes.
+	slog.DebugContext(ctx, "Conditional lease release failed, releasing unconditionally", "key", l.key, "error", err)
+	input.IfMatch = nil
+	_, err = l.s.api.DeleteObject(ctx, input)
+	return err
+}
+
+// AcquireLease acquires the lease named name below the host prefix with conditional writes, and renews it every
+// third of ttl until released.
+func (s *S3) AcquireLease(ctx context.Context, name string, ttl time.Duration) (storage.Lease, error) {
+	l := &lease{
+		s:    s,
+		key:  s.hostPrefix() + name,
+		ttl:  ttl,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+		lost: make(chan struct{}),
+	}
+	if err := l.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	go l.renew(context.WithoutCancel(ctx))
+	return l, nil
+}
@@ -0,0 +1,46 @@
+package s3
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLease_Lost(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{name: "taken over", status: http.StatusPreconditionFailed},
+		{name: "expired", status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := newTestS3(t, 1)
+			l, err := s.AcquireLease(t.Context(), "lease", 150*time.Millisecond)
+			require.NoError(t, err)
+			fake.mu.Lock()
+			assert.Contains(t, fake.puts, "backups/host/lease")
+			fake.mu.Unlock()
+
+			select {
+			case <-l.Lost():
+				t.Fatal("lease lost while renewals succeed")
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			fake.mu.Lock()
+			fake.putStatus = tt.status
+			fake.mu.Unlock()
+			select {
+			case <-l.Lost():
+			case <-time.After(5 * time.Second):
+				t.Fatal("lease not lost after its renewals failed")
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/hibare/arclift/internal/storage"
+)
+
+// ObjectLockEnabled reports whether Object Lock is enabled on the bucket.
+func (s *S3) ObjectLockEnabled(ctx context.Context) (bool, error) {
+	out, err := s.api.GetObjectLockConfiguration(ctx, &awsS3.GetObjectLockConfigurationInput{Bucket: aws.String(s.cfg.S3.Bucket)})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ObjectLockConfigurationNotFoundError" {
+			return false, nil
+		}
+		return false, fmt.Errorf("checking Object Lock of bucket %s: %w", s.cfg.S3.Bucket, err)
+	}
+	return out.ObjectLockConfiguration != nil &&
+		out.ObjectLockConfiguration.ObjectLockEnabled == types.ObjectLockEnabledEnabled, nil
+}
+
+// ObjectLocks returns the Object Lock state of the current version of the objects at key, relative to the configured
+// prefix, and below it, by object key. Deleted objects, whose current version is a delete marker, are returned without
+// a retention.
+func (s *S3) ObjectLocks(ctx context.Context, key string) ([]storage.ObjectLock, error) {
+	byKey, err := s.versions(ctx, s.hostPrefix()+key)
+	if err != nil {
+		return nil, err
+	}
+
+	locks := make([]storage.ObjectLock, 0, len(byKey))
+	for _, objKey := range slices.Sorted(maps.Keys(byKey)) {
+		versions := byKey[objKey]
+		latest := versions[0]
+		lock := storage.ObjectLock{Key: objKey, Versions: len(versions), Deleted: latest.marker}
+		if !latest.marker {
+			out, err := s.api.HeadObject(ctx, &awsS3.HeadObjectInput{
+				Bucket:    aws.String(s.cfg.S3.Bucket),
+				Key:       aws.String(objKey),
+				VersionId: aws.String(latest.id),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("checking Object Lock of %s: %w", objKey, err)
+			}
+			lock.Mode = string(out.ObjectLockMode)
+			lock.RetainUntil = aws.ToTime(out.ObjectLockRetainUntilDate)
+			lock.LegalHold = out.ObjectLockLegalHoldStatus == types.ObjectLockLegalHoldStatusOn
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
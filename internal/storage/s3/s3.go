@@ -4,33 +4,55 @@ package s3
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"net/url"
+	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
-	commonS3 "github.com/hibare/GoCommon/v2/pkg/aws/s3"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/naming"
 	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/walk"
 )
 
 // S3 implements the StorageIface for S3-compatible storage backends.
 type S3 struct {
-	s3  commonS3.ClientIface
-	cfg *config.Config
+	api  *awsS3.Client
+	cfg  *config.Config
+	keys naming.KeyLayout
 }
 
 // Init prepares the S3 storage by establishing a session.
 func (s *S3) Init(ctx context.Context) error {
-	s3, err := commonS3.NewClient(ctx, commonS3.Options{
-		Endpoint:  s.cfg.S3.Endpoint,
-		Region:    s.cfg.S3.Region,
-		AccessKey: s.cfg.S3.AccessKey,
-		SecretKey: s.cfg.S3.SecretKey,
-	})
+	api, err := newAPIClient(ctx, s.cfg)
 	if err != nil {
 		return err
 	}
 
-	s.s3 = s3
+	s.api = api
+
+	if s.cfg.S3.CreateBucket.Enabled {
+		if err := s.ensureBucket(ctx); err != nil {
+			return err
+		}
+	}
+
+	keys, err := s.cfg.KeyLayout()
+	if err != nil {
+		return err
+	}
+
+	s.keys = keys
 
 	return nil
 }
@@ -40,56 +62,401 @@ func (s *S3) Name() string {
 	return fmt.Sprintf("s3 (%s)", s.cfg.S3.Bucket)
 }
 
+// hostPrefix returns the prefix holding every backup of this host, ending with a slash, see config.KeyLayout.
+func (s *S3) hostPrefix() string {
+	return s.keys.Dir
+}
+
+// timestampedPrefix returns the prefix of a new backup, ending with a slash.
+func (s *S3) timestampedPrefix() string {
+	return s.keys.Dir + s.keys.Format(time.Now()) + "/"
+}
+
+// putObject uploads body to key with the configured storage class, tagged with the backup labels.
+func (s *S3) putObject(ctx context.Context, key string, body io.Reader) error {
+	input := &awsS3.PutObjectInput{
+		Bucket:       aws.String(s.cfg.S3.Bucket),
+		Key:          aws.String(key),
+		Body:         storage.ProgressReader(ctx, body),
+		StorageClass: types.StorageClass(s.cfg.S3.StorageClass),
+	}
+	if tagging := s.cfg.Backup.Tagging(); tagging != "" {
+		input.Tagging = aws.String(tagging)
+	}
+	_, err := s.api.PutObject(ctx, input)
+	return err
+}
+
+// Put writes body to the object at key, relative to the host prefix, tagged with the backup labels. Unlike backups, it
+// is stored with the bucket default storage class, so it can be read right away.
+func (s *S3) Put(ctx context.Context, key string, body io.Reader) error {
+	input := &awsS3.PutObjectInput{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Key:    aws.String(s.hostPrefix() + key),
+		Body:   body,
+	}
+	if tagging := s.cfg.Backup.Tagging(); tagging != "" {
+		input.Tagging = aws.String(tagging)
+	}
+	_, err := s.api.PutObject(ctx, input)
+	return err
+}
+
 // UploadFile uploads a local file to S3 and returns the remote key/path.
 func (s *S3) UploadFile(ctx context.Context, localPath string) (string, error) {
-	prefix := s.s3.BuildTimestampedKey(s.cfg.S3.Prefix, s.cfg.Backup.Hostname)
+	prefix := s.timestampedPrefix()
+	key := prefix + filepath.Base(localPath)
 
 	slog.DebugContext(ctx, "Uploading file to S3", "file", localPath, "bucket", s.cfg.S3.Bucket, "key_prefix", prefix)
-	key, err := s.s3.UploadFile(ctx, s.cfg.S3.Bucket, prefix, localPath)
+	f, err := os.Open(localPath)
 	if err != nil {
 		return "", err
 	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if err := s.putObject(ctx, key, f); err != nil {
+		return "", err
+	}
 	return key, nil
 }
 
-// UploadDir uploads a local directory to S3 and returns the remote key/path.
-func (s *S3) UploadDir(ctx context.Context, localPath string) (storage.UploadDirResponse, error) {
-	prefix := s.s3.BuildTimestampedKey(s.cfg.S3.Prefix, s.cfg.Backup.Hostname)
-	resp, err := s.s3.UploadDir(ctx, s.cfg.S3.Bucket, prefix, localPath, nil)
-	if err != nil {
-		return storage.UploadDirResponse{}, err
+// UploadDir uploads a local directory to S3 and returns the remote key/path. Up to s3.upload-concurrency files are
+// uploaded at a time. Entries that cannot be uploaded, such as sockets and unreadable files, are skipped and reported.
+func (s *S3) UploadDir(ctx context.Context, localPath string, opts walk.Options) (storage.UploadDirResponse, error) {
+	return s.uploadDir(ctx, localPath, s.timestampedPrefix(), opts, nil)
+}
+
+// SyncDir uploads the files of a local directory that unchanged does not report as stored into the backup at key.
+func (s *S3) SyncDir(
+	ctx context.Context, localPath, key string, opts walk.Options, unchanged storage.Unchanged,
+) (storage.UploadDirResponse, error) {
+	return s.uploadDir(ctx, localPath, s.hostPrefix()+key+"/", opts, unchanged)
+}
+
+// uploadDir uploads a local directory under prefix, skipping the files unchanged, when set, reports as stored.
+func (s *S3) uploadDir(
+	ctx context.Context, localPath, prefix string, opts walk.Options, unchanged storage.Unchanged,
+) (storage.UploadDirResponse, error) {
+	localPath = filepath.Clean(localPath)
+	base := filepath.Base(localPath)
+
+	resp := storage.UploadDirResponse{FailedFiles: map[string]error{}, Checksums: map[string]string{}}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	slots := make(chan struct{}, max(s.cfg.S3.UploadConcurrency, 1))
+
+	upload := func(path, key string, f *os.File) {
+		sum, pErr := storage.Checksum(f)
+		if pErr == nil {
+			pErr = s.putObject(ctx, key, f)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if pErr != nil {
+			resp.FailedFiles[path] = pErr
+			return
+		}
+		resp.Checksums[key] = sum
+		resp.SuccessFiles++
+		if info, sErr := f.Stat(); sErr == nil {
+			resp.Bytes += info.Size()
+		}
+	}
+
+	skipped, err := walk.Dir(ctx, localPath, opts, func(path, rel string, _ fs.DirEntry, f *os.File) error {
+		if f == nil {
+			resp.TotalDirs++
+			return nil
+		}
+		resp.TotalFiles++
+
+		key := prefix + base + "/" + rel
+		if path == localPath {
+			// A single file is stored as the backup itself.
+			key = prefix + base
+		}
+		if unchanged != nil && unchanged(key, f) {
+			mu.Lock()
+			resp.Unchanged++
+			resp.SuccessFiles++
+			mu.Unlock()
+			return nil
+		}
+		if cap(slots) == 1 {
+			upload(path, key, f)
+			return nil
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wg.Go(func() {
+			defer func() {
+				<-slots
+			}()
+
+			// The walk closes f once this callback returns, so the upload opens the file again.
+			uf, oErr := os.Open(path)
+			if oErr != nil {
+				mu.Lock()
+				resp.FailedFiles[path] = oErr
+				mu.Unlock()
+				return
+			}
+			defer func() {
+				_ = uf.Close()
+			}()
+			upload(path, key, uf)
+		})
+		return nil
+	})
+	wg.Wait()
+
+	resp.Skipped = skipped
+	if resp.SuccessFiles > 0 {
+		resp.BaseKey = prefix + base
 	}
-	return storage.UploadDirResponse{
-		BaseKey:      resp.BaseKey,
-		TotalFiles:   resp.TotalFiles,
-		TotalDirs:    resp.TotalDirs,
-		SuccessFiles: resp.SuccessFiles,
-		FailedFiles:  resp.FailedFiles,
-	}, nil
+	// On error, BaseKey holds the partial upload, if any, for the caller to clean up.
+	return resp, err
 }
 
 // List returns keys/identifiers under the configured prefix.
 func (s *S3) List(ctx context.Context) ([]string, error) {
+	if s.keys.Partitioned() {
+		return s.listPartitioned(ctx)
+	}
+
 	// Prefix excluding timestamp to list all backups for this instance
-	prefix := s.s3.BuildKey(s.cfg.S3.Prefix, s.cfg.Backup.Hostname)
-	keys, err := s.s3.ListObjectsAtPrefix(ctx, s.cfg.S3.Bucket, prefix)
+	prefix := s.hostPrefix()
+	var keys []string
+	paginator := awsS3.NewListObjectsV2Paginator(s.api, &awsS3.ListObjectsV2Input{
+		Bucket:    aws.String(s.cfg.S3.Bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			if key := aws.ToString(obj.Key); key != prefix {
+				keys = append(keys, key)
+			}
+		}
+		for _, cp := range page.CommonPrefixes {
+			keys = append(keys, aws.ToString(cp.Prefix))
+		}
+	}
+	return slices.DeleteFunc(keys, func(key string) bool { return !storage.Owns(s.keys, strings.TrimPrefix(key, prefix)) }), nil
+}
+
+// listPartitioned lists the backups partitioned by date, whose keys span several segments, from the listing of every
+// object under the host prefix, see storage.ListedKey.
+func (s *S3) listPartitioned(ctx context.Context) ([]string, error) {
+	objects, err := s.ListObjects(ctx)
 	if err != nil {
 		return nil, err
 	}
+
+	prefix := s.hostPrefix()
+	seen := map[string]bool{}
+	var keys []string
+	for _, obj := range objects {
+		rel := storage.ListedKey(s.keys, strings.TrimPrefix(obj.Key, prefix))
+		if rel == "" || seen[rel] || !storage.Owns(s.keys, rel) {
+			continue
+		}
+		seen[rel] = true
+		keys = append(keys, prefix+rel)
+	}
 	return keys, nil
 }
 
+// ListObjects returns every object, recursively, under the configured prefix.
+func (s *S3) ListObjects(ctx context.Context) ([]storage.ObjectInfo, error) {
+	prefix := s.hostPrefix()
+
+	var objects []storage.ObjectInfo
+	paginator := awsS3.NewListObjectsV2Paginator(s.api, &awsS3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			if !storage.Owns(s.keys, strings.TrimPrefix(aws.ToString(obj.Key), prefix)) {
+				continue
+			}
+			objects = append(objects, storage.ObjectInfo{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+				ETag:         strings.Trim(aws.ToString(obj.ETag), `"`),
+			})
+		}
+	}
+	return objects, nil
+}
+
+// ListDetailed returns the size and object count of every backup under the configured prefix.
+func (s *S3) ListDetailed(ctx context.Context) ([]storage.BackupDetail, error) {
+	objects, err := s.ListObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.Details(s.keys, s.hostPrefix(), objects), nil
+}
+
+// Download downloads the object at key to localPath, creating parent directories as needed.
+func (s *S3) Download(ctx context.Context, key, localPath string) error {
+	out, err := s.api.GetObject(ctx, &awsS3.GetObjectInput{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = out.Body.Close()
+	}()
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o750); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, storage.ProgressReader(ctx, out.Body)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Copy copies the objects under key to the same backup key in dst, server side, using the storage class of dst.
+// dst must be an S3 storage reachable with the credentials of s.
+func (s *S3) Copy(ctx context.Context, key string, dst storage.StorageIface) error {
+	return s.copyObjects(ctx, key, dst, func(target *S3, objKey string) string {
+		return target.hostPrefix() + strings.TrimPrefix(objKey, s.hostPrefix())
+	})
+}
+
+// CopyTo copies the objects under key, relative to the host prefix, to dstKey, relative to the host prefix of dst,
+// server side, using the storage class of dst. dst must be an S3 storage reachable with the credentials of s.
+func (s *S3) CopyTo(ctx context.Context, key string, dst storage.StorageIface, dstKey string) error {
+	srcKey := s.hostPrefix() + key
+	return s.copyObjects(ctx, srcKey, dst, func(target *S3, objKey string) string {
+		return target.hostPrefix() + dstKey + strings.TrimPrefix(objKey, srcKey)
+	})
+}
+
+// copyObjects copies key and the objects below it to dst, at the keys returned by dstKey.
+func (s *S3) copyObjects(ctx context.Context, key string, dst storage.StorageIface, dstKey func(target *S3, objKey string) string) error {
+	target, ok := dst.(*S3)
+	if !ok {
+		return storage.ErrCopyUnsupported
+	}
+
+	paginator := awsS3.NewListObjectsV2Paginator(s.api, &awsS3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Prefix: aws.String(key),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			objKey := aws.ToString(obj.Key)
+			// The prefix also matches siblings such as <key>2/; only copy key itself and what is below it.
+			if objKey != key && !strings.HasPrefix(objKey, strings.TrimSuffix(key, "/")+"/") {
+				continue
+			}
+
+			to := dstKey(target, objKey)
+			slog.DebugContext(ctx, "Copying object", "key", objKey, "bucket", target.cfg.S3.Bucket, "dst", to)
+			if _, err := s.api.CopyObject(ctx, &awsS3.CopyObjectInput{
+				Bucket:       aws.String(target.cfg.S3.Bucket),
+				Key:          aws.String(to),
+				CopySource:   aws.String(copySource(s.cfg.S3.Bucket, objKey)),
+				StorageClass: types.StorageClass(target.cfg.S3.StorageClass),
+			}); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", objKey, err)
+			}
+		}
+	}
+	return nil
+}
+
+// copySource returns the URL encoded bucket/key source of a CopyObject request.
+func copySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return bucket + "/" + strings.Join(segments, "/")
+}
+
 // Delete deletes the provided key/path from S3 storage.
 func (s *S3) Delete(ctx context.Context, timestamp string) error {
-	prefix := s.s3.BuildKey(s.cfg.S3.Prefix, s.cfg.Backup.Hostname)
-	key := filepath.Join(prefix, timestamp)
-	return s.s3.DeleteObjects(ctx, s.cfg.S3.Bucket, key, true)
+	prefix := s.hostPrefix()
+	key := path.Join(prefix, timestamp)
+
+	// Delete every object below key, then key itself.
+	paginator := awsS3.NewListObjectsV2Paginator(s.api, &awsS3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Prefix: aws.String(key),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			objKey := aws.ToString(obj.Key)
+			// The prefix also matches siblings such as <key>2/, which belong to other backups.
+			if objKey != key && !strings.HasPrefix(objKey, key+"/") {
+				continue
+			}
+			if err := s.deleteObject(ctx, objKey); err != nil {
+				return err
+			}
+		}
+	}
+	return s.deleteObject(ctx, key)
+}
+
+func (s *S3) deleteObject(ctx context.Context, key string) error {
+	_, err := s.api.DeleteObject(ctx, &awsS3.DeleteObjectInput{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
 }
 
 // TrimPrefix trims the configured prefix from a given key, if present.
 func (s *S3) TrimPrefix(keys []string) []string {
 	// Trim the prefix from the keys to get timestamps only
-	return s.s3.TrimPrefix(keys, s.s3.BuildKey(s.cfg.S3.Prefix, s.cfg.Backup.Hostname))
+	trimmed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		trimmed = append(trimmed, strings.TrimSuffix(strings.TrimPrefix(key, s.hostPrefix()), "/"))
+	}
+	return trimmed
 }
 
 // NewS3Storage creates a new S3Storage instance with the provided configuration.
@@ -0,0 +1,145 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+	"github.com/hibare/arclift/internal/storage"
+	"github.com/hibare/arclift/internal/walk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is an S3 endpoint accepting object uploads, recording the keys written and the most uploads in flight at
+// once. Uploads fail with putStatus when it is set, and with 500 for the keys fail reports.
+type fakeS3 struct {
+	mu          sync.Mutex
+	puts        map[string]int
+	putStatus   int
+	fail        func(key string) bool
+	inFlight    int
+	maxInFlight int
+	delay       time.Duration
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	_, _ = io.Copy(io.Discard, r.Body)
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+
+	f.mu.Lock()
+	f.inFlight++
+	f.maxInFlight = max(f.maxInFlight, f.inFlight)
+	status := f.putStatus
+	if status == 0 && f.fail != nil && f.fail(key) {
+		status = http.StatusInternalServerError
+	}
+	f.mu.Unlock()
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	f.inFlight--
+	if status == 0 {
+		f.puts[key]++
+	}
+	f.mu.Unlock()
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// newTestS3 returns S3 storage uploading to a fake endpoint with concurrency uploads at a time.
+func newTestS3(t *testing.T, concurrency int) (*S3, *fakeS3) {
+	t.Helper()
+
+	fake := &fakeS3{puts: map[string]int{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	s := NewS3Storage(&config.Config{
+		S3: config.S3Config{
+			Endpoint:          srv.URL,
+			Region:            "us-east-1",
+			AccessKey:         "test",
+			SecretKey:         "test",
+			Bucket:            "bucket",
+			Prefix:            "backups",
+			UploadConcurrency: concurrency,
+			Retry:             config.RetryConfig{MaxAttempts: 1},
+		},
+		Backup: config.BackupConfig{Hostname: "host"},
+	})
+	require.NoError(t, s.Init(t.Context()))
+	return s, fake
+}
+
+// writeFiles writes n small files to a new directory and returns it.
+func writeFiles(t *testing.T, n int) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.Mkdir(dir, 0o750))
+	for i := range n {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("file-%02d", i)), []byte(fmt.Sprint(i)), 0o600))
+	}
+	return dir
+}
+
+func TestS3_SyncDir_Concurrent(t *testing.T) {
+	s, fake := newTestS3(t, 4)
+	dir := writeFiles(t, 40)
+
+	// Every other file is reported as stored, while the changed ones upload concurrently.
+	unchanged := func(key string, _ *os.File) bool {
+		var i int
+		_, err := fmt.Sscanf(filepath.Base(key), "file-%d", &i)
+		return err == nil && i%2 == 0
+	}
+	resp, err := s.SyncDir(t.Context(), dir, "20240101000000", walk.Options{}, unchanged)
+	require.NoError(t, err)
+
+	assert.Equal(t, 40, resp.TotalFiles)
+	assert.Equal(t, 40, resp.SuccessFiles)
+	assert.Equal(t, 20, resp.Unchanged)
+	assert.Empty(t, resp.FailedFiles)
+	assert.Len(t, resp.Checksums, 20)
+	assert.Len(t, fake.puts, 20)
+	assert.Contains(t, fake.puts, "backups/host/20240101000000/data/file-01")
+}
+
+func TestS3_UploadDir_Concurrent(t *testing.T) {
+	s, fake := newTestS3(t, 4)
+	fake.delay = 10 * time.Millisecond
+	fake.fail = func(key string) bool { return strings.HasSuffix(key, "7") }
+	dir := writeFiles(t, 30)
+
+	resp, err := s.UploadDir(t.Context(), dir, walk.Options{})
+	require.NoError(t, err)
+
+	// file-07, file-17 and file-27 fail; the files hold their index, so the others hold 45 bytes.
+	assert.Equal(t, 30, resp.TotalFiles)
+	assert.Equal(t, 27, resp.SuccessFiles)
+	assert.Len(t, resp.FailedFiles, 3)
+	assert.Contains(t, resp.FailedFiles, filepath.Join(dir, "file-17"))
+	assert.Len(t, resp.Checksums, 27)
+	assert.Equal(t, int64(45), resp.Bytes)
+	require.ErrorIs(t, resp.Err(), storage.ErrPartialUpload)
+	assert.Len(t, fake.puts, 27)
+	assert.Greater(t, fake.maxInFlight, 1, "uploads ran one at a time")
+	assert.LessOrEqual(t, fake.maxInFlight, 4, "more uploads than the concurrency")
+}
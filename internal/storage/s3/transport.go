@@ -0,0 +1,104 @@
+package s3
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	awsHTTP "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/hibare/arclift/internal/config"
+)
+
+// idleConn fails reads and writes once the connection went without any traffic for timeout, so a hung connection
+// fails its request instead of stalling the backup.
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	if err := c.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	if err := c.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// applyTLS applies the configured CA bundle, client certificate and verification setting to base.
+func applyTLS(base *tls.Config, cfg config.TLSConfig) (*tls.Config, error) {
+	tlsCfg := base.Clone()
+	if tlsCfg == nil {
+		tlsCfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls ca-file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls ca-file %s contains no PEM certificates", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	tlsCfg.InsecureSkipVerify = cfg.InsecureSkipVerify //nolint:gosec // opt-in, warned about when the config is loaded
+	return tlsCfg, nil
+}
+
+// newHTTPClient builds the HTTP client of the S3 requests, bounded by the configured timeouts, sent through the
+// configured proxy and using the configured TLS settings.
+func newHTTPClient(cfg *config.Config) (*awsHTTP.BuildableClient, error) {
+	timeouts := cfg.S3.Timeouts
+	var tlsErr error
+	client := awsHTTP.NewBuildableClient().
+		WithDialerOptions(func(d *net.Dialer) {
+			d.Timeout = timeouts.Connect
+		}).
+		WithTransportOptions(func(tr *http.Transport) {
+			tr.Proxy = cfg.Proxy.Func()
+			tr.TLSClientConfig, tlsErr = applyTLS(tr.TLSClientConfig, cfg.S3.TLS)
+			tr.TLSHandshakeTimeout = timeouts.Connect
+			tr.ResponseHeaderTimeout = timeouts.Read
+
+			if timeouts.Read <= 0 {
+				return
+			}
+			dial := tr.DialContext
+			tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				conn, err := dial(ctx, network, addr)
+				if err != nil {
+					return nil, err
+				}
+				return &idleConn{Conn: conn, timeout: timeouts.Read}, nil
+			}
+		}).
+		WithTimeout(timeouts.Request)
+	if tlsErr != nil {
+		return nil, tlsErr
+	}
+	return client, nil
+}
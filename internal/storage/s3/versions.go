@@ -0,0 +1,124 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// deleteBatchSize is the maximum number of objects deleted by a single DeleteObjects request.
+const deleteBatchSize = 1000
+
+// version is a version of an object, or its delete marker.
+type version struct {
+	id           string
+	lastModified time.Time
+	latest       bool
+	marker       bool
+}
+
+// Versioned reports whether versioning is enabled on the bucket. A suspended versioning keeps the existing versions,
+// but overwrites the null version, so it does not count.
+func (s *S3) Versioned(ctx context.Context) (bool, error) {
+	out, err := s.api.GetBucketVersioning(ctx, &awsS3.GetBucketVersioningInput{Bucket: aws.String(s.cfg.S3.Bucket)})
+	if err != nil {
+		return false, fmt.Errorf("checking versioning of bucket %s: %w", s.cfg.S3.Bucket, err)
+	}
+	return out.Status == types.BucketVersioningStatusEnabled, nil
+}
+
+// versions lists the versions of the objects at key and below it, newest first, by object key.
+func (s *S3) versions(ctx context.Context, key string) (map[string][]version, error) {
+	byKey := map[string][]version{}
+	add := func(objKey *string, v version) {
+		k := aws.ToString(objKey)
+		// The prefix also matches siblings such as <key>2/, which belong to other backups.
+		if k == key || strings.HasPrefix(k, key+"/") {
+			byKey[k] = append(byKey[k], v)
+		}
+	}
+
+	paginator := awsS3.NewListObjectVersionsPaginator(s.api, &awsS3.ListObjectVersionsInput{
+		Bucket: aws.String(s.cfg.S3.Bucket),
+		Prefix: aws.String(key),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range page.Versions {
+			add(v.Key, version{
+				id:           aws.ToString(v.VersionId),
+				lastModified: aws.ToTime(v.LastModified),
+				latest:       aws.ToBool(v.IsLatest),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			add(m.Key, version{
+				id:           aws.ToString(m.VersionId),
+				lastModified: aws.ToTime(m.LastModified),
+				latest:       aws.ToBool(m.IsLatest),
+				marker:       true,
+			})
+		}
+	}
+
+	for _, versions := range byKey {
+		slices.SortStableFunc(versions, func(a, b version) int {
+			if a.latest != b.latest {
+				if a.latest {
+					return -1
+				}
+				return 1
+			}
+			return b.lastModified.Compare(a.lastModified)
+		})
+	}
+	return byKey, nil
+}
+
+// PruneVersions deletes the versions of the objects at key, relative to the configured prefix, and below it, except
+// the newest keep of each object. An object whose kept versions are all delete markers is gone, so they are deleted
+// too.
+func (s *S3) PruneVersions(ctx context.Context, key string, keep int) (int, error) {
+	byKey, err := s.versions(ctx, s.hostPrefix()+key)
+	if err != nil {
+		return 0, err
+	}
+
+	var expired []types.ObjectIdentifier
+	for objKey, versions := range byKey {
+		kept := versions[:min(keep, len(versions))]
+		if !slices.ContainsFunc(kept, func(v version) bool { return !v.marker }) {
+			kept = nil
+		}
+		for _, v := range versions[len(kept):] {
+			expired = append(expired, types.ObjectIdentifier{Key: aws.String(objKey), VersionId: aws.String(v.id)})
+		}
+	}
+
+	deleted := 0
+	for batch := range slices.Chunk(expired, deleteBatchSize) {
+		out, err := s.api.DeleteObjects(ctx, &awsS3.DeleteObjectsInput{
+			Bucket: aws.String(s.cfg.S3.Bucket),
+			Delete: &types.Delete{Objects: batch, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += len(batch) - len(out.Errors)
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return deleted, fmt.Errorf("deleting version %s of %s: %s: %s", aws.ToString(e.VersionId), aws.ToString(e.Key),
+				aws.ToString(e.Code), aws.ToString(e.Message))
+		}
+	}
+	return deleted, nil
+}
@@ -1,7 +1,41 @@
 // Package storage defines the interface for various storage backends.
 package storage
 
-import "context"
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hibare/arclift/internal/naming"
+	"github.com/hibare/arclift/internal/state"
+	"github.com/hibare/arclift/internal/walk"
+)
+
+var (
+	// ErrCopyUnsupported is returned when backups cannot be copied between two storage backends.
+	ErrCopyUnsupported = errors.New("copy between these storage backends is not supported")
+
+	// ErrLeaseHeld is returned when another instance holds the lease.
+	ErrLeaseHeld = errors.New("lease is held by another instance")
+
+	// ErrLeaseLost is the cause with which an operation holding a lease is cancelled when the lease could not be
+	// renewed before it expired, or was taken over by another instance.
+	ErrLeaseLost = errors.New("lease lost")
+
+	// ErrStorageUnavailable is returned when the storage backend could not be reached or failed on its side, such as
+	// on network errors and server errors, rather than rejected the request; a later attempt may succeed.
+	ErrStorageUnavailable = errors.New("storage unavailable")
+
+	// ErrPartialUpload is returned by UploadDirResponse.Err when some files of a backup were not uploaded.
+	ErrPartialUpload = errors.New("partial upload")
+)
 
 type UploadDirResponse struct {
 	BaseKey      string
@@ -9,6 +43,136 @@ type UploadDirResponse struct {
 	TotalDirs    int
 	SuccessFiles int
 	FailedFiles  map[string]error
+	Bytes        int64
+
+	// Unchanged counts the files a sync left as they were stored, see StorageIface.SyncDir. They are included in
+	// SuccessFiles.
+	Unchanged int
+
+	// Skipped counts the entries that were skipped, per category, see walk.Report.
+	Skipped map[string]int
+
+	// TooLarge lists the files skipped for exceeding the maximum file size.
+	TooLarge []state.SkippedFile
+
+	// Fingerprint identifies the backed up content, to tell whether it changed since, see backup.skip-unchanged.
+	Fingerprint string
+
+	// Checksums are the SHA-256 digests, hex encoded, of the uploaded files by object key, see Checksum.
+	Checksums map[string]string
+}
+
+// Err returns ErrPartialUpload, with the number of files that failed to upload, when some did, and nil otherwise. The
+// backup is still stored, without those files.
+func (r UploadDirResponse) Err() error {
+	if len(r.FailedFiles) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d files failed", ErrPartialUpload, len(r.FailedFiles), r.TotalFiles)
+}
+
+// Checksum returns the SHA-256 digest of f, hex encoded, and leaves its offset at the start.
+func Checksum(f *os.File) (string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Unchanged reports whether the file f, about to be uploaded to the object at key, is already stored there. It may
+// read f, but must leave its offset at the start.
+type Unchanged func(key string, f *os.File) bool
+
+// ObjectInfo describes a single stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ETag         string
+}
+
+// BackupDetail is the aggregate of the objects stored under a single backup key.
+type BackupDetail struct {
+	Key     string
+	Size    int64
+	Objects int
+
+	// Names are the distinct top-level object names of the backup, e.g. archive names or directory names.
+	Names []string
+}
+
+// Owns reports whether the object at rel, relative to the directory of keys, belongs to the backups named by keys.
+// The directory may be shared with other hosts or jobs when the key template names backups after them. Auxiliary
+// objects live under a hidden directory, in a subdirectory named after their backup.
+func Owns(keys naming.KeyLayout, rel string) bool {
+	if hidden, rest, _ := strings.Cut(rel, "/"); strings.HasPrefix(hidden, ".") {
+		key, _, nested := keys.Cut(rest)
+		if !nested {
+			return !strings.Contains(rest, "/")
+		}
+		_, ok := keys.Parse(key)
+		return ok
+	}
+	key, _, _ := keys.Cut(rel)
+	_, ok := keys.Parse(key)
+	return ok
+}
+
+// ListedKey returns the key of the object at rel, relative to the directory of keys, in a listing of the backups
+// like a delimited S3 listing: the key of its backup or hidden directory, ending with a slash, or rel itself for a
+// backup stored as a single object.
+func ListedKey(keys naming.KeyLayout, rel string) string {
+	if hidden, _, nested := strings.Cut(rel, "/"); nested && strings.HasPrefix(hidden, ".") {
+		return hidden + "/"
+	}
+	if key, _, nested := keys.Cut(rel); nested {
+		return key + "/"
+	}
+	return rel
+}
+
+// Details aggregates objects, listed under prefix, the directory of keys, into the backups they belong to, in
+// listing order.
+func Details(keys naming.KeyLayout, prefix string, objects []ObjectInfo) []BackupDetail {
+	byKey := map[string]*BackupDetail{}
+	var details []*BackupDetail
+	for _, obj := range objects {
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		key, rest, _ := keys.Cut(rel)
+		if hidden, hiddenRest, _ := strings.Cut(rel, "/"); strings.HasPrefix(hidden, ".") {
+			// Auxiliary objects are aggregated under their hidden directory.
+			key, rest = hidden, hiddenRest
+		}
+		if key == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(rest, "/")
+
+		d, ok := byKey[key]
+		if !ok {
+			d = &BackupDetail{Key: key}
+			byKey[key] = d
+			details = append(details, d)
+		}
+		d.Size += obj.Size
+		d.Objects++
+		if name != "" && !slices.Contains(d.Names, name) {
+			d.Names = append(d.Names, name)
+		}
+	}
+
+	out := make([]BackupDetail, 0, len(details))
+	for _, d := range details {
+		out = append(out, *d)
+	}
+	return out
 }
 
 // StorageIface defines a generic storage backend used to upload and manage backups.
@@ -20,12 +184,36 @@ type StorageIface interface {
 	// UploadFile uploads a local file and returns the remote key/path
 	UploadFile(context.Context, string) (string, error)
 
-	// UploadDir uploads a local directory and returns the remote key/path
-	UploadDir(context.Context, string) (UploadDirResponse, error)
+	// UploadDir uploads the parts of a local directory selected by the walk options and returns the remote key/path.
+	// A local path that is a single file is stored as the backup itself.
+	UploadDir(context.Context, string, walk.Options) (UploadDirResponse, error)
+
+	// SyncDir uploads the parts of a local directory selected by the walk options into the existing backup key,
+	// relative to the configured prefix, like UploadDir, except for the files unchanged reports true for, given the
+	// object key they would be uploaded to
+	SyncDir(ctx context.Context, localPath, key string, opts walk.Options, unchanged Unchanged) (UploadDirResponse, error)
 
 	// List returns keys/identifiers under configured prefix
 	List(context.Context) ([]string, error)
 
+	// ListObjects returns every object, recursively, under configured prefix
+	ListObjects(context.Context) ([]ObjectInfo, error)
+
+	// ListDetailed returns the size and object count of every backup under configured prefix
+	ListDetailed(context.Context) ([]BackupDetail, error)
+
+	// Download downloads the object at key to a local file path
+	Download(ctx context.Context, key, localPath string) error
+
+	// Copy copies the objects under key, as returned by UploadFile or UploadDir, to the same backup key in dst
+	Copy(ctx context.Context, key string, dst StorageIface) error
+
+	// CopyTo copies the objects under key, relative to the configured prefix, to dstKey, relative to the prefix of dst
+	CopyTo(ctx context.Context, key string, dst StorageIface, dstKey string) error
+
+	// Put writes body to the object at key, relative to the configured prefix
+	Put(ctx context.Context, key string, body io.Reader) error
+
 	// Delete deletes the provided key/path from storage
 	Delete(context.Context, string) error
 
@@ -35,3 +223,69 @@ type StorageIface interface {
 	// Name returns the name of the storage backend (e.g., "s3", "gcs")
 	Name() string
 }
+
+// Lease is a lock held in the storage, see Leaser.
+type Lease interface {
+	// Release releases the lease
+	Release(ctx context.Context) error
+
+	// Lost returns a channel closed once the lease could not be renewed before it expired, or was taken over by
+	// another instance. The lease is then no longer renewed.
+	Lost() <-chan struct{}
+}
+
+// Leaser is implemented by storage backends that can hold a lease shared by every instance storing to the same
+// prefix. A lease expires after its ttl unless renewed, so an instance that dies does not hold it forever.
+type Leaser interface {
+	// AcquireLease acquires the lease named name, relative to the configured prefix, and renews it until released.
+	// It returns ErrLeaseHeld when another instance holds it
+	AcquireLease(ctx context.Context, name string, ttl time.Duration) (Lease, error)
+}
+
+// Versioner is implemented by storage backends that can keep the previous versions of overwritten and deleted
+// objects, such as S3 buckets with versioning enabled.
+type Versioner interface {
+	// Versioned reports whether previous versions of objects are kept
+	Versioned(ctx context.Context) (bool, error)
+
+	// PruneVersions deletes the versions of the objects under key, relative to the configured prefix, except the
+	// newest keep of each object, and returns the number of versions deleted. The delete marker of a deleted object
+	// counts as its newest version
+	PruneVersions(ctx context.Context, key string, keep int) (int, error)
+}
+
+// Object Lock modes of ObjectLock.
+const (
+	ObjectLockGovernance = "GOVERNANCE"
+	ObjectLockCompliance = "COMPLIANCE"
+)
+
+// ObjectLock is the Object Lock state of a stored object.
+type ObjectLock struct {
+	Key string
+
+	// Mode is the retention mode of the current version, ObjectLockGovernance or ObjectLockCompliance, and
+	// RetainUntil the end of its retention. Mode is empty when the version has no retention.
+	Mode        string
+	RetainUntil time.Time
+
+	// LegalHold reports whether the current version is under a legal hold, which protects it without a date.
+	LegalHold bool
+
+	// Deleted reports whether the current version is a delete marker, left by deleting the object.
+	Deleted bool
+
+	// Versions counts the versions of the object, delete markers included. More than one means it was overwritten or
+	// deleted since it was stored.
+	Versions int
+}
+
+// ObjectLocker is implemented by storage backends that can protect stored objects from being deleted or overwritten
+// until a retention date, such as S3 buckets with Object Lock enabled.
+type ObjectLocker interface {
+	// ObjectLockEnabled reports whether Object Lock is enabled for the stored objects
+	ObjectLockEnabled(ctx context.Context) (bool, error)
+
+	// ObjectLocks returns the Object Lock state of the objects at key, relative to the configured prefix, and below it
+	ObjectLocks(ctx context.Context, key string) ([]ObjectLock, error)
+}
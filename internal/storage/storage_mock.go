@@ -2,10 +2,14 @@ package storage
 
 import (
 	"context"
+	"io"
 
+	"github.com/hibare/arclift/internal/walk"
 	"github.com/stretchr/testify/mock"
 )
 
+var _ StorageIface = (*MockStorageIface)(nil)
+
 // MockStorageIface is a mock of StorageIface interface.
 type MockStorageIface struct {
 	mock.Mock
@@ -23,12 +27,24 @@ func (_m *MockStorageIface) Name() string {
 	return _mockArgs.String(0)
 }
 
-// Upload provides a mock function with given fields.
-func (_m *MockStorageIface) Upload(_ context.Context, localPath string) (string, error) {
+// UploadFile provides a mock function with given fields.
+func (_m *MockStorageIface) UploadFile(_ context.Context, localPath string) (string, error) {
 	_mockArgs := _m.Called(localPath)
 	return _mockArgs.String(0), _mockArgs.Error(1)
 }
 
+// UploadDir provides a mock function with given fields.
+func (_m *MockStorageIface) UploadDir(_ context.Context, localPath string, opts walk.Options) (UploadDirResponse, error) {
+	_mockArgs := _m.Called(localPath, opts)
+	return _mockArgs.Get(0).(UploadDirResponse), _mockArgs.Error(1) //nolint:errcheck // reason: type assertion on mock, error not possible/needed
+}
+
+// SyncDir provides a mock function with given fields.
+func (_m *MockStorageIface) SyncDir(_ context.Context, localPath, key string, opts walk.Options, unchanged Unchanged) (UploadDirResponse, error) {
+	_mockArgs := _m.Called(localPath, key, opts, unchanged)
+	return _mockArgs.Get(0).(UploadDirResponse), _mockArgs.Error(1) //nolint:errcheck // reason: type assertion on mock, error not possible/needed
+}
+
 // List provides a mock function with given fields.
 func (_m *MockStorageIface) List(_ context.Context) ([]string, error) {
 	_mockArgs := _m.Called()
@@ -38,6 +54,48 @@ func (_m *MockStorageIface) List(_ context.Context) ([]string, error) {
 	return _mockArgs.Get(0).([]string), _mockArgs.Error(1) //nolint:errcheck // reason: type assertion on mock, error not possible/needed
 }
 
+// ListObjects provides a mock function with given fields.
+func (_m *MockStorageIface) ListObjects(_ context.Context) ([]ObjectInfo, error) {
+	_mockArgs := _m.Called()
+	if _mockArgs.Get(0) == nil {
+		return nil, _mockArgs.Error(1)
+	}
+	return _mockArgs.Get(0).([]ObjectInfo), _mockArgs.Error(1) //nolint:errcheck // reason: type assertion on mock, error not possible/needed
+}
+
+// ListDetailed provides a mock function with given fields.
+func (_m *MockStorageIface) ListDetailed(_ context.Context) ([]BackupDetail, error) {
+	_mockArgs := _m.Called()
+	if _mockArgs.Get(0) == nil {
+		return nil, _mockArgs.Error(1)
+	}
+	return _mockArgs.Get(0).([]BackupDetail), _mockArgs.Error(1) //nolint:errcheck // reason: type assertion on mock, error not possible/needed
+}
+
+// Download provides a mock function with given fields.
+func (_m *MockStorageIface) Download(_ context.Context, key, localPath string) error {
+	_mockArgs := _m.Called(key, localPath)
+	return _mockArgs.Error(0)
+}
+
+// Copy provides a mock function with given fields.
+func (_m *MockStorageIface) Copy(_ context.Context, key string, dst StorageIface) error {
+	_mockArgs := _m.Called(key, dst)
+	return _mockArgs.Error(0)
+}
+
+// CopyTo provides a mock function with given fields.
+func (_m *MockStorageIface) CopyTo(_ context.Context, key string, dst StorageIface, dstKey string) error {
+	_mockArgs := _m.Called(key, dst, dstKey)
+	return _mockArgs.Error(0)
+}
+
+// Put provides a mock function with given fields.
+func (_m *MockStorageIface) Put(_ context.Context, key string, body io.Reader) error {
+	_mockArgs := _m.Called(key, body)
+	return _mockArgs.Error(0)
+}
+
 // Delete provides a mock function with given fields.
 func (_m *MockStorageIface) Delete(_ context.Context, key string) error {
 	_mockArgs := _m.Called(key)
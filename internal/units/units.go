@@ -0,0 +1,73 @@
+// Package units provides helpers for parsing and formatting human readable quantities.
+package units
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	kilo = 1000
+	kibi = 1024
+)
+
+// ErrInvalidSize is returned when a size string cannot be parsed.
+var ErrInvalidSize = errors.New("invalid size")
+
+var sizeMultipliers = map[string]int64{
+	"":    1,
+	"B":   1,
+	"KB":  kilo,
+	"MB":  kilo * kilo,
+	"GB":  kilo * kilo * kilo,
+	"TB":  kilo * kilo * kilo * kilo,
+	"KIB": kibi,
+	"MIB": kibi * kibi,
+	"GIB": kibi * kibi * kibi,
+	"TIB": kibi * kibi * kibi * kibi,
+}
+
+// ParseBytes parses sizes such as "512", "10MB" or "1.5GiB" into bytes.
+func ParseBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("%w: empty value", ErrInvalidSize)
+	}
+
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, suffix := s, ""
+	if i >= 0 {
+		number, suffix = s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+	}
+
+	multiplier, ok := sizeMultipliers[suffix]
+	if !ok {
+		return 0, fmt.Errorf("%w: unknown unit %q", ErrInvalidSize, suffix)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSize, s)
+	}
+
+	return int64(value * float64(multiplier)), nil
+}
+
+// FormatBytes formats bytes using binary units, e.g. "1.5 GiB".
+func FormatBytes(b int64) string {
+	if b < kibi {
+		return fmt.Sprintf("%d B", b)
+	}
+
+	div, exp := int64(kibi), 0
+	for n := b / kibi; n >= kibi; n /= kibi {
+		div *= kibi
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
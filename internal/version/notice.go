@@ -0,0 +1,45 @@
+package version
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hibare/arclift/internal/config"
+)
+
+// noticeMu serializes UpdateNotice, so concurrent notifications add the notice once.
+var noticeMu sync.Mutex
+
+// UpdateNotice returns the update notification to add to a notification sent now, or "" when no update is available
+// or the notice of the same release was added less than update-check.notify-every ago. The last notice is recorded
+// in the state dir, so the interval spans one-shot runs; a newer release is announced right away.
+func UpdateNotice(cfg *config.Config) string {
+	if !V.IsUpdateAvailable() {
+		return ""
+	}
+	notice := V.GetUpdateNotification()
+	every := cfg.UpdateCheck.NotifyEvery
+	if every == 0 {
+		return notice
+	}
+
+	noticeMu.Lock()
+	defer noticeMu.Unlock()
+
+	path := cfg.State.UpdateNoticePath()
+	latest := V.GetLatestVersion()
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < every {
+		if last, err := os.ReadFile(path); err == nil && strings.TrimSpace(string(last)) == latest {
+			return ""
+		}
+	}
+
+	// Failing to record the notice only repeats it sooner.
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err == nil {
+		_ = os.WriteFile(path, []byte(latest+"\n"), 0o600)
+	}
+	return notice
+}
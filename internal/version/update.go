@@ -0,0 +1,386 @@
+package version
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/hibare/arclift/internal/constants"
+)
+
+// Release assets, as published by GoReleaser.
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = checksumsAsset + ".sig"
+)
+
+// maxChecksumsSize bounds the checksums and signature downloads.
+const maxChecksumsSize = 1 << 20
+
+// releaseKey is the armored GPG public key the release checksums are signed with. It is exported into the source tree
+// by the release build, and empty in development builds.
+//
+//go:embed release-key.asc
+var releaseKey []byte
+
+var (
+	// ErrNoReleaseAsset is returned when a release has no archive for the platform of the running binary.
+	ErrNoReleaseAsset = errors.New("release has no archive for this platform")
+
+	// ErrChecksumMismatch is returned when a downloaded archive does not match the published checksum.
+	ErrChecksumMismatch = errors.New("release archive checksum mismatch")
+
+	// ErrSignatureMissing is returned when the release checksums are not signed.
+	ErrSignatureMissing = errors.New("release checksums are not signed")
+
+	// ErrNoReleaseKey is returned when the binary embeds no release key and no public key is given.
+	ErrNoReleaseKey = errors.New("this build has no release signing key, give the public key to verify releases with")
+
+	// ErrUnsupportedPlatform is returned on the platforms no release archive is published for.
+	ErrUnsupportedPlatform = errors.New("self-update is not supported on this platform")
+)
+
+// UpdateOptions are the options of SelfUpdate.
+type UpdateOptions struct {
+	// Version is the tag of the release to install; empty installs the latest release.
+	Version string
+
+	// Prerelease makes the latest release the newest one, pre-releases included.
+	Prerelease bool
+
+	// PublicKey is the path of the armored GPG public key the release checksums must be signed with, instead of the
+	// release key embedded in the binary.
+	PublicKey string
+
+	// Force reinstalls the release even when it is the running version.
+	Force bool
+
+	// Client downloads the release.
+	Client *http.Client
+}
+
+// UpdateResult is the outcome of SelfUpdate.
+type UpdateResult struct {
+	Previous string
+	Version  string
+	Path     string
+	Updated  bool
+}
+
+// SelfUpdate downloads the release of opts for the current platform, verifies it against the signed checksums
+// published with it, and replaces the running binary with it. The binary is replaced by a rename, so a failed update
+// leaves the running binary untouched; running processes keep executing the previous binary until restarted.
+func SelfUpdate(ctx context.Context, opts UpdateOptions) (UpdateResult, error) {
+	result := UpdateResult{Previous: CurrentVersion}
+
+	// Releases are only built for these, as tar.gz archives of a binary replaced by a rename.
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		return result, fmt.Errorf("%w: %s, install the release manually", ErrUnsupportedPlatform, runtime.GOOS)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return result, err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return result, err
+	}
+	result.Path = exe
+
+	r, err := findRelease(ctx, opts)
+	if err != nil {
+		return result, err
+	}
+	result.Version = r.TagName
+	if !opts.Force && strings.TrimPrefix(r.TagName, "v") == strings.TrimPrefix(CurrentVersion, "v") {
+		return result, nil
+	}
+
+	archive, ok := r.asset(func(name string) bool {
+		return strings.HasSuffix(strings.ToLower(name), "_"+platform()+".tar.gz")
+	})
+	if !ok {
+		return result, fmt.Errorf("%w: %s %s", ErrNoReleaseAsset, r.TagName, platform())
+	}
+	sum, err := releaseChecksum(ctx, opts, r, archive.Name)
+	if err != nil {
+		return result, err
+	}
+
+	slog.InfoContext(ctx, "Downloading release", "version", r.TagName, "archive", archive.Name)
+	binary, err := downloadBinary(ctx, opts.Client, archive, sum, filepath.Dir(exe))
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		_ = os.Remove(binary)
+	}()
+
+	if err := replaceBinary(ctx, binary, exe); err != nil {
+		return result, err
+	}
+	result.Updated = true
+	return result, nil
+}
+
+// findRelease returns the release of opts.
+func findRelease(ctx context.Context, opts UpdateOptions) (release, error) {
+	if opts.Version == "" {
+		return latestRelease(ctx, opts.Client, opts.Prerelease)
+	}
+
+	tag := opts.Version
+	if !strings.HasPrefix(tag, "v") {
+		tag = "v" + tag
+	}
+	var r release
+	err := getJSON(ctx, opts.Client, fmt.Sprintf(releaseEndpoint, constants.GithubOwner, constants.ProgramPrettyIdentifier, "tags/"+tag), &r)
+	return r, err
+}
+
+// asset returns the first asset of r whose name matches.
+func (r *release) asset(match func(name string) bool) (asset, bool) {
+	for _, a := range r.Assets {
+		if match(a.Name) {
+			return a, true
+		}
+	}
+	return asset{}, false
+}
+
+// platform returns the platform suffix of the release archives of the running binary, such as linux_arm64 or
+// linux_armv7. macOS releases ship a universal binary.
+func platform() string {
+	if runtime.GOOS == "darwin" {
+		return "darwin_all"
+	}
+	arch := runtime.GOARCH
+	if arch == "arm" {
+		goarm := "6"
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range info.Settings {
+				if s.Key == "GOARM" && s.Value != "" {
+					goarm, _, _ = strings.Cut(s.Value, ",")
+				}
+			}
+		}
+		arch = "armv" + goarm
+	}
+	return runtime.GOOS + "_" + arch
+}
+
+// releaseChecksum returns the SHA-256 checksum of the asset name of r, from the checksums of r, which must carry a
+// valid signature by the release key.
+func releaseChecksum(ctx context.Context, opts UpdateOptions, r release, name string) (string, error) {
+	checksums, ok := r.asset(func(n string) bool { return n == checksumsAsset })
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s", r.TagName, checksumsAsset)
+	}
+	data, err := download(ctx, opts.Client, checksums.URL)
+	if err != nil {
+		return "", err
+	}
+
+	if err := verifySignature(ctx, opts, r, data); err != nil {
+		return "", err
+	}
+
+	for line := range strings.Lines(string(data)) {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s of release %s has no checksum of %s", checksumsAsset, r.TagName, name)
+}
+
+// verifySignature checks the detached signature of the checksums of r, armored or binary, with the release key.
+func verifySignature(ctx context.Context, opts UpdateOptions, r release, checksums []byte) error {
+	keyring, err := releaseKeyring(opts)
+	if err != nil {
+		return err
+	}
+
+	sigAsset, ok := r.asset(func(n string) bool { return n == signatureAsset })
+	if !ok {
+		return fmt.Errorf("%w: release %s has no %s", ErrSignatureMissing, r.TagName, signatureAsset)
+	}
+	sig, err := download(ctx, opts.Client, sigAsset.URL)
+	if err != nil {
+		return err
+	}
+
+	check := openpgp.CheckDetachedSignature
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN")) {
+		check = openpgp.CheckArmoredDetachedSignature
+	}
+	if _, err := check(keyring, bytes.NewReader(checksums), bytes.NewReader(sig), nil); err != nil {
+		return fmt.Errorf("invalid signature of release %s: %w", r.TagName, err)
+	}
+	return nil
+}
+
+// releaseKeyring returns the public key of opts, or the embedded release key without one.
+func releaseKeyring(opts UpdateOptions) (openpgp.EntityList, error) {
+	if opts.PublicKey == "" {
+		if len(bytes.TrimSpace(releaseKey)) == 0 {
+			return nil, ErrNoReleaseKey
+		}
+		keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(releaseKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid embedded release key: %w", err)
+		}
+		return keyring, nil
+	}
+
+	f, err := os.Open(opts.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	keyring, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %s: %w", opts.PublicKey, err)
+	}
+	return keyring, nil
+}
+
+// download returns the body of url, of at most maxChecksumsSize bytes.
+func download(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	body, err := get(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+	return io.ReadAll(io.LimitReader(body, maxChecksumsSize))
+}
+
+// get returns the body of a successful GET of url.
+func get(ctx context.Context, client *http.Client, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code downloading %s: %d", url, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// downloadBinary downloads the archive a, checks it against sum, and extracts the arclift binary into a temporary
+// file of dir, so it can be renamed over the running binary. The caller removes the returned file.
+func downloadBinary(ctx context.Context, client *http.Client, a asset, sum, dir string) (string, error) {
+	tmp, err := os.CreateTemp("", constants.ProgramIdentifier+"-release-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	body, err := get(ctx, client, a.URL)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), body)
+	_ = body.Close()
+	if err != nil {
+		return "", err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return "", fmt.Errorf("%w: %s is %s, expected %s", ErrChecksumMismatch, a.Name, got, sum)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return extractBinary(tmp, dir)
+}
+
+// extractBinary writes the arclift binary of the gzipped tar archive r to a temporary executable file of dir.
+func extractBinary(r io.Reader, dir string) (string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = gz.Close()
+	}()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("release archive has no %s binary", constants.ProgramIdentifier)
+		}
+		if err != nil {
+			return "", err
+		}
+		if hdr.Typeflag != tar.TypeReg || filepath.Base(hdr.Name) != constants.ProgramIdentifier {
+			continue
+		}
+
+		f, err := os.CreateTemp(dir, "."+constants.ProgramIdentifier+"-update-*")
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(f, tr); err != nil { //nolint:gosec // the archive was verified against its checksum
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+			return "", err
+		}
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			_ = os.Remove(f.Name())
+			return "", err
+		}
+		return f.Name(), nil
+	}
+}
+
+// replaceBinary gives binary the mode of exe, checks that it runs, and renames it over exe.
+func replaceBinary(ctx context.Context, binary, exe string) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(binary, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if out, err := exec.CommandContext(ctx, binary, "--version").CombinedOutput(); err != nil {
+		return fmt.Errorf("downloaded binary does not run: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return os.Rename(binary, exe)
+}
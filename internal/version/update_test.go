@@ -0,0 +1,101 @@
+package version
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// armoredKey returns the armored public key of e.
+func armoredKey(t *testing.T, e *openpgp.Entity) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, e.Serialize(w))
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// writePublicKey writes the armored public key of e to a file and returns its path.
+func writePublicKey(t *testing.T, e *openpgp.Entity) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "key.asc")
+	require.NoError(t, os.WriteFile(path, armoredKey(t, e), 0o600))
+	return path
+}
+
+func TestReleaseChecksum_Signature(t *testing.T) {
+	const checksums = "0123abcd  arclift_1.0.0_linux_amd64.tar.gz\n"
+
+	signer, err := openpgp.NewEntity("release", "", "release@example.com", nil)
+	require.NoError(t, err)
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	require.NoError(t, err)
+
+	var sig bytes.Buffer
+	require.NoError(t, openpgp.ArmoredDetachSign(&sig, signer, bytes.NewReader([]byte(checksums)), nil))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/" + checksumsAsset:
+			_, _ = w.Write([]byte(checksums))
+		case "/" + signatureAsset:
+			_, _ = w.Write(sig.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	signed := release{TagName: "v1.0.0", Assets: []asset{
+		{Name: checksumsAsset, URL: srv.URL + "/" + checksumsAsset},
+		{Name: signatureAsset, URL: srv.URL + "/" + signatureAsset},
+	}}
+	unsigned := release{TagName: "v1.0.0", Assets: signed.Assets[:1]}
+
+	tests := []struct {
+		name        string
+		release     release
+		embedded    []byte
+		publicKey   string
+		wantErr     error
+		wantInvalid bool
+	}{
+		{name: "signed", release: signed, embedded: armoredKey(t, signer)},
+		{name: "public key", release: signed, embedded: armoredKey(t, other), publicKey: writePublicKey(t, signer)},
+		{name: "unsigned", release: unsigned, embedded: armoredKey(t, signer), wantErr: ErrSignatureMissing},
+		{name: "signed by another key", release: signed, publicKey: writePublicKey(t, other), wantInvalid: true},
+		{name: "no release key", release: signed, wantErr: ErrNoReleaseKey},
+	}
+
+	originalKey := releaseKey
+	t.Cleanup(func() { releaseKey = originalKey })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			releaseKey = tt.embedded
+			opts := UpdateOptions{PublicKey: tt.publicKey, Client: srv.Client()}
+			sum, err := releaseChecksum(t.Context(), opts, tt.release, "arclift_1.0.0_linux_amd64.tar.gz")
+			switch {
+			case tt.wantErr != nil:
+				require.ErrorIs(t, err, tt.wantErr)
+			case tt.wantInvalid:
+				require.ErrorContains(t, err, "invalid signature")
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, "0123abcd", sum)
+			}
+		})
+	}
+}
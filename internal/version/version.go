@@ -1,11 +1,222 @@
 package version
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
 	"github.com/hibare/GoCommon/v2/pkg/version"
+	"github.com/hibare/arclift/internal/config"
 	"github.com/hibare/arclift/internal/constants"
 )
 
 var (
 	CurrentVersion = "0.0.0"
-	V              = version.NewVersion(constants.GithubOwner, constants.ProgramPrettyIdentifier, CurrentVersion, version.Options{})
+	V              = &Checker{v: version.NewVersion(constants.GithubOwner, constants.ProgramPrettyIdentifier, CurrentVersion, version.Options{})}
+)
+
+const (
+	// releasesEndpoint lists the releases of arclift, newest first, pre-releases included.
+	releasesEndpoint = "https://api.github.com/repos/%s/%s/releases?per_page=20"
+
+	// releaseEndpoint returns one release of arclift: latest, the latest stable release, or tags/<tag>.
+	releaseEndpoint = "https://api.github.com/repos/%s/%s/releases/%s"
 )
+
+// Checker checks for updates on the configured release channel. It stays usable while Configure switches channels.
+type Checker struct {
+	mu sync.RWMutex
+	v  version.VersionIface
+}
+
+// current returns the version checking the configured channel.
+func (c *Checker) current() version.VersionIface {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.v
+}
+
+// GetUpdateNotification returns the notification of the available update, or "" when there is none.
+func (c *Checker) GetUpdateNotification() string {
+	return c.current().GetUpdateNotification()
+}
+
+// FetchLatestVersion fetches the latest release of the channel.
+func (c *Checker) FetchLatestVersion() error {
+	return c.current().FetchLatestVersion()
+}
+
+// CheckUpdate fetches the latest release of the channel and compares it with the current version.
+func (c *Checker) CheckUpdate() error {
+	return c.current().CheckUpdate()
+}
+
+// IsUpdateAvailable reports whether the latest release differs from the current version.
+func (c *Checker) IsUpdateAvailable() bool {
+	return c.current().IsUpdateAvailable()
+}
+
+// GetLatestVersion returns the latest release of the channel, once fetched.
+func (c *Checker) GetLatestVersion() string {
+	return c.current().GetLatestVersion()
+}
+
+// GetCurrentVersion returns the version of this build.
+func (c *Checker) GetCurrentVersion() string {
+	return c.current().GetCurrentVersion()
+}
+
+// Configure makes V check the release channel of cfg, through its proxy. Switching channels forgets the update
+// found on the previous one.
+func Configure(cfg *config.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:errcheck // the default transport is an *http.Transport
+	transport.Proxy = cfg.Proxy.Func()
+	client := &http.Client{Transport: transport, Timeout: cfg.Notifiers.Timeout}
+
+	var v version.VersionIface
+	if cfg.UpdateCheck.Channel == config.UpdateChannelPrerelease {
+		v = &prerelease{current: CurrentVersion, client: client}
+	} else {
+		v = version.NewVersion(constants.GithubOwner, constants.ProgramPrettyIdentifier, CurrentVersion, version.Options{HTTPClient: client})
+	}
+
+	V.mu.Lock()
+	defer V.mu.Unlock()
+	V.v = v
+}
+
+// Check checks for an update on the channel V is configured for, unless update checks are disabled in cfg, and logs
+// the update when one is available.
+func Check(ctx context.Context, cfg *config.Config) error {
+	if !cfg.UpdateCheck.Enabled {
+		return nil
+	}
+	if err := V.CheckUpdate(); err != nil {
+		return err
+	}
+	if V.IsUpdateAvailable() {
+		slog.InfoContext(ctx, V.GetUpdateNotification())
+	}
+	return nil
+}
+
+// prerelease checks for updates among every release, pre-releases included, as the GoCommon version only considers
+// the latest stable release.
+type prerelease struct {
+	mu              sync.RWMutex
+	current         string
+	latest          string
+	updateAvailable bool
+	client          *http.Client
+}
+
+// release is a release returned by the GitHub releases API.
+type release struct {
+	TagName string  `json:"tag_name"`
+	Draft   bool    `json:"draft"`
+	Assets  []asset `json:"assets"`
+}
+
+// asset is a file attached to a release.
+type asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// GetUpdateNotification returns the notification of the available update, or "" when there is none.
+func (p *prerelease) GetUpdateNotification() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.updateAvailable && p.latest != "" {
+		return fmt.Sprintf(version.UpdateNotificationMessage, p.latest)
+	}
+	return ""
+}
+
+// FetchLatestVersion fetches the tag of the newest release, which may be a pre-release.
+func (p *prerelease) FetchLatestVersion() error {
+	r, err := latestRelease(context.Background(), p.client, true)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latest = r.TagName
+	return nil
+}
+
+// latestRelease returns the latest stable release or, with prerelease, the newest release.
+func latestRelease(ctx context.Context, client *http.Client, prerelease bool) (release, error) {
+	if !prerelease {
+		var r release
+		err := getJSON(ctx, client, fmt.Sprintf(releaseEndpoint, constants.GithubOwner, constants.ProgramPrettyIdentifier, "latest"), &r)
+		return r, err
+	}
+
+	var releases []release
+	if err := getJSON(ctx, client, fmt.Sprintf(releasesEndpoint, constants.GithubOwner, constants.ProgramPrettyIdentifier), &releases); err != nil {
+		return release{}, err
+	}
+	for _, r := range releases {
+		if !r.Draft && r.TagName != "" {
+			return r, nil
+		}
+	}
+	return release{}, version.ErrNoTagNameInRelease
+}
+
+// getJSON decodes the response of the GitHub API to a GET of url into v.
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code from GitHub releases endpoint: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// CheckUpdate fetches the newest release and compares it with the current version.
+func (p *prerelease) CheckUpdate() error {
+	if err := p.FetchLatestVersion(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.updateAvailable = strings.TrimPrefix(p.current, "v") != strings.TrimPrefix(p.latest, "v")
+	return nil
+}
+
+// IsUpdateAvailable reports whether the newest release differs from the current version.
+func (p *prerelease) IsUpdateAvailable() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.updateAvailable
+}
+
+// GetLatestVersion returns the tag of the newest release, once fetched.
+func (p *prerelease) GetLatestVersion() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.latest
+}
+
+// GetCurrentVersion returns the version of this build.
+func (p *prerelease) GetCurrentVersion() string {
+	return p.current
+}
@@ -0,0 +1,338 @@
+// Package walk walks backup directories, skipping and classifying the entries that cannot be backed up.
+package walk
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Categories of skipped entries.
+const (
+	CategorySocket     = "socket"
+	CategoryPipe       = "pipe"
+	CategoryDevice     = "device"
+	CategorySymlink    = "symlink"
+	CategoryIrregular  = "irregular"
+	CategoryUnreadable = "unreadable"
+	CategoryMountPoint = "mount point"
+	CategoryTooLarge   = "too large"
+	CategoryModTime    = "modified time"
+)
+
+// Report counts the entries skipped during a walk, per category.
+type Report map[string]int
+
+// Total returns the number of skipped entries.
+func (r Report) Total() int {
+	total := 0
+	for _, n := range r {
+		total += n
+	}
+	return total
+}
+
+// String formats the report as "pipe: 1, socket: 2".
+func (r Report) String() string {
+	categories := make([]string, 0, len(r))
+	for c := range r {
+		categories = append(categories, c)
+	}
+	sort.Strings(categories)
+
+	parts := make([]string, 0, len(categories))
+	for _, c := range categories {
+		parts = append(parts, c+": "+strconv.Itoa(r[c]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// skip counts a skipped entry. Entries skipped by type are only logged at debug level, as their counts are reported
+// with the backup.
+func (r Report) skip(ctx context.Context, category, path string, err error) {
+	r[category]++
+	if err != nil {
+		slog.WarnContext(ctx, "Skipping entry", "category", category, "path", path, "error", err)
+		return
+	}
+	slog.DebugContext(ctx, "Skipping entry", "category", category, "path", path)
+}
+
+// Func is called for every directory and every readable regular file below root. rel is the slash separated path
+// relative to root. f is nil for directories; for files it is open for reading and closed by Dir once Func returns.
+type Func func(path, rel string, d fs.DirEntry, f *os.File) error
+
+// ExtraFile is a file outside of root that is walked as if it was stored at Name.
+type ExtraFile struct {
+	Name string
+	Path string
+}
+
+// Options narrows down what is walked.
+type Options struct {
+	// Include lists the paths, relative to root, that are walked. Empty walks everything.
+	Include []string
+
+	// Exclude lists glob patterns of entries that are not walked. Patterns containing a "/" match the path relative
+	// to root, others match the base name.
+	Exclude []string
+
+	// Extra lists files that are walked after root.
+	Extra []ExtraFile
+
+	// Visit, when set, is called with the info of every directory and file below root before it is passed to the
+	// walk func, such as to record their metadata. The info of a symlinked file is that of its target.
+	Visit func(path, rel string, info fs.FileInfo)
+
+	// RecordSpecial passes pipes and devices to Visit, and not to the walk func, instead of skipping them.
+	RecordSpecial bool
+
+	// OneFileSystem skips the directories on another filesystem than root, such as /proc or network mounts.
+	OneFileSystem bool
+
+	// MaxFileSize, when positive, skips the files larger than it, in bytes. TooLarge, when set, is called with each
+	// of them.
+	MaxFileSize int64
+	TooLarge    func(path string, size int64)
+
+	// ModifiedAfter and ModifiedBefore, when set, skip the files modified before and not before them.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+
+	// MaxDepth, when positive, is the number of directory levels below root that are walked. The directories at
+	// that depth are passed to the walk func, but not their entries.
+	MaxDepth int
+}
+
+// visit calls the Visit func of o, if any.
+func (o Options) visit(path, rel string, stat func() (fs.FileInfo, error)) {
+	if o.Visit == nil {
+		return
+	}
+	if info, err := stat(); err == nil {
+		o.Visit(path, rel, info)
+	}
+}
+
+// filter returns the category the file at path is skipped as by the size and modification time filters of o, or ""
+// when it is walked. Files exceeding the maximum file size are passed to TooLarge.
+func (o Options) filter(path string) string {
+	if o.MaxFileSize <= 0 && o.ModifiedAfter.IsZero() && o.ModifiedBefore.IsZero() {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		// Reported as unreadable when it is opened.
+		return ""
+	}
+
+	switch modTime := info.ModTime(); {
+	case o.MaxFileSize > 0 && info.Size() > o.MaxFileSize:
+		if o.TooLarge != nil {
+			o.TooLarge(path, info.Size())
+		}
+		return CategoryTooLarge
+	case !o.ModifiedAfter.IsZero() && modTime.Before(o.ModifiedAfter), !o.ModifiedBefore.IsZero() && !modTime.Before(o.ModifiedBefore):
+		return CategoryModTime
+	}
+	return ""
+}
+
+// included reports whether rel is walked, and for directories whether it is only walked to reach an included path.
+func (o Options) included(rel string, isDir bool) bool {
+	if rel == "." {
+		return true
+	}
+
+	for _, pattern := range o.Exclude {
+		target := rel
+		if !strings.Contains(pattern, "/") {
+			target = filepath.Base(rel)
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return false
+		}
+	}
+
+	if len(o.Include) == 0 {
+		return true
+	}
+	for _, inc := range o.Include {
+		inc = strings.Trim(filepath.ToSlash(inc), "/")
+		if rel == inc || strings.HasPrefix(rel, inc+"/") || (isDir && strings.HasPrefix(inc, rel+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// classify returns the category of a non-regular, non-directory mode.
+func classify(mode fs.FileMode) string {
+	switch {
+	case mode&fs.ModeSocket != 0:
+		return CategorySocket
+	case mode&fs.ModeNamedPipe != 0:
+		return CategoryPipe
+	case mode&(fs.ModeDevice|fs.ModeCharDevice) != 0:
+		return CategoryDevice
+	default:
+		return CategoryIrregular
+	}
+}
+
+// Dir walks root, calling fn for directories and regular files. Symlinks to regular files are followed. A root that
+// is a file is walked as the single file named after its base name.
+// Sockets, pipes, devices and unreadable entries are never opened: they are skipped and counted in the returned report.
+// An error is only returned when root itself cannot be walked or fn fails.
+func Dir(ctx context.Context, root string, opts Options, fn Func) (Report, error) {
+	report := Report{}
+	rootDev, checkDev := rootDevice(root, opts)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		// Stop between entries once cancelled, so an interrupted backup does not keep walking.
+		if cErr := ctx.Err(); cErr != nil {
+			return cErr
+		}
+
+		rel, rErr := filepath.Rel(root, path)
+		if rErr != nil {
+			return rErr
+		}
+		rel = filepath.ToSlash(rel)
+		if path == root && d != nil && !d.IsDir() {
+			rel = filepath.Base(root)
+		}
+
+		if d != nil && !opts.included(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if err != nil {
+			if path == root {
+				return err
+			}
+			report.skip(ctx, CategoryUnreadable, path, err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if checkDev && path != root && !sameDevice(d, rootDev) {
+				report.skip(ctx, CategoryMountPoint, path, nil)
+				return filepath.SkipDir
+			}
+			opts.visit(path, rel, d.Info)
+			if fErr := fn(path, rel, d, nil); fErr != nil {
+				return fErr
+			}
+			if opts.MaxDepth > 0 && rel != "." && strings.Count(rel, "/")+1 >= opts.MaxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		mode := d.Type()
+		if mode&fs.ModeSymlink != 0 {
+			info, sErr := os.Stat(path)
+			if sErr != nil {
+				report.skip(ctx, CategoryUnreadable, path, sErr)
+				return nil
+			}
+			if info.IsDir() {
+				report.skip(ctx, CategorySymlink, path, nil)
+				return nil
+			}
+			mode = info.Mode().Type()
+		}
+
+		if !mode.IsRegular() {
+			category := classify(mode)
+			if opts.RecordSpecial && (category == CategoryPipe || category == CategoryDevice) {
+				opts.visit(path, rel, func() (fs.FileInfo, error) { return os.Stat(path) })
+				return nil
+			}
+			report.skip(ctx, category, path, nil)
+			return nil
+		}
+
+		if category := opts.filter(path); category != "" {
+			report.skip(ctx, category, path, nil)
+			return nil
+		}
+
+		f, oErr := os.Open(path)
+		if oErr != nil {
+			report.skip(ctx, CategoryUnreadable, path, oErr)
+			return nil
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+
+		opts.visit(path, rel, f.Stat)
+		return fn(path, rel, d, f)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for _, extra := range opts.Extra {
+		if err := walkExtra(ctx, extra, report, fn); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// rootDevice returns the device of root when the walk is restricted to its filesystem. ok is false when it is not,
+// or when devices are not known on this platform.
+func rootDevice(root string, opts Options) (uint64, bool) {
+	if !opts.OneFileSystem {
+		return 0, false
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return 0, false
+	}
+	return device(info)
+}
+
+// sameDevice reports whether the directory d is on dev. Directories whose device is not known are walked.
+func sameDevice(d fs.DirEntry, dev uint64) bool {
+	info, err := d.Info()
+	if err != nil {
+		return true
+	}
+	dDev, ok := device(info)
+	return !ok || dDev == dev
+}
+
+func walkExtra(ctx context.Context, extra ExtraFile, report Report, fn Func) error {
+	f, err := os.Open(extra.Path)
+	if err != nil {
+		report.skip(ctx, CategoryUnreadable, extra.Path, err)
+		return nil
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	info, err := f.Stat()
+	if err != nil {
+		report.skip(ctx, CategoryUnreadable, extra.Path, err)
+		return nil
+	}
+
+	return fn(extra.Path, extra.Name, fs.FileInfoToDirEntry(info), f)
+}
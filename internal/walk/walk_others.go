@@ -0,0 +1,8 @@
+//go:build !unix
+
+package walk
+
+import "io/fs"
+
+// Devices are not known on this platform, so walks are not restricted to a filesystem.
+func device(fs.FileInfo) (uint64, bool) { return 0, false }
@@ -0,0 +1,150 @@
+// Package watch reports changes to the files of the backed up paths, so they can be backed up soon after they change.
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher watches paths, and every directory under them, for changes.
+type Watcher struct {
+	paths    []string
+	interval time.Duration
+	fsw      *fsnotify.Watcher
+}
+
+// New creates a watcher of paths reporting changes at most once per interval.
+func New(ctx context.Context, paths []string, interval time.Duration) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{interval: interval, fsw: fsw}
+	for _, path := range paths {
+		w.paths = append(w.paths, filepath.Clean(path))
+		w.add(ctx, path)
+	}
+	return w, nil
+}
+
+// add watches path and, when it is a directory, every directory under it. Directories that cannot be watched are
+// logged and skipped.
+func (w *Watcher) add(ctx context.Context, path string) {
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to watch path", "path", p, "error", err)
+			return nil //nolint:nilerr // unreadable entries are skipped
+		}
+		if p != path && !d.IsDir() {
+			return nil
+		}
+		if aErr := w.fsw.Add(p); aErr != nil {
+			slog.WarnContext(ctx, "Failed to watch path", "path", p, "error", aErr)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to watch path", "path", path, "error", err)
+	}
+}
+
+// root returns the watched path name is under, or "" when there is none.
+func (w *Watcher) root(name string) string {
+	var root string
+	for _, path := range w.paths {
+		if (name == path || strings.HasPrefix(name, path+string(filepath.Separator))) && len(path) > len(root) {
+			root = path
+		}
+	}
+	return root
+}
+
+// Run calls fn with the watched paths whose files changed until ctx is cancelled, then waits for the running call.
+// fn is called at most once per interval, the first time one interval after the first change, and never while a
+// previous call is running; changes made meanwhile are reported by the next call.
+func (w *Watcher) Run(ctx context.Context, fn func(changed []string)) {
+	defer func() {
+		_ = w.fsw.Close()
+	}()
+
+	changed := map[string]bool{}
+	done := make(chan struct{})
+	running := false
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			if running {
+				<-done
+			}
+			return
+
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			root := w.root(event.Name)
+			if root == "" {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					w.add(ctx, event.Name)
+				}
+			}
+			changed[root] = true
+			if timer == nil && !running {
+				timer = time.After(w.interval)
+			}
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// Some changes were lost, so every path may have changed.
+				for _, path := range w.paths {
+					changed[path] = true
+				}
+				if timer == nil && !running {
+					timer = time.After(w.interval)
+				}
+			}
+			slog.WarnContext(ctx, "Error watching paths", "error", err)
+
+		case <-timer:
+			timer = nil
+			running = true
+			paths := slices.Sorted(maps.Keys(changed))
+			clear(changed)
+			go func() {
+				fn(paths)
+				done <- struct{}{}
+			}()
+
+		case <-done:
+			running = false
+			if len(changed) > 0 {
+				timer = time.After(w.interval)
+			}
+		}
+	}
+}
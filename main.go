@@ -1,6 +1,11 @@
 package main
 
-import "github.com/hibare/arclift/cmd"
+import (
+	// Embed the time zone database so backup.timezone works on hosts without one, such as Windows and minimal images.
+	_ "time/tzdata"
+
+	"github.com/hibare/arclift/cmd"
+)
 
 func main() {
 	cmd.Execute()